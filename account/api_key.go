@@ -0,0 +1,166 @@
+package account
+
+import (
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/apihub/apihub/errors"
+	. "github.com/apihub/apihub/log"
+	"github.com/apihub/apihub/util"
+)
+
+const API_KEY_LAST_USED_INTERVAL = time.Minute
+
+var httpMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "OPTIONS": true,
+}
+
+// ApiKey is one of the keys an App uses to access the services, in the format `Id.Secret`.
+// Only the hash of the secret is stored, so the Key is only known when the key is created or rotated.
+// Empty Services and Methods mean that the key is allowed to access any service with any method.
+type ApiKey struct {
+	Id         string   `json:"id"`
+	Name       string   `json:"name"`
+	ClientId   string   `json:"client_id"`
+	Services   []string `json:"services,omitempty"`
+	Methods    []string `json:"methods,omitempty"`
+	Key        string   `json:"key,omitempty" bson:"-"`
+	Hash       string   `json:"-"`
+	CreatedAt  string   `json:"created_at"`
+	ExpiresAt  string   `json:"expires_at,omitempty"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+	RevokedAt  string   `json:"revoked_at,omitempty"`
+}
+
+func (k *ApiKey) Create(app App) error {
+	k.ClientId = app.ClientId
+	k.RevokedAt = ""
+	k.LastUsedAt = ""
+
+	if err := k.valid(); err != nil {
+		Logger.Info("Failed to create an api key with invalid data: %+v.", k.Name)
+		return err
+	}
+
+	k.Id = strings.TrimRight(util.GenerateRandomStr(12), "=")
+	k.CreatedAt = time.Now().In(time.UTC).Format(time.RFC3339)
+	k.generateSecret()
+
+	err := k.save()
+	Logger.Info("apiKey.Create: `%s` for app `%s`. Err: %s.", k.Id, k.ClientId, err)
+	return err
+}
+
+// Rotate replaces the secret of the key, so the previous one is no longer accepted.
+func (k *ApiKey) Rotate() error {
+	if k.RevokedAt != "" {
+		return errors.NewValidationError(errors.ErrApiKeyRevoked)
+	}
+
+	k.generateSecret()
+	err := k.save()
+	Logger.Info("apiKey.Rotate: `%s` for app `%s`. Err: %s.", k.Id, k.ClientId, err)
+	return err
+}
+
+func (k *ApiKey) Revoke() error {
+	k.RevokedAt = time.Now().In(time.UTC).Format(time.RFC3339)
+	err := k.save()
+	Logger.Info("apiKey.Revoke: `%s` for app `%s`. Err: %s.", k.Id, k.ClientId, err)
+	return err
+}
+
+func (k ApiKey) Revoked() bool {
+	return k.RevokedAt != ""
+}
+
+func (k ApiKey) Expired() bool {
+	return k.ExpiresAt != "" && expired(k.ExpiresAt)
+}
+
+// Allows reports whether the key is allowed to access the service with the given method.
+func (k ApiKey) Allows(subdomain, method string) bool {
+	return (len(k.Services) == 0 || contains(k.Services, subdomain)) &&
+		(len(k.Methods) == 0 || contains(k.Methods, strings.ToUpper(method)))
+}
+
+func (k *ApiKey) generateSecret() {
+	secret := util.GenerateRandomStr(32)
+	k.Key = k.Id + "." + secret
+	k.Hash = hashSecret(secret)
+}
+
+// save stores the key without the plain secret.
+func (k ApiKey) save() error {
+	k.Key = ""
+	return store.UpsertApiKey(k)
+}
+
+func (k *ApiKey) valid() error {
+	if k.Name == "" || k.ClientId == "" {
+		return errors.NewValidationError(errors.ErrApiKeyInvalidFields)
+	}
+	for i, method := range k.Methods {
+		k.Methods[i] = strings.ToUpper(method)
+		if !httpMethods[k.Methods[i]] {
+			return errors.NewValidationError(errors.ErrApiKeyInvalidFields)
+		}
+	}
+	if k.ExpiresAt != "" {
+		if _, err := time.Parse(time.RFC3339, k.ExpiresAt); err != nil {
+			return errors.NewValidationError(errors.ErrApiKeyInvalidFields)
+		}
+	}
+
+	return nil
+}
+
+// AuthenticateApiKey finds the key which matches the given `Id.Secret`, as long as it is
+// neither revoked nor expired. The time it was last used is updated at most once a minute,
+// without writing the rest of the key, so a key revoked or rotated meanwhile is not restored.
+func AuthenticateApiKey(key string) (*ApiKey, error) {
+	parts := strings.SplitN(key, ".", 2)
+	if len(parts) != 2 {
+		return nil, errors.ErrApiKeyUnauthorized
+	}
+
+	apiKey, err := store.FindApiKeyById(parts[0])
+	if err != nil {
+		return nil, errors.ErrApiKeyUnauthorized
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(parts[1])), []byte(apiKey.Hash)) != 1 ||
+		apiKey.Revoked() || apiKey.Expired() {
+		return nil, errors.ErrApiKeyUnauthorized
+	}
+
+	now := time.Now().In(time.UTC)
+	if lastUsed, err := time.Parse(time.RFC3339, apiKey.LastUsedAt); err != nil || now.Sub(lastUsed) >= API_KEY_LAST_USED_INTERVAL {
+		apiKey.LastUsedAt = now.Format(time.RFC3339)
+		if err := store.UpdateApiKeyLastUsedAt(apiKey); err != nil {
+			Logger.Warn("Failed to update the last use of api key `%s`: %+v.", apiKey.Id, err)
+		}
+	}
+	return &apiKey, nil
+}
+
+func FindApiKeyById(id string) (*ApiKey, error) {
+	apiKey, err := store.FindApiKeyById(id)
+	if err != nil {
+		return nil, err
+	}
+	return &apiKey, nil
+}
+
+func AppApiKeys(app App) ([]ApiKey, error) {
+	return store.AppApiKeys(app)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
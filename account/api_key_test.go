@@ -0,0 +1,128 @@
+package account_test
+
+import (
+	"strings"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestCreateApiKey(c *C) {
+	app.Create(owner, team)
+	defer app.Delete(owner)
+
+	apiKey := account.ApiKey{Name: "production", Services: []string{"apihub"}, Methods: []string{"get"}}
+	err := apiKey.Create(app)
+	c.Assert(err, IsNil)
+
+	c.Assert(apiKey.ClientId, Equals, app.ClientId)
+	c.Assert(apiKey.Methods, DeepEquals, []string{"GET"})
+	c.Assert(strings.HasPrefix(apiKey.Key, apiKey.Id+"."), Equals, true)
+
+	// The plain key is not stored.
+	stored, err := account.FindApiKeyById(apiKey.Id)
+	c.Assert(err, IsNil)
+	c.Assert(stored.Key, Equals, "")
+	c.Assert(stored.Hash, Not(Equals), "")
+	c.Assert(strings.Contains(apiKey.Key, stored.Hash), Equals, false)
+}
+
+func (s *S) TestCreateApiKeyWithInvalidFields(c *C) {
+	for _, apiKey := range []account.ApiKey{
+		account.ApiKey{},
+		account.ApiKey{Name: "production", Methods: []string{"FETCH"}},
+		account.ApiKey{Name: "production", ExpiresAt: "tomorrow"},
+	} {
+		err := apiKey.Create(app)
+		_, ok := err.(errors.ValidationError)
+		c.Assert(ok, Equals, true)
+	}
+}
+
+func (s *S) TestAuthenticateApiKey(c *C) {
+	app.Create(owner, team)
+	defer app.Delete(owner)
+
+	apiKey := account.ApiKey{Name: "production"}
+	apiKey.Create(app)
+
+	authenticated, err := account.AuthenticateApiKey(apiKey.Key)
+	c.Assert(err, IsNil)
+	c.Assert(authenticated.Id, Equals, apiKey.Id)
+	c.Assert(authenticated.LastUsedAt, Not(Equals), "")
+
+	_, err = account.AuthenticateApiKey(apiKey.Id + ".wrong")
+	c.Assert(err, Equals, errors.ErrApiKeyUnauthorized)
+	_, err = account.AuthenticateApiKey("wrong")
+	c.Assert(err, Equals, errors.ErrApiKeyUnauthorized)
+}
+
+func (s *S) TestAuthenticateExpiredApiKey(c *C) {
+	app.Create(owner, team)
+	defer app.Delete(owner)
+
+	apiKey := account.ApiKey{Name: "production", ExpiresAt: "2015-05-16T13:40:44Z"}
+	apiKey.Create(app)
+
+	_, err := account.AuthenticateApiKey(apiKey.Key)
+	c.Assert(err, Equals, errors.ErrApiKeyUnauthorized)
+}
+
+func (s *S) TestRotateApiKey(c *C) {
+	app.Create(owner, team)
+	defer app.Delete(owner)
+
+	apiKey := account.ApiKey{Name: "production"}
+	apiKey.Create(app)
+	previous := apiKey.Key
+
+	err := apiKey.Rotate()
+	c.Assert(err, IsNil)
+	c.Assert(apiKey.Key, Not(Equals), previous)
+
+	_, err = account.AuthenticateApiKey(previous)
+	c.Assert(err, Equals, errors.ErrApiKeyUnauthorized)
+	_, err = account.AuthenticateApiKey(apiKey.Key)
+	c.Assert(err, IsNil)
+}
+
+func (s *S) TestRevokeApiKey(c *C) {
+	app.Create(owner, team)
+	defer app.Delete(owner)
+
+	apiKey := account.ApiKey{Name: "production"}
+	apiKey.Create(app)
+
+	err := apiKey.Revoke()
+	c.Assert(err, IsNil)
+	c.Assert(apiKey.Revoked(), Equals, true)
+
+	_, err = account.AuthenticateApiKey(apiKey.Key)
+	c.Assert(err, Equals, errors.ErrApiKeyUnauthorized)
+
+	err = apiKey.Rotate()
+	_, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestApiKeyAllows(c *C) {
+	apiKey := account.ApiKey{}
+	c.Assert(apiKey.Allows("apihub", "DELETE"), Equals, true)
+
+	apiKey = account.ApiKey{Services: []string{"apihub"}, Methods: []string{"GET"}}
+	c.Assert(apiKey.Allows("apihub", "get"), Equals, true)
+	c.Assert(apiKey.Allows("apihub", "POST"), Equals, false)
+	c.Assert(apiKey.Allows("another", "GET"), Equals, false)
+}
+
+func (s *S) TestDeleteAppDeletesApiKeys(c *C) {
+	app.Create(owner, team)
+	apiKey := account.ApiKey{Name: "production"}
+	apiKey.Create(app)
+
+	app.Delete(owner)
+	_, err := account.FindApiKeyById(apiKey.Id)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
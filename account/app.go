@@ -1,17 +1,23 @@
 package account
 
 import (
+	"crypto/subtle"
+	"strings"
+
 	"github.com/apihub/apihub/errors"
 	. "github.com/apihub/apihub/log"
 	"github.com/apihub/apihub/util"
 	goutils "github.com/mrvdot/golang-utils"
 )
 
+// App is a client of the services. `Scopes` are the only scopes the App is allowed to request
+// when asking for access tokens.
 type App struct {
 	ClientId     string   `json:"client_id"`
 	ClientSecret string   `json:"client_secret"`
 	Name         string   `json:"name"`
 	RedirectUris []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes,omitempty"`
 	Owner        string   `json:"owner"`
 	Team         string   `json:"team"`
 }
@@ -60,13 +66,21 @@ func (app *App) Update() error {
 	return err
 }
 
-func (app App) Delete(owner User) error {
-	if app.Owner != owner.Email {
-		Logger.Info("Failed to delete an app. Only the owner has permission to do that: %+v.", app)
-		return errors.NewForbiddenError(errors.ErrOnlyOwnerHasPermission)
+// Delete removes the app, which is allowed to the members of its team whose role has the delete permission.
+func (app App) Delete(user User) error {
+	if err := authorizeOnTeam(app.Team, app.Owner, user, PERMISSION_DELETE); err != nil {
+		Logger.Info("Failed to delete an app. The user %s has no permission to do that: %+v.", user.Email, app)
+		return err
 	}
+	return app.delete()
+}
 
+func (app App) delete() error {
 	err := store.DeleteApp(app)
+	if err == nil {
+		store.DeleteApiKeysByApp(app)
+		store.DeleteSubscriptionsByApp(app)
+	}
 	Logger.Info("app.Delete: %+v. Err: %s.", app, err)
 	return err
 }
@@ -79,10 +93,48 @@ func (app App) Exists() bool {
 	return true
 }
 
+// Authenticate checks the secret sent by the App when requesting tokens.
+func (app App) Authenticate(secret string) bool {
+	return secret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(app.ClientSecret)) == 1
+}
+
+// RedirectUri returns the uri which the authorization response is sent to.
+// It must match one of the registered uris exactly, and may only be omitted when there is just one.
+func (app App) RedirectUri(uri string) (string, bool) {
+	if uri == "" {
+		if len(app.RedirectUris) == 1 {
+			return app.RedirectUris[0], true
+		}
+		return "", false
+	}
+
+	for _, registered := range app.RedirectUris {
+		if uri == registered {
+			return uri, true
+		}
+	}
+	return "", false
+}
+
+// AllowsScope reports whether all the scopes requested, separated by spaces, are allowed to the App.
+func (app App) AllowsScope(scope string) bool {
+	for _, s := range strings.Fields(scope) {
+		if !contains(app.Scopes, s) {
+			return false
+		}
+	}
+	return true
+}
+
 func (app *App) valid() error {
 	if app.Name == "" {
 		return errors.NewValidationError(errors.ErrAppMissingRequiredFields)
 	}
+	for _, s := range app.Scopes {
+		if s == "" || strings.ContainsAny(s, " \t\n") {
+			return errors.NewValidationError(errors.ErrAppInvalidScopes)
+		}
+	}
 
 	return nil
 }
@@ -94,9 +146,9 @@ func DeleteAppsByTeam(team Team, owner User) error {
 		return err
 	}
 	for _, s := range apps {
-		s.Delete(owner)
+		s.delete()
 	}
-	Logger.Info("All apps were excluded from the team `%s`.", team.Alias)
+	Logger.Info("All apps were excluded from the team `%s` by %s.", team.Alias, owner.Email)
 	return nil
 }
 
@@ -29,6 +29,24 @@ func (s *S) TestCreateAppWithoutRequiredFields(c *C) {
 	c.Assert(ok, Equals, true)
 }
 
+func (s *S) TestCreateAppWithInvalidScopes(c *C) {
+	app.Scopes = []string{"read write"}
+	err := app.Create(owner, team)
+	_, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestAppAllowsScope(c *C) {
+	app.Scopes = []string{"read", "write"}
+	c.Assert(app.AllowsScope(""), Equals, true)
+	c.Assert(app.AllowsScope("read"), Equals, true)
+	c.Assert(app.AllowsScope("write read"), Equals, true)
+	c.Assert(app.AllowsScope("read admin"), Equals, false)
+
+	app.Scopes = nil
+	c.Assert(app.AllowsScope("read"), Equals, false)
+}
+
 func (s *S) TestUpdateApp(c *C) {
 	app.Name = "ApiHub App"
 	err := app.Create(owner, team)
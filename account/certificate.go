@@ -0,0 +1,129 @@
+package account
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apihub/apihub/errors"
+	. "github.com/apihub/apihub/log"
+)
+
+// Certificate is used by the gateway to serve a host of a Service over HTTPS.
+//
+// `Certificate` (along with the intermediate certificates) and `Key` are PEM encoded.
+// `Host` must be either the subdomain of the service (such as billing.example.org) or one of the hosts
+// of its routes, wildcard hosts included. Certificates obtained by the gateway through ACME are `Managed`.
+type Certificate struct {
+	Host        string `json:"host"`
+	Service     string `json:"service"`
+	Certificate string `json:"certificate"`
+	Key         string `json:"key,omitempty"`
+	NotAfter    string `json:"not_after,omitempty"`
+	Managed     bool   `json:"managed,omitempty"`
+}
+
+func (cert *Certificate) Save(service Service) error {
+	cert.Service = service.Subdomain
+	cert.Host = strings.ToLower(cert.Host)
+
+	if err := cert.valid(service); err != nil {
+		Logger.Info("Failed to save a certificate with invalid data: %s (host) -> %s (service).", cert.Host, cert.Service)
+		return err
+	}
+
+	if other, err := store.FindCertificateByHost(cert.Host); err == nil && other.Service != cert.Service {
+		Logger.Info("Failed to save a certificate for a host of another service: %s (host).", cert.Host)
+		return errors.NewValidationError(errors.ErrCertificateDuplicateEntry)
+	}
+
+	err := store.UpsertCertificate(*cert)
+	if err == nil {
+		go publishCertificate(cert)
+	}
+	Logger.Info("certificate.Save: %s (host) -> %s (service). Err: %s.", cert.Host, cert.Service, err)
+	return err
+}
+
+func (cert Certificate) Delete() error {
+	err := store.DeleteCertificate(cert)
+	if err == nil {
+		go publishCertificate(&cert)
+	}
+	Logger.Info("certificate.Delete: %s (host) -> %s (service). Err: %s.", cert.Host, cert.Service, err)
+	return err
+}
+
+// valid checks that the certificate and the key are a pair, which is valid for the host of the service.
+func (cert *Certificate) valid(service Service) error {
+	if cert.Host == "" || cert.Certificate == "" || cert.Key == "" {
+		return errors.NewValidationError(errors.ErrCertificateMissingRequiredFields)
+	}
+	if !service.hasHost(cert.Host) {
+		return errors.NewValidationError(errors.ErrCertificateInvalidHost)
+	}
+
+	pair, err := tls.X509KeyPair([]byte(cert.Certificate), []byte(cert.Key))
+	if err != nil {
+		return errors.NewValidationError(errors.ErrCertificateInvalid)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil || leaf.VerifyHostname(strings.Replace(cert.Host, "*", "wildcard", 1)) != nil {
+		return errors.NewValidationError(errors.ErrCertificateInvalid)
+	}
+
+	cert.NotAfter = leaf.NotAfter.In(time.UTC).Format(time.RFC3339)
+	return nil
+}
+
+// hasHost reports whether the requests to the host are dispatched to the service,
+// either by its subdomain or by one of its routes.
+func (service Service) hasHost(host string) bool {
+	if strings.HasPrefix(host, service.Subdomain+".") {
+		return true
+	}
+	for _, route := range service.Routes {
+		if route.Host == "" {
+			continue
+		}
+		if strings.EqualFold(route.Host, host) {
+			return true
+		}
+		if strings.HasPrefix(route.Host, "*.") && strings.HasSuffix(host, route.Host[1:]) && !strings.HasPrefix(host, "*.") {
+			return true
+		}
+	}
+	return false
+}
+
+func FindCertificateByHost(host string) (*Certificate, error) {
+	cert, err := store.FindCertificateByHost(strings.ToLower(host))
+	if err != nil {
+		return nil, err
+	}
+
+	return &cert, nil
+}
+
+func FindCertificatesByService(service Service) ([]Certificate, error) {
+	return store.FindCertificatesByService(service)
+}
+
+// asJson leaves the key out, since it does not need to go through the pubsub.
+func (cert *Certificate) asJson() []byte {
+	c := *cert
+	c.Key = ""
+	j, _ := json.Marshal(c)
+	return j
+}
+
+// publishCertificate notifies the gateways that the certificate of a host has changed,
+// so they load it again from the storage.
+func publishCertificate(cert *Certificate) {
+	name := fmt.Sprintf("/certificates/%s", cert.Host)
+	pubsub.Publish(name, cert.asJson())
+	Logger.Info("The following certificate has been published: %s (host) -> %s (service).", cert.Host, cert.Service)
+}
@@ -0,0 +1,114 @@
+package account_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+	. "gopkg.in/check.v1"
+)
+
+// selfSignedCertificate returns a PEM encoded certificate and key, valid for the given hosts.
+func selfSignedCertificate(c *C, hosts ...string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		DNSNames:     hosts,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	c.Assert(err, IsNil)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, IsNil)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+}
+
+func (s *S) TestSaveCertificate(c *C) {
+	certPEM, keyPEM := selfSignedCertificate(c, "apihub.example.org")
+	cert := account.Certificate{Host: "ApiHub.example.org", Certificate: certPEM, Key: keyPEM}
+	err := cert.Save(service)
+	c.Assert(err, IsNil)
+	defer cert.Delete()
+
+	c.Assert(cert.Host, Equals, "apihub.example.org")
+	c.Assert(cert.Service, Equals, service.Subdomain)
+	c.Assert(cert.NotAfter, Equals, "2030-01-01T00:00:00Z")
+}
+
+func (s *S) TestSaveCertificateForRouteHost(c *C) {
+	service.Routes = []account.Route{{Host: "*.example.com"}}
+	certPEM, keyPEM := selfSignedCertificate(c, "*.example.com")
+	cert := account.Certificate{Host: "*.example.com", Certificate: certPEM, Key: keyPEM}
+	err := cert.Save(service)
+	c.Assert(err, IsNil)
+	defer cert.Delete()
+}
+
+func (s *S) TestSaveCertificateWithInvalidFields(c *C) {
+	certPEM, keyPEM := selfSignedCertificate(c, "apihub.example.org")
+	_, otherKeyPEM := selfSignedCertificate(c, "apihub.example.org")
+	for _, cert := range []account.Certificate{
+		account.Certificate{},
+		account.Certificate{Host: "other.example.org", Certificate: certPEM, Key: keyPEM},
+		account.Certificate{Host: "apihub.example.org", Certificate: certPEM, Key: otherKeyPEM},
+		account.Certificate{Host: "apihub.example.org", Certificate: "invalid", Key: "invalid"},
+		account.Certificate{Host: "apihub.example.com", Certificate: certPEM, Key: keyPEM},
+	} {
+		err := cert.Save(service)
+		_, ok := err.(errors.ValidationError)
+		c.Assert(ok, Equals, true)
+	}
+}
+
+func (s *S) TestSaveCertificateOfAnotherService(c *C) {
+	certPEM, keyPEM := selfSignedCertificate(c, "apihub.example.org")
+	cert := account.Certificate{Host: "apihub.example.org", Certificate: certPEM, Key: keyPEM}
+	cert.Save(service)
+	defer cert.Delete()
+
+	other := account.Service{Subdomain: "other", Routes: []account.Route{{Host: "apihub.example.org"}}}
+	dup := account.Certificate{Host: "apihub.example.org", Certificate: certPEM, Key: keyPEM}
+	err := dup.Save(other)
+	_, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestFindCertificateByHost(c *C) {
+	certPEM, keyPEM := selfSignedCertificate(c, "apihub.example.org")
+	cert := account.Certificate{Host: "apihub.example.org", Certificate: certPEM, Key: keyPEM}
+	cert.Save(service)
+	defer cert.Delete()
+
+	found, err := account.FindCertificateByHost("APIHUB.example.org")
+	c.Assert(err, IsNil)
+	c.Assert(*found, DeepEquals, cert)
+}
+
+func (s *S) TestFindCertificateByHostNotFound(c *C) {
+	cert, err := account.FindCertificateByHost("not-found.example.org")
+	c.Check(cert, IsNil)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestFindCertificatesByService(c *C) {
+	certPEM, keyPEM := selfSignedCertificate(c, "apihub.example.org")
+	cert := account.Certificate{Host: "apihub.example.org", Certificate: certPEM, Key: keyPEM}
+	cert.Save(service)
+	defer cert.Delete()
+
+	certs, err := account.FindCertificatesByService(service)
+	c.Assert(err, IsNil)
+	c.Assert(certs, DeepEquals, []account.Certificate{cert})
+}
@@ -0,0 +1,187 @@
+package account
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/apihub/apihub/errors"
+	. "github.com/apihub/apihub/log"
+)
+
+// ENCRYPTED_KEY_PREFIX marks the keys of the client certificates which are encrypted at rest.
+const ENCRYPTED_KEY_PREFIX = "encrypted:"
+
+var clientCertificateSecret string
+
+// ClientCertificateSecret sets the secret which the keys of the client certificates are encrypted with,
+// before they are stored. The gateway must be configured with the same secret to decrypt them.
+func ClientCertificateSecret(secret string) {
+	clientCertificateSecret = secret
+}
+
+// ClientCertificate is used by the gateway to reach the upstreams of a Service which enforce mutual TLS.
+//
+// `Certificate` (along with the intermediate certificates) and `Key` are PEM encoded, and are presented to
+// the upstreams. `CA` is a PEM encoded bundle of the authorities trusted to sign the certificates of the
+// upstreams, instead of the ones of the system. Either the pair or the bundle may be left out.
+// The `Key` is encrypted before it is stored, and only the gateway decrypts it.
+type ClientCertificate struct {
+	Name        string `json:"name"`
+	Service     string `json:"service"`
+	Certificate string `json:"certificate,omitempty"`
+	Key         string `json:"key,omitempty"`
+	CA          string `json:"ca,omitempty"`
+	NotAfter    string `json:"not_after,omitempty"`
+}
+
+// UpstreamTLS describes how the gateway reaches the upstreams of a service over HTTPS.
+//
+// `ClientCertificate` is the name of a client certificate of the service. The certificates of the upstreams
+// are verified against `ServerName`, which defaults to the host of each upstream.
+// With `SkipServerNameVerification`, only the chain of the certificates is verified,
+// which is useful for upstreams reached by their IP address.
+type UpstreamTLS struct {
+	ClientCertificate          string `json:"client_certificate,omitempty"`
+	ServerName                 string `json:"server_name,omitempty"`
+	SkipServerNameVerification bool   `json:"skip_server_name_verification,omitempty"`
+}
+
+func (cert *ClientCertificate) Save(service Service) error {
+	cert.Service = service.Subdomain
+
+	if err := cert.valid(); err != nil {
+		Logger.Info("Failed to save a client certificate with invalid data: %s (name) -> %s (service).", cert.Name, cert.Service)
+		return err
+	}
+	if cert.Key != "" {
+		key, err := encryptKey(cert.Key, clientCertificateSecret)
+		if err != nil {
+			Logger.Warn("Failed to encrypt the key of the client certificate: %s (name) -> %s (service). Err: %s.", cert.Name, cert.Service, err)
+			return err
+		}
+		cert.Key = key
+	}
+
+	err := store.UpsertClientCertificate(*cert)
+	if err == nil && service.usesClientCertificate(cert.Name) {
+		// The gateway loads the client certificate again when the service is published.
+		go publishService(&service)
+	}
+	Logger.Info("clientCertificate.Save: %s (name) -> %s (service). Err: %s.", cert.Name, cert.Service, err)
+	return err
+}
+
+func (cert ClientCertificate) Delete(service Service) error {
+	if service.usesClientCertificate(cert.Name) {
+		Logger.Info("Failed to delete a client certificate in use: %s (name) -> %s (service).", cert.Name, cert.Service)
+		return errors.NewForbiddenError(errors.ErrClientCertificateInUse)
+	}
+
+	err := store.DeleteClientCertificate(cert)
+	Logger.Info("clientCertificate.Delete: %s (name) -> %s (service). Err: %s.", cert.Name, cert.Service, err)
+	return err
+}
+
+// valid checks that the certificate and the key are a pair, and that the bundle has at least one certificate.
+func (cert *ClientCertificate) valid() error {
+	if cert.Name == "" || (cert.Certificate == "") != (cert.Key == "") || (cert.Certificate == "" && cert.CA == "") {
+		return errors.NewValidationError(errors.ErrClientCertificateMissingRequiredFields)
+	}
+
+	if cert.Certificate != "" {
+		pair, err := tls.X509KeyPair([]byte(cert.Certificate), []byte(cert.Key))
+		if err != nil {
+			return errors.NewValidationError(errors.ErrClientCertificateInvalid)
+		}
+		leaf, err := x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			return errors.NewValidationError(errors.ErrClientCertificateInvalid)
+		}
+		cert.NotAfter = leaf.NotAfter.In(time.UTC).Format(time.RFC3339)
+	}
+	if cert.CA != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(cert.CA)) {
+		return errors.NewValidationError(errors.ErrClientCertificateInvalid)
+	}
+	return nil
+}
+
+// DecryptKey returns the PEM encoded key of the certificate, decrypted with the secret.
+// Keys stored before they were encrypted are returned as they are, until the certificate is saved again.
+func (cert ClientCertificate) DecryptKey(secret string) (string, error) {
+	if !strings.HasPrefix(cert.Key, ENCRYPTED_KEY_PREFIX) {
+		return cert.Key, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(cert.Key, ENCRYPTED_KEY_PREFIX))
+	if err != nil {
+		return "", errors.ErrClientCertificateKeyNotDecrypted
+	}
+	gcm, err := keyCipher(secret)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", errors.ErrClientCertificateKeyNotDecrypted
+	}
+	key, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.ErrClientCertificateKeyNotDecrypted
+	}
+	return string(key), nil
+}
+
+// encryptKey seals the key with AES-GCM, keeping the random nonce before the encrypted key.
+func encryptKey(key, secret string) (string, error) {
+	if secret == "" {
+		return "", errors.ErrClientCertificateSecretMissing
+	}
+	gcm, err := keyCipher(secret)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return ENCRYPTED_KEY_PREFIX + base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(key), nil)), nil
+}
+
+func keyCipher(secret string) (cipher.AEAD, error) {
+	sum := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (service Service) usesClientCertificate(name string) bool {
+	return service.UpstreamTLS != nil && service.UpstreamTLS.ClientCertificate == name
+}
+
+func (t UpstreamTLS) valid(service Service) error {
+	if t.ClientCertificate == "" {
+		return nil
+	}
+	if _, err := store.FindClientCertificateByNameAndService(t.ClientCertificate, service); err != nil {
+		return errors.NewValidationError(errors.ErrServiceInvalidUpstreamTLS)
+	}
+	return nil
+}
+
+func FindClientCertificateByNameAndService(name string, service Service) (*ClientCertificate, error) {
+	cert, err := store.FindClientCertificateByNameAndService(name, service)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cert, nil
+}
+
+func FindClientCertificatesByService(service Service) ([]ClientCertificate, error) {
+	return store.FindClientCertificatesByService(service)
+}
@@ -0,0 +1,133 @@
+package account
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/apihub/apihub/errors"
+	. "github.com/apihub/apihub/log"
+	"github.com/apihub/apihub/util"
+)
+
+// DOMAIN_CHALLENGE_PREFIX is prepended to the domain to build the name of the TXT record which proves
+// that the team controls the domain.
+const DOMAIN_CHALLENGE_PREFIX = "_apihub-challenge."
+
+var validDomain = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}$`)
+
+// Resolver looks up the TXT records of a name. The default one uses the DNS resolver of the system.
+type Resolver interface {
+	LookupTXT(name string) ([]string, error)
+}
+
+type netResolver struct{}
+
+func (netResolver) LookupTXT(name string) ([]string, error) {
+	return net.LookupTXT(name)
+}
+
+var resolver Resolver = netResolver{}
+
+// DNSResolver sets the resolver used to verify the domains.
+func DNSResolver(r Resolver) {
+	resolver = r
+}
+
+// Domain is a domain which a team has proven to control, by publishing the `Token` in the TXT record
+// `_apihub-challenge.<Name>`. Once it is verified, the services of the team may declare routes to the domain
+// and to its subdomains.
+type Domain struct {
+	Name       string `json:"name"`
+	Team       string `json:"team"`
+	Token      string `json:"token"`
+	CreatedAt  string `json:"created_at"`
+	VerifiedAt string `json:"verified_at,omitempty"`
+}
+
+func (d *Domain) Create(team Team) error {
+	d.Name = strings.ToLower(strings.TrimSuffix(d.Name, "."))
+	d.Team = team.Alias
+	if !validDomain.MatchString(d.Name) {
+		return errors.NewValidationError(errors.ErrDomainInvalid)
+	}
+	if _, err := store.FindDomainByNameAndTeam(d.Name, team.Alias); err == nil {
+		return errors.NewValidationError(errors.ErrDomainDuplicateEntry)
+	}
+
+	d.Token = strings.TrimRight(util.GenerateRandomStr(24), "=")
+	d.CreatedAt = time.Now().In(time.UTC).Format(time.RFC3339)
+	d.VerifiedAt = ""
+
+	err := store.UpsertDomain(*d)
+	Logger.Info("domain.Create: %s (domain) -> %s (team). Err: %s.", d.Name, d.Team, err)
+	return err
+}
+
+// Verify looks up the TXT record of the domain, which must contain the token.
+// A domain is verified by one team at most.
+func (d *Domain) Verify() error {
+	domains, err := store.FindDomainsByName(d.Name)
+	if err != nil {
+		return err
+	}
+	for _, other := range domains {
+		if other.Team != d.Team && other.Verified() {
+			return errors.NewValidationError(errors.ErrDomainTaken)
+		}
+	}
+
+	records, err := resolver.LookupTXT(DOMAIN_CHALLENGE_PREFIX + d.Name)
+	if err != nil || !contains(records, d.Token) {
+		Logger.Info("Failed to verify the domain %s of the team %s. Err: %s.", d.Name, d.Team, err)
+		return errors.NewValidationError(errors.ErrDomainNotVerified)
+	}
+
+	d.VerifiedAt = time.Now().In(time.UTC).Format(time.RFC3339)
+	err = store.UpsertDomain(*d)
+	Logger.Info("domain.Verify: %s (domain) -> %s (team). Err: %s.", d.Name, d.Team, err)
+	return err
+}
+
+func (d Domain) Delete() error {
+	err := store.DeleteDomain(d)
+	Logger.Info("domain.Delete: %s (domain) -> %s (team). Err: %s.", d.Name, d.Team, err)
+	return err
+}
+
+func (d Domain) Verified() bool {
+	return d.VerifiedAt != ""
+}
+
+// Covers reports whether the host, wildcard hosts included, is either the domain or one of its subdomains.
+func (d Domain) Covers(host string) bool {
+	host = strings.TrimPrefix(strings.ToLower(host), "*.")
+	return host == d.Name || strings.HasSuffix(host, "."+d.Name)
+}
+
+func FindDomainByNameAndTeam(name string, team Team) (*Domain, error) {
+	d, err := store.FindDomainByNameAndTeam(strings.ToLower(name), team.Alias)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func FindDomainsByTeam(team Team) ([]Domain, error) {
+	return store.FindDomainsByTeam(team)
+}
+
+// teamControlsHost reports whether the host is covered by one of the domains verified by the team.
+func teamControlsHost(alias, host string) bool {
+	domains, err := store.FindDomainsByTeam(Team{Alias: alias})
+	if err != nil {
+		return false
+	}
+	for _, d := range domains {
+		if d.Verified() && d.Covers(host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package account_test
+
+import (
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+	. "gopkg.in/check.v1"
+)
+
+// fakeResolver answers the TXT lookups with the records it was given.
+type fakeResolver map[string][]string
+
+func (f fakeResolver) LookupTXT(name string) ([]string, error) {
+	return f[name], nil
+}
+
+// verifyDomain adds the domain to the team and publishes its token, so the verification succeeds.
+func verifyDomain(c *C, t account.Team, name string) account.Domain {
+	d := account.Domain{Name: name}
+	c.Assert(d.Create(t), IsNil)
+	account.DNSResolver(fakeResolver{account.DOMAIN_CHALLENGE_PREFIX + name: {"other", d.Token}})
+	c.Assert(d.Verify(), IsNil)
+	return d
+}
+
+func (s *S) TestCreateDomain(c *C) {
+	d := account.Domain{Name: "Example.org."}
+	err := d.Create(team)
+	c.Assert(err, IsNil)
+	defer d.Delete()
+	c.Assert(d.Name, Equals, "example.org")
+	c.Assert(d.Team, Equals, team.Alias)
+	c.Assert(d.Token, Not(Equals), "")
+	c.Assert(d.Verified(), Equals, false)
+
+	err = d.Create(team)
+	_, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestCreateDomainWithInvalidName(c *C) {
+	for _, name := range []string{"", "localhost", "*.example.org", "example.org/path", "-example.org"} {
+		d := account.Domain{Name: name}
+		err := d.Create(team)
+		_, ok := err.(errors.ValidationError)
+		c.Check(ok, Equals, true, Commentf("%s", name))
+	}
+}
+
+func (s *S) TestVerifyDomain(c *C) {
+	d := verifyDomain(c, team, "example.org")
+	defer d.Delete()
+	c.Assert(d.Verified(), Equals, true)
+
+	found, err := account.FindDomainByNameAndTeam("example.org", team)
+	c.Assert(err, IsNil)
+	c.Assert(found.Verified(), Equals, true)
+}
+
+func (s *S) TestVerifyDomainWithoutRecord(c *C) {
+	d := account.Domain{Name: "example.org"}
+	c.Assert(d.Create(team), IsNil)
+	defer d.Delete()
+
+	account.DNSResolver(fakeResolver{account.DOMAIN_CHALLENGE_PREFIX + "example.org": {"other"}})
+	err := d.Verify()
+	c.Assert(err, Equals, errors.NewValidationError(errors.ErrDomainNotVerified))
+	c.Assert(d.Verified(), Equals, false)
+}
+
+func (s *S) TestVerifyDomainTakenByAnotherTeam(c *C) {
+	d := verifyDomain(c, team, "example.org")
+	defer d.Delete()
+
+	other := account.Domain{Name: "example.org"}
+	c.Assert(other.Create(account.Team{Alias: "other"}), IsNil)
+	defer other.Delete()
+	account.DNSResolver(fakeResolver{account.DOMAIN_CHALLENGE_PREFIX + "example.org": {other.Token}})
+	err := other.Verify()
+	c.Assert(err, Equals, errors.NewValidationError(errors.ErrDomainTaken))
+}
+
+func (s *S) TestDomainCovers(c *C) {
+	d := account.Domain{Name: "example.org"}
+	c.Assert(d.Covers("example.org"), Equals, true)
+	c.Assert(d.Covers("api.example.org"), Equals, true)
+	c.Assert(d.Covers("*.example.org"), Equals, true)
+	c.Assert(d.Covers("API.Example.org"), Equals, true)
+	c.Assert(d.Covers("badexample.org"), Equals, false)
+	c.Assert(d.Covers("example.org.evil.com"), Equals, false)
+}
+
+func (s *S) TestFindDomainsByTeam(c *C) {
+	d := verifyDomain(c, team, "example.org")
+	defer d.Delete()
+
+	domains, err := account.FindDomainsByTeam(team)
+	c.Assert(err, IsNil)
+	c.Assert(domains, DeepEquals, []account.Domain{d})
+}
+
+func (s *S) TestDeleteDomainNotFound(c *C) {
+	d := account.Domain{Name: "example.org", Team: team.Alias}
+	_, ok := d.Delete().(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
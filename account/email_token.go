@@ -0,0 +1,142 @@
+package account
+
+import (
+	"crypto/subtle"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/apihub/apihub/errors"
+	. "github.com/apihub/apihub/log"
+	"github.com/apihub/apihub/util"
+)
+
+const (
+	PASSWORD_RESET_EXPIRES_IN     = time.Hour
+	EMAIL_VERIFICATION_EXPIRES_IN = 48 * time.Hour
+
+	EMAIL_TOKEN_PASSWORD_RESET     = "password_reset"
+	EMAIL_TOKEN_EMAIL_VERIFICATION = "email_verification"
+)
+
+var passwordResetMail = template.Must(template.New("password_reset").Parse(`Hi,
+
+Someone has asked to reset the password of your ApiHub account. If it was not you, just ignore this email.
+
+Otherwise, reset your password with the following token before {{.ExpiresAt}}:
+
+{{.Token}}
+`))
+
+var emailVerificationMail = template.Must(template.New("email_verification").Parse(`Hi,
+
+Welcome to ApiHub! Please verify your email with the following token before {{.ExpiresAt}}:
+
+{{.Token}}
+`))
+
+// EmailToken is a single-use token delivered by email, which proves that the user owns the email.
+// It is used to reset the password and to verify the email.
+//
+// The token is in the format `Id.Secret`, and only the hash of the secret is stored.
+type EmailToken struct {
+	Id        string `json:"id"`
+	Email     string `json:"email"`
+	Purpose   string `json:"purpose"`
+	Token     string `json:"-" bson:"-"`
+	Hash      string `json:"-"`
+	CreatedAt string `json:"created_at"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// RecoverPassword sends a token to reset the password, replacing the tokens sent before.
+func (user User) RecoverPassword() error {
+	err := sendEmailToken(user.Email, EMAIL_TOKEN_PASSWORD_RESET, PASSWORD_RESET_EXPIRES_IN,
+		"Reset your ApiHub password", passwordResetMail)
+	Logger.Info("user.RecoverPassword: %s. Err: %s.", user.Email, err)
+	return err
+}
+
+// SendEmailVerification sends a token to verify the email, replacing the tokens sent before.
+func (user User) SendEmailVerification() error {
+	err := sendEmailToken(user.Email, EMAIL_TOKEN_EMAIL_VERIFICATION, EMAIL_VERIFICATION_EXPIRES_IN,
+		"Verify your ApiHub email", emailVerificationMail)
+	Logger.Info("user.SendEmailVerification: %s. Err: %s.", user.Email, err)
+	return err
+}
+
+// ResetPassword changes the password of the user who the token was sent to.
+// As the token was delivered by email, the email is verified as well.
+func ResetPassword(token, password string) (*User, error) {
+	user, err := useEmailToken(token, EMAIL_TOKEN_PASSWORD_RESET)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Password = password
+	user.EmailVerified = true
+	if err := user.ChangePassword(); err != nil {
+		return nil, err
+	}
+	Logger.Info("user.ResetPassword: %s.", user.Email)
+	return user, nil
+}
+
+// VerifyEmail confirms the email of the user who the token was sent to.
+func VerifyEmail(token string) (*User, error) {
+	user, err := useEmailToken(token, EMAIL_TOKEN_EMAIL_VERIFICATION)
+	if err != nil {
+		return nil, err
+	}
+
+	user.EmailVerified = true
+	err = store.UpsertUser(*user)
+	Logger.Info("user.VerifyEmail: %s. Err: %s.", user.Email, err)
+	return user, err
+}
+
+func sendEmailToken(email, purpose string, expiresIn time.Duration, subject string, body *template.Template) error {
+	if err := store.DeleteEmailTokens(email, purpose); err != nil {
+		return err
+	}
+
+	now := time.Now().In(time.UTC)
+	t := EmailToken{
+		Id:        strings.TrimRight(util.GenerateRandomStr(12), "="),
+		Email:     email,
+		Purpose:   purpose,
+		CreatedAt: now.Format(time.RFC3339),
+		ExpiresAt: now.Add(expiresIn).Format(time.RFC3339),
+	}
+	secret := util.GenerateRandomStr(32)
+	t.Hash = hashSecret(secret)
+	if err := store.UpsertEmailToken(t); err != nil {
+		return err
+	}
+
+	t.Token = t.Id + "." + secret
+	return sendMail(email, subject, body, t)
+}
+
+// useEmailToken finds the user who the token was sent to, and removes the token so it is not used again.
+func useEmailToken(token, purpose string) (*User, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return nil, errors.NewValidationError(errors.ErrEmailTokenInvalid)
+	}
+
+	t, err := store.FindEmailTokenById(parts[0])
+	if err != nil || t.Purpose != purpose || expired(t.ExpiresAt) ||
+		subtle.ConstantTimeCompare([]byte(hashSecret(parts[1])), []byte(t.Hash)) != 1 {
+		return nil, errors.NewValidationError(errors.ErrEmailTokenInvalid)
+	}
+	if err := store.DeleteEmailToken(t); err != nil {
+		return nil, errors.NewValidationError(errors.ErrEmailTokenInvalid)
+	}
+
+	user, err := store.FindUserByEmail(t.Email)
+	if err != nil {
+		return nil, errors.NewValidationError(errors.ErrEmailTokenInvalid)
+	}
+	return &user, nil
+}
@@ -0,0 +1,109 @@
+package account_test
+
+import (
+	"strings"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+	"github.com/apihub/apihub/mail"
+	. "gopkg.in/check.v1"
+)
+
+// lastToken returns the token of the last message sent, which is on its last line.
+func lastToken(sender *mail.MemorySender) string {
+	messages := sender.Messages()
+	lines := strings.Split(strings.TrimSpace(messages[len(messages)-1].Body), "\n")
+	return lines[len(lines)-1]
+}
+
+func (s *S) TestRecoverPassword(c *C) {
+	sender := setUpMailer()
+	alice.Create()
+	defer alice.Delete()
+
+	err := alice.RecoverPassword()
+	c.Assert(err, IsNil)
+	messages := sender.Messages()
+	c.Assert(messages, HasLen, 1)
+	c.Assert(messages[0].To, DeepEquals, []string{alice.Email})
+	c.Assert(messages[0].Subject, Equals, "Reset your ApiHub password")
+}
+
+func (s *S) TestResetPassword(c *C) {
+	sender := setUpMailer()
+	alice.Create()
+	defer alice.Delete()
+	alice.RecoverPassword()
+
+	user, err := account.ResetPassword(lastToken(sender), "new-password")
+	c.Assert(err, IsNil)
+	c.Assert(user.Email, Equals, alice.Email)
+	c.Assert(user.EmailVerified, Equals, true)
+	c.Assert(user.Password, Not(Equals), "new-password")
+}
+
+func (s *S) TestResetPasswordIsSingleUse(c *C) {
+	sender := setUpMailer()
+	alice.Create()
+	defer alice.Delete()
+	alice.RecoverPassword()
+	token := lastToken(sender)
+
+	_, err := account.ResetPassword(token, "new-password")
+	c.Assert(err, IsNil)
+	_, err = account.ResetPassword(token, "another-password")
+	e, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Error(), Equals, errors.ErrEmailTokenInvalid.Error())
+}
+
+func (s *S) TestResetPasswordWithReplacedToken(c *C) {
+	sender := setUpMailer()
+	alice.Create()
+	defer alice.Delete()
+	alice.RecoverPassword()
+	token := lastToken(sender)
+	alice.RecoverPassword()
+
+	_, err := account.ResetPassword(token, "new-password")
+	_, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+	_, err = account.ResetPassword(lastToken(sender), "new-password")
+	c.Assert(err, IsNil)
+}
+
+func (s *S) TestResetPasswordWithVerificationToken(c *C) {
+	sender := setUpMailer()
+	alice.Create()
+	defer alice.Delete()
+	alice.SendEmailVerification()
+
+	_, err := account.ResetPassword(lastToken(sender), "new-password")
+	_, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestResetPasswordWithInvalidToken(c *C) {
+	_, err := account.ResetPassword("invalid", "new-password")
+	_, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestVerifyEmail(c *C) {
+	sender := setUpMailer()
+	alice.Create()
+	defer alice.Delete()
+
+	err := alice.SendEmailVerification()
+	c.Assert(err, IsNil)
+	c.Assert(sender.Messages()[0].Subject, Equals, "Verify your ApiHub email")
+
+	user, err := account.VerifyEmail(lastToken(sender))
+	c.Assert(err, IsNil)
+	c.Assert(user.EmailVerified, Equals, true)
+	c.Assert(user.Password, Equals, alice.Password)
+
+	_, err = account.VerifyEmail(lastToken(sender))
+	_, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+}
@@ -0,0 +1,202 @@
+package account
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/apihub/apihub/errors"
+	. "github.com/apihub/apihub/log"
+	"github.com/apihub/apihub/util"
+)
+
+const INVITATION_EXPIRES_IN = 7 * 24 * time.Hour
+
+var invitationMail = template.Must(template.New("invitation").Parse(`Hi,
+
+{{.Inviter}} has invited you to join the team {{.Team}} on ApiHub, as {{.Role}}.
+
+If you do not have an account yet, just sign up with this email and you will join the team.
+Otherwise, accept the invitation with the following token before {{.ExpiresAt}}:
+
+{{.Token}}
+`))
+
+// Invitation allows someone to join a team, even before signing up.
+//
+// The invitation is delivered by email, along with a token in the format `Id.Secret`.
+// Only the hash of the secret is stored, so the Token is only known when the invitation is created or resent.
+type Invitation struct {
+	Id        string `json:"id"`
+	Team      string `json:"team"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	Inviter   string `json:"inviter"`
+	Token     string `json:"token,omitempty" bson:"-"`
+	Hash      string `json:"-"`
+	CreatedAt string `json:"created_at"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// Create invites the email to join the team, replacing the pending invitation for the same email.
+func (inv *Invitation) Create(team Team, inviter User) error {
+	inv.Team = team.Alias
+	inv.Inviter = inviter.Email
+	if inv.Role == "" {
+		inv.Role = ROLE_DEVELOPER
+	}
+
+	if err := inv.valid(team); err != nil {
+		Logger.Info("Failed to create an invitation with invalid data: %s (email) -> %s (team).", inv.Email, inv.Team)
+		return err
+	}
+
+	inv.Id = strings.TrimRight(util.GenerateRandomStr(12), "=")
+	if invitations, err := store.FindInvitationsByTeam(team); err == nil {
+		for _, pending := range invitations {
+			if pending.Email == inv.Email {
+				inv.Id = pending.Id
+			}
+		}
+	}
+	inv.CreatedAt = time.Now().In(time.UTC).Format(time.RFC3339)
+
+	err := inv.send()
+	Logger.Info("invitation.Create: %s (email) -> %s (team). Err: %s.", inv.Email, inv.Team, err)
+	return err
+}
+
+// Resend delivers the invitation again, with a new token and expiration.
+func (inv *Invitation) Resend() error {
+	err := inv.send()
+	Logger.Info("invitation.Resend: %s (email) -> %s (team). Err: %s.", inv.Email, inv.Team, err)
+	return err
+}
+
+// Delete revokes the invitation.
+func (inv Invitation) Delete() error {
+	err := store.DeleteInvitation(inv)
+	Logger.Info("invitation.Delete: %s (email) -> %s (team). Err: %s.", inv.Email, inv.Team, err)
+	return err
+}
+
+func (inv Invitation) Expired() bool {
+	return expired(inv.ExpiresAt)
+}
+
+// Accept adds the user to the team, with the role of the invitation, and removes the invitation.
+// The invitation is only accepted by the user it was sent to.
+func (inv Invitation) Accept(user User) error {
+	if inv.Email != user.Email {
+		return errors.NewForbiddenError(errors.ErrInvitationNotForUser)
+	}
+	if inv.Expired() {
+		return errors.NewValidationError(errors.ErrInvitationExpired)
+	}
+
+	team, err := store.FindTeamByAlias(inv.Team)
+	if err != nil {
+		return err
+	}
+	if _, err := team.ContainsUser(&user); err != nil {
+		team.Users = append(team.Users, user.Email)
+		if inv.Role != ROLE_DEVELOPER {
+			team.setRole(user.Email, inv.Role)
+		}
+		if err := store.UpsertTeam(team); err != nil {
+			return err
+		}
+	}
+
+	err = store.DeleteInvitation(inv)
+	Logger.Info("invitation.Accept: %s (email) -> %s (team). Err: %s.", inv.Email, inv.Team, err)
+	return err
+}
+
+// send generates a new token, saves the invitation without it and delivers it.
+// A failure to deliver the email is not reported, so the invitation may be resent later.
+func (inv *Invitation) send() error {
+	secret := util.GenerateRandomStr(32)
+	inv.Token = inv.Id + "." + secret
+	inv.Hash = hashSecret(secret)
+	inv.ExpiresAt = time.Now().In(time.UTC).Add(INVITATION_EXPIRES_IN).Format(time.RFC3339)
+
+	stored := *inv
+	stored.Token = ""
+	if err := store.UpsertInvitation(stored); err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("You have been invited to join the team %s on ApiHub", inv.Team)
+	if err := sendMail(inv.Email, subject, invitationMail, inv); err != nil {
+		Logger.Warn("Failed to deliver the invitation to %s: %+v.", inv.Email, err)
+	}
+	return nil
+}
+
+func (inv *Invitation) valid(team Team) error {
+	inv.Email = strings.TrimSpace(inv.Email)
+	if inv.Email == "" || inv.Team == "" {
+		return errors.NewValidationError(errors.ErrInvitationMissingRequiredFields)
+	}
+	if inv.Role != ROLE_ADMIN && inv.Role != ROLE_DEVELOPER && inv.Role != ROLE_VIEWER {
+		return errors.NewValidationError(errors.ErrTeamInvalidRole)
+	}
+	if team.RoleOf(inv.Email) != "" {
+		return errors.NewValidationError(errors.ErrInvitationAlreadyMember)
+	}
+	return nil
+}
+
+// AcceptInvitations adds the user to the teams which the email was invited to, as long as the invitations have not expired.
+// It is meant to be called once the user has verified the email, which proves that the invitations were sent to them.
+func (user User) AcceptInvitations() error {
+	invitations, err := store.FindInvitationsByEmail(user.Email)
+	if err != nil {
+		return err
+	}
+	for _, inv := range invitations {
+		if inv.Expired() {
+			continue
+		}
+		if err := inv.Accept(user); err != nil {
+			Logger.Warn("Failed to accept the invitation of %s to the team %s: %+v.", user.Email, inv.Team, err)
+		}
+	}
+	return nil
+}
+
+// FindInvitationByToken finds the invitation which matches the given `Id.Secret`.
+func FindInvitationByToken(token string) (*Invitation, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return nil, errors.NewNotFoundError(errors.ErrInvitationNotFound)
+	}
+
+	inv, err := store.FindInvitationById(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(parts[1])), []byte(inv.Hash)) != 1 {
+		return nil, errors.NewNotFoundError(errors.ErrInvitationNotFound)
+	}
+	return &inv, nil
+}
+
+// FindInvitationByIdAndTeam finds an invitation to join the team.
+func FindInvitationByIdAndTeam(id string, team Team) (*Invitation, error) {
+	inv, err := store.FindInvitationById(id)
+	if err != nil {
+		return nil, err
+	}
+	if inv.Team != team.Alias {
+		return nil, errors.NewNotFoundError(errors.ErrInvitationNotFound)
+	}
+	return &inv, nil
+}
+
+func FindInvitationsByTeam(team Team) ([]Invitation, error) {
+	return store.FindInvitationsByTeam(team)
+}
@@ -0,0 +1,190 @@
+package account_test
+
+import (
+	"strings"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+	"github.com/apihub/apihub/mail"
+	. "gopkg.in/check.v1"
+)
+
+func setUpMailer() *mail.MemorySender {
+	sender := mail.NewMemorySender()
+	account.Mailer(sender)
+	return sender
+}
+
+func (s *S) TestCreateInvitation(c *C) {
+	sender := setUpMailer()
+	team.Create(owner)
+	defer team.Delete(owner)
+
+	inv := account.Invitation{Email: alice.Email, Role: account.ROLE_VIEWER}
+	err := inv.Create(team, owner)
+	c.Assert(err, IsNil)
+	defer inv.Delete()
+	c.Assert(inv.Team, Equals, team.Alias)
+	c.Assert(inv.Inviter, Equals, owner.Email)
+	c.Assert(strings.HasPrefix(inv.Token, inv.Id+"."), Equals, true)
+
+	messages := sender.Messages()
+	c.Assert(messages, HasLen, 1)
+	c.Assert(messages[0].To, DeepEquals, []string{alice.Email})
+	c.Assert(strings.Contains(messages[0].Body, inv.Token), Equals, true)
+
+	found, err := account.FindInvitationByToken(inv.Token)
+	c.Assert(err, IsNil)
+	c.Assert(found.Token, Equals, "")
+	c.Assert(found.Role, Equals, account.ROLE_VIEWER)
+}
+
+func (s *S) TestCreateInvitationWithDefaultRole(c *C) {
+	setUpMailer()
+	team.Create(owner)
+	defer team.Delete(owner)
+
+	inv := account.Invitation{Email: alice.Email}
+	err := inv.Create(team, owner)
+	c.Assert(err, IsNil)
+	defer inv.Delete()
+	c.Assert(inv.Role, Equals, account.ROLE_DEVELOPER)
+}
+
+func (s *S) TestCreateInvitationWithoutEmail(c *C) {
+	team.Create(owner)
+	defer team.Delete(owner)
+
+	inv := account.Invitation{}
+	err := inv.Create(team, owner)
+	e, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Error(), Equals, errors.ErrInvitationMissingRequiredFields.Error())
+}
+
+func (s *S) TestCreateInvitationWithInvalidRole(c *C) {
+	team.Create(owner)
+	defer team.Delete(owner)
+
+	inv := account.Invitation{Email: alice.Email, Role: account.ROLE_OWNER}
+	err := inv.Create(team, owner)
+	e, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Error(), Equals, errors.ErrTeamInvalidRole.Error())
+}
+
+func (s *S) TestCreateInvitationForMember(c *C) {
+	team.Create(owner)
+	defer team.Delete(owner)
+
+	inv := account.Invitation{Email: owner.Email}
+	err := inv.Create(team, owner)
+	e, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Error(), Equals, errors.ErrInvitationAlreadyMember.Error())
+}
+
+func (s *S) TestCreateInvitationReplacesThePendingOne(c *C) {
+	setUpMailer()
+	team.Create(owner)
+	defer team.Delete(owner)
+
+	inv := account.Invitation{Email: alice.Email}
+	inv.Create(team, owner)
+	defer inv.Delete()
+	again := account.Invitation{Email: alice.Email, Role: account.ROLE_ADMIN}
+	err := again.Create(team, owner)
+	c.Assert(err, IsNil)
+	c.Assert(again.Id, Equals, inv.Id)
+
+	invitations, err := account.FindInvitationsByTeam(team)
+	c.Assert(err, IsNil)
+	c.Assert(invitations, HasLen, 1)
+	c.Assert(invitations[0].Role, Equals, account.ROLE_ADMIN)
+
+	_, err = account.FindInvitationByToken(inv.Token)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestResendInvitation(c *C) {
+	sender := setUpMailer()
+	team.Create(owner)
+	defer team.Delete(owner)
+
+	inv := account.Invitation{Email: alice.Email}
+	inv.Create(team, owner)
+	defer inv.Delete()
+	token := inv.Token
+
+	err := inv.Resend()
+	c.Assert(err, IsNil)
+	c.Assert(inv.Token, Not(Equals), token)
+	c.Assert(sender.Messages(), HasLen, 2)
+
+	_, err = account.FindInvitationByToken(token)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+	_, err = account.FindInvitationByToken(inv.Token)
+	c.Assert(err, IsNil)
+}
+
+func (s *S) TestFindInvitationByTokenWithInvalidToken(c *C) {
+	_, err := account.FindInvitationByToken("invalid")
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestAcceptInvitation(c *C) {
+	setUpMailer()
+	team.Create(owner)
+	alice.Create()
+	defer alice.Delete()
+	defer team.Delete(owner)
+
+	inv := account.Invitation{Email: alice.Email, Role: account.ROLE_VIEWER}
+	inv.Create(team, owner)
+	err := inv.Accept(alice)
+	c.Assert(err, IsNil)
+
+	t, err := account.FindTeamByAlias(team.Alias)
+	c.Assert(err, IsNil)
+	c.Assert(t.RoleOf(alice.Email), Equals, account.ROLE_VIEWER)
+	_, err = account.FindInvitationByToken(inv.Token)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestAcceptInvitationForAnotherUser(c *C) {
+	inv := account.Invitation{Email: "bob@example.org", Team: team.Alias}
+	err := inv.Accept(alice)
+	e, ok := err.(errors.ForbiddenError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Error(), Equals, errors.ErrInvitationNotForUser.Error())
+}
+
+func (s *S) TestAcceptExpiredInvitation(c *C) {
+	inv := account.Invitation{Email: alice.Email, Team: team.Alias, ExpiresAt: time.Now().Add(-time.Minute).In(time.UTC).Format(time.RFC3339)}
+	err := inv.Accept(alice)
+	e, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Error(), Equals, errors.ErrInvitationExpired.Error())
+}
+
+func (s *S) TestAcceptInvitations(c *C) {
+	setUpMailer()
+	team.Create(owner)
+	defer team.Delete(owner)
+
+	inv := account.Invitation{Email: alice.Email, Role: account.ROLE_ADMIN}
+	inv.Create(team, owner)
+	alice.Create()
+	defer alice.Delete()
+
+	err := alice.AcceptInvitations()
+	c.Assert(err, IsNil)
+	t, err := account.FindTeamByAlias(team.Alias)
+	c.Assert(err, IsNil)
+	c.Assert(t.RoleOf(alice.Email), Equals, account.ROLE_ADMIN)
+}
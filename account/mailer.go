@@ -0,0 +1,33 @@
+package account
+
+import (
+	"bytes"
+	"text/template"
+
+	. "github.com/apihub/apihub/log"
+	"github.com/apihub/apihub/mail"
+)
+
+var mailer mail.Sender
+
+// Mailer sets the sender of the emails, such as the invitations to join a team.
+// Without a sender, the emails are not delivered.
+func Mailer(s mail.Sender) {
+	mailer = s
+}
+
+// sendMail renders the body of the message with the data, and delivers it.
+func sendMail(to, subject string, body *template.Template, data interface{}) error {
+	if mailer == nil {
+		Logger.Warn("Failed to send `%s` to %s: there is no mailer.", subject, to)
+		return nil
+	}
+
+	var b bytes.Buffer
+	if err := body.Execute(&b, data); err != nil {
+		return err
+	}
+	err := mailer.Send(mail.Message{To: []string{to}, Subject: subject, Body: b.String()})
+	Logger.Info("mailer.Send: `%s` to %s. Err: %s.", subject, to, err)
+	return err
+}
@@ -2,33 +2,57 @@
 package mem
 
 import (
-	"fmt"
-
 	"github.com/apihub/apihub/account"
 	"github.com/apihub/apihub/errors"
 )
 
 type Mem struct {
-	Apps       map[string]account.App
-	Services   map[string]account.Service
-	Users      map[string]account.User
-	Teams      map[string]account.Team
-	Plugins    map[string]map[string]account.Plugin
-	Tokens     map[string]account.Token
-	UserTokens map[string]account.User
-	Hooks      map[string]account.Hook
+	Apps     map[string]account.App
+	Services map[string]account.Service
+	Users    map[string]account.User
+	Teams    map[string]account.Team
+	Plugins  map[string]map[string]account.Plugin
+	Tokens   map[string]account.Token
+	Hooks    map[string]account.Hook
+
+	ApiKeys              map[string]account.ApiKey
+	Plans                map[string]map[string]account.Plan
+	Subscriptions        map[string]map[string]account.Subscription
+	Grants               map[string]account.Grant
+	OAuthTokens          map[string]account.OAuthToken
+	UpstreamStates       map[string]map[string]account.UpstreamState
+	CircuitBreakerStates map[string]account.CircuitBreakerState
+	Certificates         map[string]account.Certificate
+	ClientCertificates   map[string]map[string]account.ClientCertificate
+	Invitations          map[string]account.Invitation
+	EmailTokens          map[string]account.EmailToken
+	PersonalAccessTokens map[string]account.PersonalAccessToken
+	Domains              map[string]map[string]account.Domain
 }
 
 func New() *Mem {
 	return &Mem{
-		Apps:       make(map[string]account.App),
-		Services:   make(map[string]account.Service),
-		Users:      make(map[string]account.User),
-		Teams:      make(map[string]account.Team),
-		Plugins:    make(map[string]map[string]account.Plugin),
-		Tokens:     make(map[string]account.Token),
-		UserTokens: make(map[string]account.User),
-		Hooks:      make(map[string]account.Hook),
+		Apps:     make(map[string]account.App),
+		Services: make(map[string]account.Service),
+		Users:    make(map[string]account.User),
+		Teams:    make(map[string]account.Team),
+		Plugins:  make(map[string]map[string]account.Plugin),
+		Tokens:   make(map[string]account.Token),
+		Hooks:    make(map[string]account.Hook),
+
+		ApiKeys:              make(map[string]account.ApiKey),
+		Plans:                make(map[string]map[string]account.Plan),
+		Subscriptions:        make(map[string]map[string]account.Subscription),
+		Grants:               make(map[string]account.Grant),
+		OAuthTokens:          make(map[string]account.OAuthToken),
+		UpstreamStates:       make(map[string]map[string]account.UpstreamState),
+		CircuitBreakerStates: make(map[string]account.CircuitBreakerState),
+		Certificates:         make(map[string]account.Certificate),
+		ClientCertificates:   make(map[string]map[string]account.ClientCertificate),
+		Invitations:          make(map[string]account.Invitation),
+		EmailTokens:          make(map[string]account.EmailToken),
+		PersonalAccessTokens: make(map[string]account.PersonalAccessToken),
+		Domains:              make(map[string]map[string]account.Domain),
 	}
 }
 
@@ -113,30 +137,146 @@ func (m *Mem) TeamApps(team account.Team) ([]account.App, error) {
 	return apps, nil
 }
 
-func (m *Mem) CreateToken(token account.Token) error {
-	key := fmt.Sprintf("%s: %s", token.Type, token.User.Email)
-	m.Tokens[key] = token
-	m.UserTokens[token.AccessToken] = *token.User
+func (m *Mem) UpsertToken(t account.Token) error {
+	m.Tokens[t.Id] = t
+	return nil
+}
+
+func (m *Mem) DeleteToken(t account.Token) error {
+	if _, ok := m.Tokens[t.Id]; !ok {
+		return errors.NewNotFoundError(errors.ErrTokenNotFound)
+	}
+	delete(m.Tokens, t.Id)
 	return nil
 }
 
-func (m *Mem) DecodeToken(key string, t interface{}) error {
-	if token, ok := m.Tokens[key]; ok {
-		*t.(*account.Token) = token
+func (m *Mem) DeleteTokensByUser(email string) error {
+	for id, t := range m.Tokens {
+		if t.User == email {
+			delete(m.Tokens, id)
+		}
+	}
+	return nil
+}
+
+func (m *Mem) FindTokenByAccessToken(accessToken string) (account.Token, error) {
+	for _, t := range m.Tokens {
+		if accessToken != "" && t.AccessToken == accessToken {
+			return t, nil
+		}
+	}
+	return account.Token{}, errors.NewNotFoundError(errors.ErrTokenNotFound)
+}
+
+func (m *Mem) FindTokenByRefreshToken(refreshToken string) (account.Token, error) {
+	for _, t := range m.Tokens {
+		if refreshToken != "" && t.RefreshToken == refreshToken {
+			return t, nil
+		}
+	}
+	return account.Token{}, errors.NewNotFoundError(errors.ErrTokenNotFound)
+}
+
+func (m *Mem) FindTokensByUser(email string) ([]account.Token, error) {
+	tokens := []account.Token{}
+	for _, t := range m.Tokens {
+		if t.User == email {
+			tokens = append(tokens, t)
+		}
 	}
+	return tokens, nil
+}
 
-	if token, ok := m.UserTokens[key]; ok {
-		*t.(*account.User) = token
+func (m *Mem) UpdateTokenLastUsedAt(t account.Token) error {
+	stored, ok := m.Tokens[t.Id]
+	if !ok {
+		return errors.NewNotFoundError(errors.ErrTokenNotFound)
 	}
+	stored.LastUsedAt = t.LastUsedAt
+	m.Tokens[t.Id] = stored
 	return nil
 }
 
-func (m *Mem) DeleteToken(key string) error {
-	delete(m.Tokens, key)
-	delete(m.UserTokens, key)
+func (m *Mem) UpsertPersonalAccessToken(t account.PersonalAccessToken) error {
+	m.PersonalAccessTokens[t.Id] = t
 	return nil
 }
 
+func (m *Mem) DeletePersonalAccessToken(t account.PersonalAccessToken) error {
+	if _, ok := m.PersonalAccessTokens[t.Id]; !ok {
+		return errors.NewNotFoundError(errors.ErrPersonalAccessTokenNotFound)
+	}
+	delete(m.PersonalAccessTokens, t.Id)
+	return nil
+}
+
+func (m *Mem) DeletePersonalAccessTokensByUser(email string) error {
+	for id, t := range m.PersonalAccessTokens {
+		if t.User == email {
+			delete(m.PersonalAccessTokens, id)
+		}
+	}
+	return nil
+}
+
+func (m *Mem) FindPersonalAccessTokenById(id string) (account.PersonalAccessToken, error) {
+	if t, ok := m.PersonalAccessTokens[id]; !ok {
+		return account.PersonalAccessToken{}, errors.NewNotFoundError(errors.ErrPersonalAccessTokenNotFound)
+	} else {
+		return t, nil
+	}
+}
+
+func (m *Mem) FindPersonalAccessTokensByUser(email string) ([]account.PersonalAccessToken, error) {
+	tokens := []account.PersonalAccessToken{}
+	for _, t := range m.PersonalAccessTokens {
+		if t.User == email {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens, nil
+}
+
+func (m *Mem) UpdatePersonalAccessTokenLastUsedAt(t account.PersonalAccessToken) error {
+	stored, ok := m.PersonalAccessTokens[t.Id]
+	if !ok {
+		return errors.NewNotFoundError(errors.ErrPersonalAccessTokenNotFound)
+	}
+	stored.LastUsedAt = t.LastUsedAt
+	m.PersonalAccessTokens[t.Id] = stored
+	return nil
+}
+
+func (m *Mem) UpsertEmailToken(t account.EmailToken) error {
+	m.EmailTokens[t.Id] = t
+	return nil
+}
+
+func (m *Mem) DeleteEmailToken(t account.EmailToken) error {
+	if _, ok := m.EmailTokens[t.Id]; !ok {
+		return errors.NewNotFoundError(errors.ErrTokenNotFound)
+	}
+	delete(m.EmailTokens, t.Id)
+	return nil
+}
+
+func (m *Mem) DeleteEmailTokens(email, purpose string) error {
+	for id, t := range m.EmailTokens {
+		if t.Email == email && t.Purpose == purpose {
+			delete(m.EmailTokens, id)
+		}
+	}
+	return nil
+}
+
+func (m *Mem) FindEmailTokenById(id string) (account.EmailToken, error) {
+	if t, ok := m.EmailTokens[id]; !ok {
+		return account.EmailToken{}, errors.NewNotFoundError(errors.ErrTokenNotFound)
+	} else {
+		return t, nil
+	}
+}
+
 func (m *Mem) UpsertService(s account.Service) error {
 	m.Services[s.Subdomain] = s
 	return nil
@@ -159,6 +299,19 @@ func (m *Mem) FindServiceBySubdomain(subdomain string) (account.Service, error)
 	}
 }
 
+func (m *Mem) FindServicesByRouteHost(host string) ([]account.Service, error) {
+	services := []account.Service{}
+	for _, service := range m.Services {
+		for _, route := range service.Routes {
+			if route.Host == host {
+				services = append(services, service)
+				break
+			}
+		}
+	}
+	return services, nil
+}
+
 func (m *Mem) UserServices(user account.User) ([]account.Service, error) {
 	teams, _ := m.UserTeams(user)
 	services := []account.Service{}
@@ -195,8 +348,199 @@ func (m *Mem) DeleteApp(a account.App) error {
 	return nil
 }
 
+func (m *Mem) UpsertApiKey(k account.ApiKey) error {
+	m.ApiKeys[k.Id] = k
+	return nil
+}
+
+func (m *Mem) FindApiKeyById(id string) (account.ApiKey, error) {
+	if apiKey, ok := m.ApiKeys[id]; !ok {
+		return account.ApiKey{}, errors.NewNotFoundError(errors.ErrApiKeyNotFound)
+	} else {
+		return apiKey, nil
+	}
+}
+
+func (m *Mem) AppApiKeys(app account.App) ([]account.ApiKey, error) {
+	apiKeys := []account.ApiKey{}
+	for _, apiKey := range m.ApiKeys {
+		if apiKey.ClientId == app.ClientId {
+			apiKeys = append(apiKeys, apiKey)
+		}
+	}
+	return apiKeys, nil
+}
+
+func (m *Mem) UpdateApiKeyLastUsedAt(k account.ApiKey) error {
+	stored, ok := m.ApiKeys[k.Id]
+	if !ok {
+		return errors.NewNotFoundError(errors.ErrApiKeyNotFound)
+	}
+	stored.LastUsedAt = k.LastUsedAt
+	m.ApiKeys[k.Id] = stored
+	return nil
+}
+
+func (m *Mem) DeleteApiKeysByApp(app account.App) error {
+	for id, apiKey := range m.ApiKeys {
+		if apiKey.ClientId == app.ClientId {
+			delete(m.ApiKeys, id)
+		}
+	}
+	return nil
+}
+
+func (m *Mem) UpsertPlan(p account.Plan) error {
+	if _, ok := m.Plans[p.Service]; !ok {
+		m.Plans[p.Service] = make(map[string]account.Plan)
+	}
+	m.Plans[p.Service][p.Name] = p
+	return nil
+}
+
+func (m *Mem) DeletePlan(p account.Plan) error {
+	if _, ok := m.Plans[p.Service][p.Name]; !ok {
+		return errors.NewNotFoundError(errors.ErrPlanNotFound)
+	}
+
+	delete(m.Plans[p.Service], p.Name)
+	return nil
+}
+
+func (m *Mem) DeletePlansByService(service account.Service) error {
+	delete(m.Plans, service.Subdomain)
+	return nil
+}
+
+func (m *Mem) FindPlanByNameAndService(name string, service account.Service) (account.Plan, error) {
+	if plan, ok := m.Plans[service.Subdomain][name]; !ok {
+		return account.Plan{}, errors.NewNotFoundError(errors.ErrPlanNotFound)
+	} else {
+		return plan, nil
+	}
+}
+
+func (m *Mem) FindPlansByService(service account.Service) ([]account.Plan, error) {
+	plans := []account.Plan{}
+	for _, plan := range m.Plans[service.Subdomain] {
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+func (m *Mem) UpsertSubscription(s account.Subscription) error {
+	if _, ok := m.Subscriptions[s.Service]; !ok {
+		m.Subscriptions[s.Service] = make(map[string]account.Subscription)
+	}
+	m.Subscriptions[s.Service][s.ClientId] = s
+	return nil
+}
+
+func (m *Mem) DeleteSubscription(s account.Subscription) error {
+	if _, ok := m.Subscriptions[s.Service][s.ClientId]; !ok {
+		return errors.NewNotFoundError(errors.ErrSubscriptionNotFound)
+	}
+
+	delete(m.Subscriptions[s.Service], s.ClientId)
+	return nil
+}
+
+func (m *Mem) FindSubscription(clientId, subdomain string) (account.Subscription, error) {
+	if subscription, ok := m.Subscriptions[subdomain][clientId]; !ok {
+		return account.Subscription{}, errors.NewNotFoundError(errors.ErrSubscriptionNotFound)
+	} else {
+		return subscription, nil
+	}
+}
+
+func (m *Mem) AppSubscriptions(app account.App) ([]account.Subscription, error) {
+	subscriptions := []account.Subscription{}
+	for _, s := range m.Subscriptions {
+		if subscription, ok := s[app.ClientId]; ok {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions, nil
+}
+
+func (m *Mem) ServiceSubscriptions(service account.Service) ([]account.Subscription, error) {
+	subscriptions := []account.Subscription{}
+	for _, subscription := range m.Subscriptions[service.Subdomain] {
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, nil
+}
+
+func (m *Mem) DeleteSubscriptionsByApp(app account.App) error {
+	for _, s := range m.Subscriptions {
+		delete(s, app.ClientId)
+	}
+	return nil
+}
+
+func (m *Mem) DeleteSubscriptionsByService(service account.Service) error {
+	delete(m.Subscriptions, service.Subdomain)
+	return nil
+}
+
+func (m *Mem) UpsertGrant(g account.Grant) error {
+	m.Grants[g.Code] = g
+	return nil
+}
+
+func (m *Mem) DeleteGrant(g account.Grant) error {
+	if _, ok := m.Grants[g.Code]; !ok {
+		return errors.NewNotFoundError(errors.ErrGrantNotFound)
+	}
+
+	delete(m.Grants, g.Code)
+	return nil
+}
+
+func (m *Mem) FindGrantByCode(code string) (account.Grant, error) {
+	if grant, ok := m.Grants[code]; !ok {
+		return account.Grant{}, errors.NewNotFoundError(errors.ErrGrantNotFound)
+	} else {
+		return grant, nil
+	}
+}
+
+func (m *Mem) UpsertOAuthToken(t account.OAuthToken) error {
+	m.OAuthTokens[t.AccessToken] = t
+	return nil
+}
+
+func (m *Mem) DeleteOAuthToken(t account.OAuthToken) error {
+	if _, ok := m.OAuthTokens[t.AccessToken]; !ok {
+		return errors.NewNotFoundError(errors.ErrTokenNotFound)
+	}
+
+	delete(m.OAuthTokens, t.AccessToken)
+	return nil
+}
+
+func (m *Mem) FindOAuthTokenByAccessToken(accessToken string) (account.OAuthToken, error) {
+	if token, ok := m.OAuthTokens[accessToken]; !ok {
+		return account.OAuthToken{}, errors.NewNotFoundError(errors.ErrTokenNotFound)
+	} else {
+		return token, nil
+	}
+}
+
+func (m *Mem) FindOAuthTokenByRefreshToken(refreshToken string) (account.OAuthToken, error) {
+	for _, token := range m.OAuthTokens {
+		if refreshToken != "" && token.RefreshToken == refreshToken {
+			return token, nil
+		}
+	}
+	return account.OAuthToken{}, errors.NewNotFoundError(errors.ErrTokenNotFound)
+}
+
 func (m *Mem) UpsertPlugin(pc account.Plugin) error {
-	m.Plugins[pc.Service] = map[string]account.Plugin{pc.Name: pc}
+	if _, ok := m.Plugins[pc.Service]; !ok {
+		m.Plugins[pc.Service] = make(map[string]account.Plugin)
+	}
+	m.Plugins[pc.Service][pc.Name] = pc
 	return nil
 }
 
@@ -205,7 +549,7 @@ func (m *Mem) DeletePlugin(pc account.Plugin) error {
 		return errors.NewNotFoundError(errors.ErrPluginNotFound)
 	}
 
-	delete(m.Plugins, pc.Name)
+	delete(m.Plugins[pc.Service], pc.Name)
 	return nil
 }
 
@@ -226,6 +570,229 @@ func (m *Mem) FindPluginByNameAndService(pluginName string, service account.Serv
 	}
 }
 
+func (m *Mem) FindPluginsByService(service account.Service) ([]account.Plugin, error) {
+	plugins := []account.Plugin{}
+	for _, plugin := range m.Plugins[service.Subdomain] {
+		plugins = append(plugins, plugin)
+	}
+	return plugins, nil
+}
+
+func (m *Mem) UpsertUpstreamState(state account.UpstreamState) error {
+	if _, ok := m.UpstreamStates[state.Service]; !ok {
+		m.UpstreamStates[state.Service] = make(map[string]account.UpstreamState)
+	}
+	m.UpstreamStates[state.Service][state.Target] = state
+	return nil
+}
+
+func (m *Mem) DeleteUpstreamStatesByService(service account.Service) error {
+	delete(m.UpstreamStates, service.Subdomain)
+	return nil
+}
+
+func (m *Mem) FindUpstreamStatesByService(service account.Service) ([]account.UpstreamState, error) {
+	states := []account.UpstreamState{}
+	for _, state := range m.UpstreamStates[service.Subdomain] {
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func (m *Mem) UpsertCircuitBreakerState(state account.CircuitBreakerState) error {
+	m.CircuitBreakerStates[state.Service] = state
+	return nil
+}
+
+func (m *Mem) DeleteCircuitBreakerStateByService(service account.Service) error {
+	delete(m.CircuitBreakerStates, service.Subdomain)
+	return nil
+}
+
+func (m *Mem) FindCircuitBreakerStateByService(service account.Service) (account.CircuitBreakerState, error) {
+	state, ok := m.CircuitBreakerStates[service.Subdomain]
+	if !ok {
+		return account.CircuitBreakerState{}, errors.NewNotFoundError(errors.ErrCircuitBreakerStateNotFound)
+	}
+	return state, nil
+}
+
+func (m *Mem) UpsertCertificate(cert account.Certificate) error {
+	m.Certificates[cert.Host] = cert
+	return nil
+}
+
+func (m *Mem) DeleteCertificate(cert account.Certificate) error {
+	if _, ok := m.Certificates[cert.Host]; !ok {
+		return errors.NewNotFoundError(errors.ErrCertificateNotFound)
+	}
+
+	delete(m.Certificates, cert.Host)
+	return nil
+}
+
+func (m *Mem) DeleteCertificatesByService(service account.Service) error {
+	for host, cert := range m.Certificates {
+		if cert.Service == service.Subdomain {
+			delete(m.Certificates, host)
+		}
+	}
+	return nil
+}
+
+func (m *Mem) FindCertificateByHost(host string) (account.Certificate, error) {
+	if cert, ok := m.Certificates[host]; !ok {
+		return account.Certificate{}, errors.NewNotFoundError(errors.ErrCertificateNotFound)
+	} else {
+		return cert, nil
+	}
+}
+
+func (m *Mem) FindCertificatesByService(service account.Service) ([]account.Certificate, error) {
+	certs := []account.Certificate{}
+	for _, cert := range m.Certificates {
+		if cert.Service == service.Subdomain {
+			certs = append(certs, cert)
+		}
+	}
+	return certs, nil
+}
+
+func (m *Mem) UpsertClientCertificate(cert account.ClientCertificate) error {
+	if _, ok := m.ClientCertificates[cert.Service]; !ok {
+		m.ClientCertificates[cert.Service] = make(map[string]account.ClientCertificate)
+	}
+	m.ClientCertificates[cert.Service][cert.Name] = cert
+	return nil
+}
+
+func (m *Mem) DeleteClientCertificate(cert account.ClientCertificate) error {
+	if _, ok := m.ClientCertificates[cert.Service][cert.Name]; !ok {
+		return errors.NewNotFoundError(errors.ErrClientCertificateNotFound)
+	}
+
+	delete(m.ClientCertificates[cert.Service], cert.Name)
+	return nil
+}
+
+func (m *Mem) DeleteClientCertificatesByService(service account.Service) error {
+	delete(m.ClientCertificates, service.Subdomain)
+	return nil
+}
+
+func (m *Mem) FindClientCertificateByNameAndService(name string, service account.Service) (account.ClientCertificate, error) {
+	if cert, ok := m.ClientCertificates[service.Subdomain][name]; !ok {
+		return account.ClientCertificate{}, errors.NewNotFoundError(errors.ErrClientCertificateNotFound)
+	} else {
+		return cert, nil
+	}
+}
+
+func (m *Mem) FindClientCertificatesByService(service account.Service) ([]account.ClientCertificate, error) {
+	certs := []account.ClientCertificate{}
+	for _, cert := range m.ClientCertificates[service.Subdomain] {
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+func (m *Mem) UpsertInvitation(inv account.Invitation) error {
+	m.Invitations[inv.Id] = inv
+	return nil
+}
+
+func (m *Mem) DeleteInvitation(inv account.Invitation) error {
+	if _, ok := m.Invitations[inv.Id]; !ok {
+		return errors.NewNotFoundError(errors.ErrInvitationNotFound)
+	}
+	delete(m.Invitations, inv.Id)
+	return nil
+}
+
+func (m *Mem) DeleteInvitationsByTeam(team account.Team) error {
+	for id, inv := range m.Invitations {
+		if inv.Team == team.Alias {
+			delete(m.Invitations, id)
+		}
+	}
+	return nil
+}
+
+func (m *Mem) FindInvitationById(id string) (account.Invitation, error) {
+	if inv, ok := m.Invitations[id]; !ok {
+		return account.Invitation{}, errors.NewNotFoundError(errors.ErrInvitationNotFound)
+	} else {
+		return inv, nil
+	}
+}
+
+func (m *Mem) FindInvitationsByTeam(team account.Team) ([]account.Invitation, error) {
+	invitations := []account.Invitation{}
+	for _, inv := range m.Invitations {
+		if inv.Team == team.Alias {
+			invitations = append(invitations, inv)
+		}
+	}
+	return invitations, nil
+}
+
+func (m *Mem) FindInvitationsByEmail(email string) ([]account.Invitation, error) {
+	invitations := []account.Invitation{}
+	for _, inv := range m.Invitations {
+		if inv.Email == email {
+			invitations = append(invitations, inv)
+		}
+	}
+	return invitations, nil
+}
+
+func (m *Mem) UpsertDomain(d account.Domain) error {
+	if _, ok := m.Domains[d.Team]; !ok {
+		m.Domains[d.Team] = make(map[string]account.Domain)
+	}
+	m.Domains[d.Team][d.Name] = d
+	return nil
+}
+
+func (m *Mem) DeleteDomain(d account.Domain) error {
+	if _, ok := m.Domains[d.Team][d.Name]; !ok {
+		return errors.NewNotFoundError(errors.ErrDomainNotFound)
+	}
+	delete(m.Domains[d.Team], d.Name)
+	return nil
+}
+
+func (m *Mem) DeleteDomainsByTeam(team account.Team) error {
+	delete(m.Domains, team.Alias)
+	return nil
+}
+
+func (m *Mem) FindDomainByNameAndTeam(name string, team string) (account.Domain, error) {
+	if d, ok := m.Domains[team][name]; !ok {
+		return account.Domain{}, errors.NewNotFoundError(errors.ErrDomainNotFound)
+	} else {
+		return d, nil
+	}
+}
+
+func (m *Mem) FindDomainsByName(name string) ([]account.Domain, error) {
+	domains := []account.Domain{}
+	for _, teamDomains := range m.Domains {
+		if d, ok := teamDomains[name]; ok {
+			domains = append(domains, d)
+		}
+	}
+	return domains, nil
+}
+
+func (m *Mem) FindDomainsByTeam(team account.Team) ([]account.Domain, error) {
+	domains := []account.Domain{}
+	for _, d := range m.Domains[team.Alias] {
+		domains = append(domains, d)
+	}
+	return domains, nil
+}
+
 func (m *Mem) UpsertHook(w account.Hook) error {
 	m.Hooks[w.Name] = w
 	return nil
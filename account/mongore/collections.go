@@ -1,10 +1,6 @@
 package mongore
 
 import (
-	"fmt"
-
-	"github.com/apihub/apihub/db"
-	"github.com/garyburd/redigo/redis"
 	"github.com/tsuru/tsuru/db/storage"
 	"gopkg.in/mgo.v2"
 )
@@ -20,6 +16,42 @@ func (strg *Storage) Apps() *storage.Collection {
 	return collection
 }
 
+func (strg *Storage) ApiKeys() *storage.Collection {
+	index := mgo.Index{Key: []string{"id"}, Unique: true, Background: false}
+	collection := strg.Collection("api_keys")
+	collection.EnsureIndex(index)
+	return collection
+}
+
+func (strg *Storage) Plans() *storage.Collection {
+	index := mgo.Index{Key: []string{"service", "name"}, Unique: true, Background: false}
+	collection := strg.Collection("plans")
+	collection.EnsureIndex(index)
+	return collection
+}
+
+func (strg *Storage) Subscriptions() *storage.Collection {
+	index := mgo.Index{Key: []string{"service", "clientid"}, Unique: true, Background: false}
+	collection := strg.Collection("subscriptions")
+	collection.EnsureIndex(index)
+	return collection
+}
+
+func (strg *Storage) Grants() *storage.Collection {
+	index := mgo.Index{Key: []string{"code"}, Unique: true, Background: false}
+	collection := strg.Collection("grants")
+	collection.EnsureIndex(index)
+	return collection
+}
+
+func (strg *Storage) OAuthTokens() *storage.Collection {
+	index := mgo.Index{Key: []string{"accesstoken"}, Unique: true, Background: false}
+	collection := strg.Collection("oauth_tokens")
+	collection.EnsureIndex(index)
+	collection.EnsureIndex(mgo.Index{Key: []string{"refreshtoken"}, Background: false})
+	return collection
+}
+
 func (strg *Storage) Users() *storage.Collection {
 	index := mgo.Index{Key: []string{"email"}, Unique: true, Background: false}
 	collection := strg.Collection("users")
@@ -48,42 +80,78 @@ func (strg *Storage) Plugins() *storage.Collection {
 	return collection
 }
 
-func (strg *Storage) Hooks() *storage.Collection {
-	index := mgo.Index{Key: []string{"name", "team"}, Unique: true, Background: false}
-	collection := strg.Collection("hooks")
+func (strg *Storage) UpstreamStates() *storage.Collection {
+	index := mgo.Index{Key: []string{"service", "target"}, Unique: true, Background: false}
+	collection := strg.Collection("upstream_states")
+	collection.EnsureIndex(index)
+	return collection
+}
+
+func (strg *Storage) CircuitBreakerStates() *storage.Collection {
+	index := mgo.Index{Key: []string{"service"}, Unique: true, Background: false}
+	collection := strg.Collection("circuit_breaker_states")
+	collection.EnsureIndex(index)
+	return collection
+}
+
+func (strg *Storage) Certificates() *storage.Collection {
+	index := mgo.Index{Key: []string{"host"}, Unique: true, Background: false}
+	collection := strg.Collection("certificates")
+	collection.EnsureIndex(index)
+	collection.EnsureIndex(mgo.Index{Key: []string{"service"}, Background: false})
+	return collection
+}
+
+func (strg *Storage) ClientCertificates() *storage.Collection {
+	index := mgo.Index{Key: []string{"service", "name"}, Unique: true, Background: false}
+	collection := strg.Collection("client_certificates")
+	collection.EnsureIndex(index)
+	return collection
+}
+
+func (strg *Storage) Tokens() *storage.Collection {
+	index := mgo.Index{Key: []string{"id"}, Unique: true, Background: false}
+	collection := strg.Collection("tokens")
+	collection.EnsureIndex(index)
+	collection.EnsureIndex(mgo.Index{Key: []string{"accesstoken"}, Background: false})
+	collection.EnsureIndex(mgo.Index{Key: []string{"refreshtoken"}, Background: false})
+	collection.EnsureIndex(mgo.Index{Key: []string{"user"}, Background: false})
+	return collection
+}
+
+func (strg *Storage) PersonalAccessTokens() *storage.Collection {
+	index := mgo.Index{Key: []string{"id"}, Unique: true, Background: false}
+	collection := strg.Collection("personal_access_tokens")
 	collection.EnsureIndex(index)
+	collection.EnsureIndex(mgo.Index{Key: []string{"user"}, Background: false})
 	return collection
 }
 
-func (strg *Storage) GetTokenValue(key string, t interface{}) error {
-	var (
-		data []interface{}
-		err  error
-	)
+func (strg *Storage) EmailTokens() *storage.Collection {
+	index := mgo.Index{Key: []string{"id"}, Unique: true, Background: false}
+	collection := strg.Collection("email_tokens")
+	collection.EnsureIndex(index)
+	return collection
+}
 
-	if item := db.Cache.Get(key); item != nil && item.Value() != nil {
-		if !item.Expired() {
-			data = item.Value().([]interface{})
-		}
-	}
-	if len(data) == 0 {
-		data, err = db.GetHCache(key)
-		if err != nil {
-			return err
-		}
-	}
+func (strg *Storage) Invitations() *storage.Collection {
+	index := mgo.Index{Key: []string{"id"}, Unique: true, Background: false}
+	collection := strg.Collection("invitations")
+	collection.EnsureIndex(index)
+	return collection
+}
 
-	if err = redis.ScanStruct(data, t); err != nil {
-		fmt.Print(err)
-		return err
-	}
-	if len(data) > 0 {
-		db.Cache.Replace(key, data)
-	}
-	return nil
+func (strg *Storage) Domains() *storage.Collection {
+	index := mgo.Index{Key: []string{"name", "team"}, Unique: true, Background: false}
+	collection := strg.Collection("domains")
+	collection.EnsureIndex(index)
+	collection.EnsureIndex(mgo.Index{Key: []string{"team"}, Background: false})
+	return collection
 }
 
-func (strg *Storage) DeleteToken(key string) (interface{}, error) {
-	db.Cache.Delete(key)
-	return db.DelCache(key)
+func (strg *Storage) Hooks() *storage.Collection {
+	index := mgo.Index{Key: []string{"name", "team"}, Unique: true, Background: false}
+	collection := strg.Collection("hooks")
+	collection.EnsureIndex(index)
+	return collection
 }
@@ -1,14 +1,9 @@
 package mongore
 
 import (
-	"fmt"
-	"time"
-
 	"github.com/apihub/apihub/account"
-	"github.com/apihub/apihub/db"
 	"github.com/apihub/apihub/errors"
 	. "github.com/apihub/apihub/log"
-	"github.com/fatih/structs"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -161,33 +156,277 @@ func (m *Mongore) TeamServices(team account.Team) ([]account.Service, error) {
 	return services, err
 }
 
-func (m *Mongore) CreateToken(token account.Token) error {
-	key := fmt.Sprintf("%s: %s", token.Type, token.User.Email)
-	db.Cache.Set(key, nil, time.Duration(token.Expires)*time.Minute)
-	db.AddHCache(key, token.Expires, structs.Map(token))
+func (m *Mongore) UpsertToken(t account.Token) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.Tokens().Upsert(bson.M{"id": t.Id}, t)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeleteToken(t account.Token) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	err := strg.Tokens().Remove(bson.M{"id": t.Id})
+
+	if err == mgo.ErrNotFound {
+		return errors.NewNotFoundError(errors.ErrTokenNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeleteTokensByUser(email string) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.Tokens().RemoveAll(bson.M{"user": email})
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) FindTokenByAccessToken(accessToken string) (account.Token, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	var token account.Token
+	err := strg.Tokens().Find(bson.M{"accesstoken": accessToken}).One(&token)
+
+	if err == mgo.ErrNotFound {
+		return account.Token{}, errors.NewNotFoundError(errors.ErrTokenNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return token, err
+}
+
+func (m *Mongore) FindTokenByRefreshToken(refreshToken string) (account.Token, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	var token account.Token
+	err := strg.Tokens().Find(bson.M{"refreshtoken": refreshToken}).One(&token)
+
+	if err == mgo.ErrNotFound {
+		return account.Token{}, errors.NewNotFoundError(errors.ErrTokenNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return token, err
+}
+
+func (m *Mongore) FindTokensByUser(email string) ([]account.Token, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	tokens := []account.Token{}
+	err := strg.Tokens().Find(bson.M{"user": email}).All(&tokens)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return tokens, err
+}
+
+func (m *Mongore) UpdateTokenLastUsedAt(t account.Token) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	err := strg.Tokens().Update(bson.M{"id": t.Id}, bson.M{"$set": bson.M{"lastusedat": t.LastUsedAt}})
+
+	if err == mgo.ErrNotFound {
+		return errors.NewNotFoundError(errors.ErrTokenNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) UpsertPersonalAccessToken(t account.PersonalAccessToken) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.PersonalAccessTokens().Upsert(bson.M{"id": t.Id}, t)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeletePersonalAccessToken(t account.PersonalAccessToken) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	err := strg.PersonalAccessTokens().Remove(bson.M{"id": t.Id})
+
+	if err == mgo.ErrNotFound {
+		return errors.NewNotFoundError(errors.ErrPersonalAccessTokenNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeletePersonalAccessTokensByUser(email string) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.PersonalAccessTokens().RemoveAll(bson.M{"user": email})
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) FindPersonalAccessTokenById(id string) (account.PersonalAccessToken, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	var token account.PersonalAccessToken
+	err := strg.PersonalAccessTokens().Find(bson.M{"id": id}).One(&token)
+
+	if err == mgo.ErrNotFound {
+		return account.PersonalAccessToken{}, errors.NewNotFoundError(errors.ErrPersonalAccessTokenNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return token, err
+}
+
+func (m *Mongore) FindPersonalAccessTokensByUser(email string) ([]account.PersonalAccessToken, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	tokens := []account.PersonalAccessToken{}
+	err := strg.PersonalAccessTokens().Find(bson.M{"user": email}).All(&tokens)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return tokens, err
+}
+
+func (m *Mongore) UpdatePersonalAccessTokenLastUsedAt(t account.PersonalAccessToken) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	err := strg.PersonalAccessTokens().Update(bson.M{"id": t.Id}, bson.M{"$set": bson.M{"lastusedat": t.LastUsedAt}})
+
+	if err == mgo.ErrNotFound {
+		return errors.NewNotFoundError(errors.ErrPersonalAccessTokenNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) UpsertEmailToken(t account.EmailToken) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.EmailTokens().Upsert(bson.M{"id": t.Id}, t)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
 
-	db.Cache.Set(token.AccessToken, nil, time.Duration(token.Expires))
-	db.AddHCache(token.AccessToken, token.Expires, structs.Map(token.User))
-	return nil
+	return err
 }
 
-func (m *Mongore) DecodeToken(key string, t interface{}) error {
+func (m *Mongore) DeleteEmailToken(t account.EmailToken) error {
 	var strg Storage
 	strg.Storage = m.openSession()
 	defer strg.Close()
 
-	return strg.GetTokenValue(key, t)
+	err := strg.EmailTokens().Remove(bson.M{"id": t.Id})
+
+	if err == mgo.ErrNotFound {
+		return errors.NewNotFoundError(errors.ErrTokenNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
 }
 
-func (m *Mongore) DeleteToken(key string) error {
+func (m *Mongore) DeleteEmailTokens(email, purpose string) error {
 	var strg Storage
 	strg.Storage = m.openSession()
 	defer strg.Close()
 
-	_, err := strg.DeleteToken(key)
+	_, err := strg.EmailTokens().RemoveAll(bson.M{"email": email, "purpose": purpose})
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
 	return err
 }
 
+func (m *Mongore) FindEmailTokenById(id string) (account.EmailToken, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	var t account.EmailToken
+	err := strg.EmailTokens().Find(bson.M{"id": id}).One(&t)
+
+	if err == mgo.ErrNotFound {
+		return account.EmailToken{}, errors.NewNotFoundError(errors.ErrTokenNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return t, err
+}
+
 func (m *Mongore) UpsertService(s account.Service) error {
 	var strg Storage
 	strg.Storage = m.openSession()
@@ -237,6 +476,21 @@ func (m *Mongore) FindServiceBySubdomain(subdomain string) (account.Service, err
 	return service, err
 }
 
+func (m *Mongore) FindServicesByRouteHost(host string) ([]account.Service, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	services := []account.Service{}
+	err := strg.Services().Find(bson.M{"routes.host": host}).All(&services)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return services, err
+}
+
 func (m *Mongore) UserServices(user account.User) ([]account.Service, error) {
 	var strg Storage
 	strg.Storage = m.openSession()
@@ -321,12 +575,12 @@ func (m *Mongore) TeamApps(team account.Team) ([]account.App, error) {
 	return apps, err
 }
 
-func (m *Mongore) UpsertPlugin(pc account.Plugin) error {
+func (m *Mongore) UpsertApiKey(k account.ApiKey) error {
 	var strg Storage
 	strg.Storage = m.openSession()
 	defer strg.Close()
 
-	_, err := strg.Plugins().Upsert(bson.M{"service": pc.Service, "name": pc.Name}, pc)
+	_, err := strg.ApiKeys().Upsert(bson.M{"id": k.Id}, k)
 
 	if err != nil {
 		Logger.Warn(err.Error())
@@ -335,32 +589,48 @@ func (m *Mongore) UpsertPlugin(pc account.Plugin) error {
 	return err
 }
 
-func (m *Mongore) DeletePlugin(pc account.Plugin) error {
+func (m *Mongore) FindApiKeyById(id string) (account.ApiKey, error) {
 	var strg Storage
 	strg.Storage = m.openSession()
 	defer strg.Close()
 
-	err := strg.Plugins().Remove(pc)
+	var apiKey account.ApiKey
+	err := strg.ApiKeys().Find(bson.M{"id": id}).One(&apiKey)
 
 	if err == mgo.ErrNotFound {
-		return errors.NewNotFoundError(errors.ErrPluginNotFound)
+		return account.ApiKey{}, errors.NewNotFoundError(errors.ErrApiKeyNotFound)
 	}
 	if err != nil {
 		Logger.Warn(err.Error())
 	}
 
-	return err
+	return apiKey, err
 }
 
-func (m *Mongore) DeletePluginsByService(service account.Service) error {
+func (m *Mongore) AppApiKeys(app account.App) ([]account.ApiKey, error) {
 	var strg Storage
 	strg.Storage = m.openSession()
 	defer strg.Close()
 
-	err := strg.Plugins().Remove(bson.M{"service": service.Subdomain})
+	apiKeys := []account.ApiKey{}
+	err := strg.ApiKeys().Find(bson.M{"clientid": app.ClientId}).All(&apiKeys)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return apiKeys, err
+}
+
+func (m *Mongore) UpdateApiKeyLastUsedAt(k account.ApiKey) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	err := strg.ApiKeys().Update(bson.M{"id": k.Id}, bson.M{"$set": bson.M{"lastusedat": k.LastUsedAt}})
 
 	if err == mgo.ErrNotFound {
-		return errors.NewNotFoundError(errors.ErrPluginNotFound)
+		return errors.NewNotFoundError(errors.ErrApiKeyNotFound)
 	}
 	if err != nil {
 		Logger.Warn(err.Error())
@@ -369,22 +639,831 @@ func (m *Mongore) DeletePluginsByService(service account.Service) error {
 	return err
 }
 
-func (m *Mongore) FindPluginByNameAndService(pluginName string, service account.Service) (account.Plugin, error) {
+func (m *Mongore) DeleteApiKeysByApp(app account.App) error {
 	var strg Storage
 	strg.Storage = m.openSession()
 	defer strg.Close()
 
-	var plugin account.Plugin
-	err := strg.Plugins().Find(bson.M{"name": pluginName, "service": service.Subdomain}).One(&plugin)
+	_, err := strg.ApiKeys().RemoveAll(bson.M{"clientid": app.ClientId})
 
-	if err == mgo.ErrNotFound {
-		return account.Plugin{}, errors.NewNotFoundError(errors.ErrPluginNotFound)
+	if err != nil {
+		Logger.Warn(err.Error())
 	}
+
+	return err
+}
+
+func (m *Mongore) UpsertPlan(p account.Plan) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.Plans().Upsert(bson.M{"service": p.Service, "name": p.Name}, p)
+
 	if err != nil {
 		Logger.Warn(err.Error())
 	}
 
-	return plugin, err
+	return err
+}
+
+func (m *Mongore) DeletePlan(p account.Plan) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	err := strg.Plans().Remove(bson.M{"service": p.Service, "name": p.Name})
+
+	if err == mgo.ErrNotFound {
+		return errors.NewNotFoundError(errors.ErrPlanNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeletePlansByService(service account.Service) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.Plans().RemoveAll(bson.M{"service": service.Subdomain})
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) FindPlanByNameAndService(name string, service account.Service) (account.Plan, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	var plan account.Plan
+	err := strg.Plans().Find(bson.M{"name": name, "service": service.Subdomain}).One(&plan)
+
+	if err == mgo.ErrNotFound {
+		return account.Plan{}, errors.NewNotFoundError(errors.ErrPlanNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return plan, err
+}
+
+func (m *Mongore) FindPlansByService(service account.Service) ([]account.Plan, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	plans := []account.Plan{}
+	err := strg.Plans().Find(bson.M{"service": service.Subdomain}).All(&plans)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return plans, err
+}
+
+func (m *Mongore) UpsertSubscription(s account.Subscription) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.Subscriptions().Upsert(bson.M{"service": s.Service, "clientid": s.ClientId}, s)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeleteSubscription(s account.Subscription) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	err := strg.Subscriptions().Remove(bson.M{"service": s.Service, "clientid": s.ClientId})
+
+	if err == mgo.ErrNotFound {
+		return errors.NewNotFoundError(errors.ErrSubscriptionNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) FindSubscription(clientId, subdomain string) (account.Subscription, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	var subscription account.Subscription
+	err := strg.Subscriptions().Find(bson.M{"service": subdomain, "clientid": clientId}).One(&subscription)
+
+	if err == mgo.ErrNotFound {
+		return account.Subscription{}, errors.NewNotFoundError(errors.ErrSubscriptionNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return subscription, err
+}
+
+func (m *Mongore) AppSubscriptions(app account.App) ([]account.Subscription, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	subscriptions := []account.Subscription{}
+	err := strg.Subscriptions().Find(bson.M{"clientid": app.ClientId}).All(&subscriptions)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return subscriptions, err
+}
+
+func (m *Mongore) ServiceSubscriptions(service account.Service) ([]account.Subscription, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	subscriptions := []account.Subscription{}
+	err := strg.Subscriptions().Find(bson.M{"service": service.Subdomain}).All(&subscriptions)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return subscriptions, err
+}
+
+func (m *Mongore) DeleteSubscriptionsByApp(app account.App) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.Subscriptions().RemoveAll(bson.M{"clientid": app.ClientId})
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeleteSubscriptionsByService(service account.Service) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.Subscriptions().RemoveAll(bson.M{"service": service.Subdomain})
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) UpsertGrant(g account.Grant) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.Grants().Upsert(bson.M{"code": g.Code}, g)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeleteGrant(g account.Grant) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	err := strg.Grants().Remove(bson.M{"code": g.Code})
+
+	if err == mgo.ErrNotFound {
+		return errors.NewNotFoundError(errors.ErrGrantNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) FindGrantByCode(code string) (account.Grant, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	var grant account.Grant
+	err := strg.Grants().Find(bson.M{"code": code}).One(&grant)
+
+	if err == mgo.ErrNotFound {
+		return account.Grant{}, errors.NewNotFoundError(errors.ErrGrantNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return grant, err
+}
+
+func (m *Mongore) UpsertOAuthToken(t account.OAuthToken) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.OAuthTokens().Upsert(bson.M{"accesstoken": t.AccessToken}, t)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeleteOAuthToken(t account.OAuthToken) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	err := strg.OAuthTokens().Remove(bson.M{"accesstoken": t.AccessToken})
+
+	if err == mgo.ErrNotFound {
+		return errors.NewNotFoundError(errors.ErrTokenNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) FindOAuthTokenByAccessToken(accessToken string) (account.OAuthToken, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	var token account.OAuthToken
+	err := strg.OAuthTokens().Find(bson.M{"accesstoken": accessToken}).One(&token)
+
+	if err == mgo.ErrNotFound {
+		return account.OAuthToken{}, errors.NewNotFoundError(errors.ErrTokenNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return token, err
+}
+
+func (m *Mongore) FindOAuthTokenByRefreshToken(refreshToken string) (account.OAuthToken, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	var token account.OAuthToken
+	err := strg.OAuthTokens().Find(bson.M{"refreshtoken": refreshToken}).One(&token)
+
+	if err == mgo.ErrNotFound {
+		return account.OAuthToken{}, errors.NewNotFoundError(errors.ErrTokenNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return token, err
+}
+
+func (m *Mongore) UpsertPlugin(pc account.Plugin) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.Plugins().Upsert(bson.M{"service": pc.Service, "name": pc.Name}, pc)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeletePlugin(pc account.Plugin) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	err := strg.Plugins().Remove(pc)
+
+	if err == mgo.ErrNotFound {
+		return errors.NewNotFoundError(errors.ErrPluginNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeletePluginsByService(service account.Service) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	err := strg.Plugins().Remove(bson.M{"service": service.Subdomain})
+
+	if err == mgo.ErrNotFound {
+		return errors.NewNotFoundError(errors.ErrPluginNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) FindPluginByNameAndService(pluginName string, service account.Service) (account.Plugin, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	var plugin account.Plugin
+	err := strg.Plugins().Find(bson.M{"name": pluginName, "service": service.Subdomain}).One(&plugin)
+
+	if err == mgo.ErrNotFound {
+		return account.Plugin{}, errors.NewNotFoundError(errors.ErrPluginNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return plugin, err
+}
+
+func (m *Mongore) FindPluginsByService(service account.Service) ([]account.Plugin, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	plugins := []account.Plugin{}
+	err := strg.Plugins().Find(bson.M{"service": service.Subdomain}).All(&plugins)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return plugins, err
+}
+
+func (m *Mongore) UpsertUpstreamState(state account.UpstreamState) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.UpstreamStates().Upsert(bson.M{"service": state.Service, "target": state.Target}, state)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeleteUpstreamStatesByService(service account.Service) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.UpstreamStates().RemoveAll(bson.M{"service": service.Subdomain})
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) FindUpstreamStatesByService(service account.Service) ([]account.UpstreamState, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	states := []account.UpstreamState{}
+	err := strg.UpstreamStates().Find(bson.M{"service": service.Subdomain}).All(&states)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return states, err
+}
+
+func (m *Mongore) UpsertCircuitBreakerState(state account.CircuitBreakerState) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.CircuitBreakerStates().Upsert(bson.M{"service": state.Service}, state)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeleteCircuitBreakerStateByService(service account.Service) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.CircuitBreakerStates().RemoveAll(bson.M{"service": service.Subdomain})
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) FindCircuitBreakerStateByService(service account.Service) (account.CircuitBreakerState, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	var state account.CircuitBreakerState
+	err := strg.CircuitBreakerStates().Find(bson.M{"service": service.Subdomain}).One(&state)
+
+	if err == mgo.ErrNotFound {
+		return account.CircuitBreakerState{}, errors.NewNotFoundError(errors.ErrCircuitBreakerStateNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return state, err
+}
+
+func (m *Mongore) UpsertCertificate(cert account.Certificate) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.Certificates().Upsert(bson.M{"host": cert.Host}, cert)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeleteCertificate(cert account.Certificate) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	err := strg.Certificates().Remove(bson.M{"host": cert.Host})
+
+	if err == mgo.ErrNotFound {
+		return errors.NewNotFoundError(errors.ErrCertificateNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeleteCertificatesByService(service account.Service) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.Certificates().RemoveAll(bson.M{"service": service.Subdomain})
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) FindCertificateByHost(host string) (account.Certificate, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	var cert account.Certificate
+	err := strg.Certificates().Find(bson.M{"host": host}).One(&cert)
+
+	if err == mgo.ErrNotFound {
+		return account.Certificate{}, errors.NewNotFoundError(errors.ErrCertificateNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return cert, err
+}
+
+func (m *Mongore) FindCertificatesByService(service account.Service) ([]account.Certificate, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	certs := []account.Certificate{}
+	err := strg.Certificates().Find(bson.M{"service": service.Subdomain}).All(&certs)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return certs, err
+}
+
+func (m *Mongore) UpsertClientCertificate(cert account.ClientCertificate) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.ClientCertificates().Upsert(bson.M{"name": cert.Name, "service": cert.Service}, cert)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeleteClientCertificate(cert account.ClientCertificate) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	err := strg.ClientCertificates().Remove(bson.M{"name": cert.Name, "service": cert.Service})
+
+	if err == mgo.ErrNotFound {
+		return errors.NewNotFoundError(errors.ErrClientCertificateNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeleteClientCertificatesByService(service account.Service) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.ClientCertificates().RemoveAll(bson.M{"service": service.Subdomain})
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) FindClientCertificateByNameAndService(name string, service account.Service) (account.ClientCertificate, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	var cert account.ClientCertificate
+	err := strg.ClientCertificates().Find(bson.M{"name": name, "service": service.Subdomain}).One(&cert)
+
+	if err == mgo.ErrNotFound {
+		return account.ClientCertificate{}, errors.NewNotFoundError(errors.ErrClientCertificateNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return cert, err
+}
+
+func (m *Mongore) FindClientCertificatesByService(service account.Service) ([]account.ClientCertificate, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	certs := []account.ClientCertificate{}
+	err := strg.ClientCertificates().Find(bson.M{"service": service.Subdomain}).All(&certs)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return certs, err
+}
+
+func (m *Mongore) UpsertInvitation(inv account.Invitation) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.Invitations().Upsert(bson.M{"id": inv.Id}, inv)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeleteInvitation(inv account.Invitation) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	err := strg.Invitations().Remove(bson.M{"id": inv.Id})
+
+	if err == mgo.ErrNotFound {
+		return errors.NewNotFoundError(errors.ErrInvitationNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeleteInvitationsByTeam(team account.Team) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.Invitations().RemoveAll(bson.M{"team": team.Alias})
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) FindInvitationById(id string) (account.Invitation, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	var inv account.Invitation
+	err := strg.Invitations().Find(bson.M{"id": id}).One(&inv)
+
+	if err == mgo.ErrNotFound {
+		return account.Invitation{}, errors.NewNotFoundError(errors.ErrInvitationNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return inv, err
+}
+
+func (m *Mongore) FindInvitationsByTeam(team account.Team) ([]account.Invitation, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	invitations := []account.Invitation{}
+	err := strg.Invitations().Find(bson.M{"team": team.Alias}).All(&invitations)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return invitations, err
+}
+
+func (m *Mongore) FindInvitationsByEmail(email string) ([]account.Invitation, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	invitations := []account.Invitation{}
+	err := strg.Invitations().Find(bson.M{"email": email}).All(&invitations)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return invitations, err
+}
+
+func (m *Mongore) UpsertDomain(d account.Domain) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.Domains().Upsert(bson.M{"name": d.Name, "team": d.Team}, d)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeleteDomain(d account.Domain) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	err := strg.Domains().Remove(bson.M{"name": d.Name, "team": d.Team})
+
+	if err == mgo.ErrNotFound {
+		return errors.NewNotFoundError(errors.ErrDomainNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) DeleteDomainsByTeam(team account.Team) error {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	_, err := strg.Domains().RemoveAll(bson.M{"team": team.Alias})
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return err
+}
+
+func (m *Mongore) FindDomainByNameAndTeam(name string, team string) (account.Domain, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	var d account.Domain
+	err := strg.Domains().Find(bson.M{"name": name, "team": team}).One(&d)
+
+	if err == mgo.ErrNotFound {
+		return account.Domain{}, errors.NewNotFoundError(errors.ErrDomainNotFound)
+	}
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return d, err
+}
+
+func (m *Mongore) FindDomainsByName(name string) ([]account.Domain, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	domains := []account.Domain{}
+	err := strg.Domains().Find(bson.M{"name": name}).All(&domains)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return domains, err
+}
+
+func (m *Mongore) FindDomainsByTeam(team account.Team) ([]account.Domain, error) {
+	var strg Storage
+	strg.Storage = m.openSession()
+	defer strg.Close()
+
+	domains := []account.Domain{}
+	err := strg.Domains().Find(bson.M{"team": team.Alias}).All(&domains)
+
+	if err != nil {
+		Logger.Warn(err.Error())
+	}
+
+	return domains, err
 }
 
 func (m *Mongore) UpsertHook(w account.Hook) error {
@@ -0,0 +1,151 @@
+package account
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"time"
+
+	. "github.com/apihub/apihub/log"
+	"github.com/apihub/apihub/util"
+)
+
+const (
+	GRANT_EXPIRES_IN       = 10 * time.Minute
+	OAUTH_TOKEN_EXPIRES_IN = time.Hour
+	OAUTH_TOKEN_TYPE       = "Bearer"
+	CODE_CHALLENGE_PLAIN   = "plain"
+	CODE_CHALLENGE_S256    = "S256"
+)
+
+// Grant is an authorization code issued to an App on behalf of a user.
+// It is exchanged for an OAuthToken only once, before it expires.
+// The code challenge is kept when the App uses PKCE (RFC 7636).
+type Grant struct {
+	Code                string `json:"code"`
+	ClientId            string `json:"client_id"`
+	User                string `json:"user"`
+	RedirectUri         string `json:"redirect_uri,omitempty"`
+	Scope               string `json:"scope,omitempty"`
+	CodeChallenge       string `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string `json:"code_challenge_method,omitempty"`
+	ExpiresAt           string `json:"expires_at"`
+}
+
+func (g *Grant) Create() error {
+	g.Code = util.GenerateRandomStr(32)
+	g.ExpiresAt = time.Now().In(time.UTC).Add(GRANT_EXPIRES_IN).Format(time.RFC3339)
+
+	err := store.UpsertGrant(*g)
+	Logger.Info("grant.Create: client `%s`, user `%s`. Err: %s.", g.ClientId, g.User, err)
+	return err
+}
+
+func (g Grant) Delete() error {
+	err := store.DeleteGrant(g)
+	Logger.Info("grant.Delete: client `%s`, user `%s`. Err: %s.", g.ClientId, g.User, err)
+	return err
+}
+
+func (g Grant) Expired() bool {
+	return expired(g.ExpiresAt)
+}
+
+// VerifyCodeVerifier checks the verifier sent by the App against the code challenge of the grant.
+func (g Grant) VerifyCodeVerifier(verifier string) bool {
+	if g.CodeChallenge == "" || verifier == "" {
+		return false
+	}
+
+	challenge := verifier
+	if g.CodeChallengeMethod == CODE_CHALLENGE_S256 {
+		sum := sha256.Sum256([]byte(verifier))
+		challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(g.CodeChallenge)) == 1
+}
+
+func FindGrantByCode(code string) (*Grant, error) {
+	grant, err := store.FindGrantByCode(code)
+	if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// OAuthToken is an access token issued to an App. The User is empty when the token
+// is issued through the client credentials grant, since the App acts on its own behalf.
+type OAuthToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ClientId     string `json:"client_id"`
+	User         string `json:"user,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	CreatedAt    string `json:"created_at"`
+	ExpiresAt    string `json:"expires_at"`
+	// Public is set when the token was issued to a client which has not sent its secret, using PKCE,
+	// so its refresh token may be redeemed without the secret as well.
+	Public bool `json:"public,omitempty"`
+}
+
+func (t *OAuthToken) Create(withRefreshToken bool) error {
+	t.AccessToken = util.GenerateRandomStr(32)
+	if withRefreshToken {
+		t.RefreshToken = util.GenerateRandomStr(32)
+	}
+	now := time.Now().In(time.UTC)
+	t.CreatedAt = now.Format(time.RFC3339)
+	t.ExpiresAt = now.Add(OAUTH_TOKEN_EXPIRES_IN).Format(time.RFC3339)
+
+	err := store.UpsertOAuthToken(*t)
+	Logger.Info("oauthToken.Create: client `%s`, user `%s`. Err: %s.", t.ClientId, t.User, err)
+	return err
+}
+
+func (t OAuthToken) Delete() error {
+	err := store.DeleteOAuthToken(t)
+	Logger.Info("oauthToken.Delete: client `%s`, user `%s`. Err: %s.", t.ClientId, t.User, err)
+	return err
+}
+
+func (t OAuthToken) Expired() bool {
+	return expired(t.ExpiresAt)
+}
+
+// ExpiresIn returns the number of seconds until the token expires.
+func (t OAuthToken) ExpiresIn() int {
+	expiresAt, err := time.Parse(time.RFC3339, t.ExpiresAt)
+	if err != nil {
+		return 0
+	}
+	return int(expiresAt.Sub(time.Now()).Seconds())
+}
+
+func FindOAuthTokenByAccessToken(accessToken string) (*OAuthToken, error) {
+	token, err := store.FindOAuthTokenByAccessToken(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func FindOAuthTokenByRefreshToken(refreshToken string) (*OAuthToken, error) {
+	token, err := store.FindOAuthTokenByRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ValidCodeChallengeMethod reports whether the PKCE method is supported.
+// An empty method defaults to plain, as defined by RFC 7636.
+func ValidCodeChallengeMethod(method string) bool {
+	return method == "" || method == CODE_CHALLENGE_PLAIN || method == CODE_CHALLENGE_S256
+}
+
+// expired reports whether the given time, formatted as RFC 3339, is in the past.
+// Invalid times are considered expired.
+func expired(expiresAt string) bool {
+	t, err := time.Parse(time.RFC3339, expiresAt)
+	return err != nil || time.Now().After(t)
+}
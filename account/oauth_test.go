@@ -0,0 +1,96 @@
+package account_test
+
+import (
+	"github.com/apihub/apihub/account"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestCreateGrant(c *C) {
+	grant := account.Grant{ClientId: app.ClientId, User: alice.Email}
+	err := grant.Create()
+	c.Assert(err, IsNil)
+	defer grant.Delete()
+
+	c.Assert(grant.Code, Not(Equals), "")
+	c.Assert(grant.Expired(), Equals, false)
+
+	g, err := account.FindGrantByCode(grant.Code)
+	c.Assert(err, IsNil)
+	c.Assert(*g, DeepEquals, grant)
+}
+
+func (s *S) TestGrantExpired(c *C) {
+	grant := account.Grant{ExpiresAt: "2015-05-16T13:40:44Z"}
+	c.Assert(grant.Expired(), Equals, true)
+	grant.ExpiresAt = ""
+	c.Assert(grant.Expired(), Equals, true)
+}
+
+func (s *S) TestGrantVerifyCodeVerifierWithPlainMethod(c *C) {
+	grant := account.Grant{CodeChallenge: "verifier", CodeChallengeMethod: account.CODE_CHALLENGE_PLAIN}
+	c.Assert(grant.VerifyCodeVerifier("verifier"), Equals, true)
+	c.Assert(grant.VerifyCodeVerifier("another"), Equals, false)
+}
+
+func (s *S) TestGrantVerifyCodeVerifierWithS256Method(c *C) {
+	// Example from RFC 7636, Appendix B.
+	grant := account.Grant{CodeChallenge: "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", CodeChallengeMethod: account.CODE_CHALLENGE_S256}
+	c.Assert(grant.VerifyCodeVerifier("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"), Equals, true)
+	c.Assert(grant.VerifyCodeVerifier("E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"), Equals, false)
+}
+
+func (s *S) TestGrantVerifyCodeVerifierWithoutChallenge(c *C) {
+	grant := account.Grant{}
+	c.Assert(grant.VerifyCodeVerifier(""), Equals, false)
+}
+
+func (s *S) TestCreateOAuthToken(c *C) {
+	token := account.OAuthToken{ClientId: app.ClientId, User: alice.Email}
+	err := token.Create(true)
+	c.Assert(err, IsNil)
+	defer token.Delete()
+
+	c.Assert(token.AccessToken, Not(Equals), "")
+	c.Assert(token.RefreshToken, Not(Equals), "")
+	c.Assert(token.Expired(), Equals, false)
+	c.Assert(token.ExpiresIn() > 3500, Equals, true)
+
+	t, err := account.FindOAuthTokenByRefreshToken(token.RefreshToken)
+	c.Assert(err, IsNil)
+	c.Assert(*t, DeepEquals, token)
+}
+
+func (s *S) TestCreateOAuthTokenWithoutRefreshToken(c *C) {
+	token := account.OAuthToken{ClientId: app.ClientId}
+	err := token.Create(false)
+	c.Assert(err, IsNil)
+	defer token.Delete()
+
+	c.Assert(token.RefreshToken, Equals, "")
+	t, err := account.FindOAuthTokenByAccessToken(token.AccessToken)
+	c.Assert(err, IsNil)
+	c.Assert(*t, DeepEquals, token)
+}
+
+func (s *S) TestAppAuthenticate(c *C) {
+	c.Assert(app.Authenticate("secret"), Equals, true)
+	c.Assert(app.Authenticate("another"), Equals, false)
+	c.Assert(app.Authenticate(""), Equals, false)
+}
+
+func (s *S) TestAppRedirectUri(c *C) {
+	uri, ok := app.RedirectUri("")
+	c.Assert(ok, Equals, true)
+	c.Assert(uri, Equals, "http://www.example.org/auth")
+
+	uri, ok = app.RedirectUri("http://www.example.org/auth")
+	c.Assert(ok, Equals, true)
+	c.Assert(uri, Equals, "http://www.example.org/auth")
+
+	_, ok = app.RedirectUri("http://www.example.org/another")
+	c.Assert(ok, Equals, false)
+
+	app.RedirectUris = append(app.RedirectUris, "http://www.example.org/another")
+	_, ok = app.RedirectUri("")
+	c.Assert(ok, Equals, false)
+}
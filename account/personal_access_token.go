@@ -0,0 +1,159 @@
+package account
+
+import (
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/apihub/apihub/errors"
+	. "github.com/apihub/apihub/log"
+	"github.com/apihub/apihub/util"
+)
+
+const (
+	PERSONAL_ACCESS_TOKEN_TYPE               = "PersonalToken"
+	PERSONAL_ACCESS_TOKEN_LAST_USED_INTERVAL = time.Minute
+
+	SCOPE_TEAMS_READ     = "teams:read"
+	SCOPE_TEAMS_WRITE    = "teams:write"
+	SCOPE_SERVICES_READ  = "services:read"
+	SCOPE_SERVICES_WRITE = "services:write"
+	SCOPE_APPS_READ      = "apps:read"
+	SCOPE_APPS_WRITE     = "apps:write"
+	SCOPE_HOOKS_WRITE    = "hooks:write"
+)
+
+var scopes = map[string]bool{
+	SCOPE_TEAMS_READ: true, SCOPE_TEAMS_WRITE: true,
+	SCOPE_SERVICES_READ: true, SCOPE_SERVICES_WRITE: true,
+	SCOPE_APPS_READ: true, SCOPE_APPS_WRITE: true,
+	SCOPE_HOOKS_WRITE: true,
+}
+
+// PersonalAccessToken is a long-lived token of a user, used by scripts and deploy pipelines instead of
+// logging in with the credentials of the user. It only allows the operations covered by its scopes,
+// and it expires only when ExpiresAt is set.
+//
+// The token is in the format `Id.Secret`, and only the hash of the secret is stored,
+// so the Token is only known when it is created.
+type PersonalAccessToken struct {
+	Id         string   `json:"id"`
+	Name       string   `json:"name"`
+	User       string   `json:"-"`
+	Scopes     []string `json:"scopes"`
+	Token      string   `json:"token,omitempty" bson:"-"`
+	Hash       string   `json:"-"`
+	CreatedAt  string   `json:"created_at"`
+	ExpiresAt  string   `json:"expires_at,omitempty"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+	Type       string   `json:"token_type"`
+}
+
+func (t *PersonalAccessToken) Create(user User) error {
+	if err := t.valid(); err != nil {
+		Logger.Info("Failed to create a personal access token with invalid data: %+v.", t.Name)
+		return err
+	}
+
+	secret := util.GenerateRandomStr(32)
+	t.Id = strings.TrimRight(util.GenerateRandomStr(12), "=")
+	t.User = user.Email
+	t.Token = t.Id + "." + secret
+	t.Hash = hashSecret(secret)
+	t.CreatedAt = time.Now().In(time.UTC).Format(time.RFC3339)
+	t.LastUsedAt = ""
+	t.Type = PERSONAL_ACCESS_TOKEN_TYPE
+
+	err := t.save()
+	Logger.Info("personalAccessToken.Create: `%s` for user `%s`. Err: %s.", t.Id, t.User, err)
+	return err
+}
+
+func (t PersonalAccessToken) Delete() error {
+	err := store.DeletePersonalAccessToken(t)
+	Logger.Info("personalAccessToken.Delete: `%s` for user `%s`. Err: %s.", t.Id, t.User, err)
+	return err
+}
+
+func (t PersonalAccessToken) Expired() bool {
+	return t.ExpiresAt != "" && expired(t.ExpiresAt)
+}
+
+// Allows reports whether the token has the given scope. The write scope of a resource includes the read one.
+func (t PersonalAccessToken) Allows(scope string) bool {
+	if contains(t.Scopes, scope) {
+		return true
+	}
+	if strings.HasSuffix(scope, ":read") {
+		return contains(t.Scopes, strings.TrimSuffix(scope, ":read")+":write")
+	}
+	return false
+}
+
+// save stores the token without the plain secret.
+func (t PersonalAccessToken) save() error {
+	t.Token = ""
+	return store.UpsertPersonalAccessToken(t)
+}
+
+func (t *PersonalAccessToken) valid() error {
+	if t.Name == "" || len(t.Scopes) == 0 {
+		return errors.NewValidationError(errors.ErrPersonalAccessTokenInvalidFields)
+	}
+	for i, scope := range t.Scopes {
+		t.Scopes[i] = strings.ToLower(scope)
+		if !scopes[t.Scopes[i]] {
+			return errors.NewValidationError(errors.ErrPersonalAccessTokenInvalidFields)
+		}
+	}
+	if t.ExpiresAt != "" {
+		if _, err := time.Parse(time.RFC3339, t.ExpiresAt); err != nil || t.Expired() {
+			return errors.NewValidationError(errors.ErrPersonalAccessTokenInvalidFields)
+		}
+	}
+
+	return nil
+}
+
+// AuthenticatePersonalAccessToken finds the token which matches the given `Id.Secret`, as long as it has not expired.
+// The time it was last used is updated at most once a minute, without writing the rest of the token,
+// so a token revoked meanwhile is not restored.
+func AuthenticatePersonalAccessToken(token string) (*PersonalAccessToken, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return nil, errors.ErrTokenNotFound
+	}
+
+	t, err := store.FindPersonalAccessTokenById(parts[0])
+	if err != nil {
+		return nil, errors.ErrTokenNotFound
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(parts[1])), []byte(t.Hash)) != 1 || t.Expired() {
+		return nil, errors.ErrTokenNotFound
+	}
+
+	now := time.Now().In(time.UTC)
+	if lastUsed, err := time.Parse(time.RFC3339, t.LastUsedAt); err != nil || now.Sub(lastUsed) >= PERSONAL_ACCESS_TOKEN_LAST_USED_INTERVAL {
+		t.LastUsedAt = now.Format(time.RFC3339)
+		if err := store.UpdatePersonalAccessTokenLastUsedAt(t); err != nil {
+			Logger.Warn("Failed to update the last use of personal access token `%s`: %+v.", t.Id, err)
+		}
+	}
+	return &t, nil
+}
+
+// FindPersonalAccessTokenByIdAndUser returns the token, as long as it belongs to the user.
+func FindPersonalAccessTokenByIdAndUser(id string, user User) (*PersonalAccessToken, error) {
+	t, err := store.FindPersonalAccessTokenById(id)
+	if err != nil {
+		return nil, err
+	}
+	if t.User != user.Email {
+		return nil, errors.NewNotFoundError(errors.ErrPersonalAccessTokenNotFound)
+	}
+	return &t, nil
+}
+
+func FindPersonalAccessTokensByUser(user User) ([]PersonalAccessToken, error) {
+	return store.FindPersonalAccessTokensByUser(user.Email)
+}
@@ -0,0 +1,98 @@
+package account_test
+
+import (
+	"strings"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestCreatePersonalAccessToken(c *C) {
+	token := account.PersonalAccessToken{Name: "deploy", Scopes: []string{"Services:Write"}}
+	err := token.Create(owner)
+	c.Assert(err, IsNil)
+	defer token.Delete()
+
+	c.Assert(token.User, Equals, owner.Email)
+	c.Assert(token.Scopes, DeepEquals, []string{account.SCOPE_SERVICES_WRITE})
+	c.Assert(token.Type, Equals, account.PERSONAL_ACCESS_TOKEN_TYPE)
+	c.Assert(strings.HasPrefix(token.Token, token.Id+"."), Equals, true)
+
+	// The plain token is not stored.
+	stored, err := account.FindPersonalAccessTokenByIdAndUser(token.Id, owner)
+	c.Assert(err, IsNil)
+	c.Assert(stored.Token, Equals, "")
+	c.Assert(stored.Hash, Not(Equals), "")
+	c.Assert(strings.Contains(token.Token, stored.Hash), Equals, false)
+}
+
+func (s *S) TestCreatePersonalAccessTokenWithInvalidFields(c *C) {
+	for _, token := range []account.PersonalAccessToken{
+		account.PersonalAccessToken{},
+		account.PersonalAccessToken{Name: "deploy"},
+		account.PersonalAccessToken{Name: "deploy", Scopes: []string{"users:write"}},
+		account.PersonalAccessToken{Name: "deploy", Scopes: []string{account.SCOPE_TEAMS_READ}, ExpiresAt: "tomorrow"},
+		account.PersonalAccessToken{Name: "deploy", Scopes: []string{account.SCOPE_TEAMS_READ}, ExpiresAt: time.Now().Add(-time.Minute).In(time.UTC).Format(time.RFC3339)},
+	} {
+		err := token.Create(owner)
+		e, ok := err.(errors.ValidationError)
+		c.Assert(ok, Equals, true)
+		c.Assert(e.Error(), Equals, errors.ErrPersonalAccessTokenInvalidFields.Error())
+	}
+}
+
+func (s *S) TestPersonalAccessTokenAllows(c *C) {
+	token := account.PersonalAccessToken{Scopes: []string{account.SCOPE_SERVICES_WRITE, account.SCOPE_TEAMS_READ}}
+	c.Assert(token.Allows(account.SCOPE_SERVICES_WRITE), Equals, true)
+	c.Assert(token.Allows(account.SCOPE_SERVICES_READ), Equals, true)
+	c.Assert(token.Allows(account.SCOPE_TEAMS_READ), Equals, true)
+	c.Assert(token.Allows(account.SCOPE_TEAMS_WRITE), Equals, false)
+	c.Assert(token.Allows(account.SCOPE_APPS_READ), Equals, false)
+	c.Assert(token.Allows(""), Equals, false)
+}
+
+func (s *S) TestAuthenticatePersonalAccessToken(c *C) {
+	token := account.PersonalAccessToken{Name: "deploy", Scopes: []string{account.SCOPE_TEAMS_READ}}
+	token.Create(owner)
+	defer token.Delete()
+
+	authenticated, err := account.AuthenticatePersonalAccessToken(token.Token)
+	c.Assert(err, IsNil)
+	c.Assert(authenticated.Id, Equals, token.Id)
+	c.Assert(authenticated.LastUsedAt, Not(Equals), "")
+
+	_, err = account.AuthenticatePersonalAccessToken(token.Id + ".wrong")
+	c.Assert(err, Equals, errors.ErrTokenNotFound)
+	_, err = account.AuthenticatePersonalAccessToken("wrong")
+	c.Assert(err, Equals, errors.ErrTokenNotFound)
+}
+
+func (s *S) TestPersonalAccessTokenExpired(c *C) {
+	c.Assert(account.PersonalAccessToken{}.Expired(), Equals, false)
+	c.Assert(account.PersonalAccessToken{ExpiresAt: time.Now().Add(time.Minute).In(time.UTC).Format(time.RFC3339)}.Expired(), Equals, false)
+	c.Assert(account.PersonalAccessToken{ExpiresAt: time.Now().Add(-time.Minute).In(time.UTC).Format(time.RFC3339)}.Expired(), Equals, true)
+}
+
+func (s *S) TestFindPersonalAccessTokenOfAnotherUser(c *C) {
+	token := account.PersonalAccessToken{Name: "deploy", Scopes: []string{account.SCOPE_TEAMS_READ}}
+	token.Create(owner)
+	defer token.Delete()
+
+	_, err := account.FindPersonalAccessTokenByIdAndUser(token.Id, alice)
+	e, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Error(), Equals, errors.ErrPersonalAccessTokenNotFound.Error())
+}
+
+func (s *S) TestDeleteUserDeletesThePersonalAccessTokens(c *C) {
+	alice.Create()
+	token := account.PersonalAccessToken{Name: "deploy", Scopes: []string{account.SCOPE_TEAMS_READ}}
+	token.Create(alice)
+	alice.Delete()
+
+	tokens, err := account.FindPersonalAccessTokensByUser(alice)
+	c.Assert(err, IsNil)
+	c.Assert(tokens, HasLen, 0)
+}
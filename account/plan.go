@@ -0,0 +1,94 @@
+package account
+
+import (
+	"strings"
+
+	"github.com/apihub/apihub/errors"
+	. "github.com/apihub/apihub/log"
+)
+
+// Plan is a tier of a Service, which the Apps are assigned to through their subscriptions.
+//
+// The quotas limit the number of requests per calendar day and month (in UTC), while `RateLimit`
+// limits the number of requests per minute. Empty Methods and Paths mean that the App is allowed
+// to send requests with any method to any path; otherwise `Paths` are the path prefixes allowed.
+type Plan struct {
+	Name          string   `json:"name"`
+	Service       string   `json:"service"`
+	Description   string   `json:"description,omitempty"`
+	QuotaPerDay   int      `json:"quota_per_day,omitempty"`
+	QuotaPerMonth int      `json:"quota_per_month,omitempty"`
+	RateLimit     int      `json:"rate_limit,omitempty"`
+	Methods       []string `json:"methods,omitempty"`
+	Paths         []string `json:"paths,omitempty"`
+}
+
+func (p *Plan) Save(service Service) error {
+	p.Service = service.Subdomain
+
+	if err := p.valid(); err != nil {
+		Logger.Info("Failed to save a plan with invalid data: %+v.", p)
+		return err
+	}
+
+	err := store.UpsertPlan(*p)
+	Logger.Info("plan.Save: %+v. Err: %s.", p, err)
+	return err
+}
+
+func (p Plan) Delete() error {
+	err := store.DeletePlan(p)
+	Logger.Info("plan.Delete: %+v. Err: %s.", p, err)
+	return err
+}
+
+// Allows reports whether the plan allows the request with the given method to the given path.
+func (p Plan) Allows(method, path string) bool {
+	if len(p.Methods) > 0 && !contains(p.Methods, strings.ToUpper(method)) {
+		return false
+	}
+	if len(p.Paths) == 0 {
+		return true
+	}
+	for _, prefix := range p.Paths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Plan) valid() error {
+	if p.Name == "" || p.Service == "" {
+		return errors.NewValidationError(errors.ErrPlanMissingRequiredFields)
+	}
+	if p.QuotaPerDay < 0 || p.QuotaPerMonth < 0 || p.RateLimit < 0 {
+		return errors.NewValidationError(errors.ErrPlanInvalidFields)
+	}
+	for i, method := range p.Methods {
+		p.Methods[i] = strings.ToUpper(method)
+		if !httpMethods[p.Methods[i]] {
+			return errors.NewValidationError(errors.ErrPlanInvalidFields)
+		}
+	}
+	for _, path := range p.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return errors.NewValidationError(errors.ErrPlanInvalidFields)
+		}
+	}
+
+	return nil
+}
+
+func FindPlanByNameAndService(name string, service Service) (*Plan, error) {
+	plan, err := store.FindPlanByNameAndService(name, service)
+	if err != nil {
+		return nil, err
+	}
+
+	return &plan, nil
+}
+
+func FindPlansByService(service Service) ([]Plan, error) {
+	return store.FindPlansByService(service)
+}
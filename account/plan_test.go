@@ -0,0 +1,68 @@
+package account_test
+
+import (
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestSavePlan(c *C) {
+	plan := account.Plan{Name: "gold", QuotaPerDay: 1000, Methods: []string{"get"}, Paths: []string{"/v1"}}
+	err := plan.Save(service)
+	c.Assert(err, IsNil)
+	defer plan.Delete()
+
+	c.Assert(plan.Service, Equals, service.Subdomain)
+	c.Assert(plan.Methods, DeepEquals, []string{"GET"})
+}
+
+func (s *S) TestSavePlanWithInvalidFields(c *C) {
+	for _, plan := range []account.Plan{
+		account.Plan{},
+		account.Plan{Name: "gold", QuotaPerDay: -1},
+		account.Plan{Name: "gold", Methods: []string{"FETCH"}},
+		account.Plan{Name: "gold", Paths: []string{"v1"}},
+	} {
+		err := plan.Save(service)
+		_, ok := err.(errors.ValidationError)
+		c.Assert(ok, Equals, true)
+	}
+}
+
+func (s *S) TestFindPlanByNameAndService(c *C) {
+	plan := account.Plan{Name: "gold", QuotaPerMonth: 10000}
+	plan.Save(service)
+	defer plan.Delete()
+
+	p, err := account.FindPlanByNameAndService("gold", service)
+	c.Assert(err, IsNil)
+	c.Assert(*p, DeepEquals, plan)
+}
+
+func (s *S) TestFindPlanByNameAndServiceNotFound(c *C) {
+	p, err := account.FindPlanByNameAndService("not-found", service)
+	c.Check(p, IsNil)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestFindPlansByService(c *C) {
+	plan := account.Plan{Name: "gold"}
+	plan.Save(service)
+	defer plan.Delete()
+
+	plans, err := account.FindPlansByService(service)
+	c.Assert(err, IsNil)
+	c.Assert(plans, DeepEquals, []account.Plan{plan})
+}
+
+func (s *S) TestPlanAllows(c *C) {
+	plan := account.Plan{}
+	c.Assert(plan.Allows("DELETE", "/"), Equals, true)
+
+	plan = account.Plan{Methods: []string{"GET"}, Paths: []string{"/v1", "/status"}}
+	c.Assert(plan.Allows("get", "/v1/users"), Equals, true)
+	c.Assert(plan.Allows("GET", "/status"), Equals, true)
+	c.Assert(plan.Allows("POST", "/v1/users"), Equals, false)
+	c.Assert(plan.Allows("GET", "/v2/users"), Equals, false)
+}
@@ -1,6 +1,9 @@
 package account
 
 import (
+	"encoding/json"
+	"fmt"
+
 	"github.com/apihub/apihub/errors"
 	. "github.com/apihub/apihub/log"
 )
@@ -20,12 +23,18 @@ func (pc *Plugin) Save(service Service) error {
 	}
 
 	err := store.UpsertPlugin(*pc)
+	if err == nil {
+		go publishPlugin(pc)
+	}
 	Logger.Info("plugin.Save: %+v. Err: %s.", pc, err)
 	return err
 }
 
 func (pc Plugin) Delete() error {
 	err := store.DeletePlugin(pc)
+	if err == nil {
+		go publishPlugin(&pc)
+	}
 	Logger.Info("plugin.Delete: %+v. Err: %s.", pc, err)
 	return err
 }
@@ -46,3 +55,25 @@ func FindPluginByNameAndService(pluginName string, service Service) (*Plugin, er
 
 	return &plugin, nil
 }
+
+func FindPluginsByService(service Service) ([]Plugin, error) {
+	plugins, err := store.FindPluginsByService(service)
+	if err != nil {
+		return nil, err
+	}
+
+	return plugins, nil
+}
+
+func (pc *Plugin) asJson() []byte {
+	j, _ := json.Marshal(pc)
+	return j
+}
+
+// publishPlugin notifies the gateways that the plugins of a service have changed,
+// so they can rebuild the middleware chain for it.
+func publishPlugin(pc *Plugin) {
+	name := fmt.Sprintf("/plugins/%s/%s", pc.Service, pc.Name)
+	pubsub.Publish(name, pc.asJson())
+	Logger.Info("The following plugin has been published: %s (name) -> %s (service).", pc.Name, pc.Service)
+}
@@ -34,3 +34,13 @@ func (s *S) TestFindPluginByNameAndServiceNotFound(c *C) {
 	_, ok := err.(errors.NotFoundError)
 	c.Assert(ok, Equals, true)
 }
+
+func (s *S) TestFindPluginsByService(c *C) {
+	err := pluginConfig.Save(service)
+	c.Assert(err, IsNil)
+	defer pluginConfig.Delete()
+
+	plugins, err := account.FindPluginsByService(service)
+	c.Check(err, IsNil)
+	c.Assert(plugins, DeepEquals, []account.Plugin{pluginConfig})
+}
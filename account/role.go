@@ -0,0 +1,172 @@
+package account
+
+import (
+	"github.com/apihub/apihub/errors"
+	. "github.com/apihub/apihub/log"
+)
+
+// Roles of the members of a team.
+//
+// The owner is the only member with the `owner` role, which is handed over through TransferOwnership.
+// Members without a role are developers.
+const (
+	ROLE_OWNER     = "owner"
+	ROLE_ADMIN     = "admin"
+	ROLE_DEVELOPER = "developer"
+	ROLE_VIEWER    = "viewer"
+)
+
+// Permission is an operation which the members of a team may perform, depending on their role.
+type Permission string
+
+const (
+	// PERMISSION_READ allows to see the team, along with its services, apps and their settings.
+	PERMISSION_READ Permission = "read"
+	// PERMISSION_WRITE allows to create and update services, apps, plugins, plans, certificates, keys, hooks and domains.
+	PERMISSION_WRITE Permission = "write"
+	// PERMISSION_DELETE allows to delete services, apps, plans, certificates, hooks and domains.
+	PERMISSION_DELETE Permission = "delete"
+	// PERMISSION_MANAGE_MEMBERS allows to update the team, add and remove members and change their roles.
+	PERMISSION_MANAGE_MEMBERS Permission = "manage_members"
+	// PERMISSION_DELETE_TEAM allows to delete the team.
+	PERMISSION_DELETE_TEAM Permission = "delete_team"
+	// PERMISSION_TRANSFER_OWNERSHIP allows to hand the team over to another member.
+	PERMISSION_TRANSFER_OWNERSHIP Permission = "transfer_ownership"
+)
+
+// rolePermissions is the permission matrix of the roles.
+var rolePermissions = map[string][]Permission{
+	ROLE_OWNER:     {PERMISSION_READ, PERMISSION_WRITE, PERMISSION_DELETE, PERMISSION_MANAGE_MEMBERS, PERMISSION_DELETE_TEAM, PERMISSION_TRANSFER_OWNERSHIP},
+	ROLE_ADMIN:     {PERMISSION_READ, PERMISSION_WRITE, PERMISSION_DELETE, PERMISSION_MANAGE_MEMBERS},
+	ROLE_DEVELOPER: {PERMISSION_READ, PERMISSION_WRITE},
+	ROLE_VIEWER:    {PERMISSION_READ},
+}
+
+// MemberRole is the role of a member of a team.
+type MemberRole struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// RoleAllows reports whether the role has the permission.
+func RoleAllows(role string, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleOf returns the role of the user in the team, or an empty string if the user is not a member.
+func (team Team) RoleOf(email string) string {
+	if email == team.Owner {
+		return ROLE_OWNER
+	}
+	if _, err := team.ContainsUser(&User{Email: email}); err != nil {
+		return ""
+	}
+	for _, r := range team.Roles {
+		if r.Email == email {
+			return r.Role
+		}
+	}
+	return ROLE_DEVELOPER
+}
+
+// Authorize checks that the role of the user in the team has the permission.
+func (team Team) Authorize(user User, permission Permission) error {
+	role := team.RoleOf(user.Email)
+	if RoleAllows(role, permission) {
+		return nil
+	}
+
+	switch {
+	case !RoleAllows(ROLE_ADMIN, permission):
+		return errors.NewForbiddenError(errors.ErrOnlyOwnerHasPermission)
+	case role == "":
+		return errors.NewForbiddenError(errors.ErrUserNotInTeam)
+	default:
+		Logger.Info("The role `%s` of %s does not allow to %s on team %s.", role, user.Email, permission, team.Alias)
+		return errors.NewForbiddenError(errors.ErrRoleNotAllowed)
+	}
+}
+
+// authorizeOnTeam checks the permission of the user on the team of a service or an app.
+// When the team no longer exists, only the user who created the resource is allowed.
+func authorizeOnTeam(alias, creator string, user User, permission Permission) error {
+	team, err := store.FindTeamByAlias(alias)
+	if err != nil {
+		if creator != user.Email {
+			return errors.NewForbiddenError(errors.ErrOnlyOwnerHasPermission)
+		}
+		return nil
+	}
+	return team.Authorize(user, permission)
+}
+
+// ChangeRole sets the role of a member of the team, other than the owner.
+// The member keeps the role until it is removed from the team.
+func (team *Team) ChangeRole(email, role string, by User) error {
+	if err := team.Authorize(by, PERMISSION_MANAGE_MEMBERS); err != nil {
+		return err
+	}
+	if role != ROLE_ADMIN && role != ROLE_DEVELOPER && role != ROLE_VIEWER {
+		return errors.NewValidationError(errors.ErrTeamInvalidRole)
+	}
+	switch team.RoleOf(email) {
+	case "":
+		return errors.NewValidationError(errors.ErrTeamMemberNotFound)
+	case ROLE_OWNER:
+		return errors.NewValidationError(errors.ErrTeamOwnerRole)
+	}
+
+	team.setRole(email, role)
+	err := store.UpsertTeam(*team)
+	Logger.Info("team.ChangeRole: %s (user) -> %s (role) on team %s. Err: %s.", email, role, team.Alias, err)
+	return err
+}
+
+// TransferOwnership hands the team over to another member, so the team is not stuck when the owner leaves.
+// The previous owner stays in the team as an admin.
+func (team *Team) TransferOwnership(email string, by User) error {
+	if err := team.Authorize(by, PERMISSION_TRANSFER_OWNERSHIP); err != nil {
+		return err
+	}
+	if role := team.RoleOf(email); role == "" {
+		return errors.NewValidationError(errors.ErrTeamMemberNotFound)
+	} else if role == ROLE_OWNER {
+		return nil
+	}
+
+	previous := team.Owner
+	team.Owner = email
+	team.removeRole(email)
+	team.setRole(previous, ROLE_ADMIN)
+	err := store.UpsertTeam(*team)
+	Logger.Info("team.TransferOwnership: %s -> %s on team %s. Err: %s.", previous, email, team.Alias, err)
+	return err
+}
+
+func (team *Team) setRole(email, role string) {
+	for i, r := range team.Roles {
+		if r.Email == email {
+			team.Roles[i].Role = role
+			return
+		}
+	}
+	team.Roles = append(team.Roles, MemberRole{Email: email, Role: role})
+}
+
+func (team *Team) removeRole(email string) {
+	roles := []MemberRole{}
+	for _, r := range team.Roles {
+		if r.Email != email {
+			roles = append(roles, r)
+		}
+	}
+	if len(roles) == 0 {
+		roles = nil
+	}
+	team.Roles = roles
+}
@@ -0,0 +1,163 @@
+package account_test
+
+import (
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestRoleOf(c *C) {
+	team = account.Team{Owner: owner.Email, Users: []string{owner.Email, alice.Email, "bob@example.org"}, Roles: []account.MemberRole{{Email: alice.Email, Role: account.ROLE_VIEWER}}}
+	c.Assert(team.RoleOf(owner.Email), Equals, account.ROLE_OWNER)
+	c.Assert(team.RoleOf(alice.Email), Equals, account.ROLE_VIEWER)
+	c.Assert(team.RoleOf("bob@example.org"), Equals, account.ROLE_DEVELOPER)
+	c.Assert(team.RoleOf("mary@example.org"), Equals, "")
+}
+
+func (s *S) TestRoleAllows(c *C) {
+	c.Assert(account.RoleAllows(account.ROLE_OWNER, account.PERMISSION_TRANSFER_OWNERSHIP), Equals, true)
+	c.Assert(account.RoleAllows(account.ROLE_ADMIN, account.PERMISSION_MANAGE_MEMBERS), Equals, true)
+	c.Assert(account.RoleAllows(account.ROLE_ADMIN, account.PERMISSION_DELETE_TEAM), Equals, false)
+	c.Assert(account.RoleAllows(account.ROLE_DEVELOPER, account.PERMISSION_WRITE), Equals, true)
+	c.Assert(account.RoleAllows(account.ROLE_DEVELOPER, account.PERMISSION_DELETE), Equals, false)
+	c.Assert(account.RoleAllows(account.ROLE_VIEWER, account.PERMISSION_READ), Equals, true)
+	c.Assert(account.RoleAllows(account.ROLE_VIEWER, account.PERMISSION_WRITE), Equals, false)
+	c.Assert(account.RoleAllows("", account.PERMISSION_READ), Equals, false)
+}
+
+func (s *S) TestAuthorize(c *C) {
+	team = account.Team{Owner: owner.Email, Users: []string{owner.Email, alice.Email}, Roles: []account.MemberRole{{Email: alice.Email, Role: account.ROLE_VIEWER}}}
+	c.Assert(team.Authorize(owner, account.PERMISSION_DELETE_TEAM), IsNil)
+	c.Assert(team.Authorize(alice, account.PERMISSION_READ), IsNil)
+
+	err := team.Authorize(alice, account.PERMISSION_WRITE)
+	e, ok := err.(errors.ForbiddenError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Error(), Equals, errors.ErrRoleNotAllowed.Error())
+
+	err = team.Authorize(alice, account.PERMISSION_DELETE_TEAM)
+	e, ok = err.(errors.ForbiddenError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Error(), Equals, errors.ErrOnlyOwnerHasPermission.Error())
+
+	err = team.Authorize(account.User{Email: "mary@example.org"}, account.PERMISSION_READ)
+	e, ok = err.(errors.ForbiddenError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Error(), Equals, errors.ErrUserNotInTeam.Error())
+}
+
+func (s *S) TestChangeRole(c *C) {
+	team.Create(owner)
+	alice.Create()
+	defer alice.Delete()
+	defer team.Delete(owner)
+	team.AddUsers([]string{alice.Email})
+
+	err := team.ChangeRole(alice.Email, account.ROLE_ADMIN, owner)
+	c.Assert(err, IsNil)
+
+	t, err := account.FindTeamByAlias(team.Alias)
+	c.Assert(err, IsNil)
+	c.Assert(t.RoleOf(alice.Email), Equals, account.ROLE_ADMIN)
+}
+
+func (s *S) TestChangeRoleWithoutPermission(c *C) {
+	team.Create(owner)
+	alice.Create()
+	defer alice.Delete()
+	defer team.Delete(owner)
+	team.AddUsers([]string{alice.Email})
+
+	err := team.ChangeRole(alice.Email, account.ROLE_ADMIN, alice)
+	_, ok := err.(errors.ForbiddenError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestChangeRoleWithInvalidRole(c *C) {
+	team.Create(owner)
+	alice.Create()
+	defer alice.Delete()
+	defer team.Delete(owner)
+	team.AddUsers([]string{alice.Email})
+
+	err := team.ChangeRole(alice.Email, account.ROLE_OWNER, owner)
+	e, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Error(), Equals, errors.ErrTeamInvalidRole.Error())
+}
+
+func (s *S) TestChangeRoleOfOwner(c *C) {
+	team.Create(owner)
+	defer team.Delete(owner)
+
+	err := team.ChangeRole(owner.Email, account.ROLE_VIEWER, owner)
+	e, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Error(), Equals, errors.ErrTeamOwnerRole.Error())
+}
+
+func (s *S) TestChangeRoleNotMember(c *C) {
+	team.Create(owner)
+	defer team.Delete(owner)
+
+	err := team.ChangeRole(alice.Email, account.ROLE_VIEWER, owner)
+	e, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Error(), Equals, errors.ErrTeamMemberNotFound.Error())
+}
+
+func (s *S) TestRemoveUsersRemovesTheRole(c *C) {
+	team.Create(owner)
+	alice.Create()
+	defer alice.Delete()
+	defer team.Delete(owner)
+	team.AddUsers([]string{alice.Email})
+	team.ChangeRole(alice.Email, account.ROLE_VIEWER, owner)
+
+	err := team.RemoveUsers([]string{alice.Email})
+	c.Assert(err, IsNil)
+	t, err := account.FindTeamByAlias(team.Alias)
+	c.Assert(err, IsNil)
+	c.Assert(t.Roles, HasLen, 0)
+}
+
+func (s *S) TestTransferOwnership(c *C) {
+	team.Create(owner)
+	alice.Create()
+	defer alice.Delete()
+	defer team.Delete(alice)
+	team.AddUsers([]string{alice.Email})
+
+	err := team.TransferOwnership(alice.Email, owner)
+	c.Assert(err, IsNil)
+
+	t, err := account.FindTeamByAlias(team.Alias)
+	c.Assert(err, IsNil)
+	c.Assert(t.Owner, Equals, alice.Email)
+	c.Assert(t.RoleOf(alice.Email), Equals, account.ROLE_OWNER)
+	c.Assert(t.RoleOf(owner.Email), Equals, account.ROLE_ADMIN)
+}
+
+func (s *S) TestTransferOwnershipNotOwner(c *C) {
+	team.Create(owner)
+	alice.Create()
+	defer alice.Delete()
+	defer team.Delete(owner)
+	team.AddUsers([]string{alice.Email})
+	team.ChangeRole(alice.Email, account.ROLE_ADMIN, owner)
+
+	err := team.TransferOwnership(alice.Email, alice)
+	e, ok := err.(errors.ForbiddenError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Error(), Equals, errors.ErrOnlyOwnerHasPermission.Error())
+}
+
+func (s *S) TestTransferOwnershipNotMember(c *C) {
+	team.Create(owner)
+	defer team.Delete(owner)
+
+	err := team.TransferOwnership(alice.Email, owner)
+	e, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Error(), Equals, errors.ErrTeamMemberNotFound.Error())
+}
@@ -0,0 +1,13 @@
+package account
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// hashSecret hashes the secrets sent to the users, such as the ones of the API keys, personal access tokens,
+// email tokens and invitations, so only their hashes are kept in the storage.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -10,15 +10,36 @@ import (
 )
 
 type Service struct {
-	Subdomain     string   `json:"subdomain"`
-	Description   string   `json:"description,omitempty"`
-	Disabled      bool     `json:"disabled,omitempty"`
-	Documentation string   `json:"documentation,omitempty"`
-	Endpoint      string   `json:"endpoint,omitempty"`
-	Transformers  []string `json:"transformers,omitempty"`
-	Owner         string   `json:"owner,omitempty"`
-	Team          string   `json:"team"`
-	Timeout       int      `json:"timeout,omitempty"`
+	Subdomain      string          `json:"subdomain"`
+	Description    string          `json:"description,omitempty"`
+	Disabled       bool            `json:"disabled,omitempty"`
+	Documentation  string          `json:"documentation,omitempty"`
+	Endpoint       string          `json:"endpoint,omitempty"`
+	Transformers   []string        `json:"transformers,omitempty"`
+	Owner          string          `json:"owner,omitempty"`
+	Team           string          `json:"team"`
+	Timeout        int             `json:"timeout,omitempty"`
+	Timeouts       *Timeouts       `json:"timeouts,omitempty"`
+	Routes         []Route         `json:"routes,omitempty"`
+	Upstreams      []Upstream      `json:"upstreams,omitempty"`
+	LoadBalancer   *LoadBalancer   `json:"load_balancer,omitempty"`
+	HealthCheck    *HealthCheck    `json:"health_check,omitempty"`
+	Retry          *Retry          `json:"retry,omitempty"`
+	CircuitBreaker *CircuitBreaker `json:"circuit_breaker,omitempty"`
+	UpstreamTLS    *UpstreamTLS    `json:"upstream_tls,omitempty"`
+}
+
+// Route declares an additional rule used by the gateway to dispatch requests to the service,
+// besides its subdomain.
+//
+// `Host` accepts either an exact host (api.example.org) or a wildcard host (*.example.org), under a domain
+// verified by the team. Routes without `Host` only apply to the requests sent to the subdomain of the service.
+// When `PathPrefix` is informed, only requests under that path are dispatched to the service,
+// and `StripPrefix` removes it before the request is sent to the endpoint.
+type Route struct {
+	Host        string `json:"host,omitempty"`
+	PathPrefix  string `json:"path_prefix,omitempty"`
+	StripPrefix bool   `json:"strip_prefix,omitempty"`
 }
 
 func (service *Service) Create(owner User, team Team) error {
@@ -63,13 +84,23 @@ func (service *Service) Update() error {
 	return err
 }
 
-func (service *Service) Delete(owner User) error {
-	if service.Owner != owner.Email {
-		Logger.Warn("Only the owner has permission to delete the following service: %s.", service.Subdomain)
-		return errors.NewForbiddenError(errors.ErrOnlyOwnerHasPermission)
+// Delete removes the service, which is allowed to the members of its team whose role has the delete permission.
+func (service *Service) Delete(user User) error {
+	if err := authorizeOnTeam(service.Team, service.Owner, user, PERMISSION_DELETE); err != nil {
+		Logger.Warn("The user %s has no permission to delete the following service: %s.", user.Email, service.Subdomain)
+		return err
 	}
+	return service.delete()
+}
 
+func (service *Service) delete() error {
 	go store.DeletePluginsByService(*service)
+	go store.DeleteUpstreamStatesByService(*service)
+	go store.DeleteCircuitBreakerStateByService(*service)
+	go store.DeleteSubscriptionsByService(*service)
+	go store.DeletePlansByService(*service)
+	go store.DeleteCertificatesByService(*service)
+	go store.DeleteClientCertificatesByService(*service)
 
 	err := store.DeleteService(*service)
 	if err == nil {
@@ -89,23 +120,106 @@ func (service Service) Exists() bool {
 }
 
 func (service *Service) valid() error {
-	if service.Subdomain == "" || service.Endpoint == "" || service.Team == "" {
+	if service.Subdomain == "" || (service.Endpoint == "" && len(service.Upstreams) == 0) || service.Team == "" {
 		return errors.NewValidationError(errors.ErrServiceMissingRequiredFields)
 	}
+	for _, upstream := range service.Upstreams {
+		if err := upstream.valid(); err != nil {
+			return err
+		}
+	}
+	if service.LoadBalancer != nil {
+		if err := service.LoadBalancer.valid(); err != nil {
+			return err
+		}
+	}
+	if service.HealthCheck != nil {
+		if err := service.HealthCheck.valid(); err != nil {
+			return err
+		}
+	}
+	if service.Timeouts != nil {
+		if err := service.Timeouts.valid(); err != nil {
+			return err
+		}
+	}
+	if service.Retry != nil {
+		if err := service.Retry.valid(); err != nil {
+			return err
+		}
+	}
+	if service.CircuitBreaker != nil {
+		if err := service.CircuitBreaker.valid(); err != nil {
+			return err
+		}
+	}
+	if service.UpstreamTLS != nil {
+		if err := service.UpstreamTLS.valid(*service); err != nil {
+			return err
+		}
+	}
+	for i, route := range service.Routes {
+		if err := route.valid(); err != nil {
+			return err
+		}
+		service.Routes[i].Host = strings.ToLower(route.Host)
+	}
+	return service.validRouteHosts()
+}
+
+// validRouteHosts checks that the hosts of the routes are under the domains verified by the team,
+// and that no other service has already claimed the same host and path prefix.
+func (service *Service) validRouteHosts() error {
+	for _, route := range service.Routes {
+		if route.Host == "" {
+			continue
+		}
+		if !teamControlsHost(service.Team, route.Host) {
+			return errors.NewValidationError(errors.ErrServiceRouteHostNotVerified)
+		}
+
+		services, err := store.FindServicesByRouteHost(route.Host)
+		if err != nil {
+			return err
+		}
+		for _, other := range services {
+			if other.Subdomain == service.Subdomain {
+				continue
+			}
+			for _, r := range other.Routes {
+				if r.Host == route.Host && strings.TrimSuffix(r.PathPrefix, "/") == strings.TrimSuffix(route.PathPrefix, "/") {
+					return errors.NewValidationError(errors.ErrServiceRouteDuplicateEntry)
+				}
+			}
+		}
+	}
 	return nil
 }
 
+func (route Route) valid() error {
+	if route.Host == "" && route.PathPrefix == "" {
+		return errors.NewValidationError(errors.ErrServiceInvalidRoute)
+	}
+	if route.PathPrefix != "" && !strings.HasPrefix(route.PathPrefix, "/") {
+		return errors.NewValidationError(errors.ErrServiceInvalidRoute)
+	}
+	if strings.Contains(strings.TrimPrefix(route.Host, "*."), "*") {
+		return errors.NewValidationError(errors.ErrServiceInvalidRoute)
+	}
+	return nil
+}
+
+// DeleteServicesByTeam removes all the services of the team, once the owner has deleted it.
 func DeleteServicesByTeam(team Team, owner User) error {
 	services, err := store.TeamServices(team)
 	if err != nil {
 		return err
 	}
 	for _, s := range services {
-
-		s.Delete(owner)
+		s.delete()
 	}
 
-	Logger.Info("All services were excluded from the team `%s`.", team.Alias)
+	Logger.Info("All services were excluded from the team `%s` by %s.", team.Alias, owner.Email)
 	return nil
 }
 
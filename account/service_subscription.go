@@ -0,0 +1,126 @@
+package account
+
+import (
+	"time"
+
+	"github.com/apihub/apihub/errors"
+	. "github.com/apihub/apihub/log"
+)
+
+const (
+	SUBSCRIPTION_PENDING  = "pending"
+	SUBSCRIPTION_APPROVED = "approved"
+	SUBSCRIPTION_REJECTED = "rejected"
+)
+
+// Subscription is the request of an App to consume a Service, which is reviewed by the team of the service.
+// Only Apps with approved subscriptions are allowed to reach the service through the gateway.
+type Subscription struct {
+	ClientId    string `json:"client_id"`
+	Service     string `json:"service"`
+	Plan        string `json:"plan,omitempty"`
+	State       string `json:"state"`
+	RequestedBy string `json:"requested_by"`
+	ReviewedBy  string `json:"reviewed_by,omitempty"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at,omitempty"`
+}
+
+// Create requests access to the service on behalf of the app. A rejected subscription may be requested again.
+func (s *Subscription) Create(owner User, app App) error {
+	s.ClientId = app.ClientId
+	s.RequestedBy = owner.Email
+	s.State = SUBSCRIPTION_PENDING
+	s.ReviewedBy = ""
+
+	if s.Service == "" {
+		Logger.Info("Failed to create a subscription with invalid data: %+v.", s)
+		return errors.NewValidationError(errors.ErrSubscriptionMissingRequiredFields)
+	}
+
+	service, err := FindServiceBySubdomain(s.Service)
+	if err != nil {
+		return err
+	}
+	s.Service = service.Subdomain
+
+	if s.Plan != "" {
+		if _, err := FindPlanByNameAndService(s.Plan, *service); err != nil {
+			return err
+		}
+	}
+
+	if existing, err := FindSubscription(s.ClientId, s.Service); err == nil && existing.State != SUBSCRIPTION_REJECTED {
+		Logger.Info("Failed to create a subscription with duplicate data: %+v.", s)
+		return errors.NewValidationError(errors.ErrSubscriptionDuplicateEntry)
+	}
+
+	s.CreatedAt = time.Now().In(time.UTC).Format(time.RFC3339)
+	s.UpdatedAt = ""
+	err = store.UpsertSubscription(*s)
+	Logger.Info("subscription.Create: %+v. Err: %s.", s, err)
+	return err
+}
+
+// Approve grants the app access to the service.
+func (s *Subscription) Approve(reviewer User) error {
+	return s.review(reviewer, SUBSCRIPTION_APPROVED)
+}
+
+// Revoke rejects a pending subscription or withdraws the access of an approved one.
+func (s *Subscription) Revoke(reviewer User) error {
+	return s.review(reviewer, SUBSCRIPTION_REJECTED)
+}
+
+// ChangePlan assigns the app to one of the plans of the service. An empty plan removes the limits of the plan.
+func (s *Subscription) ChangePlan(reviewer User, plan string) error {
+	if plan != "" {
+		if _, err := FindPlanByNameAndService(plan, Service{Subdomain: s.Service}); err != nil {
+			return err
+		}
+	}
+
+	s.Plan = plan
+	s.ReviewedBy = reviewer.Email
+	s.UpdatedAt = time.Now().In(time.UTC).Format(time.RFC3339)
+
+	err := store.UpsertSubscription(*s)
+	Logger.Info("subscription.ChangePlan: %+v. Err: %s.", s, err)
+	return err
+}
+
+func (s *Subscription) review(reviewer User, state string) error {
+	s.State = state
+	s.ReviewedBy = reviewer.Email
+	s.UpdatedAt = time.Now().In(time.UTC).Format(time.RFC3339)
+
+	err := store.UpsertSubscription(*s)
+	Logger.Info("subscription.Review: %+v. Err: %s.", s, err)
+	return err
+}
+
+func (s Subscription) Delete() error {
+	err := store.DeleteSubscription(s)
+	Logger.Info("subscription.Delete: %+v. Err: %s.", s, err)
+	return err
+}
+
+func (s Subscription) Approved() bool {
+	return s.State == SUBSCRIPTION_APPROVED
+}
+
+func FindSubscription(clientId, subdomain string) (*Subscription, error) {
+	subscription, err := store.FindSubscription(clientId, subdomain)
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func AppSubscriptions(app App) ([]Subscription, error) {
+	return store.AppSubscriptions(app)
+}
+
+func ServiceSubscriptions(service Service) ([]Subscription, error) {
+	return store.ServiceSubscriptions(service)
+}
@@ -0,0 +1,152 @@
+package account_test
+
+import (
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestCreateSubscription(c *C) {
+	service.Create(owner, team)
+	defer service.Delete(owner)
+
+	plan := account.Plan{Name: "gold"}
+	plan.Save(service)
+	defer plan.Delete()
+
+	subscription := account.Subscription{Service: service.Subdomain, Plan: "gold"}
+	err := subscription.Create(alice, app)
+	c.Assert(err, IsNil)
+	defer subscription.Delete()
+
+	c.Assert(subscription.ClientId, Equals, app.ClientId)
+	c.Assert(subscription.State, Equals, account.SUBSCRIPTION_PENDING)
+	c.Assert(subscription.RequestedBy, Equals, alice.Email)
+	c.Assert(subscription.Approved(), Equals, false)
+}
+
+func (s *S) TestCreateSubscriptionWithoutRequiredFields(c *C) {
+	subscription := account.Subscription{}
+	err := subscription.Create(alice, app)
+	_, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestCreateSubscriptionServiceNotFound(c *C) {
+	subscription := account.Subscription{Service: "not-found"}
+	err := subscription.Create(alice, app)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestCreateSubscriptionPlanNotFound(c *C) {
+	service.Create(owner, team)
+	defer service.Delete(owner)
+
+	subscription := account.Subscription{Service: service.Subdomain, Plan: "not-found"}
+	err := subscription.Create(alice, app)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestChangeSubscriptionPlan(c *C) {
+	service.Create(owner, team)
+	defer service.Delete(owner)
+	plan := account.Plan{Name: "gold"}
+	plan.Save(service)
+	defer plan.Delete()
+
+	subscription := account.Subscription{Service: service.Subdomain}
+	subscription.Create(alice, app)
+	defer subscription.Delete()
+
+	err := subscription.ChangePlan(owner, "gold")
+	c.Assert(err, IsNil)
+	found, _ := account.FindSubscription(app.ClientId, service.Subdomain)
+	c.Assert(found.Plan, Equals, "gold")
+
+	err = subscription.ChangePlan(owner, "not-found")
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestCreateSubscriptionWithDuplicateEntry(c *C) {
+	service.Create(owner, team)
+	defer service.Delete(owner)
+
+	subscription := account.Subscription{Service: service.Subdomain}
+	subscription.Create(alice, app)
+	defer subscription.Delete()
+
+	duplicate := account.Subscription{Service: service.Subdomain}
+	err := duplicate.Create(alice, app)
+	_, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestCreateSubscriptionAfterRejected(c *C) {
+	service.Create(owner, team)
+	defer service.Delete(owner)
+
+	subscription := account.Subscription{Service: service.Subdomain}
+	subscription.Create(alice, app)
+	defer subscription.Delete()
+	subscription.Revoke(owner)
+
+	again := account.Subscription{Service: service.Subdomain}
+	err := again.Create(alice, app)
+	c.Assert(err, IsNil)
+	c.Assert(again.State, Equals, account.SUBSCRIPTION_PENDING)
+	c.Assert(again.ReviewedBy, Equals, "")
+}
+
+func (s *S) TestApproveAndRevokeSubscription(c *C) {
+	service.Create(owner, team)
+	defer service.Delete(owner)
+
+	subscription := account.Subscription{Service: service.Subdomain}
+	subscription.Create(alice, app)
+	defer subscription.Delete()
+
+	err := subscription.Approve(owner)
+	c.Assert(err, IsNil)
+	found, _ := account.FindSubscription(app.ClientId, service.Subdomain)
+	c.Assert(found.Approved(), Equals, true)
+	c.Assert(found.ReviewedBy, Equals, owner.Email)
+
+	err = subscription.Revoke(owner)
+	c.Assert(err, IsNil)
+	found, _ = account.FindSubscription(app.ClientId, service.Subdomain)
+	c.Assert(found.State, Equals, account.SUBSCRIPTION_REJECTED)
+}
+
+func (s *S) TestAppAndServiceSubscriptions(c *C) {
+	service.Create(owner, team)
+	defer service.Delete(owner)
+
+	subscription := account.Subscription{Service: service.Subdomain}
+	subscription.Create(alice, app)
+	defer subscription.Delete()
+
+	subscriptions, err := account.AppSubscriptions(app)
+	c.Assert(err, IsNil)
+	c.Assert(subscriptions, DeepEquals, []account.Subscription{subscription})
+
+	subscriptions, err = account.ServiceSubscriptions(service)
+	c.Assert(err, IsNil)
+	c.Assert(subscriptions, DeepEquals, []account.Subscription{subscription})
+}
+
+func (s *S) TestDeleteAppDeletesSubscriptions(c *C) {
+	service.Create(owner, team)
+	defer service.Delete(owner)
+	app.Create(owner, team)
+
+	subscription := account.Subscription{Service: service.Subdomain}
+	subscription.Create(alice, app)
+
+	app.Delete(owner)
+	_, err := account.FindSubscription(app.ClientId, service.Subdomain)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
@@ -29,6 +29,107 @@ func (s *S) TestCreateServiceWithoutRequiredFields(c *C) {
 	c.Assert(ok, Equals, true)
 }
 
+func (s *S) TestCreateServiceWithRoutes(c *C) {
+	d := verifyDomain(c, team, "example.org")
+	defer d.Delete()
+
+	service.Routes = []account.Route{{Host: "*.example.org"}, {Host: "API.example.org", PathPrefix: "/billing", StripPrefix: true}}
+	err := service.Create(owner, team)
+	c.Assert(err, IsNil)
+	defer service.Delete(owner)
+	c.Assert(service.Routes[1].Host, Equals, "api.example.org")
+}
+
+func (s *S) TestCreateServiceWithRouteToHostNotVerified(c *C) {
+	d := account.Domain{Name: "example.org"}
+	c.Assert(d.Create(team), IsNil)
+	defer d.Delete()
+
+	for _, host := range []string{"api.example.org", "*.org", "api.example.com"} {
+		service.Routes = []account.Route{{Host: host}}
+		err := service.Create(owner, team)
+		c.Check(err, Equals, errors.NewValidationError(errors.ErrServiceRouteHostNotVerified), Commentf("%s", host))
+	}
+}
+
+func (s *S) TestCreateServiceWithRouteClaimedByAnotherService(c *C) {
+	d := verifyDomain(c, team, "example.org")
+	defer d.Delete()
+
+	service.Routes = []account.Route{{Host: "api.example.org", PathPrefix: "/billing"}}
+	c.Assert(service.Create(owner, team), IsNil)
+	defer service.Delete(owner)
+
+	other := account.Service{Endpoint: "http://example.org/other", Subdomain: "other", Routes: []account.Route{{Host: "api.example.org", PathPrefix: "/billing/"}}}
+	err := other.Create(owner, team)
+	c.Assert(err, Equals, errors.NewValidationError(errors.ErrServiceRouteDuplicateEntry))
+
+	other.Routes = []account.Route{{Host: "api.example.org", PathPrefix: "/users"}}
+	err = other.Create(owner, team)
+	c.Assert(err, IsNil)
+	defer other.Delete(owner)
+
+	service.Routes = append(service.Routes, account.Route{Host: "api.example.org", PathPrefix: "/users"})
+	err = service.Update()
+	c.Assert(err, Equals, errors.NewValidationError(errors.ErrServiceRouteDuplicateEntry))
+}
+
+func (s *S) TestCreateServiceWithInvalidRoutes(c *C) {
+	service.Routes = []account.Route{{PathPrefix: "billing"}}
+	err := service.Create(owner, team)
+	_, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+
+	service.Routes = []account.Route{{StripPrefix: true}}
+	err = service.Create(owner, team)
+	_, ok = err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestCreateServiceWithInvalidHealthCheck(c *C) {
+	service.HealthCheck = &account.HealthCheck{Path: "health"}
+	err := service.Create(owner, team)
+	_, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+
+	service.HealthCheck = &account.HealthCheck{Path: "/health", ExpectedStatus: 1000}
+	err = service.Create(owner, team)
+	_, ok = err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestCreateServiceWithInvalidTimeouts(c *C) {
+	service.Timeouts = &account.Timeouts{Connect: 100, Idle: -1}
+	err := service.Create(owner, team)
+	_, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestCreateServiceWithInvalidRetry(c *C) {
+	for _, retry := range []*account.Retry{
+		&account.Retry{MaxAttempts: -1},
+		&account.Retry{StatusCodes: []int{1000}},
+		&account.Retry{Methods: []string{"FETCH"}},
+	} {
+		service.Retry = retry
+		err := service.Create(owner, team)
+		_, ok := err.(errors.ValidationError)
+		c.Assert(ok, Equals, true)
+	}
+}
+
+func (s *S) TestCreateServiceWithInvalidCircuitBreaker(c *C) {
+	for _, cb := range []*account.CircuitBreaker{
+		&account.CircuitBreaker{ErrorThreshold: 101},
+		&account.CircuitBreaker{Cooldown: -1},
+	} {
+		service.CircuitBreaker = cb
+		err := service.Create(owner, team)
+		_, ok := err.(errors.ValidationError)
+		c.Assert(ok, Equals, true)
+	}
+}
+
 func (s *S) TestUpdateService(c *C) {
 	err := service.Create(owner, team)
 	c.Assert(err, IsNil)
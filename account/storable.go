@@ -15,13 +15,30 @@ type Storable interface {
 	FindTeamByAlias(string) (Team, error)
 	DeleteTeamByAlias(string) error
 
-	CreateToken(Token) error
-	DeleteToken(key string) error
-	DecodeToken(key string, t interface{}) error
+	UpsertToken(Token) error
+	DeleteToken(Token) error
+	DeleteTokensByUser(email string) error
+	FindTokenByAccessToken(string) (Token, error)
+	FindTokenByRefreshToken(string) (Token, error)
+	FindTokensByUser(email string) ([]Token, error)
+	UpdateTokenLastUsedAt(Token) error
+
+	UpsertPersonalAccessToken(PersonalAccessToken) error
+	DeletePersonalAccessToken(PersonalAccessToken) error
+	DeletePersonalAccessTokensByUser(email string) error
+	FindPersonalAccessTokenById(string) (PersonalAccessToken, error)
+	FindPersonalAccessTokensByUser(email string) ([]PersonalAccessToken, error)
+	UpdatePersonalAccessTokenLastUsedAt(PersonalAccessToken) error
+
+	UpsertEmailToken(EmailToken) error
+	DeleteEmailToken(EmailToken) error
+	DeleteEmailTokens(email string, purpose string) error
+	FindEmailTokenById(string) (EmailToken, error)
 
 	UpsertService(Service) error
 	DeleteService(Service) error
 	FindServiceBySubdomain(string) (Service, error)
+	FindServicesByRouteHost(string) ([]Service, error)
 	TeamServices(Team) ([]Service, error)
 
 	UpsertApp(App) error
@@ -29,10 +46,74 @@ type Storable interface {
 	DeleteApp(App) error
 	TeamApps(Team) ([]App, error)
 
+	UpsertApiKey(ApiKey) error
+	FindApiKeyById(string) (ApiKey, error)
+	AppApiKeys(App) ([]ApiKey, error)
+	DeleteApiKeysByApp(App) error
+	UpdateApiKeyLastUsedAt(ApiKey) error
+
+	UpsertSubscription(Subscription) error
+	DeleteSubscription(Subscription) error
+	FindSubscription(clientId string, subdomain string) (Subscription, error)
+	AppSubscriptions(App) ([]Subscription, error)
+	ServiceSubscriptions(Service) ([]Subscription, error)
+	DeleteSubscriptionsByApp(App) error
+	DeleteSubscriptionsByService(Service) error
+
+	UpsertGrant(Grant) error
+	DeleteGrant(Grant) error
+	FindGrantByCode(string) (Grant, error)
+
+	UpsertOAuthToken(OAuthToken) error
+	DeleteOAuthToken(OAuthToken) error
+	FindOAuthTokenByAccessToken(string) (OAuthToken, error)
+	FindOAuthTokenByRefreshToken(string) (OAuthToken, error)
+
 	UpsertPlugin(Plugin) error
 	DeletePlugin(Plugin) error
 	DeletePluginsByService(Service) error
 	FindPluginByNameAndService(string, Service) (Plugin, error)
+	FindPluginsByService(Service) ([]Plugin, error)
+
+	UpsertPlan(Plan) error
+	DeletePlan(Plan) error
+	DeletePlansByService(Service) error
+	FindPlanByNameAndService(string, Service) (Plan, error)
+	FindPlansByService(Service) ([]Plan, error)
+
+	UpsertUpstreamState(UpstreamState) error
+	DeleteUpstreamStatesByService(Service) error
+	FindUpstreamStatesByService(Service) ([]UpstreamState, error)
+
+	UpsertCircuitBreakerState(CircuitBreakerState) error
+	DeleteCircuitBreakerStateByService(Service) error
+	FindCircuitBreakerStateByService(Service) (CircuitBreakerState, error)
+
+	UpsertCertificate(Certificate) error
+	DeleteCertificate(Certificate) error
+	DeleteCertificatesByService(Service) error
+	FindCertificateByHost(string) (Certificate, error)
+	FindCertificatesByService(Service) ([]Certificate, error)
+
+	UpsertClientCertificate(ClientCertificate) error
+	DeleteClientCertificate(ClientCertificate) error
+	DeleteClientCertificatesByService(Service) error
+	FindClientCertificateByNameAndService(string, Service) (ClientCertificate, error)
+	FindClientCertificatesByService(Service) ([]ClientCertificate, error)
+
+	UpsertInvitation(Invitation) error
+	DeleteInvitation(Invitation) error
+	DeleteInvitationsByTeam(Team) error
+	FindInvitationById(string) (Invitation, error)
+	FindInvitationsByTeam(Team) ([]Invitation, error)
+	FindInvitationsByEmail(string) ([]Invitation, error)
+
+	UpsertDomain(Domain) error
+	DeleteDomain(Domain) error
+	DeleteDomainsByTeam(Team) error
+	FindDomainByNameAndTeam(string, string) (Domain, error)
+	FindDomainsByName(string) ([]Domain, error)
+	FindDomainsByTeam(Team) ([]Domain, error)
 
 	UpsertHook(Hook) error
 	DeleteHook(Hook) error
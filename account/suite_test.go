@@ -25,6 +25,7 @@ func (s *S) SetUpSuite(c *C) {
 	// FIXME: add memory
 	pubsub := account.NewEtcdSubscription("/account_test/", &db.EtcdConfig{Machines: []string{"http://localhost:2379"}})
 	account.NewPubSub(pubsub)
+	account.ClientCertificateSecret("secret")
 }
 
 var app account.App
@@ -12,14 +12,16 @@ const (
 
 // The Team type is an encapsulation of a team details.
 // It is not allowed to have more than one team with the same alias.
-// The `Owner` field indicates the user who created the team.
+// The `Owner` field indicates the user who owns the team, initially the one who created it.
+// `Roles` keeps the role of the members other than the owner; members without a role are developers.
 type Team struct {
-	Name     string    `json:"name"`
-	Alias    string    `json:"alias"`
-	Users    []string  `json:"users"`
-	Owner    string    `json:"owner"`
-	Services []Service `json:"services,omitempty"`
-	Apps     []App     `json:"apps,omitempty"`
+	Name     string       `json:"name"`
+	Alias    string       `json:"alias"`
+	Users    []string     `json:"users"`
+	Owner    string       `json:"owner"`
+	Roles    []MemberRole `json:"roles,omitempty"`
+	Services []Service    `json:"services,omitempty"`
+	Apps     []App        `json:"apps,omitempty"`
 }
 
 // Create a team.
@@ -61,15 +63,18 @@ func (team *Team) Update() error {
 	return err
 }
 
-// Delete removes an existing team from the server.
+// Delete removes an existing team from the server, along with its services, apps, hooks and invitations.
+// Only the owner is allowed to do that.
 func (team Team) Delete(owner User) error {
-	if team.Owner != owner.Email {
-		return errors.NewForbiddenError(errors.ErrOnlyOwnerHasPermission)
+	if err := team.Authorize(owner, PERMISSION_DELETE_TEAM); err != nil {
+		return err
 	}
 
 	go DeleteServicesByTeam(team, owner)
 	go DeleteAppsByTeam(team, owner)
 	go store.DeleteHooksByTeam(team)
+	go store.DeleteInvitationsByTeam(team)
+	go store.DeleteDomainsByTeam(team)
 
 	err := store.DeleteTeam(team)
 	Logger.Info("team.Delete: %+v. Err: %s.", team, err)
@@ -174,6 +179,7 @@ func (team *Team) RemoveUsers(emails []string) error {
 				team.Users[i] = team.Users[hi]
 			}
 			team.Users = team.Users[:hi]
+			team.removeRole(user.Email)
 			removedUsers = true
 		}
 	}
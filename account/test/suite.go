@@ -15,6 +15,12 @@ var team account.Team
 var service account.Service
 var token account.Token
 var hook account.Hook
+var apiKey account.ApiKey
+var subscription account.Subscription
+var plan account.Plan
+var grant account.Grant
+var oauthToken account.OAuthToken
+var personalAccessToken account.PersonalAccessToken
 
 //Hook up gocheck into the "go test" runner.
 func Test(t *testing.T) { TestingT(t) }
@@ -25,12 +31,18 @@ type StorableSuite struct {
 
 func (s *StorableSuite) SetUpTest(c *C) {
 	user = account.User{Name: "Alice", Email: "alice@example.org", Password: "123456"}
-	token = account.Token{AccessToken: "secret-token", Expires: 10, Type: "Token", User: &user}
+	token = account.Token{Id: "token-id", AccessToken: "secret-token", RefreshToken: "refresh-token", User: user.Email, UserAgent: "curl/7.43.0", CreatedAt: "2015-05-16T12:40:44Z", Expires: 10, ExpiresAt: "2015-05-16T12:40:54Z", RefreshExpiresAt: "2015-06-15T12:40:44Z", Type: "Token"}
 	team = account.Team{Name: "ApiHub Team", Alias: "apihub", Users: []string{user.Email}, Owner: user.Email, Apps: []account.App{}, Services: []account.Service{}}
 	service = account.Service{Endpoint: "http://example.org/api", Subdomain: "apihub", Team: team.Alias, Owner: user.Email, Transformers: []string{}}
 	app = account.App{ClientId: "ios", ClientSecret: "secret", Name: "Ios App", Team: team.Alias, Owner: user.Email, RedirectUris: []string{"http://www.example.org/auth"}}
 	plugin = account.Plugin{Name: "cors", Service: service.Subdomain, Config: map[string]interface{}{"version": 1}}
 	hook = account.Hook{Name: "service.update", Events: []string{"service.update"}, Config: account.HookConfig{Address: "http://www.example.org"}}
+	apiKey = account.ApiKey{Id: "key-id", Name: "production", ClientId: app.ClientId, Services: []string{service.Subdomain}, Methods: []string{"GET"}, Hash: "hash", CreatedAt: "2015-05-16T12:40:44Z"}
+	subscription = account.Subscription{ClientId: app.ClientId, Service: service.Subdomain, Plan: "gold", State: account.SUBSCRIPTION_PENDING, RequestedBy: user.Email, CreatedAt: "2015-05-16T12:40:44Z"}
+	plan = account.Plan{Name: "gold", Service: service.Subdomain, QuotaPerDay: 1000, QuotaPerMonth: 10000, RateLimit: 60, Methods: []string{"GET"}, Paths: []string{"/v1"}}
+	grant = account.Grant{Code: "secret-code", ClientId: app.ClientId, User: user.Email, RedirectUri: "http://www.example.org/auth", ExpiresAt: "2015-05-16T13:40:44Z"}
+	personalAccessToken = account.PersonalAccessToken{Id: "token-id", Name: "deploy", User: user.Email, Scopes: []string{"services:write"}, Hash: "hash", CreatedAt: "2015-05-16T12:40:44Z", Type: "PersonalToken"}
+	oauthToken = account.OAuthToken{AccessToken: "access-token", RefreshToken: "refresh-token", ClientId: app.ClientId, User: user.Email, CreatedAt: "2015-05-16T12:40:44Z", ExpiresAt: "2015-05-16T13:40:44Z"}
 }
 
 func (s *StorableSuite) TestUpsertUser(c *C) {
@@ -146,24 +158,178 @@ func (s *StorableSuite) TestTeamServiceNotFound(c *C) {
 	c.Assert(services, DeepEquals, []account.Service{})
 }
 
-func (s *StorableSuite) TestCreateToken(c *C) {
-	defer s.Storage.DeleteToken(token.AccessToken)
-	err := s.Storage.CreateToken(token)
+func (s *StorableSuite) TestUpsertToken(c *C) {
+	defer s.Storage.DeleteToken(token)
+	err := s.Storage.UpsertToken(token)
 	c.Check(err, IsNil)
 }
 
 func (s *StorableSuite) TestDeleteToken(c *C) {
-	err := s.Storage.CreateToken(token)
+	s.Storage.UpsertToken(token)
+	err := s.Storage.DeleteToken(token)
 	c.Check(err, IsNil)
-	err = s.Storage.DeleteToken(token.AccessToken)
+
+	_, err = s.Storage.FindTokenByAccessToken(token.AccessToken)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestDeleteTokenNotFound(c *C) {
+	err := s.Storage.DeleteToken(token)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestDeleteTokensByUser(c *C) {
+	s.Storage.UpsertToken(token)
+	err := s.Storage.DeleteTokensByUser(user.Email)
+	c.Check(err, IsNil)
+
+	tokens, err := s.Storage.FindTokensByUser(user.Email)
+	c.Check(err, IsNil)
+	c.Assert(tokens, HasLen, 0)
+}
+
+func (s *StorableSuite) TestFindTokenByAccessToken(c *C) {
+	defer s.Storage.DeleteToken(token)
+	s.Storage.UpsertToken(token)
+	t, err := s.Storage.FindTokenByAccessToken(token.AccessToken)
+	c.Check(err, IsNil)
+	c.Assert(t, DeepEquals, token)
+}
+
+func (s *StorableSuite) TestFindTokenByAccessTokenNotFound(c *C) {
+	_, err := s.Storage.FindTokenByAccessToken("not-found")
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestFindTokenByRefreshToken(c *C) {
+	defer s.Storage.DeleteToken(token)
+	s.Storage.UpsertToken(token)
+	t, err := s.Storage.FindTokenByRefreshToken(token.RefreshToken)
+	c.Check(err, IsNil)
+	c.Assert(t, DeepEquals, token)
+}
+
+func (s *StorableSuite) TestFindTokenByRefreshTokenNotFound(c *C) {
+	_, err := s.Storage.FindTokenByRefreshToken("not-found")
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestFindTokensByUser(c *C) {
+	defer s.Storage.DeleteToken(token)
+	s.Storage.UpsertToken(token)
+	tokens, err := s.Storage.FindTokensByUser(user.Email)
+	c.Check(err, IsNil)
+	c.Assert(tokens, DeepEquals, []account.Token{token})
+}
+
+func (s *StorableSuite) TestUpdateTokenLastUsedAt(c *C) {
+	defer s.Storage.DeleteToken(token)
+	s.Storage.UpsertToken(token)
+
+	touched := token
+	touched.LastUsedAt = "2030-01-01T00:00:00Z"
+	touched.AccessToken = "stale"
+	err := s.Storage.UpdateTokenLastUsedAt(touched)
+	c.Check(err, IsNil)
+
+	t, err := s.Storage.FindTokenByAccessToken(token.AccessToken)
+	c.Check(err, IsNil)
+	c.Assert(t.LastUsedAt, Equals, "2030-01-01T00:00:00Z")
+	c.Assert(t.AccessToken, Equals, token.AccessToken)
+}
+
+func (s *StorableSuite) TestUpdateTokenLastUsedAtNotFound(c *C) {
+	err := s.Storage.UpdateTokenLastUsedAt(token)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+
+	_, err = s.Storage.FindTokenByAccessToken(token.AccessToken)
+	_, ok = err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestUpsertPersonalAccessToken(c *C) {
+	defer s.Storage.DeletePersonalAccessToken(personalAccessToken)
+	err := s.Storage.UpsertPersonalAccessToken(personalAccessToken)
 	c.Check(err, IsNil)
 }
 
-func (s *StorableSuite) TestDecodeToken(c *C) {
-	s.Storage.CreateToken(token)
-	var u account.User
-	s.Storage.DecodeToken(token.AccessToken, &u)
-	c.Assert(u, DeepEquals, user)
+func (s *StorableSuite) TestDeletePersonalAccessToken(c *C) {
+	s.Storage.UpsertPersonalAccessToken(personalAccessToken)
+	err := s.Storage.DeletePersonalAccessToken(personalAccessToken)
+	c.Check(err, IsNil)
+
+	_, err = s.Storage.FindPersonalAccessTokenById(personalAccessToken.Id)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestDeletePersonalAccessTokenNotFound(c *C) {
+	err := s.Storage.DeletePersonalAccessToken(personalAccessToken)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestDeletePersonalAccessTokensByUser(c *C) {
+	s.Storage.UpsertPersonalAccessToken(personalAccessToken)
+	err := s.Storage.DeletePersonalAccessTokensByUser(user.Email)
+	c.Check(err, IsNil)
+
+	tokens, err := s.Storage.FindPersonalAccessTokensByUser(user.Email)
+	c.Check(err, IsNil)
+	c.Assert(tokens, HasLen, 0)
+}
+
+func (s *StorableSuite) TestUpdatePersonalAccessTokenLastUsedAt(c *C) {
+	defer s.Storage.DeletePersonalAccessToken(personalAccessToken)
+	s.Storage.UpsertPersonalAccessToken(personalAccessToken)
+
+	touched := personalAccessToken
+	touched.LastUsedAt = "2030-01-01T00:00:00Z"
+	touched.Name = "stale"
+	err := s.Storage.UpdatePersonalAccessTokenLastUsedAt(touched)
+	c.Check(err, IsNil)
+
+	t, err := s.Storage.FindPersonalAccessTokenById(personalAccessToken.Id)
+	c.Check(err, IsNil)
+	c.Assert(t.LastUsedAt, Equals, "2030-01-01T00:00:00Z")
+	c.Assert(t.Name, Equals, personalAccessToken.Name)
+}
+
+func (s *StorableSuite) TestUpdatePersonalAccessTokenLastUsedAtNotFound(c *C) {
+	err := s.Storage.UpdatePersonalAccessTokenLastUsedAt(personalAccessToken)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+
+	_, err = s.Storage.FindPersonalAccessTokenById(personalAccessToken.Id)
+	_, ok = err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestFindPersonalAccessTokenById(c *C) {
+	defer s.Storage.DeletePersonalAccessToken(personalAccessToken)
+	s.Storage.UpsertPersonalAccessToken(personalAccessToken)
+	t, err := s.Storage.FindPersonalAccessTokenById(personalAccessToken.Id)
+	c.Check(err, IsNil)
+	c.Assert(t, DeepEquals, personalAccessToken)
+}
+
+func (s *StorableSuite) TestFindPersonalAccessTokenByIdNotFound(c *C) {
+	_, err := s.Storage.FindPersonalAccessTokenById("not-found")
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestFindPersonalAccessTokensByUser(c *C) {
+	defer s.Storage.DeletePersonalAccessToken(personalAccessToken)
+	s.Storage.UpsertPersonalAccessToken(personalAccessToken)
+	tokens, err := s.Storage.FindPersonalAccessTokensByUser(user.Email)
+	c.Check(err, IsNil)
+	c.Assert(tokens, DeepEquals, []account.PersonalAccessToken{personalAccessToken})
 }
 
 func (s *StorableSuite) TestUpsertService(c *C) {
@@ -198,6 +364,20 @@ func (s *StorableSuite) TestFindServiceBySubdomainNotFound(c *C) {
 	c.Assert(ok, Equals, true)
 }
 
+func (s *StorableSuite) TestFindServicesByRouteHost(c *C) {
+	routed := account.Service{Endpoint: "http://example.org/api", Subdomain: "routed", Team: team.Alias, Owner: user.Email, Transformers: []string{}, Routes: []account.Route{{Host: "api.example.org", PathPrefix: "/billing"}}}
+	defer s.Storage.DeleteService(routed)
+	s.Storage.UpsertService(routed)
+
+	services, err := s.Storage.FindServicesByRouteHost("api.example.org")
+	c.Check(err, IsNil)
+	c.Assert(services, DeepEquals, []account.Service{routed})
+
+	services, err = s.Storage.FindServicesByRouteHost("not-found.example.org")
+	c.Check(err, IsNil)
+	c.Assert(services, DeepEquals, []account.Service{})
+}
+
 func (s *StorableSuite) TestUserServices(c *C) {
 	s.Storage.UpsertTeam(team)
 	defer s.Storage.DeleteTeam(team)
@@ -266,6 +446,307 @@ func (s *StorableSuite) TestFindAppByClientIdNotFound(c *C) {
 	c.Assert(ok, Equals, true)
 }
 
+func (s *StorableSuite) TestUpsertApiKey(c *C) {
+	defer s.Storage.DeleteApiKeysByApp(app)
+	err := s.Storage.UpsertApiKey(apiKey)
+	c.Check(err, IsNil)
+}
+
+func (s *StorableSuite) TestFindApiKeyById(c *C) {
+	defer s.Storage.DeleteApiKeysByApp(app)
+	s.Storage.UpsertApiKey(apiKey)
+	apiKey.LastUsedAt = "2015-05-16T13:40:44Z"
+	s.Storage.UpsertApiKey(apiKey)
+
+	k, err := s.Storage.FindApiKeyById(apiKey.Id)
+	c.Check(err, IsNil)
+	c.Assert(k, DeepEquals, apiKey)
+}
+
+func (s *StorableSuite) TestFindApiKeyByIdNotFound(c *C) {
+	_, err := s.Storage.FindApiKeyById("not-found")
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestAppApiKeys(c *C) {
+	defer s.Storage.DeleteApiKeysByApp(app)
+	s.Storage.UpsertApiKey(apiKey)
+
+	apiKeys, err := s.Storage.AppApiKeys(app)
+	c.Check(err, IsNil)
+	c.Assert(apiKeys, DeepEquals, []account.ApiKey{apiKey})
+}
+
+func (s *StorableSuite) TestAppApiKeysNotFound(c *C) {
+	apiKeys, err := s.Storage.AppApiKeys(app)
+	c.Check(err, IsNil)
+	c.Assert(apiKeys, DeepEquals, []account.ApiKey{})
+}
+
+func (s *StorableSuite) TestDeleteApiKeysByApp(c *C) {
+	s.Storage.UpsertApiKey(apiKey)
+	err := s.Storage.DeleteApiKeysByApp(app)
+	c.Check(err, IsNil)
+
+	apiKeys, _ := s.Storage.AppApiKeys(app)
+	c.Assert(apiKeys, DeepEquals, []account.ApiKey{})
+}
+
+func (s *StorableSuite) TestUpdateApiKeyLastUsedAt(c *C) {
+	defer s.Storage.DeleteApiKeysByApp(app)
+	s.Storage.UpsertApiKey(apiKey)
+
+	touched := apiKey
+	touched.LastUsedAt = "2030-01-01T00:00:00Z"
+	touched.Hash = "stale"
+	err := s.Storage.UpdateApiKeyLastUsedAt(touched)
+	c.Check(err, IsNil)
+
+	k, err := s.Storage.FindApiKeyById(apiKey.Id)
+	c.Check(err, IsNil)
+	c.Assert(k.LastUsedAt, Equals, "2030-01-01T00:00:00Z")
+	c.Assert(k.Hash, Equals, apiKey.Hash)
+}
+
+func (s *StorableSuite) TestUpdateApiKeyLastUsedAtNotFound(c *C) {
+	err := s.Storage.UpdateApiKeyLastUsedAt(apiKey)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+
+	_, err = s.Storage.FindApiKeyById(apiKey.Id)
+	_, ok = err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestUpsertPlan(c *C) {
+	defer s.Storage.DeletePlan(plan)
+	err := s.Storage.UpsertPlan(plan)
+	c.Check(err, IsNil)
+}
+
+func (s *StorableSuite) TestDeletePlan(c *C) {
+	s.Storage.UpsertPlan(plan)
+	err := s.Storage.DeletePlan(plan)
+	c.Check(err, IsNil)
+}
+
+func (s *StorableSuite) TestDeletePlanNotFound(c *C) {
+	err := s.Storage.DeletePlan(plan)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestDeletePlansByService(c *C) {
+	s.Storage.UpsertPlan(plan)
+	err := s.Storage.DeletePlansByService(service)
+	c.Check(err, IsNil)
+
+	plans, _ := s.Storage.FindPlansByService(service)
+	c.Assert(plans, DeepEquals, []account.Plan{})
+}
+
+func (s *StorableSuite) TestFindPlanByNameAndService(c *C) {
+	defer s.Storage.DeletePlan(plan)
+	s.Storage.UpsertPlan(plan)
+	plan.QuotaPerDay = 2000
+	s.Storage.UpsertPlan(plan)
+
+	p, err := s.Storage.FindPlanByNameAndService(plan.Name, service)
+	c.Check(err, IsNil)
+	c.Assert(p, DeepEquals, plan)
+}
+
+func (s *StorableSuite) TestFindPlanByNameAndServiceNotFound(c *C) {
+	_, err := s.Storage.FindPlanByNameAndService("not-found", service)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestFindPlansByService(c *C) {
+	defer s.Storage.DeletePlan(plan)
+	s.Storage.UpsertPlan(plan)
+
+	plans, err := s.Storage.FindPlansByService(service)
+	c.Check(err, IsNil)
+	c.Assert(plans, DeepEquals, []account.Plan{plan})
+}
+
+func (s *StorableSuite) TestFindPlansByServiceNotFound(c *C) {
+	plans, err := s.Storage.FindPlansByService(service)
+	c.Check(err, IsNil)
+	c.Assert(plans, DeepEquals, []account.Plan{})
+}
+
+func (s *StorableSuite) TestUpsertSubscription(c *C) {
+	defer s.Storage.DeleteSubscription(subscription)
+	err := s.Storage.UpsertSubscription(subscription)
+	c.Check(err, IsNil)
+}
+
+func (s *StorableSuite) TestDeleteSubscription(c *C) {
+	s.Storage.UpsertSubscription(subscription)
+	err := s.Storage.DeleteSubscription(subscription)
+	c.Check(err, IsNil)
+}
+
+func (s *StorableSuite) TestDeleteSubscriptionNotFound(c *C) {
+	err := s.Storage.DeleteSubscription(subscription)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestFindSubscription(c *C) {
+	defer s.Storage.DeleteSubscription(subscription)
+	s.Storage.UpsertSubscription(subscription)
+	subscription.State = account.SUBSCRIPTION_APPROVED
+	s.Storage.UpsertSubscription(subscription)
+
+	sub, err := s.Storage.FindSubscription(app.ClientId, service.Subdomain)
+	c.Check(err, IsNil)
+	c.Assert(sub, DeepEquals, subscription)
+}
+
+func (s *StorableSuite) TestFindSubscriptionNotFound(c *C) {
+	_, err := s.Storage.FindSubscription(app.ClientId, "not-found")
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestAppSubscriptions(c *C) {
+	defer s.Storage.DeleteSubscription(subscription)
+	s.Storage.UpsertSubscription(subscription)
+
+	subscriptions, err := s.Storage.AppSubscriptions(app)
+	c.Check(err, IsNil)
+	c.Assert(subscriptions, DeepEquals, []account.Subscription{subscription})
+}
+
+func (s *StorableSuite) TestAppSubscriptionsNotFound(c *C) {
+	subscriptions, err := s.Storage.AppSubscriptions(app)
+	c.Check(err, IsNil)
+	c.Assert(subscriptions, DeepEquals, []account.Subscription{})
+}
+
+func (s *StorableSuite) TestServiceSubscriptions(c *C) {
+	defer s.Storage.DeleteSubscription(subscription)
+	s.Storage.UpsertSubscription(subscription)
+
+	subscriptions, err := s.Storage.ServiceSubscriptions(service)
+	c.Check(err, IsNil)
+	c.Assert(subscriptions, DeepEquals, []account.Subscription{subscription})
+}
+
+func (s *StorableSuite) TestServiceSubscriptionsNotFound(c *C) {
+	subscriptions, err := s.Storage.ServiceSubscriptions(service)
+	c.Check(err, IsNil)
+	c.Assert(subscriptions, DeepEquals, []account.Subscription{})
+}
+
+func (s *StorableSuite) TestDeleteSubscriptionsByApp(c *C) {
+	s.Storage.UpsertSubscription(subscription)
+	err := s.Storage.DeleteSubscriptionsByApp(app)
+	c.Check(err, IsNil)
+
+	subscriptions, _ := s.Storage.AppSubscriptions(app)
+	c.Assert(subscriptions, DeepEquals, []account.Subscription{})
+}
+
+func (s *StorableSuite) TestDeleteSubscriptionsByService(c *C) {
+	s.Storage.UpsertSubscription(subscription)
+	err := s.Storage.DeleteSubscriptionsByService(service)
+	c.Check(err, IsNil)
+
+	subscriptions, _ := s.Storage.ServiceSubscriptions(service)
+	c.Assert(subscriptions, DeepEquals, []account.Subscription{})
+}
+
+func (s *StorableSuite) TestUpsertGrant(c *C) {
+	defer s.Storage.DeleteGrant(grant)
+	err := s.Storage.UpsertGrant(grant)
+	c.Check(err, IsNil)
+}
+
+func (s *StorableSuite) TestDeleteGrant(c *C) {
+	s.Storage.UpsertGrant(grant)
+	err := s.Storage.DeleteGrant(grant)
+	c.Check(err, IsNil)
+
+	_, err = s.Storage.FindGrantByCode(grant.Code)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestDeleteGrantNotFound(c *C) {
+	err := s.Storage.DeleteGrant(grant)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestFindGrantByCode(c *C) {
+	defer s.Storage.DeleteGrant(grant)
+	s.Storage.UpsertGrant(grant)
+	g, err := s.Storage.FindGrantByCode(grant.Code)
+	c.Check(err, IsNil)
+	c.Assert(g, DeepEquals, grant)
+}
+
+func (s *StorableSuite) TestFindGrantByCodeNotFound(c *C) {
+	_, err := s.Storage.FindGrantByCode("not-found")
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestUpsertOAuthToken(c *C) {
+	defer s.Storage.DeleteOAuthToken(oauthToken)
+	err := s.Storage.UpsertOAuthToken(oauthToken)
+	c.Check(err, IsNil)
+}
+
+func (s *StorableSuite) TestDeleteOAuthToken(c *C) {
+	s.Storage.UpsertOAuthToken(oauthToken)
+	err := s.Storage.DeleteOAuthToken(oauthToken)
+	c.Check(err, IsNil)
+
+	_, err = s.Storage.FindOAuthTokenByAccessToken(oauthToken.AccessToken)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestDeleteOAuthTokenNotFound(c *C) {
+	err := s.Storage.DeleteOAuthToken(oauthToken)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestFindOAuthTokenByAccessToken(c *C) {
+	defer s.Storage.DeleteOAuthToken(oauthToken)
+	s.Storage.UpsertOAuthToken(oauthToken)
+	t, err := s.Storage.FindOAuthTokenByAccessToken(oauthToken.AccessToken)
+	c.Check(err, IsNil)
+	c.Assert(t, DeepEquals, oauthToken)
+}
+
+func (s *StorableSuite) TestFindOAuthTokenByAccessTokenNotFound(c *C) {
+	_, err := s.Storage.FindOAuthTokenByAccessToken("not-found")
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestFindOAuthTokenByRefreshToken(c *C) {
+	defer s.Storage.DeleteOAuthToken(oauthToken)
+	s.Storage.UpsertOAuthToken(oauthToken)
+	t, err := s.Storage.FindOAuthTokenByRefreshToken(oauthToken.RefreshToken)
+	c.Check(err, IsNil)
+	c.Assert(t, DeepEquals, oauthToken)
+}
+
+func (s *StorableSuite) TestFindOAuthTokenByRefreshTokenNotFound(c *C) {
+	_, err := s.Storage.FindOAuthTokenByRefreshToken("not-found")
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
 func (s *StorableSuite) TestUpsertPlugin(c *C) {
 	defer s.Storage.DeletePlugin(plugin)
 	err := s.Storage.UpsertPlugin(plugin)
@@ -318,6 +799,373 @@ func (s *StorableSuite) TestFindPluginByNameAndServiceNotFound(c *C) {
 	c.Assert(ok, Equals, true)
 }
 
+func (s *StorableSuite) TestFindPluginsByService(c *C) {
+	defer s.Storage.DeletePlugin(plugin)
+	plugin.Service = service.Subdomain
+	s.Storage.UpsertPlugin(plugin)
+
+	another := account.Plugin{Name: "request-id", Service: service.Subdomain}
+	defer s.Storage.DeletePlugin(another)
+	s.Storage.UpsertPlugin(another)
+
+	plugins, err := s.Storage.FindPluginsByService(service)
+	c.Check(err, IsNil)
+	c.Assert(len(plugins), Equals, 2)
+}
+
+func (s *StorableSuite) TestFindPluginsByServiceNotFound(c *C) {
+	plugins, err := s.Storage.FindPluginsByService(account.Service{Subdomain: "not-found"})
+	c.Check(err, IsNil)
+	c.Assert(plugins, DeepEquals, []account.Plugin{})
+}
+
+func (s *StorableSuite) TestUpsertUpstreamState(c *C) {
+	defer s.Storage.DeleteUpstreamStatesByService(service)
+	state := account.UpstreamState{Service: service.Subdomain, Target: service.Endpoint, Healthy: true}
+	err := s.Storage.UpsertUpstreamState(state)
+	c.Check(err, IsNil)
+}
+
+func (s *StorableSuite) TestFindUpstreamStatesByService(c *C) {
+	defer s.Storage.DeleteUpstreamStatesByService(service)
+	state := account.UpstreamState{Service: service.Subdomain, Target: service.Endpoint, Healthy: true}
+	s.Storage.UpsertUpstreamState(state)
+	state.Healthy = false
+	state.Failures = 3
+	s.Storage.UpsertUpstreamState(state)
+
+	states, err := s.Storage.FindUpstreamStatesByService(service)
+	c.Check(err, IsNil)
+	c.Assert(states, DeepEquals, []account.UpstreamState{state})
+}
+
+func (s *StorableSuite) TestFindUpstreamStatesByServiceNotFound(c *C) {
+	states, err := s.Storage.FindUpstreamStatesByService(account.Service{Subdomain: "not-found"})
+	c.Check(err, IsNil)
+	c.Assert(states, DeepEquals, []account.UpstreamState{})
+}
+
+func (s *StorableSuite) TestUpsertCircuitBreakerState(c *C) {
+	defer s.Storage.DeleteCircuitBreakerStateByService(service)
+	state := account.CircuitBreakerState{Service: service.Subdomain, State: account.CIRCUIT_OPEN, Requests: 20, Failures: 10}
+	err := s.Storage.UpsertCircuitBreakerState(state)
+	c.Check(err, IsNil)
+
+	state.State = account.CIRCUIT_CLOSED
+	s.Storage.UpsertCircuitBreakerState(state)
+	found, err := s.Storage.FindCircuitBreakerStateByService(service)
+	c.Check(err, IsNil)
+	c.Assert(found, DeepEquals, state)
+}
+
+func (s *StorableSuite) TestFindCircuitBreakerStateByServiceNotFound(c *C) {
+	_, err := s.Storage.FindCircuitBreakerStateByService(account.Service{Subdomain: "not-found"})
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestDeleteCircuitBreakerStateByService(c *C) {
+	s.Storage.UpsertCircuitBreakerState(account.CircuitBreakerState{Service: service.Subdomain, State: account.CIRCUIT_OPEN})
+	err := s.Storage.DeleteCircuitBreakerStateByService(service)
+	c.Check(err, IsNil)
+
+	_, err = s.Storage.FindCircuitBreakerStateByService(service)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestUpsertCertificate(c *C) {
+	defer s.Storage.DeleteCertificatesByService(service)
+	cert := account.Certificate{Host: "api.example.org", Service: service.Subdomain, Certificate: "cert", Key: "key"}
+	err := s.Storage.UpsertCertificate(cert)
+	c.Check(err, IsNil)
+
+	cert.Managed = true
+	s.Storage.UpsertCertificate(cert)
+	found, err := s.Storage.FindCertificateByHost(cert.Host)
+	c.Check(err, IsNil)
+	c.Assert(found, DeepEquals, cert)
+}
+
+func (s *StorableSuite) TestFindCertificateByHostNotFound(c *C) {
+	_, err := s.Storage.FindCertificateByHost("not-found.example.org")
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestFindCertificatesByService(c *C) {
+	defer s.Storage.DeleteCertificatesByService(service)
+	cert := account.Certificate{Host: "api.example.org", Service: service.Subdomain, Certificate: "cert", Key: "key"}
+	s.Storage.UpsertCertificate(cert)
+
+	certs, err := s.Storage.FindCertificatesByService(service)
+	c.Check(err, IsNil)
+	c.Assert(certs, DeepEquals, []account.Certificate{cert})
+}
+
+func (s *StorableSuite) TestDeleteCertificate(c *C) {
+	cert := account.Certificate{Host: "api.example.org", Service: service.Subdomain}
+	s.Storage.UpsertCertificate(cert)
+	err := s.Storage.DeleteCertificate(cert)
+	c.Check(err, IsNil)
+
+	_, err = s.Storage.FindCertificateByHost(cert.Host)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestDeleteCertificateNotFound(c *C) {
+	err := s.Storage.DeleteCertificate(account.Certificate{Host: "not-found.example.org"})
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestDeleteCertificatesByService(c *C) {
+	s.Storage.UpsertCertificate(account.Certificate{Host: "api.example.org", Service: service.Subdomain})
+	err := s.Storage.DeleteCertificatesByService(service)
+	c.Check(err, IsNil)
+
+	certs, _ := s.Storage.FindCertificatesByService(service)
+	c.Assert(certs, DeepEquals, []account.Certificate{})
+}
+
+func (s *StorableSuite) TestUpsertClientCertificate(c *C) {
+	defer s.Storage.DeleteClientCertificatesByService(service)
+	cert := account.ClientCertificate{Name: "internal", Service: service.Subdomain, Certificate: "cert", Key: "key"}
+	err := s.Storage.UpsertClientCertificate(cert)
+	c.Check(err, IsNil)
+
+	cert.CA = "ca"
+	s.Storage.UpsertClientCertificate(cert)
+	found, err := s.Storage.FindClientCertificateByNameAndService(cert.Name, service)
+	c.Check(err, IsNil)
+	c.Assert(found, DeepEquals, cert)
+}
+
+func (s *StorableSuite) TestFindClientCertificateByNameAndServiceNotFound(c *C) {
+	_, err := s.Storage.FindClientCertificateByNameAndService("not-found", service)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestFindClientCertificatesByService(c *C) {
+	defer s.Storage.DeleteClientCertificatesByService(service)
+	cert := account.ClientCertificate{Name: "internal", Service: service.Subdomain, CA: "ca"}
+	s.Storage.UpsertClientCertificate(cert)
+
+	certs, err := s.Storage.FindClientCertificatesByService(service)
+	c.Check(err, IsNil)
+	c.Assert(certs, DeepEquals, []account.ClientCertificate{cert})
+}
+
+func (s *StorableSuite) TestDeleteClientCertificate(c *C) {
+	cert := account.ClientCertificate{Name: "internal", Service: service.Subdomain}
+	s.Storage.UpsertClientCertificate(cert)
+	err := s.Storage.DeleteClientCertificate(cert)
+	c.Check(err, IsNil)
+
+	_, err = s.Storage.FindClientCertificateByNameAndService(cert.Name, service)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestDeleteClientCertificateNotFound(c *C) {
+	err := s.Storage.DeleteClientCertificate(account.ClientCertificate{Name: "not-found", Service: service.Subdomain})
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestDeleteClientCertificatesByService(c *C) {
+	s.Storage.UpsertClientCertificate(account.ClientCertificate{Name: "internal", Service: service.Subdomain})
+	err := s.Storage.DeleteClientCertificatesByService(service)
+	c.Check(err, IsNil)
+
+	certs, _ := s.Storage.FindClientCertificatesByService(service)
+	c.Assert(certs, DeepEquals, []account.ClientCertificate{})
+}
+
+func (s *StorableSuite) TestUpsertEmailToken(c *C) {
+	t := account.EmailToken{Id: "token-id", Email: user.Email, Purpose: "password_reset", Hash: "hash", CreatedAt: "2015-05-16T12:40:44Z", ExpiresAt: "2015-05-16T13:40:44Z"}
+	defer s.Storage.DeleteEmailToken(t)
+	err := s.Storage.UpsertEmailToken(t)
+	c.Check(err, IsNil)
+
+	found, err := s.Storage.FindEmailTokenById(t.Id)
+	c.Check(err, IsNil)
+	c.Assert(found, DeepEquals, t)
+}
+
+func (s *StorableSuite) TestFindEmailTokenByIdNotFound(c *C) {
+	_, err := s.Storage.FindEmailTokenById("not-found")
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestDeleteEmailToken(c *C) {
+	t := account.EmailToken{Id: "token-id", Email: user.Email, Purpose: "password_reset"}
+	s.Storage.UpsertEmailToken(t)
+	err := s.Storage.DeleteEmailToken(t)
+	c.Check(err, IsNil)
+
+	err = s.Storage.DeleteEmailToken(t)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestDeleteEmailTokens(c *C) {
+	reset := account.EmailToken{Id: "reset-id", Email: user.Email, Purpose: "password_reset"}
+	verification := account.EmailToken{Id: "verification-id", Email: user.Email, Purpose: "email_verification"}
+	defer s.Storage.DeleteEmailToken(verification)
+	s.Storage.UpsertEmailToken(reset)
+	s.Storage.UpsertEmailToken(verification)
+
+	err := s.Storage.DeleteEmailTokens(user.Email, "password_reset")
+	c.Check(err, IsNil)
+	_, err = s.Storage.FindEmailTokenById(reset.Id)
+	c.Assert(err, NotNil)
+	_, err = s.Storage.FindEmailTokenById(verification.Id)
+	c.Assert(err, IsNil)
+}
+
+func (s *StorableSuite) TestUpsertInvitation(c *C) {
+	defer s.Storage.DeleteInvitationsByTeam(team)
+	inv := account.Invitation{Id: "invitation-id", Team: team.Alias, Email: "bob@example.org", Role: "viewer", Inviter: user.Email, Hash: "hash", CreatedAt: "2015-05-16T12:40:44Z", ExpiresAt: "2015-05-23T12:40:44Z"}
+	err := s.Storage.UpsertInvitation(inv)
+	c.Check(err, IsNil)
+
+	inv.Role = "admin"
+	s.Storage.UpsertInvitation(inv)
+	found, err := s.Storage.FindInvitationById(inv.Id)
+	c.Check(err, IsNil)
+	c.Assert(found, DeepEquals, inv)
+}
+
+func (s *StorableSuite) TestFindInvitationByIdNotFound(c *C) {
+	_, err := s.Storage.FindInvitationById("not-found")
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestFindInvitationsByTeamAndEmail(c *C) {
+	defer s.Storage.DeleteInvitationsByTeam(team)
+	inv := account.Invitation{Id: "invitation-id", Team: team.Alias, Email: "bob@example.org", Role: "viewer", Inviter: user.Email, Hash: "hash"}
+	s.Storage.UpsertInvitation(inv)
+
+	invitations, err := s.Storage.FindInvitationsByTeam(team)
+	c.Check(err, IsNil)
+	c.Assert(invitations, DeepEquals, []account.Invitation{inv})
+
+	invitations, err = s.Storage.FindInvitationsByEmail(inv.Email)
+	c.Check(err, IsNil)
+	c.Assert(invitations, DeepEquals, []account.Invitation{inv})
+
+	invitations, err = s.Storage.FindInvitationsByEmail("not-found@example.org")
+	c.Check(err, IsNil)
+	c.Assert(invitations, DeepEquals, []account.Invitation{})
+}
+
+func (s *StorableSuite) TestDeleteInvitation(c *C) {
+	inv := account.Invitation{Id: "invitation-id", Team: team.Alias, Email: "bob@example.org"}
+	s.Storage.UpsertInvitation(inv)
+	err := s.Storage.DeleteInvitation(inv)
+	c.Check(err, IsNil)
+
+	_, err = s.Storage.FindInvitationById(inv.Id)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestDeleteInvitationNotFound(c *C) {
+	err := s.Storage.DeleteInvitation(account.Invitation{Id: "not-found"})
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestDeleteInvitationsByTeam(c *C) {
+	s.Storage.UpsertInvitation(account.Invitation{Id: "invitation-id", Team: team.Alias, Email: "bob@example.org"})
+	err := s.Storage.DeleteInvitationsByTeam(team)
+	c.Check(err, IsNil)
+
+	invitations, _ := s.Storage.FindInvitationsByTeam(team)
+	c.Assert(invitations, DeepEquals, []account.Invitation{})
+}
+
+func (s *StorableSuite) TestUpsertDomain(c *C) {
+	defer s.Storage.DeleteDomainsByTeam(team)
+	d := account.Domain{Name: "example.org", Team: team.Alias, Token: "token", CreatedAt: "2015-05-16T12:40:44Z"}
+	err := s.Storage.UpsertDomain(d)
+	c.Check(err, IsNil)
+
+	d.VerifiedAt = "2015-05-16T12:45:44Z"
+	s.Storage.UpsertDomain(d)
+	found, err := s.Storage.FindDomainByNameAndTeam(d.Name, team.Alias)
+	c.Check(err, IsNil)
+	c.Assert(found, DeepEquals, d)
+}
+
+func (s *StorableSuite) TestFindDomainByNameAndTeamNotFound(c *C) {
+	_, err := s.Storage.FindDomainByNameAndTeam("example.org", "not-found")
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestFindDomainsByNameAndTeam(c *C) {
+	other := account.Team{Alias: "other"}
+	defer s.Storage.DeleteDomainsByTeam(team)
+	defer s.Storage.DeleteDomainsByTeam(other)
+	d := account.Domain{Name: "example.org", Team: team.Alias, Token: "token"}
+	s.Storage.UpsertDomain(d)
+	o := account.Domain{Name: "example.org", Team: other.Alias, Token: "other"}
+	s.Storage.UpsertDomain(o)
+
+	domains, err := s.Storage.FindDomainsByTeam(team)
+	c.Check(err, IsNil)
+	c.Assert(domains, DeepEquals, []account.Domain{d})
+
+	domains, err = s.Storage.FindDomainsByName(d.Name)
+	c.Check(err, IsNil)
+	c.Assert(domains, HasLen, 2)
+
+	domains, err = s.Storage.FindDomainsByName("not-found.org")
+	c.Check(err, IsNil)
+	c.Assert(domains, DeepEquals, []account.Domain{})
+}
+
+func (s *StorableSuite) TestDeleteDomain(c *C) {
+	d := account.Domain{Name: "example.org", Team: team.Alias}
+	s.Storage.UpsertDomain(d)
+	err := s.Storage.DeleteDomain(d)
+	c.Check(err, IsNil)
+
+	_, err = s.Storage.FindDomainByNameAndTeam(d.Name, d.Team)
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestDeleteDomainNotFound(c *C) {
+	err := s.Storage.DeleteDomain(account.Domain{Name: "not-found.org", Team: team.Alias})
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StorableSuite) TestDeleteDomainsByTeam(c *C) {
+	s.Storage.UpsertDomain(account.Domain{Name: "example.org", Team: team.Alias})
+	err := s.Storage.DeleteDomainsByTeam(team)
+	c.Check(err, IsNil)
+
+	domains, _ := s.Storage.FindDomainsByTeam(team)
+	c.Assert(domains, DeepEquals, []account.Domain{})
+}
+
+func (s *StorableSuite) TestDeleteUpstreamStatesByService(c *C) {
+	s.Storage.UpsertUpstreamState(account.UpstreamState{Service: service.Subdomain, Target: service.Endpoint})
+	err := s.Storage.DeleteUpstreamStatesByService(service)
+	c.Check(err, IsNil)
+
+	states, _ := s.Storage.FindUpstreamStatesByService(service)
+	c.Assert(states, DeepEquals, []account.UpstreamState{})
+}
+
 func (s *StorableSuite) TestUpsertHook(c *C) {
 	defer s.Storage.DeleteHook(hook)
 	err := s.Storage.UpsertHook(hook)
@@ -1,9 +1,29 @@
 package account
 
+// Token is a session of a user, created when logging in. The access token is sent in the Authorization header
+// until it expires, and the refresh token is exchanged for a new access token while the session lasts.
+//
+// A user may have many sessions at the same time, one for each client that logged in.
 type Token struct {
-	AccessToken string `json:"access_token"`
-	CreatedAt   string `json:"created_at"`
-	Expires     int    `json:"expires"`
-	Type        string `json:"token_type"`
-	User        *User  `json:"-"`
+	Id               string `json:"id"`
+	AccessToken      string `json:"access_token,omitempty"`
+	RefreshToken     string `json:"refresh_token,omitempty"`
+	User             string `json:"-"`
+	UserAgent        string `json:"user_agent,omitempty"`
+	CreatedAt        string `json:"created_at"`
+	LastUsedAt       string `json:"last_used_at,omitempty"`
+	Expires          int    `json:"expires"`
+	ExpiresAt        string `json:"expires_at"`
+	RefreshExpiresAt string `json:"refresh_expires_at,omitempty"`
+	Type             string `json:"token_type"`
+}
+
+// Expired reports whether the access token has expired.
+func (t Token) Expired() bool {
+	return expired(t.ExpiresAt)
+}
+
+// RefreshExpired reports whether the session has expired, so the refresh token is no longer accepted.
+func (t Token) RefreshExpired() bool {
+	return expired(t.RefreshExpiresAt)
 }
@@ -0,0 +1,237 @@
+package account
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/apihub/apihub/errors"
+)
+
+const (
+	ROUND_ROBIN       string = "round-robin"
+	LEAST_CONNECTIONS string = "least-connections"
+	CONSISTENT_HASH   string = "consistent-hash"
+
+	CIRCUIT_CLOSED    string = "closed"
+	CIRCUIT_OPEN      string = "open"
+	CIRCUIT_HALF_OPEN string = "half-open"
+)
+
+// Upstream is one of the targets (replicas) which are able to respond the requests of a service.
+// The `Weight` is relative to the other upstreams of the same service and defaults to 1.
+type Upstream struct {
+	Target string `json:"target"`
+	Weight int    `json:"weight,omitempty"`
+}
+
+// LoadBalancer describes how the gateway picks an upstream for each request,
+// and how many consecutive failures eject an upstream for `Cooldown` seconds.
+//
+// The consistent-hash strategy uses the value of `HashHeader` as key,
+// or the client ip if it is not informed.
+type LoadBalancer struct {
+	Strategy   string `json:"strategy,omitempty"`
+	HashHeader string `json:"hash_header,omitempty"`
+	MaxFails   int    `json:"max_fails,omitempty"`
+	Cooldown   int    `json:"cooldown,omitempty"`
+}
+
+// HealthCheck describes how the gateway actively probes the upstreams of a service.
+// Every `Interval` seconds, a GET request is sent to `Path` on each upstream, and a response
+// with a status other than `ExpectedStatus` (or no response within `Timeout` seconds) is a failure.
+// An upstream is marked down after `UnhealthyThreshold` consecutive failures and marked up
+// again after `HealthyThreshold` consecutive successes.
+type HealthCheck struct {
+	Path               string `json:"path"`
+	Interval           int    `json:"interval,omitempty"`
+	Timeout            int    `json:"timeout,omitempty"`
+	ExpectedStatus     int    `json:"expected_status,omitempty"`
+	HealthyThreshold   int    `json:"healthy_threshold,omitempty"`
+	UnhealthyThreshold int    `json:"unhealthy_threshold,omitempty"`
+}
+
+// Timeouts describes, in milliseconds, how long the gateway waits for the upstreams of a service.
+// `Connect` bounds the time to open a connection, `ResponseHeader` the time to receive the response
+// headers once the request is sent, and `Total` the whole request, including retries and the response body.
+// Connections, either kept alive or upgraded, are closed after `Idle` milliseconds without traffic.
+// Unset values default to the `Timeout` of the service, except `Total`, which is unlimited.
+type Timeouts struct {
+	Connect        int `json:"connect,omitempty"`
+	ResponseHeader int `json:"response_header,omitempty"`
+	Total          int `json:"total,omitempty"`
+	Idle           int `json:"idle,omitempty"`
+}
+
+// Retry describes how the gateway retries the requests which fail to reach the upstreams,
+// or which are answered with one of the `StatusCodes`, picking another upstream when available.
+// At most `MaxAttempts` are sent, waiting `Backoff` milliseconds before the first retry and
+// doubling it on each retry, up to `MaxBackoff` milliseconds.
+// Only the idempotent methods are retried, unless other `Methods` are informed.
+type Retry struct {
+	MaxAttempts int      `json:"max_attempts,omitempty"`
+	Backoff     int      `json:"backoff,omitempty"`
+	MaxBackoff  int      `json:"max_backoff,omitempty"`
+	StatusCodes []int    `json:"status_codes,omitempty"`
+	Methods     []string `json:"methods,omitempty"`
+}
+
+// CircuitBreaker describes when the gateway stops sending requests to a service.
+// The circuit opens when at least `ErrorThreshold` percent of the requests, out of at least
+// `MinRequests` in a window of `Window` seconds, fail or are answered with a 5xx status.
+// After `Cooldown` seconds, up to `HalfOpenRequests` requests are let through to probe the service:
+// the circuit closes if all of them succeed and opens again otherwise.
+type CircuitBreaker struct {
+	ErrorThreshold   int `json:"error_threshold,omitempty"`
+	MinRequests      int `json:"min_requests,omitempty"`
+	Window           int `json:"window,omitempty"`
+	Cooldown         int `json:"cooldown,omitempty"`
+	HalfOpenRequests int `json:"half_open_requests,omitempty"`
+}
+
+// CircuitBreakerState is the state of the circuit breaker of a service, as seen by the gateway.
+type CircuitBreakerState struct {
+	Service   string `json:"service"`
+	State     string `json:"state"`
+	Requests  int    `json:"requests"`
+	Failures  int    `json:"failures"`
+	OpenUntil string `json:"open_until,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+// UpstreamState is the health of an upstream, as seen by the gateway.
+type UpstreamState struct {
+	Service      string `json:"service"`
+	Target       string `json:"target"`
+	Healthy      bool   `json:"healthy"`
+	Failures     int    `json:"failures"`
+	EjectedUntil string `json:"ejected_until,omitempty"`
+	UpdatedAt    string `json:"updated_at,omitempty"`
+}
+
+// Targets returns the upstreams of the service.
+// The `Endpoint` is used as the only upstream when no upstream is declared.
+func (service Service) Targets() []Upstream {
+	if len(service.Upstreams) == 0 {
+		if service.Endpoint == "" {
+			return []Upstream{}
+		}
+		return []Upstream{{Target: service.Endpoint, Weight: 1}}
+	}
+
+	upstreams := make([]Upstream, len(service.Upstreams))
+	for i, u := range service.Upstreams {
+		if u.Weight <= 0 {
+			u.Weight = 1
+		}
+		upstreams[i] = u
+	}
+	return upstreams
+}
+
+func (u Upstream) valid() error {
+	if target, err := url.Parse(u.Target); err != nil || target.Scheme == "" || target.Host == "" {
+		return errors.NewValidationError(errors.ErrServiceInvalidUpstream)
+	}
+	if u.Weight < 0 {
+		return errors.NewValidationError(errors.ErrServiceInvalidUpstream)
+	}
+	return nil
+}
+
+func (lb LoadBalancer) valid() error {
+	switch lb.Strategy {
+	case "", ROUND_ROBIN, LEAST_CONNECTIONS, CONSISTENT_HASH:
+	default:
+		return errors.NewValidationError(errors.ErrServiceInvalidLoadBalancer)
+	}
+	if lb.MaxFails < 0 || lb.Cooldown < 0 {
+		return errors.NewValidationError(errors.ErrServiceInvalidLoadBalancer)
+	}
+	return nil
+}
+
+func (hc HealthCheck) valid() error {
+	if !strings.HasPrefix(hc.Path, "/") {
+		return errors.NewValidationError(errors.ErrServiceInvalidHealthCheck)
+	}
+	if hc.Interval < 0 || hc.Timeout < 0 || hc.HealthyThreshold < 0 || hc.UnhealthyThreshold < 0 {
+		return errors.NewValidationError(errors.ErrServiceInvalidHealthCheck)
+	}
+	if hc.ExpectedStatus != 0 && (hc.ExpectedStatus < 100 || hc.ExpectedStatus > 599) {
+		return errors.NewValidationError(errors.ErrServiceInvalidHealthCheck)
+	}
+	return nil
+}
+
+func (t Timeouts) valid() error {
+	if t.Connect < 0 || t.ResponseHeader < 0 || t.Total < 0 || t.Idle < 0 {
+		return errors.NewValidationError(errors.ErrServiceInvalidTimeouts)
+	}
+	return nil
+}
+
+func (r Retry) valid() error {
+	if r.MaxAttempts < 0 || r.Backoff < 0 || r.MaxBackoff < 0 {
+		return errors.NewValidationError(errors.ErrServiceInvalidRetry)
+	}
+	for _, code := range r.StatusCodes {
+		if code < 100 || code > 599 {
+			return errors.NewValidationError(errors.ErrServiceInvalidRetry)
+		}
+	}
+	for _, method := range r.Methods {
+		if !httpMethods[strings.ToUpper(method)] {
+			return errors.NewValidationError(errors.ErrServiceInvalidRetry)
+		}
+	}
+	return nil
+}
+
+func (cb CircuitBreaker) valid() error {
+	if cb.ErrorThreshold < 0 || cb.ErrorThreshold > 100 {
+		return errors.NewValidationError(errors.ErrServiceInvalidCircuitBreaker)
+	}
+	if cb.MinRequests < 0 || cb.Window < 0 || cb.Cooldown < 0 || cb.HalfOpenRequests < 0 {
+		return errors.NewValidationError(errors.ErrServiceInvalidCircuitBreaker)
+	}
+	return nil
+}
+
+// CircuitBreakerState returns the state of the circuit breaker of the service.
+// Services without circuit breaker, or whose circuit has never changed, are reported as closed.
+func (service Service) CircuitBreakerState() (*CircuitBreakerState, error) {
+	closed := &CircuitBreakerState{Service: service.Subdomain, State: CIRCUIT_CLOSED}
+	if service.CircuitBreaker == nil {
+		return closed, nil
+	}
+
+	state, err := store.FindCircuitBreakerStateByService(service)
+	if err != nil {
+		if _, ok := err.(errors.NotFoundError); ok {
+			return closed, nil
+		}
+		return nil, err
+	}
+	return &state, nil
+}
+
+// UpstreamStates returns the health of each upstream of the service.
+// Upstreams that have never failed are reported as healthy.
+func (service Service) UpstreamStates() ([]UpstreamState, error) {
+	stored, err := store.FindUpstreamStatesByService(service)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := service.Targets()
+	states := make([]UpstreamState, len(targets))
+	for i, target := range targets {
+		states[i] = UpstreamState{Service: service.Subdomain, Target: target.Target, Healthy: true}
+		for _, state := range stored {
+			if state.Target == target.Target {
+				states[i] = state
+			}
+		}
+	}
+	return states, nil
+}
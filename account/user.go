@@ -8,10 +8,12 @@ import (
 
 // The User type is an encapsulation of a user details.
 // A valid user is capable to interact with the API to manage teams and services.
+// `EmailVerified` indicates that the user has confirmed the email, following the link sent on signup.
 type User struct {
-	Name     string `json:"name,omitempty"`
-	Email    string `json:"email,omitempty"`
-	Password string `json:"password,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Email         string `json:"email,omitempty"`
+	Password      string `json:"password,omitempty"`
+	EmailVerified bool   `json:"email_verified,omitempty"`
 }
 
 // Create creates a new user account.
@@ -45,7 +47,8 @@ func (user *User) ChangePassword() error {
 // Delete removes an existing user from the server.
 //
 // All the teams and services which the corresponding user
-// is the only member are deleted along with the user account.
+// is the only member are deleted along with the user account,
+// as well as the sessions and personal access tokens of the user.
 // It returns an error if the user is not found.
 func (user User) Delete() error {
 	go func() {
@@ -55,6 +58,12 @@ func (user User) Delete() error {
 			team.Delete(user)
 		}
 	}()
+	if err := store.DeleteTokensByUser(user.Email); err != nil {
+		Logger.Warn("Failed to delete the sessions of %s: %+v.", user.Email, err)
+	}
+	if err := store.DeletePersonalAccessTokensByUser(user.Email); err != nil {
+		Logger.Warn("Failed to delete the personal access tokens of %s: %+v.", user.Email, err)
+	}
 
 	return store.DeleteUser(user)
 }
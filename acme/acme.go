@@ -0,0 +1,439 @@
+// Package acme implements a client of the ACME protocol (RFC 8555), which obtains certificates
+// from authorities such as Let's Encrypt, validating the domains through the http-01 challenge.
+package acme
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// CHALLENGE_PATH is where the ACME server fetches the key authorizations of the http-01 challenges.
+	CHALLENGE_PATH = "/.well-known/acme-challenge/"
+
+	STATUS_PENDING    = "pending"
+	STATUS_PROCESSING = "processing"
+	STATUS_VALID      = "valid"
+	STATUS_INVALID    = "invalid"
+
+	DEFAULT_POLL_INTERVAL = time.Second
+	DEFAULT_POLL_TIMEOUT  = time.Minute
+)
+
+// Error is a problem reported by the ACME server.
+type Error struct {
+	Status int    `json:"status"`
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("acme: %d %s: %s", e.Status, e.Type, e.Detail)
+}
+
+// Solver presents the key authorizations of the http-01 challenges, so the ACME server is able to fetch them.
+type Solver interface {
+	Present(token, keyAuthorization string)
+	CleanUp(token string)
+}
+
+// HTTPSolver keeps the key authorizations in memory, serving them on CHALLENGE_PATH.
+type HTTPSolver struct {
+	mtx    sync.RWMutex
+	tokens map[string]string
+}
+
+func NewHTTPSolver() *HTTPSolver {
+	return &HTTPSolver{tokens: make(map[string]string)}
+}
+
+func (s *HTTPSolver) Present(token, keyAuthorization string) {
+	s.mtx.Lock()
+	s.tokens[token] = keyAuthorization
+	s.mtx.Unlock()
+}
+
+func (s *HTTPSolver) CleanUp(token string) {
+	s.mtx.Lock()
+	delete(s.tokens, token)
+	s.mtx.Unlock()
+}
+
+func (s *HTTPSolver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.RLock()
+	keyAuthorization, ok := s.tokens[strings.TrimPrefix(r.URL.Path, CHALLENGE_PATH)]
+	s.mtx.RUnlock()
+	if !ok || !IsChallenge(r) {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(keyAuthorization))
+}
+
+// IsChallenge reports whether the request is sent by the ACME server to validate a domain.
+func IsChallenge(r *http.Request) bool {
+	return r.Method == "GET" && strings.HasPrefix(r.URL.Path, CHALLENGE_PATH)
+}
+
+type directory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+}
+
+type identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type order struct {
+	Status         string       `json:"status"`
+	Identifiers    []identifier `json:"identifiers"`
+	Authorizations []string     `json:"authorizations"`
+	Finalize       string       `json:"finalize"`
+	Certificate    string       `json:"certificate,omitempty"`
+	Error          *Error       `json:"error,omitempty"`
+}
+
+type challenge struct {
+	Type   string `json:"type"`
+	URL    string `json:"url"`
+	Token  string `json:"token"`
+	Status string `json:"status"`
+	Error  *Error `json:"error,omitempty"`
+}
+
+type authorization struct {
+	Status     string      `json:"status"`
+	Identifier identifier  `json:"identifier"`
+	Challenges []challenge `json:"challenges"`
+}
+
+// jwk is the public key of the account, with the members in the order required by the thumbprint (RFC 7638).
+type jwk struct {
+	Crv string `json:"crv"`
+	Kty string `json:"kty"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type protectedHeader struct {
+	Alg   string `json:"alg"`
+	Jwk   *jwk   `json:"jwk,omitempty"`
+	Kid   string `json:"kid,omitempty"`
+	Nonce string `json:"nonce"`
+	URL   string `json:"url"`
+}
+
+// Client obtains certificates on behalf of an account, which is registered on the first order.
+// The account is identified by its key, which should be kept to obtain further certificates.
+type Client struct {
+	DirectoryURL string
+	Email        string
+	Key          *ecdsa.PrivateKey
+	HTTPClient   *http.Client
+	PollInterval time.Duration
+	PollTimeout  time.Duration
+
+	mtx    sync.Mutex
+	dir    *directory
+	kid    string
+	nonces []string
+}
+
+// NewClient returns a client of the ACME server, generating a new account key when none is given.
+func NewClient(directoryURL, email string, key *ecdsa.PrivateKey) (*Client, error) {
+	if key == nil {
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			return nil, err
+		}
+	}
+	return &Client{
+		DirectoryURL: directoryURL,
+		Email:        email,
+		Key:          key,
+		HTTPClient:   http.DefaultClient,
+		PollInterval: DEFAULT_POLL_INTERVAL,
+		PollTimeout:  DEFAULT_POLL_TIMEOUT,
+	}, nil
+}
+
+// Obtain orders a certificate for the domains, presenting the challenges through the solver.
+// It returns the PEM encoded certificate chain and the PEM encoded key of the certificate.
+func (c *Client) Obtain(solver Solver, domains ...string) ([]byte, []byte, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if err := c.register(); err != nil {
+		return nil, nil, err
+	}
+
+	ids := []identifier{}
+	for _, domain := range domains {
+		ids = append(ids, identifier{Type: "dns", Value: domain})
+	}
+	var o order
+	res, err := c.post(c.dir.NewOrder, map[string]interface{}{"identifiers": ids}, &o)
+	if err != nil {
+		return nil, nil, err
+	}
+	orderURL := res.Header.Get("Location")
+
+	for _, authzURL := range o.Authorizations {
+		if err := c.authorize(solver, authzURL); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := c.post(o.Finalize, map[string]string{"csr": encode(csr)}, &o); err != nil {
+		return nil, nil, err
+	}
+	if err := c.poll(orderURL, &o, func() string { return o.Status }); err != nil {
+		return nil, nil, err
+	}
+
+	res, err = c.post(o.Certificate, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	chain, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return chain, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), nil
+}
+
+// register creates the account, or finds it when the key is already registered.
+func (c *Client) register() error {
+	if c.kid != "" {
+		return nil
+	}
+	if c.dir == nil {
+		res, err := c.HTTPClient.Get(c.DirectoryURL)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		var dir directory
+		if err := json.NewDecoder(res.Body).Decode(&dir); err != nil {
+			return err
+		}
+		c.dir = &dir
+	}
+
+	account := map[string]interface{}{"termsOfServiceAgreed": true}
+	if c.Email != "" {
+		account["contact"] = []string{"mailto:" + c.Email}
+	}
+	res, err := c.post(c.dir.NewAccount, account, nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	c.kid = res.Header.Get("Location")
+	return nil
+}
+
+// authorize answers the http-01 challenge of the authorization, waiting until it is validated.
+func (c *Client) authorize(solver Solver, authzURL string) error {
+	var authz authorization
+	if _, err := c.post(authzURL, nil, &authz); err != nil {
+		return err
+	}
+	if authz.Status == STATUS_VALID {
+		return nil
+	}
+
+	var chal *challenge
+	for i := range authz.Challenges {
+		if authz.Challenges[i].Type == "http-01" {
+			chal = &authz.Challenges[i]
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("acme: no http-01 challenge for %s", authz.Identifier.Value)
+	}
+
+	solver.Present(chal.Token, chal.Token+"."+c.thumbprint())
+	defer solver.CleanUp(chal.Token)
+	res, err := c.post(chal.URL, struct{}{}, nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return c.poll(authzURL, &authz, func() string { return authz.Status })
+}
+
+// poll fetches the resource until its status is no longer pending nor processing.
+func (c *Client) poll(url string, out interface{}, status func() string) error {
+	deadline := time.Now().Add(c.PollTimeout)
+	for {
+		switch status() {
+		case STATUS_VALID:
+			return nil
+		case STATUS_PENDING, STATUS_PROCESSING, "ready":
+		default:
+			return fmt.Errorf("acme: %s is %s", url, status())
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("acme: timed out waiting for %s", url)
+		}
+		time.Sleep(c.PollInterval)
+		if _, err := c.post(url, nil, out); err != nil {
+			return err
+		}
+	}
+}
+
+// post sends a signed request to the ACME server, decoding the response into out.
+// A nil payload sends a POST-as-GET request. Requests rejected for a bad nonce are sent once again.
+func (c *Client) post(url string, payload interface{}, out interface{}) (*http.Response, error) {
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	for retry := 0; ; retry++ {
+		jws, err := c.sign(url, body)
+		if err != nil {
+			return nil, err
+		}
+		res, err := c.HTTPClient.Post(url, "application/jose+json", bytes.NewReader(jws))
+		if err != nil {
+			return nil, err
+		}
+		if nonce := res.Header.Get("Replay-Nonce"); nonce != "" {
+			c.nonces = append(c.nonces, nonce)
+		}
+
+		if res.StatusCode >= http.StatusBadRequest {
+			problem := &Error{Status: res.StatusCode}
+			json.NewDecoder(res.Body).Decode(problem)
+			res.Body.Close()
+			if problem.Type == "urn:ietf:params:acme:error:badNonce" && retry == 0 {
+				continue
+			}
+			return nil, problem
+		}
+		if out != nil {
+			defer res.Body.Close()
+			if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	}
+}
+
+// sign wraps the payload in a JWS, identifying the account by its key until it is registered.
+func (c *Client) sign(url string, payload []byte) ([]byte, error) {
+	nonce, err := c.nonce()
+	if err != nil {
+		return nil, err
+	}
+	header := protectedHeader{Alg: "ES256", Kid: c.kid, Nonce: nonce, URL: url}
+	if c.kid == "" {
+		key := c.jwk()
+		header.Jwk = &key
+	}
+	protected, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	input := encode(protected) + "." + encode(payload)
+	hash := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, c.Key, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	signature := append(pad(r.Bytes(), 32), pad(s.Bytes(), 32)...)
+
+	return json.Marshal(map[string]string{
+		"protected": encode(protected),
+		"payload":   encode(payload),
+		"signature": encode(signature),
+	})
+}
+
+func (c *Client) nonce() (string, error) {
+	if n := len(c.nonces); n > 0 {
+		nonce := c.nonces[n-1]
+		c.nonces = c.nonces[:n-1]
+		return nonce, nil
+	}
+
+	res, err := c.HTTPClient.Head(c.dir.NewNonce)
+	if err != nil {
+		return "", err
+	}
+	res.Body.Close()
+	nonce := res.Header.Get("Replay-Nonce")
+	if nonce == "" {
+		return "", fmt.Errorf("acme: no nonce from %s", c.dir.NewNonce)
+	}
+	return nonce, nil
+}
+
+func (c *Client) jwk() jwk {
+	return jwk{
+		Crv: "P-256",
+		Kty: "EC",
+		X:   encode(pad(c.Key.X.Bytes(), 32)),
+		Y:   encode(pad(c.Key.Y.Bytes(), 32)),
+	}
+}
+
+// thumbprint identifies the account key in the key authorizations.
+func (c *Client) thumbprint() string {
+	key, _ := json.Marshal(c.jwk())
+	hash := sha256.Sum256(key)
+	return encode(hash[:])
+}
+
+func encode(b []byte) string {
+	return strings.TrimRight(base64.URLEncoding.EncodeToString(b), "=")
+}
+
+func pad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
@@ -0,0 +1,106 @@
+package acme
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/apihub/apihub/acme/acmetest"
+	. "gopkg.in/check.v1"
+)
+
+func newTestClient(c *C, directoryURL string) *Client {
+	client, err := NewClient(directoryURL, "alice@example.org", nil)
+	c.Assert(err, IsNil)
+	client.PollInterval = time.Millisecond
+	client.PollTimeout = time.Second
+	return client
+}
+
+func newTestServer(c *C, challengeURL string) *acmetest.Server {
+	server, err := acmetest.NewServer()
+	c.Assert(err, IsNil)
+	server.ChallengeURL = challengeURL
+	return server
+}
+
+func (s *S) TestObtain(c *C) {
+	solver := NewHTTPSolver()
+	challenges := httptest.NewServer(solver)
+	defer challenges.Close()
+	server := newTestServer(c, challenges.URL)
+	defer server.Close()
+
+	client := newTestClient(c, server.DirectoryURL())
+	chain, key, err := client.Obtain(solver, "api.example.org")
+	c.Assert(err, IsNil)
+
+	pair, err := tls.X509KeyPair(chain, key)
+	c.Assert(err, IsNil)
+	leaf, _ := x509.ParseCertificate(pair.Certificate[0])
+	c.Assert(leaf.DNSNames, DeepEquals, []string{"api.example.org"})
+	c.Assert(leaf.CheckSignatureFrom(server.CA), IsNil)
+
+	// The challenge is no longer served.
+	res, _ := http.Get(challenges.URL + CHALLENGE_PATH + "http-token")
+	c.Assert(res.StatusCode, Equals, http.StatusNotFound)
+}
+
+func (s *S) TestObtainReusesTheAccount(c *C) {
+	solver := NewHTTPSolver()
+	challenges := httptest.NewServer(solver)
+	defer challenges.Close()
+	server := newTestServer(c, challenges.URL)
+	defer server.Close()
+
+	client := newTestClient(c, server.DirectoryURL())
+	_, _, err := client.Obtain(solver, "api.example.org")
+	c.Assert(err, IsNil)
+	kid := client.kid
+	_, _, err = client.Obtain(solver, "www.example.org")
+	c.Assert(err, IsNil)
+	c.Assert(client.kid, Equals, kid)
+}
+
+func (s *S) TestObtainRetriesBadNonce(c *C) {
+	solver := NewHTTPSolver()
+	challenges := httptest.NewServer(solver)
+	defer challenges.Close()
+	server := newTestServer(c, challenges.URL)
+	server.BadNonces = 1
+	defer server.Close()
+
+	client := newTestClient(c, server.DirectoryURL())
+	_, _, err := client.Obtain(solver, "api.example.org")
+	c.Assert(err, IsNil)
+}
+
+func (s *S) TestObtainWithFailedChallenge(c *C) {
+	challenges := httptest.NewServer(http.NotFoundHandler())
+	defer challenges.Close()
+	server := newTestServer(c, challenges.URL)
+	defer server.Close()
+
+	client := newTestClient(c, server.DirectoryURL())
+	_, _, err := client.Obtain(NewHTTPSolver(), "api.example.org")
+	c.Assert(err, ErrorMatches, "acme: .*/authz/1 is invalid")
+}
+
+func (s *S) TestHTTPSolver(c *C) {
+	solver := NewHTTPSolver()
+	solver.Present("token", "token.thumbprint")
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://api.example.org"+CHALLENGE_PATH+"token", nil)
+	c.Assert(IsChallenge(r), Equals, true)
+	solver.ServeHTTP(w, r)
+	c.Assert(w.Code, Equals, http.StatusOK)
+	c.Assert(w.Body.String(), Equals, "token.thumbprint")
+
+	solver.CleanUp("token")
+	w = httptest.NewRecorder()
+	solver.ServeHTTP(w, r)
+	c.Assert(w.Code, Equals, http.StatusNotFound)
+}
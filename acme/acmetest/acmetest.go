@@ -0,0 +1,285 @@
+// Package acmetest provides a minimal ACME server, for testing the clients of the protocol.
+package acmetest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+const CHALLENGE_PATH = "/.well-known/acme-challenge/"
+
+type jwk struct {
+	Crv string `json:"crv"`
+	Kty string `json:"kty"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type protectedHeader struct {
+	Alg   string `json:"alg"`
+	Jwk   *jwk   `json:"jwk,omitempty"`
+	Kid   string `json:"kid,omitempty"`
+	Nonce string `json:"nonce"`
+	URL   string `json:"url"`
+}
+
+type identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type order struct {
+	Status         string       `json:"status"`
+	Identifiers    []identifier `json:"identifiers"`
+	Authorizations []string     `json:"authorizations"`
+	Finalize       string       `json:"finalize"`
+	Certificate    string       `json:"certificate,omitempty"`
+}
+
+type challenge struct {
+	Type  string `json:"type"`
+	URL   string `json:"url"`
+	Token string `json:"token"`
+}
+
+type authorization struct {
+	Status     string      `json:"status"`
+	Identifier identifier  `json:"identifier"`
+	Challenges []challenge `json:"challenges"`
+}
+
+// Server is an ACME server which handles a single order at a time, with a single domain.
+// The http-01 challenge is validated by fetching the key authorization from `ChallengeURL`,
+// which defaults to http://<domain>, and the certificates are signed by its own `CA`.
+type Server struct {
+	*httptest.Server
+	CA           *x509.Certificate
+	ChallengeURL string
+	// BadNonces is the number of requests rejected for a bad nonce before accepting them.
+	BadNonces int
+
+	mtx         sync.Mutex
+	nonce       int
+	nonces      map[string]bool
+	accountKey  *ecdsa.PublicKey
+	accountJwk  jwk
+	authzStatus string
+	order       order
+	chain       []byte
+	caKey       *ecdsa.PrivateKey
+}
+
+func NewServer() (*Server, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ApiHub Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{CA: ca, caKey: caKey, nonces: map[string]bool{}}
+	s.Server = httptest.NewServer(s)
+	return s, nil
+}
+
+// DirectoryURL is the address given to the clients.
+func (s *Server) DirectoryURL() string {
+	return s.URL + "/directory"
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if r.URL.Path == "/directory" {
+		json.NewEncoder(w).Encode(map[string]string{"newNonce": s.URL + "/nonce", "newAccount": s.URL + "/account", "newOrder": s.URL + "/order"})
+		return
+	}
+	s.newNonce(w)
+	if r.URL.Path == "/nonce" {
+		return
+	}
+
+	payload, ok := s.verify(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.URL.Path {
+	case "/account":
+		w.Header().Set("Location", s.URL+"/account/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"status":"valid"}`))
+	case "/order":
+		s.order = order{}
+		json.Unmarshal(payload, &s.order)
+		s.order.Status = "pending"
+		s.order.Authorizations = []string{s.URL + "/authz/1"}
+		s.order.Finalize = s.URL + "/finalize/1"
+		s.authzStatus = "pending"
+		w.Header().Set("Location", s.URL+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(s.order)
+	case "/order/1":
+		json.NewEncoder(w).Encode(s.order)
+	case "/authz/1":
+		json.NewEncoder(w).Encode(authorization{Status: s.authzStatus, Identifier: s.order.Identifiers[0], Challenges: []challenge{
+			{Type: "dns-01", URL: s.URL + "/challenge/2", Token: "dns-token"},
+			{Type: "http-01", URL: s.URL + "/challenge/1", Token: "http-token"},
+		}})
+	case "/challenge/1":
+		s.validate()
+		w.Write([]byte(`{"status":"processing"}`))
+	case "/finalize/1":
+		if s.authzStatus != "valid" {
+			s.problem(w, http.StatusForbidden, "orderNotReady", "The order is not ready.")
+			return
+		}
+		var finalize map[string]string
+		json.Unmarshal(payload, &finalize)
+		if err := s.issue(decode(finalize["csr"])); err != nil {
+			s.problem(w, http.StatusBadRequest, "badCSR", err.Error())
+			return
+		}
+		s.order.Status = "processing"
+		json.NewEncoder(w).Encode(s.order)
+		s.order.Status = "valid"
+		s.order.Certificate = s.URL + "/certificate/1"
+	case "/certificate/1":
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		w.Write(s.chain)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) newNonce(w http.ResponseWriter) {
+	s.nonce++
+	nonce := fmt.Sprintf("nonce-%d", s.nonce)
+	s.nonces[nonce] = true
+	w.Header().Set("Replay-Nonce", nonce)
+}
+
+func (s *Server) problem(w http.ResponseWriter, status int, kind, detail string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": status, "type": "urn:ietf:params:acme:error:" + kind, "detail": detail})
+}
+
+// verify checks the nonce and the signature of the request, returning its payload.
+func (s *Server) verify(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	var jws map[string]string
+	json.NewDecoder(r.Body).Decode(&jws)
+	var header protectedHeader
+	json.Unmarshal(decode(jws["protected"]), &header)
+
+	if !s.nonces[header.Nonce] || s.BadNonces > 0 {
+		s.BadNonces--
+		s.problem(w, http.StatusBadRequest, "badNonce", "The nonce is invalid.")
+		return nil, false
+	}
+	delete(s.nonces, header.Nonce)
+	if header.URL != s.URL+r.URL.Path {
+		s.problem(w, http.StatusUnauthorized, "unauthorized", "The url does not match the request.")
+		return nil, false
+	}
+
+	key := s.accountKey
+	if header.Jwk != nil {
+		x, y := new(big.Int).SetBytes(decode(header.Jwk.X)), new(big.Int).SetBytes(decode(header.Jwk.Y))
+		key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		s.accountJwk = *header.Jwk
+	} else if header.Kid != s.URL+"/account/1" {
+		s.problem(w, http.StatusUnauthorized, "accountDoesNotExist", "The account does not exist.")
+		return nil, false
+	}
+
+	hash := sha256.Sum256([]byte(jws["protected"] + "." + jws["payload"]))
+	signature := decode(jws["signature"])
+	if key == nil || len(signature) != 64 ||
+		!ecdsa.Verify(key, hash[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		s.problem(w, http.StatusUnauthorized, "malformed", "The signature is invalid.")
+		return nil, false
+	}
+	s.accountKey = key
+	return decode(jws["payload"]), true
+}
+
+// validate fetches the key authorization of the http-01 challenge.
+func (s *Server) validate() {
+	s.authzStatus = "invalid"
+	base := s.ChallengeURL
+	if base == "" {
+		base = "http://" + s.order.Identifiers[0].Value
+	}
+	res, err := http.Get(base + CHALLENGE_PATH + "http-token")
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+
+	key, _ := json.Marshal(s.accountJwk)
+	thumbprint := sha256.Sum256(key)
+	if string(body) == "http-token."+encode(thumbprint[:]) {
+		s.authzStatus = "valid"
+	}
+}
+
+func (s *Server) issue(der []byte) error {
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      csr.Subject,
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, s.CA, csr.PublicKey, s.caKey)
+	if err != nil {
+		return err
+	}
+	s.chain = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+	return nil
+}
+
+func encode(b []byte) string {
+	return strings.TrimRight(base64.URLEncoding.EncodeToString(b), "=")
+}
+
+func decode(s string) []byte {
+	b, _ := base64.URLEncoding.DecodeString(s + strings.Repeat("=", (4-len(s)%4)%4))
+	return b
+}
@@ -0,0 +1,104 @@
+// Package analytics records the requests proxied by the gateway and aggregates them by time.
+package analytics
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Record describes a request proxied by the gateway.
+// `Latency` is the time, in milliseconds, until the response of the upstream starts to be written, and `Timestamp`
+// is the unix time the request was received at.
+type Record struct {
+	Service   string `json:"service"`
+	ClientId  string `json:"client_id,omitempty"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Status    int    `json:"status"`
+	Latency   int64  `json:"latency"`
+	BytesIn   int64  `json:"bytes_in"`
+	BytesOut  int64  `json:"bytes_out"`
+	RequestId string `json:"request_id,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Sink receives the records emitted by the gateway.
+// It is called while serving the requests, so it is not supposed to block.
+type Sink interface {
+	Write(Record)
+}
+
+// Reader finds the records of a service received in the interval [from, to).
+type Reader interface {
+	Records(service string, from, to time.Time) ([]Record, error)
+}
+
+// Bucket aggregates the records received in an interval starting at `Time`.
+// `Errors` counts the responses with status 5xx, and `Statuses` counts the responses by status class (2xx, 4xx...).
+type Bucket struct {
+	Time       string         `json:"time"`
+	Requests   int            `json:"requests"`
+	Errors     int            `json:"errors"`
+	AvgLatency float64        `json:"avg_latency"`
+	BytesIn    int64          `json:"bytes_in"`
+	BytesOut   int64          `json:"bytes_out"`
+	Statuses   map[string]int `json:"statuses,omitempty"`
+}
+
+// Aggregate groups the records in buckets of the given interval, from `from` until `to`.
+// Records outside the period are ignored.
+func Aggregate(records []Record, from, to time.Time, interval time.Duration) []Bucket {
+	buckets := []Bucket{}
+	if interval <= 0 {
+		return buckets
+	}
+
+	latencies := []int64{}
+	for t := from; t.Before(to); t = t.Add(interval) {
+		buckets = append(buckets, Bucket{Time: t.In(time.UTC).Format(time.RFC3339)})
+		latencies = append(latencies, 0)
+	}
+
+	start, end := from.Unix(), to.Unix()
+	for _, r := range records {
+		if r.Timestamp < start || r.Timestamp >= end {
+			continue
+		}
+		i := int(time.Duration(r.Timestamp-start) * time.Second / interval)
+		b := &buckets[i]
+		b.Requests++
+		b.BytesIn += r.BytesIn
+		b.BytesOut += r.BytesOut
+		if r.Status >= 500 {
+			b.Errors++
+		}
+		if b.Statuses == nil {
+			b.Statuses = map[string]int{}
+		}
+		b.Statuses[fmt.Sprintf("%dxx", r.Status/100)]++
+		latencies[i] += r.Latency
+	}
+
+	for i := range buckets {
+		if buckets[i].Requests > 0 {
+			buckets[i].AvgLatency = float64(latencies[i]) / float64(buckets[i].Requests)
+		}
+	}
+	return buckets
+}
+
+var identifier = regexp.MustCompile(`^([0-9]+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{16,})$`)
+
+// PathTemplate replaces the segments of the path which look like identifiers (numbers, uuids and
+// long hexadecimal strings) by `:id`, so the requests to the same resource are grouped together.
+func PathTemplate(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if identifier.MatchString(s) {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
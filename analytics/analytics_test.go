@@ -0,0 +1,55 @@
+package analytics
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestAggregate(c *C) {
+	from := time.Date(2015, 5, 16, 13, 0, 0, 0, time.UTC)
+	records := []Record{
+		Record{Service: "apihub", Status: 200, Latency: 10, BytesIn: 1, BytesOut: 100, Timestamp: from.Unix()},
+		Record{Service: "apihub", Status: 502, Latency: 30, BytesOut: 50, Timestamp: from.Add(59 * time.Minute).Unix()},
+		Record{Service: "apihub", Status: 404, Latency: 5, Timestamp: from.Add(90 * time.Minute).Unix()},
+		Record{Service: "apihub", Status: 200, Timestamp: from.Add(3 * time.Hour).Unix()},
+	}
+
+	buckets := Aggregate(records, from, from.Add(2*time.Hour), time.Hour)
+	c.Assert(buckets, DeepEquals, []Bucket{
+		Bucket{Time: "2015-05-16T13:00:00Z", Requests: 2, Errors: 1, AvgLatency: 20, BytesIn: 1, BytesOut: 150, Statuses: map[string]int{"2xx": 1, "5xx": 1}},
+		Bucket{Time: "2015-05-16T14:00:00Z", Requests: 1, AvgLatency: 5, Statuses: map[string]int{"4xx": 1}},
+	})
+}
+
+func (s *S) TestAggregateWithoutRecords(c *C) {
+	from := time.Date(2015, 5, 16, 13, 0, 0, 0, time.UTC)
+	buckets := Aggregate([]Record{}, from, from.Add(time.Hour), 30*time.Minute)
+	c.Assert(buckets, DeepEquals, []Bucket{
+		Bucket{Time: "2015-05-16T13:00:00Z"},
+		Bucket{Time: "2015-05-16T13:30:00Z"},
+	})
+}
+
+func (s *S) TestPathTemplate(c *C) {
+	c.Assert(PathTemplate("/"), Equals, "/")
+	c.Assert(PathTemplate("/users/42/orders"), Equals, "/users/:id/orders")
+	c.Assert(PathTemplate("/users/6ba7b810-9dad-11d1-80b4-00c04fd430c8"), Equals, "/users/:id")
+	c.Assert(PathTemplate("/commits/5d41402abc4b2a76b9719d911017c592"), Equals, "/commits/:id")
+	c.Assert(PathTemplate("/v1/users/alice"), Equals, "/v1/users/alice")
+}
+
+func (s *S) TestRingBuffer(c *C) {
+	b := NewRingBuffer(2)
+	b.Write(Record{Service: "apihub", RequestId: "1", Timestamp: 10})
+	b.Write(Record{Service: "another", RequestId: "2", Timestamp: 10})
+	b.Write(Record{Service: "apihub", RequestId: "3", Timestamp: 20})
+	b.Write(Record{Service: "apihub", RequestId: "4", Timestamp: 30})
+
+	records, err := b.Records("apihub", time.Unix(0, 0), time.Unix(40, 0))
+	c.Assert(err, IsNil)
+	c.Assert(records, DeepEquals, []Record{Record{Service: "apihub", RequestId: "3", Timestamp: 20}, Record{Service: "apihub", RequestId: "4", Timestamp: 30}})
+
+	records, _ = b.Records("apihub", time.Unix(20, 0), time.Unix(30, 0))
+	c.Assert(records, DeepEquals, []Record{Record{Service: "apihub", RequestId: "3", Timestamp: 20}})
+}
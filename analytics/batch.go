@@ -0,0 +1,88 @@
+package analytics
+
+import (
+	"time"
+
+	. "github.com/apihub/apihub/log"
+)
+
+const (
+	DEFAULT_BATCH_SIZE     = 100
+	DEFAULT_BATCH_INTERVAL = 5 * time.Second
+)
+
+// Storage persists the records, which are sent in batches by the BatchWriter.
+type Storage interface {
+	Save([]Record) error
+}
+
+// BatchWriter sends the records to the storage whenever the batch is full or the interval has passed,
+// so the gateway does not wait for the storage while serving the requests.
+// Records are dropped when the storage is not able to keep up with them.
+type BatchWriter struct {
+	storage  Storage
+	size     int
+	interval time.Duration
+	records  chan Record
+	done     chan bool
+}
+
+func NewBatchWriter(storage Storage, size int, interval time.Duration) *BatchWriter {
+	if size <= 0 {
+		size = DEFAULT_BATCH_SIZE
+	}
+	if interval <= 0 {
+		interval = DEFAULT_BATCH_INTERVAL
+	}
+
+	w := &BatchWriter{storage: storage, size: size, interval: interval, records: make(chan Record, size*10), done: make(chan bool)}
+	go w.run()
+	return w
+}
+
+func (w *BatchWriter) Write(r Record) {
+	select {
+	case w.records <- r:
+	default:
+		Logger.Warn("Analytics record dropped, the storage is not keeping up: %+v.", r)
+	}
+}
+
+// Close sends the pending records to the storage.
+func (w *BatchWriter) Close() {
+	close(w.records)
+	<-w.done
+}
+
+func (w *BatchWriter) run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	batch := make([]Record, 0, w.size)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := w.storage.Save(batch); err != nil {
+			Logger.Warn("Failed to save %d analytics records: %+v.", len(batch), err)
+		}
+		batch = make([]Record, 0, w.size)
+	}
+
+	for {
+		select {
+		case r, ok := <-w.records:
+			if !ok {
+				flush()
+				close(w.done)
+				return
+			}
+			batch = append(batch, r)
+			if len(batch) >= w.size {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
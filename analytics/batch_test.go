@@ -0,0 +1,45 @@
+package analytics
+
+import (
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type memoryStorage struct {
+	batches [][]Record
+	mtx     sync.Mutex
+}
+
+func (m *memoryStorage) Save(records []Record) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.batches = append(m.batches, records)
+	return nil
+}
+
+func (s *S) TestBatchWriterSavesFullBatches(c *C) {
+	storage := &memoryStorage{}
+	w := NewBatchWriter(storage, 2, time.Hour)
+	for i := 0; i < 5; i++ {
+		w.Write(Record{Service: "apihub", Timestamp: int64(i)})
+	}
+	w.Close()
+
+	c.Assert(storage.batches, HasLen, 3)
+	c.Assert(storage.batches[0], HasLen, 2)
+	c.Assert(storage.batches[2], DeepEquals, []Record{Record{Service: "apihub", Timestamp: 4}})
+}
+
+func (s *S) TestBatchWriterSavesOnInterval(c *C) {
+	storage := &memoryStorage{}
+	w := NewBatchWriter(storage, 100, 10*time.Millisecond)
+	defer w.Close()
+	w.Write(Record{Service: "apihub"})
+
+	time.Sleep(50 * time.Millisecond)
+	storage.mtx.Lock()
+	defer storage.mtx.Unlock()
+	c.Assert(storage.batches, DeepEquals, [][]Record{[]Record{Record{Service: "apihub"}}})
+}
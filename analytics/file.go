@@ -0,0 +1,42 @@
+package analytics
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	. "github.com/apihub/apihub/log"
+)
+
+// FileWriter writes each record as a line of JSON (JSONL), to be shipped to other tools.
+type FileWriter struct {
+	w   io.Writer
+	mtx sync.Mutex
+}
+
+func NewFileWriter(w io.Writer) *FileWriter {
+	return &FileWriter{w: w}
+}
+
+// OpenFile appends the records to the file in the given path, creating it if needed.
+func OpenFile(path string) (*FileWriter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewFileWriter(f), nil
+}
+
+func (f *FileWriter) Write(r Record) {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if _, err := f.w.Write(append(line, '\n')); err != nil {
+		Logger.Warn("Failed to write the analytics record: %+v.", err)
+	}
+}
@@ -0,0 +1,18 @@
+package analytics
+
+import (
+	"bytes"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestFileWriter(c *C) {
+	buf := new(bytes.Buffer)
+	w := NewFileWriter(buf)
+	w.Write(Record{Service: "apihub", Method: "GET", Path: "/", Status: 200, Timestamp: 1431783644})
+	w.Write(Record{Service: "apihub", ClientId: "ios", Method: "POST", Path: "/users", Status: 201, Timestamp: 1431783645})
+
+	c.Assert(buf.String(), Equals, `{"service":"apihub","method":"GET","path":"/","status":200,"latency":0,"bytes_in":0,"bytes_out":0,"timestamp":1431783644}
+{"service":"apihub","client_id":"ios","method":"POST","path":"/users","status":201,"latency":0,"bytes_in":0,"bytes_out":0,"timestamp":1431783645}
+`)
+}
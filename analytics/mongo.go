@@ -0,0 +1,64 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/db/storage"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	DefaultDatabaseHost = "127.0.0.1:27017"
+	DefaultDatabaseName = "apihub"
+)
+
+// MongoStorage keeps the records in the `analytics` collection.
+type MongoStorage struct {
+	Host         string
+	DatabaseName string
+}
+
+func (m MongoStorage) Save(records []Record) error {
+	strg, err := m.openSession()
+	if err != nil {
+		return err
+	}
+	defer strg.Close()
+
+	docs := make([]interface{}, len(records))
+	for i, r := range records {
+		docs[i] = r
+	}
+	return m.collection(strg).Insert(docs...)
+}
+
+func (m MongoStorage) Records(service string, from, to time.Time) ([]Record, error) {
+	strg, err := m.openSession()
+	if err != nil {
+		return nil, err
+	}
+	defer strg.Close()
+
+	records := []Record{}
+	query := bson.M{"service": service, "timestamp": bson.M{"$gte": from.Unix(), "$lt": to.Unix()}}
+	err = m.collection(strg).Find(query).Sort("timestamp").All(&records)
+	return records, err
+}
+
+func (m MongoStorage) collection(strg *storage.Storage) *storage.Collection {
+	index := mgo.Index{Key: []string{"service", "timestamp"}, Background: true}
+	collection := strg.Collection("analytics")
+	collection.EnsureIndex(index)
+	return collection
+}
+
+func (m MongoStorage) openSession() (*storage.Storage, error) {
+	if m.Host == "" {
+		m.Host = DefaultDatabaseHost
+	}
+	if m.DatabaseName == "" {
+		m.DatabaseName = DefaultDatabaseName
+	}
+	return storage.Open(m.Host, m.DatabaseName)
+}
@@ -0,0 +1,61 @@
+package analytics
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/apihub/apihub/db"
+)
+
+// RedisStorage keeps the records of each service in a sorted set, scored by their timestamp.
+// Records older than `Retention` are removed whenever new records are saved.
+type RedisStorage struct {
+	Retention time.Duration
+}
+
+func (s RedisStorage) Save(records []Record) error {
+	services := map[string][]Record{}
+	for _, r := range records {
+		services[r.Service] = append(services[r.Service], r)
+	}
+
+	for service, records := range services {
+		scores, members := make([]int64, len(records)), make([]string, len(records))
+		for i, r := range records {
+			member, err := json.Marshal(r)
+			if err != nil {
+				return err
+			}
+			scores[i], members[i] = r.Timestamp, string(member)
+		}
+		if err := db.AddToSortedSet(redisKey(service), scores, members); err != nil {
+			return err
+		}
+		if s.Retention > 0 {
+			db.RemoveSortedSetByScore(redisKey(service), 0, time.Now().Add(-s.Retention).Unix())
+		}
+	}
+	return nil
+}
+
+func (s RedisStorage) Records(service string, from, to time.Time) ([]Record, error) {
+	// The interval is closed on Redis, so the records of the last second are skipped.
+	members, err := db.RangeSortedSetByScore(redisKey(service), from.Unix(), to.Unix()-1)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []Record{}
+	for _, member := range members {
+		var r Record
+		if err := json.Unmarshal([]byte(member), &r); err == nil {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+func redisKey(service string) string {
+	return fmt.Sprintf("analytics:%s", service)
+}
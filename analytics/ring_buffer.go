@@ -0,0 +1,51 @@
+package analytics
+
+import (
+	"sync"
+	"time"
+)
+
+// RingBuffer keeps the last records in memory, which suits a single gateway instance and tests.
+type RingBuffer struct {
+	records []Record
+	next    int
+	full    bool
+	mtx     sync.RWMutex
+}
+
+func NewRingBuffer(size int) *RingBuffer {
+	return &RingBuffer{records: make([]Record, size)}
+}
+
+func (b *RingBuffer) Write(r Record) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if len(b.records) == 0 {
+		return
+	}
+	b.records[b.next] = r
+	b.next = (b.next + 1) % len(b.records)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// Records returns the records kept in the buffer, from the oldest to the newest.
+func (b *RingBuffer) Records(service string, from, to time.Time) ([]Record, error) {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	all := b.records[:b.next]
+	if b.full {
+		all = append(append([]Record{}, b.records[b.next:]...), b.records[:b.next]...)
+	}
+
+	records := []Record{}
+	for _, r := range all {
+		if r.Service == service && r.Timestamp >= from.Unix() && r.Timestamp < to.Unix() {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/analytics"
+	"github.com/apihub/apihub/errors"
+	"github.com/gorilla/mux"
+)
+
+const (
+	DEFAULT_ANALYTICS_PERIOD   = 24 * time.Hour
+	DEFAULT_ANALYTICS_INTERVAL = time.Hour
+	MAX_ANALYTICS_BUCKETS      = 1000
+	MAX_ANALYTICS_PERIOD       = 31 * 24 * time.Hour
+)
+
+type analyticsSerializer struct {
+	Service  string             `json:"service"`
+	From     string             `json:"from"`
+	To       string             `json:"to"`
+	Interval string             `json:"interval"`
+	Buckets  []analytics.Bucket `json:"buckets"`
+}
+
+func (api *Api) serviceAnalytics(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	service, err := findServiceAndCheckUser(mux.Vars(r)["subdomain"], user, account.PERMISSION_READ)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	if api.analytics == nil {
+		handleError(rw, errors.NewNotFoundError(errors.ErrAnalyticsNotEnabled))
+		return
+	}
+
+	from, to, interval, err := analyticsPeriod(r)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	records, err := api.analytics.Records(service.Subdomain, from, to)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, analyticsSerializer{
+		Service:  service.Subdomain,
+		From:     from.Format(time.RFC3339),
+		To:       to.Format(time.RFC3339),
+		Interval: interval.String(),
+		Buckets:  analytics.Aggregate(records, from, to, interval),
+	})
+}
+
+// analyticsPeriod parses the period requested, which defaults to the last 24 hours in intervals of one hour.
+// The period is limited to 31 days, so the records read at once are limited as well.
+func analyticsPeriod(r *http.Request) (from, to time.Time, interval time.Duration, err error) {
+	invalid := errors.NewValidationError(errors.ErrAnalyticsInvalidPeriod)
+	query := r.URL.Query()
+
+	to = time.Now().In(time.UTC)
+	if v := query.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, interval, invalid
+		}
+	}
+	from = to.Add(-DEFAULT_ANALYTICS_PERIOD)
+	if v := query.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, interval, invalid
+		}
+	}
+	interval = DEFAULT_ANALYTICS_INTERVAL
+	if v := query.Get("interval"); v != "" {
+		if interval, err = time.ParseDuration(v); err != nil {
+			return from, to, interval, invalid
+		}
+	}
+
+	if !from.Before(to) || to.Sub(from) > MAX_ANALYTICS_PERIOD || interval < time.Second || to.Sub(from)/interval >= MAX_ANALYTICS_BUCKETS {
+		return from, to, interval, invalid
+	}
+	return from.In(time.UTC), to.In(time.UTC), interval, nil
+}
@@ -0,0 +1,71 @@
+package api_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/apihub/apihub/analytics"
+	"github.com/apihub/apihub/requests"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) serviceAnalytics(query string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/services/%s/analytics?%s", service.Subdomain, query), nil)
+	req.Header.Set("Authorization", s.authHeader)
+	rw := httptest.NewRecorder()
+	s.api.Handler().ServeHTTP(rw, req)
+	return rw
+}
+
+func (s *S) TestServiceAnalytics(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+
+	from := time.Date(2015, 5, 16, 13, 0, 0, 0, time.UTC)
+	sink := analytics.NewRingBuffer(10)
+	sink.Write(analytics.Record{Service: service.Subdomain, Status: 200, Latency: 10, BytesOut: 100, Timestamp: from.Add(time.Minute).Unix()})
+	sink.Write(analytics.Record{Service: service.Subdomain, Status: 500, Latency: 20, Timestamp: from.Add(time.Hour).Unix()})
+	sink.Write(analytics.Record{Service: "another", Status: 200, Timestamp: from.Unix()})
+	s.api.Analytics(sink)
+
+	rw := s.serviceAnalytics("from=2015-05-16T13:00:00Z&to=2015-05-16T15:00:00Z&interval=1h")
+
+	c.Assert(rw.Code, Equals, http.StatusOK)
+	c.Assert(rw.Body.String(), Equals, `{"service":"apihub","from":"2015-05-16T13:00:00Z","to":"2015-05-16T15:00:00Z","interval":"1h0m0s","buckets":[`+
+		`{"time":"2015-05-16T13:00:00Z","requests":1,"errors":0,"avg_latency":10,"bytes_in":0,"bytes_out":100,"statuses":{"2xx":1}},`+
+		`{"time":"2015-05-16T14:00:00Z","requests":1,"errors":1,"avg_latency":20,"bytes_in":0,"bytes_out":0,"statuses":{"5xx":1}}]}`)
+}
+
+func (s *S) TestServiceAnalyticsWithInvalidPeriod(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	s.api.Analytics(analytics.NewRingBuffer(10))
+
+	for _, query := range []string{"from=yesterday", "from=2015-05-16T15:00:00Z&to=2015-05-16T13:00:00Z", "interval=1s", "interval=hourly", "from=2015-01-01T00:00:00Z&to=2015-05-16T00:00:00Z&interval=720h"} {
+		rw := s.serviceAnalytics(query)
+
+		c.Assert(rw.Code, Equals, http.StatusBadRequest)
+		c.Assert(rw.Body.String(), Equals, `{"error":"bad_request","error_description":"From and To must be in RFC 3339 format, with From before To and at most 31 days apart, and Interval must be a duration such as 1h, up to 1000 intervals."}`)
+	}
+}
+
+func (s *S) TestServiceAnalyticsNotEnabled(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNotFound,
+		Method:         "GET",
+		Path:           fmt.Sprintf("/api/services/%s/analytics", service.Subdomain),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusNotFound)
+	c.Assert(string(body), Equals, `{"error":"not_found","error_description":"Analytics is not enabled."}`)
+}
+
+func (s *S) TestServiceAnalyticsWithoutSignIn(c *C) {
+	testWithoutSignIn(requests.Args{AcceptableCode: http.StatusUnauthorized, Method: "GET", Path: "/api/services/apihub/analytics"}, c)
+}
@@ -10,8 +10,11 @@ import (
 	"time"
 
 	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/analytics"
 	"github.com/apihub/apihub/auth"
+	"github.com/apihub/apihub/gateway/transformer"
 	. "github.com/apihub/apihub/log"
+	"github.com/apihub/apihub/mail"
 	"github.com/codegangsta/negroni"
 	"github.com/tylerb/graceful"
 )
@@ -23,16 +26,25 @@ const (
 )
 
 type Api struct {
-	auth   auth.Authenticatable
-	store  account.Storable
-	router *Router
-	Events chan Event
+	auth         auth.Authenticatable
+	store        account.Storable
+	pubsub       account.PubSub
+	router       *Router
+	transformers map[string]bool
+	analytics    analytics.Reader
+	Events       chan Event
+
+	requireEmailVerification bool
+	metricsPort              string
 }
 
 func NewApi(store account.Storable, pubsub account.PubSub) *Api {
-	api := &Api{router: NewRouter(), auth: auth.NewAuth(store), Events: make(chan Event, DEFAULT_EVENTS_CHANNEL_LEN)}
+	api := &Api{router: NewRouter(), auth: auth.NewAuth(store), transformers: map[string]bool{}, Events: make(chan Event, DEFAULT_EVENTS_CHANNEL_LEN)}
 	api.Storage(store)
 	api.PubSub(pubsub)
+	for name := range transformer.Default() {
+		api.AddTransformer(name)
+	}
 
 	api.router.NotFoundHandler(http.HandlerFunc(api.notFoundHandler))
 	api.router.AddHandler(RouterArguments{Path: "/", Methods: []string{"GET"}, Handler: homeHandler})
@@ -40,8 +52,17 @@ func NewApi(store account.Storable, pubsub account.PubSub) *Api {
 	//  Auth (login, logout, signup)
 	api.router.AddHandler(RouterArguments{Path: "/auth/login", Methods: []string{"POST"}, Handler: api.userLogin})
 	api.router.AddHandler(RouterArguments{Path: "/auth/logout", Methods: []string{"DELETE"}, Handler: api.userLogout})
+	api.router.AddHandler(RouterArguments{Path: "/auth/refresh", Methods: []string{"POST"}, Handler: api.userRefreshToken})
 	api.router.AddHandler(RouterArguments{Path: "/auth/signup", Methods: []string{"POST"}, Handler: api.userSignup})
 	api.router.AddHandler(RouterArguments{Path: "/auth/password", Methods: []string{"PUT"}, Handler: api.userChangePassword})
+	api.router.AddHandler(RouterArguments{Path: "/auth/password/reset", Methods: []string{"POST"}, Handler: api.userRecoverPassword})
+	api.router.AddHandler(RouterArguments{Path: "/auth/password/reset", Methods: []string{"PUT"}, Handler: api.userResetPassword})
+	api.router.AddHandler(RouterArguments{Path: "/auth/email/verification", Methods: []string{"POST"}, Handler: api.userSendEmailVerification})
+	api.router.AddHandler(RouterArguments{Path: "/auth/email/verify", Methods: []string{"POST"}, Handler: api.userVerifyEmail})
+
+	// OAuth
+	api.router.AddHandler(RouterArguments{Path: "/oauth/authorize", Methods: []string{"GET", "POST"}, Handler: api.oauthAuthorize})
+	api.router.AddHandler(RouterArguments{Path: "/oauth/token", Methods: []string{"POST"}, Handler: api.oauthToken})
 
 	// Middlewares
 	private := api.router.AddSubrouter("/api")
@@ -56,6 +77,10 @@ func NewApi(store account.Storable, pubsub account.PubSub) *Api {
 
 	// Users
 	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/users", Methods: []string{"DELETE"}, Handler: api.userDelete})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/users/tokens", Methods: []string{"POST"}, Handler: authorizationRequiredHandler(api.userTokenCreate)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/users/tokens", Methods: []string{"GET"}, Handler: authorizationRequiredHandler(api.userTokenList)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/users/tokens", Methods: []string{"DELETE"}, Handler: authorizationRequiredHandler(api.userTokensRevoke)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/users/tokens/{id}", Methods: []string{"DELETE"}, Handler: authorizationRequiredHandler(api.userTokenRevoke)})
 
 	// Teams
 	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/teams", Methods: []string{"POST"}, Handler: authorizationRequiredHandler(api.teamCreate)})
@@ -65,6 +90,17 @@ func NewApi(store account.Storable, pubsub account.PubSub) *Api {
 	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/teams/{alias}", Methods: []string{"GET"}, Handler: authorizationRequiredHandler(api.teamInfo)})
 	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/teams/{alias}/users", Methods: []string{"PUT"}, Handler: authorizationRequiredHandler(api.teamAddUsers)})
 	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/teams/{alias}/users", Methods: []string{"DELETE"}, Handler: authorizationRequiredHandler(api.teamRemoveUsers)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/teams/{alias}/users/{email}/role", Methods: []string{"PUT"}, Handler: authorizationRequiredHandler(api.teamChangeRole)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/teams/{alias}/owner", Methods: []string{"PUT"}, Handler: authorizationRequiredHandler(api.teamTransferOwnership)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/teams/{alias}/invitations", Methods: []string{"POST"}, Handler: authorizationRequiredHandler(api.invitationCreate)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/teams/{alias}/invitations", Methods: []string{"GET"}, Handler: authorizationRequiredHandler(api.invitationList)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/teams/{alias}/invitations/{id}/resend", Methods: []string{"POST"}, Handler: authorizationRequiredHandler(api.invitationResend)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/teams/{alias}/invitations/{id}", Methods: []string{"DELETE"}, Handler: authorizationRequiredHandler(api.invitationDelete)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/invitations/accept", Methods: []string{"POST"}, Handler: authorizationRequiredHandler(api.invitationAccept)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/teams/{alias}/domains", Methods: []string{"POST"}, Handler: authorizationRequiredHandler(api.domainCreate)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/teams/{alias}/domains", Methods: []string{"GET"}, Handler: authorizationRequiredHandler(api.domainList)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/teams/{alias}/domains/{name}/verify", Methods: []string{"POST"}, Handler: authorizationRequiredHandler(api.domainVerify)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/teams/{alias}/domains/{name}", Methods: []string{"DELETE"}, Handler: authorizationRequiredHandler(api.domainDelete)})
 
 	// Services
 	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services", Methods: []string{"POST"}, Handler: authorizationRequiredHandler(api.serviceCreate)})
@@ -72,6 +108,22 @@ func NewApi(store account.Storable, pubsub account.PubSub) *Api {
 	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}", Methods: []string{"GET"}, Handler: authorizationRequiredHandler(api.serviceInfo)})
 	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}", Methods: []string{"DELETE"}, Handler: authorizationRequiredHandler(api.serviceDelete)})
 	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}", Methods: []string{"PUT"}, Handler: authorizationRequiredHandler(api.serviceUpdate)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}/upstreams", Methods: []string{"GET"}, Handler: authorizationRequiredHandler(api.serviceUpstreams)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}/circuit_breaker", Methods: []string{"GET"}, Handler: authorizationRequiredHandler(api.serviceCircuitBreaker)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}/analytics", Methods: []string{"GET"}, Handler: authorizationRequiredHandler(api.serviceAnalytics)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}/subscriptions", Methods: []string{"GET"}, Handler: authorizationRequiredHandler(api.serviceSubscriptionList)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}/subscriptions/{client_id}/approve", Methods: []string{"POST"}, Handler: authorizationRequiredHandler(api.serviceSubscriptionApprove)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}/subscriptions/{client_id}/revoke", Methods: []string{"POST"}, Handler: authorizationRequiredHandler(api.serviceSubscriptionRevoke)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}/subscriptions/{client_id}/plan", Methods: []string{"PUT"}, Handler: authorizationRequiredHandler(api.serviceSubscriptionChangePlan)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}/plans", Methods: []string{"PUT"}, Handler: authorizationRequiredHandler(api.planSave)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}/plans", Methods: []string{"GET"}, Handler: authorizationRequiredHandler(api.planList)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}/plans/{name}", Methods: []string{"DELETE"}, Handler: authorizationRequiredHandler(api.planDelete)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}/certificates", Methods: []string{"PUT"}, Handler: authorizationRequiredHandler(api.certificateSave)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}/certificates", Methods: []string{"GET"}, Handler: authorizationRequiredHandler(api.certificateList)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}/certificates/{host}", Methods: []string{"DELETE"}, Handler: authorizationRequiredHandler(api.certificateDelete)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}/client_certificates", Methods: []string{"PUT"}, Handler: authorizationRequiredHandler(api.clientCertificateSave)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}/client_certificates", Methods: []string{"GET"}, Handler: authorizationRequiredHandler(api.clientCertificateList)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}/client_certificates/{name}", Methods: []string{"DELETE"}, Handler: authorizationRequiredHandler(api.clientCertificateDelete)})
 	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}/plugins", Methods: []string{"PUT"}, Handler: authorizationRequiredHandler(api.pluginSubsribe)})
 	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/services/{subdomain}/plugins/{plugin_name}", Methods: []string{"DELETE"}, Handler: authorizationRequiredHandler(api.pluginUnsubsribe)})
 
@@ -80,6 +132,14 @@ func NewApi(store account.Storable, pubsub account.PubSub) *Api {
 	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/apps/{client_id}", Methods: []string{"DELETE"}, Handler: authorizationRequiredHandler(api.appDelete)})
 	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/apps/{client_id}", Methods: []string{"GET"}, Handler: authorizationRequiredHandler(api.appInfo)})
 	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/apps/{client_id}", Methods: []string{"PUT"}, Handler: authorizationRequiredHandler(api.appUpdate)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/apps/{client_id}/keys", Methods: []string{"POST"}, Handler: authorizationRequiredHandler(api.apiKeyCreate)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/apps/{client_id}/keys", Methods: []string{"GET"}, Handler: authorizationRequiredHandler(api.apiKeyList)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/apps/{client_id}/keys/{id}/rotate", Methods: []string{"POST"}, Handler: authorizationRequiredHandler(api.apiKeyRotate)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/apps/{client_id}/keys/{id}", Methods: []string{"DELETE"}, Handler: authorizationRequiredHandler(api.apiKeyRevoke)})
+
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/apps/{client_id}/subscriptions", Methods: []string{"POST"}, Handler: authorizationRequiredHandler(api.subscriptionCreate)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/apps/{client_id}/subscriptions", Methods: []string{"GET"}, Handler: authorizationRequiredHandler(api.subscriptionList)})
+	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/apps/{client_id}/subscriptions/{subdomain}", Methods: []string{"DELETE"}, Handler: authorizationRequiredHandler(api.subscriptionDelete)})
 
 	// Hooks
 	api.router.AddHandler(RouterArguments{PathPrefix: "/api", Path: "/hooks", Methods: []string{"PUT"}, Handler: authorizationRequiredHandler(api.hookSave)})
@@ -89,7 +149,7 @@ func NewApi(store account.Storable, pubsub account.PubSub) *Api {
 }
 
 func (api *Api) Handler() http.Handler {
-	return api.router.Handler()
+	return measuredHandler(api.router.Handler())
 }
 
 // This is intend to be used when loading the api only, just to connect the apihub with apihub-gateway.
@@ -98,12 +158,23 @@ func (api *Api) AddHook(wh account.Hook) {
 	api.store.UpsertHook(wh)
 }
 
+// Register the name of a custom transformer available on the gateway,
+// so services are allowed to use it.
+func (api *Api) AddTransformer(name string) {
+	api.transformers[name] = true
+}
+
 // Allow to override the default authentication method.
 // To be compatible, it is needed to implement the Authenticatable interface.
 func (api *Api) SetAuth(auth auth.Authenticatable) {
 	api.auth = auth
 }
 
+// Allow to query the requests recorded by the gateway, through the analytics endpoints.
+func (api *Api) Analytics(reader analytics.Reader) {
+	api.analytics = reader
+}
+
 // Allow to override the default storage engine.
 // To be compatible, it is needed to implement the Storable interface.
 func (api *Api) Storage(store account.Storable) {
@@ -112,13 +183,46 @@ func (api *Api) Storage(store account.Storable) {
 	api.auth = auth.NewAuth(store)
 }
 
+// Allow to deliver the emails, such as the invitations to join a team.
+// To be compatible, it is needed to implement the mail.Sender interface.
+func (api *Api) Mailer(sender mail.Sender) {
+	account.Mailer(sender)
+}
+
+// Allow to override the resolver used to look up the TXT records which verify the domains of the teams.
+func (api *Api) DNSResolver(resolver account.Resolver) {
+	account.DNSResolver(resolver)
+}
+
+// Allow to encrypt the keys of the client certificates before they are stored.
+// The gateway must be configured with the same secret, which is the only one to decrypt them.
+func (api *Api) ClientCertificateSecret(secret string) {
+	account.ClientCertificateSecret(secret)
+}
+
+// Allow to block the login of the users until they verify their emails.
+// The users who signed up before are required to verify their emails as well.
+func (api *Api) RequireEmailVerification(required bool) {
+	api.requireEmailVerification = required
+}
+
+// Allow to serve the metrics on a port of their own, such as ":9100", when the api is run.
+// The metrics are not served unless the port is set.
+func (api *Api) MetricsPort(port string) {
+	api.metricsPort = port
+}
+
 // Allow to override the default pubsub engine.
 // To be compatible, it is needed to implement the Subscription interface.
 func (api *Api) PubSub(pubsub account.PubSub) {
+	api.pubsub = pubsub
 	account.NewPubSub(pubsub)
 }
 
 func (api *Api) Run() {
+	if api.metricsPort != "" {
+		go api.serveMetrics()
+	}
 	Logger.Info(fmt.Sprintf("ApiHub is now ready to accept connections on port %s.", DEFAULT_PORT))
 	graceful.Run(DEFAULT_PORT, DEFAULT_TIMEOUT, api.Handler())
 }
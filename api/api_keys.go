@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+	"github.com/gorilla/mux"
+)
+
+func (api *Api) apiKeyCreate(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	app, err := findAppAndCheckUser(mux.Vars(r)["client_id"], user, account.PERMISSION_WRITE)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	apiKey := account.ApiKey{}
+	if err := json.NewDecoder(r.Body).Decode(&apiKey); err != nil {
+		handleError(rw, errors.ErrBadRequest)
+		return
+	}
+
+	if err := apiKey.Create(*app); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Created(rw, apiKey)
+}
+
+func (api *Api) apiKeyList(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	app, err := findAppAndCheckUser(mux.Vars(r)["client_id"], user, account.PERMISSION_READ)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	apiKeys, _ := account.AppApiKeys(*app)
+	Ok(rw, CollectionSerializer{Items: apiKeys, Count: len(apiKeys)})
+}
+
+func (api *Api) apiKeyRotate(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	apiKey, err := findApiKeyAndCheckUser(mux.Vars(r)["client_id"], mux.Vars(r)["id"], user, account.PERMISSION_WRITE)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	if err := apiKey.Rotate(); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, apiKey)
+}
+
+func (api *Api) apiKeyRevoke(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	apiKey, err := findApiKeyAndCheckUser(mux.Vars(r)["client_id"], mux.Vars(r)["id"], user, account.PERMISSION_WRITE)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	if err := apiKey.Revoke(); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, apiKey)
+}
+
+func findAppAndCheckUser(clientId string, user *account.User, permission account.Permission) (*account.App, error) {
+	app, err := account.FindAppByClientId(clientId)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := findTeamAndCheckUser(app.Team, user, permission); err != nil {
+		return nil, err
+	}
+
+	return app, nil
+}
+
+func findApiKeyAndCheckUser(clientId, id string, user *account.User, permission account.Permission) (*account.ApiKey, error) {
+	app, err := findAppAndCheckUser(clientId, user, permission)
+	if err != nil {
+		return nil, err
+	}
+	apiKey, err := account.FindApiKeyById(id)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey.ClientId != app.ClientId {
+		return nil, errors.NewNotFoundError(errors.ErrApiKeyNotFound)
+	}
+
+	return apiKey, nil
+}
@@ -0,0 +1,147 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/requests"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestCreateApiKey(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	headers, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusCreated,
+		Method:         "POST",
+		Path:           fmt.Sprintf("/api/apps/%s/keys", app.ClientId),
+		Body:           `{"name": "production", "services": ["apihub"], "methods": ["GET"], "expires_at": "2030-01-01T00:00:00Z"}`,
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusCreated)
+	c.Assert(headers.Get("Content-Type"), Equals, "application/json")
+	apiKey := account.ApiKey{}
+	json.Unmarshal(body, &apiKey)
+	c.Assert(apiKey.Name, Equals, "production")
+	c.Assert(apiKey.ClientId, Equals, app.ClientId)
+	c.Assert(apiKey.Services, DeepEquals, []string{"apihub"})
+	c.Assert(apiKey.ExpiresAt, Equals, "2030-01-01T00:00:00Z")
+	c.Assert(strings.HasPrefix(apiKey.Key, apiKey.Id+"."), Equals, true)
+	c.Assert(strings.Contains(string(body), "hash"), Equals, false)
+}
+
+func (s *S) TestCreateApiKeyWithInvalidFields(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusBadRequest,
+		Method:         "POST",
+		Path:           fmt.Sprintf("/api/apps/%s/keys", app.ClientId),
+		Body:           `{"name": "production", "methods": ["FETCH"]}`,
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(string(body), Equals, `{"error":"bad_request","error_description":"API key must have a Name, valid HTTP Methods and an Expiration in RFC 3339 format."}`)
+}
+
+func (s *S) TestCreateApiKeyWithoutSignIn(c *C) {
+	testWithoutSignIn(requests.Args{AcceptableCode: http.StatusUnauthorized, Method: "POST", Path: "/api/apps/ios/keys", Body: `{}`}, c)
+}
+
+func (s *S) TestCreateApiKeyAppNotFound(c *C) {
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNotFound,
+		Method:         "POST",
+		Path:           "/api/apps/not-found/keys",
+		Body:           `{"name": "production"}`,
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusNotFound)
+	c.Assert(string(body), Equals, `{"error":"not_found","error_description":"App not found."}`)
+}
+
+func (s *S) TestListApiKeys(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+	apiKey := account.ApiKey{Name: "production"}
+	apiKey.Create(app)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "GET",
+		Path:           fmt.Sprintf("/api/apps/%s/keys", app.ClientId),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(string(body), Equals, fmt.Sprintf(`{"items":[{"id":"%s","name":"production","client_id":"ios","created_at":"%s"}],"item_count":1}`, apiKey.Id, apiKey.CreatedAt))
+}
+
+func (s *S) TestRotateApiKey(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+	apiKey := account.ApiKey{Name: "production"}
+	apiKey.Create(app)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "POST",
+		Path:           fmt.Sprintf("/api/apps/%s/keys/%s/rotate", app.ClientId, apiKey.Id),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	rotated := account.ApiKey{}
+	json.Unmarshal(body, &rotated)
+	c.Assert(rotated.Id, Equals, apiKey.Id)
+	c.Assert(rotated.Key, Not(Equals), apiKey.Key)
+	_, err := account.AuthenticateApiKey(apiKey.Key)
+	c.Assert(err, NotNil)
+}
+
+func (s *S) TestRevokeApiKey(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+	apiKey := account.ApiKey{Name: "production"}
+	apiKey.Create(app)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "DELETE",
+		Path:           fmt.Sprintf("/api/apps/%s/keys/%s", app.ClientId, apiKey.Id),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	revoked := account.ApiKey{}
+	json.Unmarshal(body, &revoked)
+	c.Assert(revoked.RevokedAt, Not(Equals), "")
+	c.Assert(revoked.Key, Equals, "")
+	_, err := account.AuthenticateApiKey(apiKey.Key)
+	c.Assert(err, NotNil)
+}
+
+func (s *S) TestRevokeApiKeyOfAnotherApp(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+	apiKey := account.ApiKey{Name: "production"}
+	apiKey.Create(account.App{ClientId: "another"})
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNotFound,
+		Method:         "DELETE",
+		Path:           fmt.Sprintf("/api/apps/%s/keys/%s", app.ClientId, apiKey.Id),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusNotFound)
+	c.Assert(string(body), Equals, `{"error":"not_found","error_description":"API key not found."}`)
+}
@@ -16,7 +16,7 @@ func (api *Api) appCreate(rw http.ResponseWriter, r *http.Request, user *account
 		return
 	}
 
-	team, err := findTeamAndCheckUser(app.Team, user)
+	team, err := findTeamAndCheckUser(app.Team, user, account.PERMISSION_WRITE)
 	if err != nil {
 		handleError(rw, err)
 		return
@@ -37,7 +37,7 @@ func (api *Api) appUpdate(rw http.ResponseWriter, r *http.Request, user *account
 		return
 	}
 
-	_, err = findTeamAndCheckUser(app.Team, user)
+	_, err = findTeamAndCheckUser(app.Team, user, account.PERMISSION_WRITE)
 	if err != nil {
 		handleError(rw, err)
 		return
@@ -81,7 +81,7 @@ func (api *Api) appInfo(rw http.ResponseWriter, r *http.Request, user *account.U
 		return
 	}
 
-	_, err = findTeamAndCheckUser(app.Team, user)
+	_, err = findTeamAndCheckUser(app.Team, user, account.PERMISSION_READ)
 	if err != nil {
 		handleError(rw, err)
 		return
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+	"github.com/gorilla/mux"
+)
+
+func (api *Api) certificateSave(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	service, err := findServiceAndCheckUser(mux.Vars(r)["subdomain"], user, account.PERMISSION_WRITE)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	cert := account.Certificate{}
+	if err := json.NewDecoder(r.Body).Decode(&cert); err != nil {
+		handleError(rw, errors.ErrBadRequest)
+		return
+	}
+
+	if err := cert.Save(*service); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	cert.Key = ""
+	Ok(rw, cert)
+}
+
+func (api *Api) certificateList(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	service, err := findServiceAndCheckUser(mux.Vars(r)["subdomain"], user, account.PERMISSION_READ)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	certs, _ := account.FindCertificatesByService(*service)
+	for i := range certs {
+		certs[i].Key = ""
+	}
+	Ok(rw, CollectionSerializer{Items: certs, Count: len(certs)})
+}
+
+func (api *Api) certificateDelete(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	service, err := findServiceAndCheckUser(mux.Vars(r)["subdomain"], user, account.PERMISSION_DELETE)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	cert, err := account.FindCertificateByHost(mux.Vars(r)["host"])
+	if err != nil || cert.Service != service.Subdomain {
+		handleError(rw, errors.NewNotFoundError(errors.ErrCertificateNotFound))
+		return
+	}
+
+	if err := cert.Delete(); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	cert.Key = ""
+	Ok(rw, cert)
+}
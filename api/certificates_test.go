@@ -0,0 +1,143 @@
+package api_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/requests"
+	. "gopkg.in/check.v1"
+)
+
+func selfSignedCertificate(c *C, host string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	c.Assert(err, IsNil)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, IsNil)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+}
+
+func (s *S) TestSaveCertificate(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	defer s.store.DeleteCertificatesByService(service)
+	certPEM, keyPEM := selfSignedCertificate(c, "apihub.example.org")
+	payload, _ := json.Marshal(map[string]string{"host": "apihub.example.org", "certificate": certPEM, "key": keyPEM})
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "PUT",
+		Path:           fmt.Sprintf("/api/services/%s/certificates", service.Subdomain),
+		Body:           string(payload),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	var cert account.Certificate
+	json.Unmarshal(body, &cert)
+	c.Assert(cert, DeepEquals, account.Certificate{Host: "apihub.example.org", Service: "apihub", Certificate: certPEM, NotAfter: "2030-01-01T00:00:00Z"})
+	stored, err := account.FindCertificateByHost("apihub.example.org")
+	c.Assert(err, IsNil)
+	c.Assert(stored.Key, Equals, keyPEM)
+}
+
+func (s *S) TestSaveCertificateWithInvalidHost(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	certPEM, keyPEM := selfSignedCertificate(c, "other.example.org")
+	payload, _ := json.Marshal(map[string]string{"host": "other.example.org", "certificate": certPEM, "key": keyPEM})
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusBadRequest,
+		Method:         "PUT",
+		Path:           fmt.Sprintf("/api/services/%s/certificates", service.Subdomain),
+		Body:           string(payload),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(string(body), Equals, `{"error":"bad_request","error_description":"Host must be the subdomain of the service or one of the hosts of its routes."}`)
+}
+
+func (s *S) TestSaveCertificateWithoutSignIn(c *C) {
+	testWithoutSignIn(requests.Args{AcceptableCode: http.StatusUnauthorized, Method: "PUT", Path: "/api/services/apihub/certificates", Body: `{}`}, c)
+}
+
+func (s *S) TestListCertificates(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	defer s.store.DeleteCertificatesByService(service)
+	certPEM, keyPEM := selfSignedCertificate(c, "apihub.example.org")
+	cert := account.Certificate{Host: "apihub.example.org", Certificate: certPEM, Key: keyPEM}
+	cert.Save(service)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "GET",
+		Path:           fmt.Sprintf("/api/services/%s/certificates", service.Subdomain),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	var certs struct {
+		Items []account.Certificate `json:"items"`
+		Count int                   `json:"item_count"`
+	}
+	json.Unmarshal(body, &certs)
+	c.Assert(certs.Count, Equals, 1)
+	c.Assert(certs.Items[0].Host, Equals, "apihub.example.org")
+	c.Assert(certs.Items[0].Key, Equals, "")
+}
+
+func (s *S) TestDeleteCertificate(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	certPEM, keyPEM := selfSignedCertificate(c, "apihub.example.org")
+	cert := account.Certificate{Host: "apihub.example.org", Certificate: certPEM, Key: keyPEM}
+	cert.Save(service)
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "DELETE",
+		Path:           fmt.Sprintf("/api/services/%s/certificates/apihub.example.org", service.Subdomain),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	_, err := account.FindCertificateByHost("apihub.example.org")
+	c.Assert(err, NotNil)
+}
+
+func (s *S) TestDeleteCertificateNotFound(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNotFound,
+		Method:         "DELETE",
+		Path:           fmt.Sprintf("/api/services/%s/certificates/not-found.example.org", service.Subdomain),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusNotFound)
+	c.Assert(string(body), Equals, `{"error":"not_found","error_description":"Certificate not found."}`)
+}
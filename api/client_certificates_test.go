@@ -0,0 +1,163 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/requests"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestSaveClientCertificate(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	defer s.store.DeleteClientCertificatesByService(service)
+	certPEM, keyPEM := selfSignedCertificate(c, "gateway.example.org")
+	payload, _ := json.Marshal(map[string]string{"name": "internal", "certificate": certPEM, "key": keyPEM, "ca": certPEM})
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "PUT",
+		Path:           fmt.Sprintf("/api/services/%s/client_certificates", service.Subdomain),
+		Body:           string(payload),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	var cert account.ClientCertificate
+	json.Unmarshal(body, &cert)
+	c.Assert(cert, DeepEquals, account.ClientCertificate{Name: "internal", Service: "apihub", Certificate: certPEM, CA: certPEM, NotAfter: "2030-01-01T00:00:00Z"})
+	stored, err := account.FindClientCertificateByNameAndService("internal", service)
+	c.Assert(err, IsNil)
+	c.Assert(stored.Key, Not(Equals), keyPEM)
+	key, err := stored.DecryptKey("secret")
+	c.Assert(err, IsNil)
+	c.Assert(key, Equals, keyPEM)
+}
+
+func (s *S) TestSaveClientCertificateWithInvalidFields(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusBadRequest,
+		Method:         "PUT",
+		Path:           fmt.Sprintf("/api/services/%s/client_certificates", service.Subdomain),
+		Body:           `{"name": "internal", "ca": "invalid"}`,
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(string(body), Equals, `{"error":"bad_request","error_description":"Certificate and Key must be a PEM encoded pair, and CA a PEM encoded bundle."}`)
+}
+
+func (s *S) TestSaveClientCertificateWithoutSignIn(c *C) {
+	testWithoutSignIn(requests.Args{AcceptableCode: http.StatusUnauthorized, Method: "PUT", Path: "/api/services/apihub/client_certificates", Body: `{}`}, c)
+}
+
+func (s *S) TestListClientCertificates(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	defer s.store.DeleteClientCertificatesByService(service)
+	certPEM, keyPEM := selfSignedCertificate(c, "gateway.example.org")
+	cert := account.ClientCertificate{Name: "internal", Certificate: certPEM, Key: keyPEM}
+	cert.Save(service)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "GET",
+		Path:           fmt.Sprintf("/api/services/%s/client_certificates", service.Subdomain),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	var certs struct {
+		Items []account.ClientCertificate `json:"items"`
+		Count int                         `json:"item_count"`
+	}
+	json.Unmarshal(body, &certs)
+	c.Assert(certs.Count, Equals, 1)
+	c.Assert(certs.Items[0].Name, Equals, "internal")
+	c.Assert(certs.Items[0].Key, Equals, "")
+}
+
+func (s *S) TestUpdateServiceWithUpstreamTLS(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	defer s.store.DeleteClientCertificatesByService(service)
+	certPEM, keyPEM := selfSignedCertificate(c, "gateway.example.org")
+	cert := account.ClientCertificate{Name: "internal", Certificate: certPEM, Key: keyPEM}
+	cert.Save(service)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "PUT",
+		Path:           fmt.Sprintf("/api/services/%s", service.Subdomain),
+		Body:           `{"upstream_tls": {"client_certificate": "internal", "server_name": "internal.example.org"}}`,
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+	c.Assert(code, Equals, http.StatusOK)
+	var updated account.Service
+	json.Unmarshal(body, &updated)
+	c.Assert(*updated.UpstreamTLS, DeepEquals, account.UpstreamTLS{ClientCertificate: "internal", ServerName: "internal.example.org"})
+
+	_, code, body, _ = httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusForbidden,
+		Method:         "DELETE",
+		Path:           fmt.Sprintf("/api/services/%s/client_certificates/internal", service.Subdomain),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+	c.Assert(code, Equals, http.StatusForbidden)
+	c.Assert(string(body), Equals, `{"error":"access_denied","error_description":"The client certificate is used by the service. Please remove it from the upstream TLS settings first."}`)
+}
+
+func (s *S) TestUpdateServiceWithInvalidUpstreamTLS(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusBadRequest,
+		Method:         "PUT",
+		Path:           fmt.Sprintf("/api/services/%s", service.Subdomain),
+		Body:           `{"upstream_tls": {"client_certificate": "not-found"}}`,
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(string(body), Equals, `{"error":"bad_request","error_description":"Upstream TLS must reference a client certificate of the service."}`)
+}
+
+func (s *S) TestDeleteClientCertificate(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	certPEM, keyPEM := selfSignedCertificate(c, "gateway.example.org")
+	cert := account.ClientCertificate{Name: "internal", Certificate: certPEM, Key: keyPEM}
+	cert.Save(service)
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "DELETE",
+		Path:           fmt.Sprintf("/api/services/%s/client_certificates/internal", service.Subdomain),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	_, err := account.FindClientCertificateByNameAndService("internal", service)
+	c.Assert(err, NotNil)
+}
+
+func (s *S) TestDeleteClientCertificateNotFound(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNotFound,
+		Method:         "DELETE",
+		Path:           fmt.Sprintf("/api/services/%s/client_certificates/not-found", service.Subdomain),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusNotFound)
+	c.Assert(string(body), Equals, `{"error":"not_found","error_description":"Client certificate not found."}`)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+	"github.com/gorilla/mux"
+)
+
+func (api *Api) domainCreate(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	team, err := findTeamAndCheckUser(mux.Vars(r)["alias"], user, account.PERMISSION_WRITE)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	domain := account.Domain{}
+	if err := json.NewDecoder(r.Body).Decode(&domain); err != nil {
+		handleError(rw, errors.ErrBadRequest)
+		return
+	}
+
+	if err := domain.Create(*team); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Created(rw, domain)
+}
+
+func (api *Api) domainList(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	team, err := findTeamAndCheckUser(mux.Vars(r)["alias"], user, account.PERMISSION_READ)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	domains, _ := account.FindDomainsByTeam(*team)
+	Ok(rw, CollectionSerializer{Items: domains, Count: len(domains)})
+}
+
+func (api *Api) domainVerify(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	domain, err := findDomainAndCheckUser(mux.Vars(r)["alias"], mux.Vars(r)["name"], user, account.PERMISSION_WRITE)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	if err := domain.Verify(); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, domain)
+}
+
+func (api *Api) domainDelete(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	domain, err := findDomainAndCheckUser(mux.Vars(r)["alias"], mux.Vars(r)["name"], user, account.PERMISSION_DELETE)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	if err := domain.Delete(); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, domain)
+}
+
+func findDomainAndCheckUser(alias, name string, user *account.User, permission account.Permission) (*account.Domain, error) {
+	team, err := findTeamAndCheckUser(alias, user, permission)
+	if err != nil {
+		return nil, err
+	}
+
+	return account.FindDomainByNameAndTeam(name, *team)
+}
@@ -0,0 +1,145 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/requests"
+	. "gopkg.in/check.v1"
+)
+
+// fakeResolver answers the TXT lookups with the records it was given.
+type fakeResolver map[string][]string
+
+func (f fakeResolver) LookupTXT(name string) ([]string, error) {
+	return f[name], nil
+}
+
+func (s *S) TestCreateDomain(c *C) {
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+	defer s.store.DeleteDomainsByTeam(team)
+
+	headers, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusCreated,
+		Method:         "POST",
+		Path:           fmt.Sprintf("/api/teams/%s/domains", team.Alias),
+		Body:           `{"name": "Example.org"}`,
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusCreated)
+	c.Assert(headers.Get("Content-Type"), Equals, "application/json")
+	domain := account.Domain{}
+	json.Unmarshal(body, &domain)
+	c.Assert(domain.Name, Equals, "example.org")
+	c.Assert(domain.Team, Equals, team.Alias)
+	c.Assert(domain.Token, Not(Equals), "")
+	c.Assert(domain.Verified(), Equals, false)
+}
+
+func (s *S) TestCreateDomainWithInvalidName(c *C) {
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusBadRequest,
+		Method:         "POST",
+		Path:           fmt.Sprintf("/api/teams/%s/domains", team.Alias),
+		Body:           `{"name": "*.example.org"}`,
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(string(body), Equals, `{"error":"bad_request","error_description":"Name must be a valid domain, such as example.org."}`)
+}
+
+func (s *S) TestListDomains(c *C) {
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+	domain := account.Domain{Name: "example.org", Team: team.Alias, Token: "token", CreatedAt: "2015-05-16T12:40:44Z"}
+	s.store.UpsertDomain(domain)
+	defer s.store.DeleteDomainsByTeam(team)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "GET",
+		Path:           fmt.Sprintf("/api/teams/%s/domains", team.Alias),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(string(body), Equals, `{"items":[{"name":"example.org","team":"apihub","token":"token","created_at":"2015-05-16T12:40:44Z"}],"item_count":1}`)
+}
+
+func (s *S) TestVerifyDomain(c *C) {
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+	s.store.UpsertDomain(account.Domain{Name: "example.org", Team: team.Alias, Token: "token"})
+	defer s.store.DeleteDomainsByTeam(team)
+	s.api.DNSResolver(fakeResolver{"_apihub-challenge.example.org": {"token"}})
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "POST",
+		Path:           fmt.Sprintf("/api/teams/%s/domains/example.org/verify", team.Alias),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	domain := account.Domain{}
+	json.Unmarshal(body, &domain)
+	c.Assert(domain.Verified(), Equals, true)
+}
+
+func (s *S) TestVerifyDomainWithoutRecord(c *C) {
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+	s.store.UpsertDomain(account.Domain{Name: "example.org", Team: team.Alias, Token: "token"})
+	defer s.store.DeleteDomainsByTeam(team)
+	s.api.DNSResolver(fakeResolver{})
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusBadRequest,
+		Method:         "POST",
+		Path:           fmt.Sprintf("/api/teams/%s/domains/example.org/verify", team.Alias),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(string(body), Equals, `{"error":"bad_request","error_description":"The TXT record with the token was not found. Please check the DNS settings of the domain."}`)
+}
+
+func (s *S) TestDeleteDomain(c *C) {
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+	s.store.UpsertDomain(account.Domain{Name: "example.org", Team: team.Alias, Token: "token"})
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "DELETE",
+		Path:           fmt.Sprintf("/api/teams/%s/domains/example.org", team.Alias),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	_, err := s.store.FindDomainByNameAndTeam("example.org", team.Alias)
+	c.Assert(err, NotNil)
+}
+
+func (s *S) TestDeleteDomainNotFound(c *C) {
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNotFound,
+		Method:         "DELETE",
+		Path:           fmt.Sprintf("/api/teams/%s/domains/example.org", team.Alias),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusNotFound)
+	c.Assert(string(body), Equals, `{"error":"not_found","error_description":"Domain not found."}`)
+}
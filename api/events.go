@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -15,6 +16,8 @@ import (
 type Event interface {
 	Data() []byte
 	Name() string
+	// Team returns the alias of the team the event belongs to.
+	Team() string
 }
 
 func (api *Api) EventNotifier(ev Event) {
@@ -23,6 +26,9 @@ func (api *Api) EventNotifier(ev Event) {
 
 func (api *Api) ListenEvents() {
 	Logger.Info("Started listening to events in background.")
+	if api.pubsub != nil {
+		api.listenUpstreams()
+	}
 
 	go func() {
 		for event := range api.Events {
@@ -35,13 +41,51 @@ func (api *Api) ListenEvents() {
 	}()
 }
 
+// listenUpstreams turns the changes on the health of the upstreams, published by the gateway,
+// into events.
+func (api *Api) listenUpstreams() {
+	receiverC := make(chan interface{})
+	done := make(chan bool)
+
+	api.pubsub.Subscribe("/upstreams", receiverC, done)
+
+	go func() {
+		for msg := range receiverC {
+			if msg != nil {
+				pubsubUpdates.Inc("/upstreams")
+				m, ok := msg.(string)
+				if !ok {
+					Logger.Warn("Failed to convert message to string: %+v.", msg)
+					continue
+				}
+
+				var state account.UpstreamState
+				if err := json.NewDecoder(bytes.NewBufferString(m)).Decode(&state); err != nil {
+					Logger.Warn("Failed to decode upstream state: %+v.", msg)
+					continue
+				}
+				service, err := account.FindServiceBySubdomain(state.Service)
+				if err != nil {
+					Logger.Warn("Failed to find the service of the upstream state: %+v.", msg)
+					continue
+				}
+				api.EventNotifier(newUpstreamEvent(state, *service))
+			}
+		}
+	}()
+}
+
+// sendHooks delivers the event to the hooks of its team, along with the hooks registered for all teams.
 func sendHooks(event Event) {
-	// TODO: Need to load team specific!
 	allHookw, err := account.FindHooksByEvent(event.Name())
 
 	if len(allHookw) > 0 && err == nil {
 		Logger.Debug(fmt.Sprintf("Start sending hooks to the following list: %+v.", allHookw))
 		for _, hook := range allHookw {
+			if hook.Team != event.Team() && hook.Team != account.ALL_TEAMS {
+				continue
+			}
+
 			data, err := parseData(event, hook)
 			if err != nil {
 				Logger.Warn("Could not parse Event data: %+v. Default format will be delivered.", err)
@@ -88,7 +132,10 @@ func sendWebHook(config account.HookConfig, body interface{}) {
 		})
 
 		if err != nil {
+			webhookDeliveries.Inc("failure")
 			Logger.Warn(fmt.Sprintf("Failed to call WebHook for %s: %s.", config.Address, err.Error()))
+			return
 		}
+		webhookDeliveries.Inc("success")
 	}
 }
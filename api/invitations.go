@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+	"github.com/gorilla/mux"
+)
+
+func (api *Api) invitationCreate(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	team, err := findTeamAndCheckUser(mux.Vars(r)["alias"], user, account.PERMISSION_MANAGE_MEMBERS)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	invitation := account.Invitation{}
+	if err := json.NewDecoder(r.Body).Decode(&invitation); err != nil {
+		handleError(rw, errors.ErrBadRequest)
+		return
+	}
+
+	if err := invitation.Create(*team, *user); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Created(rw, invitation)
+}
+
+func (api *Api) invitationList(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	team, err := findTeamAndCheckUser(mux.Vars(r)["alias"], user, account.PERMISSION_MANAGE_MEMBERS)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	invitations, _ := account.FindInvitationsByTeam(*team)
+	Ok(rw, CollectionSerializer{Items: invitations, Count: len(invitations)})
+}
+
+func (api *Api) invitationResend(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	invitation, err := findInvitationAndCheckUser(mux.Vars(r)["alias"], mux.Vars(r)["id"], user)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	if err := invitation.Resend(); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, invitation)
+}
+
+func (api *Api) invitationDelete(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	invitation, err := findInvitationAndCheckUser(mux.Vars(r)["alias"], mux.Vars(r)["id"], user)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	if err := invitation.Delete(); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, invitation)
+}
+
+func (api *Api) invitationAccept(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	body := struct {
+		Token string `json:"token"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		handleError(rw, errors.ErrBadRequest)
+		return
+	}
+
+	invitation, err := account.FindInvitationByToken(body.Token)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	if err := invitation.Accept(*user); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	team, err := account.FindTeamByAlias(invitation.Team)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, team)
+}
+
+func findInvitationAndCheckUser(alias, id string, user *account.User) (*account.Invitation, error) {
+	team, err := findTeamAndCheckUser(alias, user, account.PERMISSION_MANAGE_MEMBERS)
+	if err != nil {
+		return nil, err
+	}
+
+	return account.FindInvitationByIdAndTeam(id, *team)
+}
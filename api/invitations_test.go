@@ -0,0 +1,284 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/mail"
+	"github.com/apihub/apihub/requests"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) createInvitation(c *C, email, role string) account.Invitation {
+	invitation := account.Invitation{Email: email, Role: role}
+	c.Assert(invitation.Create(team, user), IsNil)
+	return invitation
+}
+
+func (s *S) TestCreateInvitation(c *C) {
+	sender := mail.NewMemorySender()
+	s.api.Mailer(sender)
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+
+	headers, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusCreated,
+		Method:         "POST",
+		Path:           fmt.Sprintf("/api/teams/%s/invitations", team.Alias),
+		Body:           `{"email": "alice@bar.example.org", "role": "viewer"}`,
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusCreated)
+	c.Assert(headers.Get("Content-Type"), Equals, "application/json")
+	invitation := account.Invitation{}
+	json.Unmarshal(body, &invitation)
+	c.Assert(invitation.Email, Equals, "alice@bar.example.org")
+	c.Assert(invitation.Role, Equals, account.ROLE_VIEWER)
+	c.Assert(invitation.Team, Equals, team.Alias)
+	c.Assert(invitation.Inviter, Equals, user.Email)
+	c.Assert(strings.HasPrefix(invitation.Token, invitation.Id+"."), Equals, true)
+	c.Assert(strings.Contains(string(body), "hash"), Equals, false)
+
+	messages := sender.Messages()
+	c.Assert(messages, HasLen, 1)
+	c.Assert(messages[0].To, DeepEquals, []string{"alice@bar.example.org"})
+	c.Assert(strings.Contains(messages[0].Body, invitation.Token), Equals, true)
+}
+
+func (s *S) TestCreateInvitationForMember(c *C) {
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusBadRequest,
+		Method:         "POST",
+		Path:           fmt.Sprintf("/api/teams/%s/invitations", team.Alias),
+		Body:           fmt.Sprintf(`{"email": "%s"}`, user.Email),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(string(body), Equals, `{"error":"bad_request","error_description":"The user is already a member of this team."}`)
+}
+
+func (s *S) TestCreateInvitationWithoutPermission(c *C) {
+	alice := account.User{Name: "alice", Email: "alice@bar.example.org", Password: "secret"}
+	alice.Create()
+	defer alice.Delete()
+
+	team := account.Team{Name: "ApiHub Team", Alias: "apihub", Users: []string{user.Email}}
+	team.Create(alice)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusForbidden,
+		Method:         "POST",
+		Path:           fmt.Sprintf("/api/teams/%s/invitations", team.Alias),
+		Body:           `{"email": "mary@bar.example.org"}`,
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusForbidden)
+	c.Assert(string(body), Equals, `{"error":"access_denied","error_description":"Your role in this team does not allow this operation."}`)
+}
+
+func (s *S) TestCreateInvitationWithoutSignIn(c *C) {
+	testWithoutSignIn(requests.Args{AcceptableCode: http.StatusUnauthorized, Method: "POST", Path: "/api/teams/apihub/invitations", Body: `{"email": "alice@bar.example.org"}`}, c)
+}
+
+func (s *S) TestInvitationList(c *C) {
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+	invitation := s.createInvitation(c, "alice@bar.example.org", account.ROLE_ADMIN)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "GET",
+		Path:           fmt.Sprintf("/api/teams/%s/invitations", team.Alias),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(string(body), Equals, fmt.Sprintf(`{"items":[{"id":"%s","team":"apihub","email":"alice@bar.example.org","role":"admin","inviter":"bob@bar.example.org","created_at":"%s","expires_at":"%s"}],"item_count":1}`, invitation.Id, invitation.CreatedAt, invitation.ExpiresAt))
+}
+
+func (s *S) TestResendInvitation(c *C) {
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+	invitation := s.createInvitation(c, "alice@bar.example.org", account.ROLE_DEVELOPER)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "POST",
+		Path:           fmt.Sprintf("/api/teams/%s/invitations/%s/resend", team.Alias, invitation.Id),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	resent := account.Invitation{}
+	json.Unmarshal(body, &resent)
+	c.Assert(resent.Id, Equals, invitation.Id)
+	c.Assert(resent.Token, Not(Equals), invitation.Token)
+	c.Assert(strings.HasPrefix(resent.Token, invitation.Id+"."), Equals, true)
+}
+
+func (s *S) TestDeleteInvitation(c *C) {
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+	invitation := s.createInvitation(c, "alice@bar.example.org", account.ROLE_DEVELOPER)
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "DELETE",
+		Path:           fmt.Sprintf("/api/teams/%s/invitations/%s", team.Alias, invitation.Id),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	_, err := s.store.FindInvitationById(invitation.Id)
+	c.Assert(err, NotNil)
+}
+
+func (s *S) TestDeleteInvitationNotFound(c *C) {
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNotFound,
+		Method:         "DELETE",
+		Path:           fmt.Sprintf("/api/teams/%s/invitations/not-found", team.Alias),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusNotFound)
+	c.Assert(string(body), Equals, `{"error":"not_found","error_description":"Invitation not found."}`)
+}
+
+func (s *S) TestAcceptInvitation(c *C) {
+	alice := account.User{Name: "alice", Email: "alice@bar.example.org", Password: "secret"}
+	alice.Create()
+	defer alice.Delete()
+	token, err := s.api.Login(alice.Email, "secret")
+	c.Assert(err, IsNil)
+
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+	invitation := s.createInvitation(c, alice.Email, account.ROLE_VIEWER)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "POST",
+		Path:           "/api/invitations/accept",
+		Body:           fmt.Sprintf(`{"token": "%s"}`, invitation.Token),
+		Headers:        http.Header{"Authorization": {fmt.Sprintf("%s %s", token.Type, token.AccessToken)}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(string(body), Equals, `{"name":"ApiHub Team","alias":"apihub","users":["bob@bar.example.org","alice@bar.example.org"],"owner":"bob@bar.example.org","roles":[{"email":"alice@bar.example.org","role":"viewer"}]}`)
+}
+
+func (s *S) TestAcceptInvitationForAnotherUser(c *C) {
+	team := account.Team{Name: "ApiHub Team", Alias: "apihub"}
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+	invitation := account.Invitation{Email: "alice@bar.example.org"}
+	invitation.Create(team, user)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusForbidden,
+		Method:         "POST",
+		Path:           "/api/invitations/accept",
+		Body:           fmt.Sprintf(`{"token": "%s"}`, invitation.Token),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusForbidden)
+	c.Assert(string(body), Equals, `{"error":"access_denied","error_description":"The invitation was sent to another email."}`)
+}
+
+func (s *S) TestAcceptInvitationWithInvalidToken(c *C) {
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNotFound,
+		Method:         "POST",
+		Path:           "/api/invitations/accept",
+		Body:           `{"token": "invalid.token"}`,
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusNotFound)
+	c.Assert(string(body), Equals, `{"error":"not_found","error_description":"Invitation not found."}`)
+}
+
+func (s *S) TestSignupDoesNotJoinTheInvitedTeams(c *C) {
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+	s.createInvitation(c, "alice@example.org", account.ROLE_DEVELOPER)
+	defer func() {
+		s.store.DeleteUser(account.User{Email: "alice@example.org"})
+	}()
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusCreated,
+		Method:         "POST",
+		Path:           "/auth/signup",
+		Body:           `{"name": "Alice", "email": "alice@example.org", "password": "123456"}`,
+	})
+
+	// The email is not verified yet, so anyone may have signed up with it.
+	c.Assert(code, Equals, http.StatusCreated)
+	t, err := s.store.FindTeamByAlias(team.Alias)
+	c.Assert(err, IsNil)
+	c.Assert(t.Users, DeepEquals, []string{user.Email})
+	invitations, _ := s.store.FindInvitationsByEmail("alice@example.org")
+	c.Assert(invitations, HasLen, 1)
+}
+
+func (s *S) TestSignupWithInvitationJoinsTheTeam(c *C) {
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+	invitation := s.createInvitation(c, "alice@example.org", account.ROLE_DEVELOPER)
+	defer func() {
+		s.store.DeleteUser(account.User{Email: "alice@example.org"})
+	}()
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusCreated,
+		Method:         "POST",
+		Path:           "/auth/signup",
+		Body:           fmt.Sprintf(`{"name": "Alice", "email": "alice@example.org", "password": "123456", "invitation": "%s"}`, invitation.Token),
+	})
+
+	c.Assert(code, Equals, http.StatusCreated)
+	t, err := s.store.FindTeamByAlias(team.Alias)
+	c.Assert(err, IsNil)
+	c.Assert(t.Users, DeepEquals, []string{user.Email, "alice@example.org"})
+	invitations, _ := s.store.FindInvitationsByEmail("alice@example.org")
+	c.Assert(invitations, HasLen, 0)
+
+	// The token proves that the user owns the email.
+	alice, err := s.store.FindUserByEmail("alice@example.org")
+	c.Assert(err, IsNil)
+	c.Assert(alice.EmailVerified, Equals, true)
+}
+
+func (s *S) TestSignupWithInvitationToAnotherEmail(c *C) {
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+	invitation := s.createInvitation(c, "alice@example.org", account.ROLE_DEVELOPER)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusForbidden,
+		Method:         "POST",
+		Path:           "/auth/signup",
+		Body:           fmt.Sprintf(`{"name": "Mallory", "email": "mallory@example.org", "password": "123456", "invitation": "%s"}`, invitation.Token),
+	})
+
+	c.Assert(code, Equals, http.StatusForbidden)
+	c.Assert(string(body), Equals, `{"error":"access_denied","error_description":"The invitation was sent to another email."}`)
+	_, err := s.store.FindUserByEmail("mallory@example.org")
+	c.Assert(err, NotNil)
+}
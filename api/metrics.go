@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	. "github.com/apihub/apihub/log"
+	"github.com/apihub/apihub/metrics"
+	"github.com/codegangsta/negroni"
+)
+
+var (
+	requestsTotal     = metrics.NewCounter("apihub_api_requests_total", "Number of requests handled by the api.", "method", "code")
+	requestDuration   = metrics.NewHistogram("apihub_api_request_duration_seconds", "Time taken to handle the requests, in seconds.", nil, "method", "code")
+	webhookDeliveries = metrics.NewCounter("apihub_api_webhook_deliveries_total", "Number of webhooks sent, by result (success or failure).", "result")
+	pubsubUpdates     = metrics.NewCounter("apihub_api_pubsub_updates_total", "Number of updates received through the pubsub, by channel.", "channel")
+)
+
+func init() {
+	metrics.MustRegister(requestsTotal, requestDuration, webhookDeliveries, pubsubUpdates)
+}
+
+// measuredHandler counts the requests handled by the api, by method and status code.
+func measuredHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := negroni.NewResponseWriter(w)
+		h.ServeHTTP(rw, r)
+
+		status := rw.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		code := strconv.Itoa(status)
+		method := metricMethod(r.Method)
+		requestsTotal.Inc(method, code)
+		requestDuration.Observe(time.Since(start).Seconds(), method, code)
+	})
+}
+
+// metricMethod keeps the label of the standard methods only, so the clients cannot create new series at will.
+func metricMethod(method string) string {
+	switch method {
+	case "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "TRACE":
+		return method
+	}
+	return "OTHER"
+}
+
+// serveMetrics serves the metrics on a port of their own, so they are not exposed along with the api.
+func (api *Api) serveMetrics() {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	Logger.Info("ApiHub metrics are now available on port %s.", api.metricsPort)
+	Logger.Error("ApiHub metrics are no longer available: %s.", http.ListenAndServe(api.metricsPort, mux))
+}
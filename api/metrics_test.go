@@ -0,0 +1,55 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/apihub/apihub/metrics"
+	. "gopkg.in/check.v1"
+)
+
+func scrapeMetrics() *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	metrics.Handler().ServeHTTP(rw, req)
+	return rw
+}
+
+func (s *S) TestMetrics(c *C) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	s.api.Handler().ServeHTTP(httptest.NewRecorder(), req)
+
+	rw := scrapeMetrics()
+	c.Assert(rw.Code, Equals, http.StatusOK)
+	c.Assert(rw.Header().Get("Content-Type"), Equals, metrics.CONTENT_TYPE)
+	c.Assert(strings.Contains(rw.Body.String(), `apihub_api_requests_total{method="GET",code="200"} `), Equals, true)
+	c.Assert(strings.Contains(rw.Body.String(), `apihub_api_request_duration_seconds_count{method="GET",code="200"} `), Equals, true)
+	for _, name := range []string{"apihub_api_webhook_deliveries_total", "apihub_api_pubsub_updates_total"} {
+		c.Assert(strings.Contains(rw.Body.String(), "# TYPE "+name+" counter"), Equals, true)
+	}
+}
+
+func (s *S) TestMetricsCountsNotFound(c *C) {
+	req, _ := http.NewRequest("GET", "/not-found", nil)
+	s.api.Handler().ServeHTTP(httptest.NewRecorder(), req)
+
+	rw := scrapeMetrics()
+	c.Assert(strings.Contains(rw.Body.String(), `apihub_api_requests_total{method="GET",code="404"} `), Equals, true)
+}
+
+func (s *S) TestMetricsCountsUnknownMethodsAsOther(c *C) {
+	req, _ := http.NewRequest("FOOBAR", "/", nil)
+	s.api.Handler().ServeHTTP(httptest.NewRecorder(), req)
+
+	rw := scrapeMetrics()
+	c.Assert(strings.Contains(rw.Body.String(), `method="FOOBAR"`), Equals, false)
+	c.Assert(strings.Contains(rw.Body.String(), `apihub_api_requests_total{method="OTHER",code="405"} `), Equals, true)
+}
+
+func (s *S) TestMetricsAreNotServedByTheApi(c *C) {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	s.api.Handler().ServeHTTP(rw, req)
+	c.Assert(rw.Code, Equals, http.StatusNotFound)
+}
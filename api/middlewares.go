@@ -2,7 +2,9 @@ package api
 
 import (
 	"net/http"
+	"strings"
 
+	"github.com/apihub/apihub/account"
 	"github.com/apihub/apihub/errors"
 	. "github.com/apihub/apihub/log"
 	"github.com/gorilla/context"
@@ -16,13 +18,26 @@ func (api *Api) requestIdMiddleware(rw http.ResponseWriter, r *http.Request, nex
 	rw.Header().Set("X-Request-Id", reqID)
 }
 
+// authorizationMiddleware accepts both the tokens of the sessions and the personal access tokens.
+// The latter are only allowed to perform the operations covered by their scopes.
 func (api *Api) authorizationMiddleware(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	authorization := r.Header.Get("Authorization")
 
-	user, err := api.auth.UserFromToken(authorization)
+	var (
+		user *account.User
+		err  error
+	)
+	if strings.HasPrefix(authorization, account.PERSONAL_ACCESS_TOKEN_TYPE+" ") {
+		user, err = api.userFromPersonalAccessToken(r, strings.TrimPrefix(authorization, account.PERSONAL_ACCESS_TOKEN_TYPE+" "))
+	} else {
+		user, err = api.auth.UserFromToken(authorization)
+	}
 	if err != nil {
 		Logger.Info("authorizationMiddleware: Err: %s.", err)
-		AddRequestError(r, errors.NewUnauthorizedError(errors.ErrLoginRequired))
+		if _, ok := err.(errors.ForbiddenError); !ok {
+			err = errors.NewUnauthorizedError(errors.ErrLoginRequired)
+		}
+		AddRequestError(r, err)
 		return
 	}
 
@@ -0,0 +1,265 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+)
+
+const (
+	AUTHORIZATION_CODE = "authorization_code"
+	CLIENT_CREDENTIALS = "client_credentials"
+	REFRESH_TOKEN      = "refresh_token"
+	RESPONSE_TYPE_CODE = "code"
+)
+
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// oauthAuthorize issues an authorization code to the App on behalf of the logged user,
+// sending the user agent back to the redirect uri of the App.
+func (api *Api) oauthAuthorize(rw http.ResponseWriter, r *http.Request) {
+	user, err := api.auth.UserFromToken(r.Header.Get("Authorization"))
+	if err != nil {
+		handleError(rw, errors.NewUnauthorizedError(errors.ErrLoginRequired))
+		return
+	}
+
+	app, err := account.FindAppByClientId(r.FormValue("client_id"))
+	if err != nil {
+		handleError(rw, errors.NewOAuthError(errors.E_INVALID_REQUEST, errors.ErrClientNotFound))
+		return
+	}
+	// Errors are only sent to the redirect uri once it is known to belong to the App.
+	redirectUri, ok := app.RedirectUri(r.FormValue("redirect_uri"))
+	if !ok {
+		handleError(rw, errors.NewOAuthError(errors.E_INVALID_REQUEST, errors.ErrOAuthInvalidRedirectUri))
+		return
+	}
+
+	state := r.FormValue("state")
+	if r.FormValue("response_type") != RESPONSE_TYPE_CODE {
+		redirectAuthorizationError(rw, r, redirectUri, state, errors.E_UNSUPPORTED_RESPONSE_TYPE, errors.ErrOAuthUnsupportedResponseType)
+		return
+	}
+
+	challenge, method := r.FormValue("code_challenge"), r.FormValue("code_challenge_method")
+	if !account.ValidCodeChallengeMethod(method) || (method != "" && challenge == "") {
+		redirectAuthorizationError(rw, r, redirectUri, state, errors.E_INVALID_REQUEST, errors.ErrOAuthInvalidChallengeMethod)
+		return
+	}
+	if challenge != "" && method == "" {
+		method = account.CODE_CHALLENGE_PLAIN
+	}
+	if !app.AllowsScope(r.FormValue("scope")) {
+		redirectAuthorizationError(rw, r, redirectUri, state, errors.E_INVALID_SCOPE, errors.ErrOAuthInvalidScope)
+		return
+	}
+
+	grant := account.Grant{
+		ClientId:            app.ClientId,
+		User:                user.Email,
+		RedirectUri:         r.FormValue("redirect_uri"),
+		Scope:               r.FormValue("scope"),
+		CodeChallenge:       challenge,
+		CodeChallengeMethod: method,
+	}
+	if err := grant.Create(); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	redirectAuthorization(rw, r, redirectUri, state, url.Values{"code": {grant.Code}})
+}
+
+// oauthToken issues access tokens for the authorization code, client credentials and refresh token grants.
+func (api *Api) oauthToken(rw http.ResponseWriter, r *http.Request) {
+	var (
+		token *account.OAuthToken
+		err   error
+	)
+
+	switch r.PostFormValue("grant_type") {
+	case AUTHORIZATION_CODE:
+		token, err = exchangeAuthorizationCode(r)
+	case CLIENT_CREDENTIALS:
+		token, err = exchangeClientCredentials(r)
+	case REFRESH_TOKEN:
+		token, err = exchangeRefreshToken(r)
+	default:
+		err = errors.NewOAuthError(errors.E_UNSUPPORTED_GRANT_TYPE, errors.ErrOAuthUnsupportedGrantType)
+	}
+
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("Pragma", "no-cache")
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, oauthTokenResponse{
+		AccessToken:  token.AccessToken,
+		TokenType:    account.OAUTH_TOKEN_TYPE,
+		ExpiresIn:    token.ExpiresIn(),
+		RefreshToken: token.RefreshToken,
+		Scope:        token.Scope,
+	})
+}
+
+func exchangeAuthorizationCode(r *http.Request) (*account.OAuthToken, error) {
+	app, authenticated, err := oauthClient(r)
+	if err != nil {
+		return nil, err
+	}
+
+	code := r.PostFormValue("code")
+	if code == "" {
+		return nil, errors.NewOAuthError(errors.E_INVALID_REQUEST, errors.ErrOAuthMissingParameters)
+	}
+	grant, err := account.FindGrantByCode(code)
+	if err != nil {
+		return nil, errors.NewOAuthError(errors.E_INVALID_GRANT, errors.ErrOAuthInvalidGrant)
+	}
+	// A code is used only once, even if the exchange fails.
+	grant.Delete()
+
+	if grant.ClientId != app.ClientId || grant.Expired() || grant.RedirectUri != r.PostFormValue("redirect_uri") {
+		return nil, errors.NewOAuthError(errors.E_INVALID_GRANT, errors.ErrOAuthInvalidGrant)
+	}
+	if grant.CodeChallenge != "" {
+		if !grant.VerifyCodeVerifier(r.PostFormValue("code_verifier")) {
+			return nil, errors.NewOAuthError(errors.E_INVALID_GRANT, errors.ErrOAuthInvalidCodeVerifier)
+		}
+	} else if !authenticated {
+		// Clients which do not send their secret must use PKCE.
+		return nil, errors.NewOAuthError(errors.E_INVALID_CLIENT, errors.ErrOAuthInvalidClient)
+	}
+
+	token := &account.OAuthToken{ClientId: app.ClientId, User: grant.User, Scope: grant.Scope, Public: !authenticated}
+	if err := token.Create(true); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func exchangeClientCredentials(r *http.Request) (*account.OAuthToken, error) {
+	app, authenticated, err := oauthClient(r)
+	if err != nil {
+		return nil, err
+	}
+	if !authenticated {
+		return nil, errors.NewOAuthError(errors.E_INVALID_CLIENT, errors.ErrOAuthInvalidClient)
+	}
+
+	scope := r.PostFormValue("scope")
+	if !app.AllowsScope(scope) {
+		return nil, errors.NewOAuthError(errors.E_INVALID_SCOPE, errors.ErrOAuthInvalidScope)
+	}
+
+	// The App acts on its own behalf, so there is no refresh token.
+	token := &account.OAuthToken{ClientId: app.ClientId, Scope: scope}
+	if err := token.Create(false); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func exchangeRefreshToken(r *http.Request) (*account.OAuthToken, error) {
+	app, authenticated, err := oauthClient(r)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken := r.PostFormValue("refresh_token")
+	if refreshToken == "" {
+		return nil, errors.NewOAuthError(errors.E_INVALID_REQUEST, errors.ErrOAuthMissingParameters)
+	}
+	previous, err := account.FindOAuthTokenByRefreshToken(refreshToken)
+	if err != nil || previous.ClientId != app.ClientId {
+		return nil, errors.NewOAuthError(errors.E_INVALID_GRANT, errors.ErrOAuthInvalidGrant)
+	}
+	// Apps which have a secret must send it, so a leaked refresh token is not enough to get new tokens,
+	// unless the token was issued to a public client through PKCE, which has no secret to send.
+	if !authenticated && app.ClientSecret != "" && !previous.Public {
+		return nil, errors.NewOAuthError(errors.E_INVALID_CLIENT, errors.ErrOAuthInvalidClient)
+	}
+
+	scope := r.PostFormValue("scope")
+	if scope == "" {
+		scope = previous.Scope
+	} else if !scopeIncluded(scope, previous.Scope) {
+		return nil, errors.NewOAuthError(errors.E_INVALID_GRANT, errors.ErrOAuthInvalidGrant)
+	}
+	// The scopes allowed to the App may have been narrowed since the previous token was issued.
+	if !app.AllowsScope(scope) {
+		return nil, errors.NewOAuthError(errors.E_INVALID_SCOPE, errors.ErrOAuthInvalidScope)
+	}
+
+	// Refresh tokens are rotated, so the previous one cannot be used again.
+	previous.Delete()
+	token := &account.OAuthToken{ClientId: app.ClientId, User: previous.User, Scope: scope, Public: previous.Public && !authenticated}
+	if err := token.Create(true); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// oauthClient finds the App sending the request, either through HTTP Basic authentication
+// or through the client_id and client_secret parameters.
+// It also returns whether the App has sent its secret.
+func oauthClient(r *http.Request) (*account.App, bool, error) {
+	clientId, secret, ok := r.BasicAuth()
+	if !ok {
+		clientId, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+
+	app, err := account.FindAppByClientId(clientId)
+	if err != nil || (secret != "" && !app.Authenticate(secret)) {
+		return nil, false, errors.NewOAuthError(errors.E_INVALID_CLIENT, errors.ErrOAuthInvalidClient)
+	}
+	return app, secret != "", nil
+}
+
+// scopeIncluded reports whether all the scopes requested were granted before.
+func scopeIncluded(requested, granted string) bool {
+	scopes := map[string]bool{}
+	for _, s := range strings.Fields(granted) {
+		scopes[s] = true
+	}
+	for _, s := range strings.Fields(requested) {
+		if !scopes[s] {
+			return false
+		}
+	}
+	return true
+}
+
+func redirectAuthorization(rw http.ResponseWriter, r *http.Request, redirectUri, state string, params url.Values) {
+	u, err := url.Parse(redirectUri)
+	if err != nil {
+		handleError(rw, errors.NewOAuthError(errors.E_INVALID_REQUEST, errors.ErrOAuthInvalidRedirectUri))
+		return
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	u.RawQuery = query.Encode()
+	http.Redirect(rw, r, u.String(), http.StatusFound)
+}
+
+func redirectAuthorizationError(rw http.ResponseWriter, r *http.Request, redirectUri, state, errType string, err error) {
+	redirectAuthorization(rw, r, redirectUri, state, url.Values{"error": {errType}, "error_description": {err.Error()}})
+}
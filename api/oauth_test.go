@@ -0,0 +1,301 @@
+package api_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/apihub/apihub/requests"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) oauthAuthorize(params url.Values, authorization string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/oauth/authorize?"+params.Encode(), nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rw := httptest.NewRecorder()
+	s.api.Handler().ServeHTTP(rw, req)
+	return rw
+}
+
+func oauthToken(params url.Values, acceptableCode int) (http.Header, int, map[string]interface{}) {
+	headers, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: acceptableCode,
+		Method:         "POST",
+		Path:           "/oauth/token",
+		Body:           params.Encode(),
+		Headers:        http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+	})
+
+	result := map[string]interface{}{}
+	json.Unmarshal(body, &result)
+	return headers, code, result
+}
+
+func (s *S) authorizationCode(c *C, params url.Values) string {
+	params.Set("response_type", "code")
+	params.Set("client_id", app.ClientId)
+	rw := s.oauthAuthorize(params, s.authHeader)
+	c.Assert(rw.Code, Equals, http.StatusFound)
+
+	location, err := url.Parse(rw.Header().Get("Location"))
+	c.Assert(err, IsNil)
+	c.Assert(location.Query().Get("code"), Not(Equals), "")
+	return location.Query().Get("code")
+}
+
+func (s *S) createOAuthApp() {
+	team.Create(user)
+	app.Scopes = []string{"read", "write"}
+	app.Create(user, team)
+}
+
+func (s *S) deleteOAuthApp() {
+	app.Delete(user)
+	s.store.DeleteTeamByAlias(team.Alias)
+}
+
+func (s *S) TestOAuthAuthorizationCode(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	rw := s.oauthAuthorize(url.Values{"response_type": {"code"}, "client_id": {app.ClientId}, "state": {"xyz"}, "scope": {"read"}}, s.authHeader)
+	c.Assert(rw.Code, Equals, http.StatusFound)
+	location, _ := url.Parse(rw.Header().Get("Location"))
+	c.Assert(location.Host, Equals, "www.example.org")
+	c.Assert(location.Path, Equals, "/auth")
+	c.Assert(location.Query().Get("state"), Equals, "xyz")
+	code := location.Query().Get("code")
+
+	params := url.Values{"grant_type": {"authorization_code"}, "code": {code}, "client_id": {app.ClientId}, "client_secret": {app.ClientSecret}}
+	headers, status, body := oauthToken(params, http.StatusOK)
+	c.Assert(status, Equals, http.StatusOK)
+	c.Assert(headers.Get("Cache-Control"), Equals, "no-store")
+	c.Assert(body["access_token"], Not(Equals), "")
+	c.Assert(body["refresh_token"], Not(Equals), nil)
+	c.Assert(body["token_type"], Equals, "Bearer")
+	c.Assert(body["scope"], Equals, "read")
+	c.Assert(body["expires_in"].(float64) > 0, Equals, true)
+
+	// The code cannot be used again.
+	_, status, body = oauthToken(params, http.StatusBadRequest)
+	c.Assert(status, Equals, http.StatusBadRequest)
+	c.Assert(body["error"], Equals, "invalid_grant")
+}
+
+func (s *S) TestOAuthAuthorizationCodeWithPKCE(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := url.Values{"code_challenge": {base64.RawURLEncoding.EncodeToString(sum[:])}, "code_challenge_method": {"S256"}}
+
+	code := s.authorizationCode(c, challenge)
+	_, status, body := oauthToken(url.Values{"grant_type": {"authorization_code"}, "code": {code}, "client_id": {app.ClientId}, "code_verifier": {verifier}}, http.StatusOK)
+	c.Assert(status, Equals, http.StatusOK)
+	c.Assert(body["access_token"], Not(Equals), "")
+
+	code = s.authorizationCode(c, challenge)
+	_, status, body = oauthToken(url.Values{"grant_type": {"authorization_code"}, "code": {code}, "client_id": {app.ClientId}, "code_verifier": {"wrong"}}, http.StatusBadRequest)
+	c.Assert(status, Equals, http.StatusBadRequest)
+	c.Assert(body["error"], Equals, "invalid_grant")
+	c.Assert(body["error_description"], Equals, "The code verifier does not match the code challenge.")
+}
+
+func (s *S) TestOAuthAuthorizationCodeWithoutSecretOrPKCE(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	code := s.authorizationCode(c, url.Values{})
+	_, status, body := oauthToken(url.Values{"grant_type": {"authorization_code"}, "code": {code}, "client_id": {app.ClientId}}, http.StatusUnauthorized)
+	c.Assert(status, Equals, http.StatusUnauthorized)
+	c.Assert(body["error"], Equals, "invalid_client")
+}
+
+func (s *S) TestOAuthAuthorizationCodeWithAnotherRedirectUri(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	code := s.authorizationCode(c, url.Values{"redirect_uri": {"http://www.example.org/auth"}})
+	_, status, body := oauthToken(url.Values{"grant_type": {"authorization_code"}, "code": {code}, "client_id": {app.ClientId}, "client_secret": {app.ClientSecret}}, http.StatusBadRequest)
+	c.Assert(status, Equals, http.StatusBadRequest)
+	c.Assert(body["error"], Equals, "invalid_grant")
+}
+
+func (s *S) TestOAuthAuthorizeWithoutSignIn(c *C) {
+	rw := s.oauthAuthorize(url.Values{"response_type": {"code"}, "client_id": {app.ClientId}}, "")
+	c.Assert(rw.Code, Equals, http.StatusUnauthorized)
+	c.Assert(rw.Body.String(), Equals, `{"error":"unauthorized_access","error_description":"Invalid or expired token. Please log in with your ApiHub credentials."}`)
+}
+
+func (s *S) TestOAuthAuthorizeClientNotFound(c *C) {
+	rw := s.oauthAuthorize(url.Values{"response_type": {"code"}, "client_id": {"not-found"}}, s.authHeader)
+	c.Assert(rw.Code, Equals, http.StatusBadRequest)
+	c.Assert(rw.Body.String(), Equals, `{"error":"invalid_request","error_description":"Client not found."}`)
+}
+
+func (s *S) TestOAuthAuthorizeInvalidRedirectUri(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	rw := s.oauthAuthorize(url.Values{"response_type": {"code"}, "client_id": {app.ClientId}, "redirect_uri": {"http://evil.example.org"}}, s.authHeader)
+	c.Assert(rw.Code, Equals, http.StatusBadRequest)
+	c.Assert(rw.Body.String(), Equals, `{"error":"invalid_request","error_description":"The redirect uri is missing or is not registered for this client."}`)
+}
+
+func (s *S) TestOAuthAuthorizeUnsupportedResponseType(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	rw := s.oauthAuthorize(url.Values{"response_type": {"token"}, "client_id": {app.ClientId}, "state": {"xyz"}}, s.authHeader)
+	c.Assert(rw.Code, Equals, http.StatusFound)
+	location, _ := url.Parse(rw.Header().Get("Location"))
+	c.Assert(location.Query().Get("error"), Equals, "unsupported_response_type")
+	c.Assert(location.Query().Get("state"), Equals, "xyz")
+}
+
+func (s *S) TestOAuthAuthorizeInvalidCodeChallengeMethod(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	rw := s.oauthAuthorize(url.Values{"response_type": {"code"}, "client_id": {app.ClientId}, "code_challenge": {"challenge"}, "code_challenge_method": {"S512"}}, s.authHeader)
+	c.Assert(rw.Code, Equals, http.StatusFound)
+	location, _ := url.Parse(rw.Header().Get("Location"))
+	c.Assert(location.Query().Get("error"), Equals, "invalid_request")
+}
+
+func (s *S) TestOAuthClientCredentials(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	_, status, body := oauthToken(url.Values{"grant_type": {"client_credentials"}, "client_id": {app.ClientId}, "client_secret": {app.ClientSecret}, "scope": {"read write"}}, http.StatusOK)
+	c.Assert(status, Equals, http.StatusOK)
+	c.Assert(body["access_token"], Not(Equals), "")
+	c.Assert(body["scope"], Equals, "read write")
+	_, ok := body["refresh_token"]
+	c.Assert(ok, Equals, false)
+}
+
+func (s *S) TestOAuthClientCredentialsWithBasicAuth(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "POST",
+		Path:           "/oauth/token",
+		Body:           "grant_type=client_credentials",
+		Headers: http.Header{
+			"Content-Type":  {"application/x-www-form-urlencoded"},
+			"Authorization": {"Basic " + base64.StdEncoding.EncodeToString([]byte(app.ClientId+":"+app.ClientSecret))},
+		},
+	})
+	c.Assert(code, Equals, http.StatusOK)
+}
+
+func (s *S) TestOAuthClientCredentialsWithInvalidSecret(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	_, status, body := oauthToken(url.Values{"grant_type": {"client_credentials"}, "client_id": {app.ClientId}, "client_secret": {"wrong"}}, http.StatusUnauthorized)
+	c.Assert(status, Equals, http.StatusUnauthorized)
+	c.Assert(body["error"], Equals, "invalid_client")
+	c.Assert(body["error_description"], Equals, "Client authentication failed.")
+}
+
+func (s *S) TestOAuthRefreshToken(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	code := s.authorizationCode(c, url.Values{"scope": {"read write"}})
+	_, _, body := oauthToken(url.Values{"grant_type": {"authorization_code"}, "code": {code}, "client_id": {app.ClientId}, "client_secret": {app.ClientSecret}}, http.StatusOK)
+	refreshToken := body["refresh_token"].(string)
+
+	params := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}, "client_id": {app.ClientId}, "client_secret": {app.ClientSecret}, "scope": {"read"}}
+	_, status, body := oauthToken(params, http.StatusOK)
+	c.Assert(status, Equals, http.StatusOK)
+	c.Assert(body["refresh_token"], Not(Equals), refreshToken)
+	c.Assert(body["scope"], Equals, "read")
+
+	// Refresh tokens are rotated.
+	_, status, body = oauthToken(params, http.StatusBadRequest)
+	c.Assert(status, Equals, http.StatusBadRequest)
+	c.Assert(body["error"], Equals, "invalid_grant")
+}
+
+func (s *S) TestOAuthRefreshTokenWithWiderScope(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	code := s.authorizationCode(c, url.Values{"scope": {"read"}})
+	_, _, body := oauthToken(url.Values{"grant_type": {"authorization_code"}, "code": {code}, "client_id": {app.ClientId}, "client_secret": {app.ClientSecret}}, http.StatusOK)
+
+	_, status, body := oauthToken(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {body["refresh_token"].(string)}, "client_id": {app.ClientId}, "client_secret": {app.ClientSecret}, "scope": {"read write"}}, http.StatusBadRequest)
+	c.Assert(status, Equals, http.StatusBadRequest)
+	c.Assert(body["error"], Equals, "invalid_grant")
+}
+
+func (s *S) TestOAuthRefreshTokenWithoutSecret(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	code := s.authorizationCode(c, url.Values{"scope": {"read"}})
+	_, _, body := oauthToken(url.Values{"grant_type": {"authorization_code"}, "code": {code}, "client_id": {app.ClientId}, "client_secret": {app.ClientSecret}}, http.StatusOK)
+
+	_, status, body := oauthToken(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {body["refresh_token"].(string)}, "client_id": {app.ClientId}}, http.StatusUnauthorized)
+	c.Assert(status, Equals, http.StatusUnauthorized)
+	c.Assert(body["error"], Equals, "invalid_client")
+}
+
+func (s *S) TestOAuthRefreshTokenOfPublicClient(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	code := s.authorizationCode(c, url.Values{"scope": {"read"}, "code_challenge": {base64.RawURLEncoding.EncodeToString(sum[:])}, "code_challenge_method": {"S256"}})
+	_, _, body := oauthToken(url.Values{"grant_type": {"authorization_code"}, "code": {code}, "client_id": {app.ClientId}, "code_verifier": {verifier}}, http.StatusOK)
+
+	_, status, body := oauthToken(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {body["refresh_token"].(string)}, "client_id": {app.ClientId}}, http.StatusOK)
+	c.Assert(status, Equals, http.StatusOK)
+	c.Assert(body["scope"], Equals, "read")
+
+	// The rotated refresh token is bound to the same client.
+	_, status, body = oauthToken(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {body["refresh_token"].(string)}, "client_id": {"another"}}, http.StatusUnauthorized)
+	c.Assert(status, Equals, http.StatusUnauthorized)
+	c.Assert(body["error"], Equals, "invalid_client")
+}
+
+func (s *S) TestOAuthAuthorizeScopeNotAllowed(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	rw := s.oauthAuthorize(url.Values{"response_type": {"code"}, "client_id": {app.ClientId}, "scope": {"read admin"}}, s.authHeader)
+	c.Assert(rw.Code, Equals, http.StatusFound)
+	location, _ := url.Parse(rw.Header().Get("Location"))
+	c.Assert(location.Query().Get("error"), Equals, "invalid_scope")
+	c.Assert(location.Query().Get("code"), Equals, "")
+}
+
+func (s *S) TestOAuthClientCredentialsScopeNotAllowed(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	_, status, body := oauthToken(url.Values{"grant_type": {"client_credentials"}, "client_id": {app.ClientId}, "client_secret": {app.ClientSecret}, "scope": {"read admin"}}, http.StatusBadRequest)
+	c.Assert(status, Equals, http.StatusBadRequest)
+	c.Assert(body["error"], Equals, "invalid_scope")
+	c.Assert(body["error_description"], Equals, "The scope requested is not allowed to this client.")
+}
+
+func (s *S) TestOAuthUnsupportedGrantType(c *C) {
+	_, status, body := oauthToken(url.Values{"grant_type": {"password"}}, http.StatusBadRequest)
+	c.Assert(status, Equals, http.StatusBadRequest)
+	c.Assert(body["error"], Equals, "unsupported_grant_type")
+	c.Assert(strings.Contains(body["error_description"].(string), "authorization_code"), Equals, true)
+}
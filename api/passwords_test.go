@@ -0,0 +1,220 @@
+package api_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/mail"
+	"github.com/apihub/apihub/requests"
+	. "gopkg.in/check.v1"
+)
+
+// lastToken returns the token of the last message sent, which is on its last line.
+func lastToken(sender *mail.MemorySender) string {
+	messages := sender.Messages()
+	lines := strings.Split(strings.TrimSpace(messages[len(messages)-1].Body), "\n")
+	return lines[len(lines)-1]
+}
+
+func (s *S) TestRecoverPassword(c *C) {
+	sender := mail.NewMemorySender()
+	s.api.Mailer(sender)
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNoContent,
+		Method:         "POST",
+		Path:           "/auth/password/reset",
+		Body:           fmt.Sprintf(`{"email": "%s"}`, user.Email),
+	})
+
+	c.Assert(code, Equals, http.StatusNoContent)
+	messages := sender.Messages()
+	c.Assert(messages, HasLen, 1)
+	c.Assert(messages[0].To, DeepEquals, []string{user.Email})
+}
+
+func (s *S) TestRecoverPasswordWithUnknownEmail(c *C) {
+	sender := mail.NewMemorySender()
+	s.api.Mailer(sender)
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNoContent,
+		Method:         "POST",
+		Path:           "/auth/password/reset",
+		Body:           `{"email": "unknown@example.org"}`,
+	})
+
+	c.Assert(code, Equals, http.StatusNoContent)
+	c.Assert(sender.Messages(), HasLen, 0)
+}
+
+func (s *S) TestResetPassword(c *C) {
+	sender := mail.NewMemorySender()
+	s.api.Mailer(sender)
+	user.RecoverPassword()
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNoContent,
+		Method:         "PUT",
+		Path:           "/auth/password/reset",
+		Body:           fmt.Sprintf(`{"token": "%s", "new_password": "new-secret", "confirmation_password": "new-secret"}`, lastToken(sender)),
+	})
+
+	c.Assert(code, Equals, http.StatusNoContent)
+	_, err := s.api.Login(user.Email, "secret")
+	c.Assert(err, NotNil)
+	_, err = s.api.Login(user.Email, "new-secret")
+	c.Assert(err, IsNil)
+
+	// The previous session is revoked.
+	_, code, _, _ = httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusUnauthorized,
+		Method:         "GET",
+		Path:           "/api/teams",
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+	c.Assert(code, Equals, http.StatusUnauthorized)
+}
+
+func (s *S) TestResetPasswordWithExpiredToken(c *C) {
+	hash := sha256.Sum256([]byte("secret"))
+	s.store.UpsertEmailToken(account.EmailToken{
+		Id:        "expired",
+		Email:     user.Email,
+		Purpose:   account.EMAIL_TOKEN_PASSWORD_RESET,
+		Hash:      hex.EncodeToString(hash[:]),
+		ExpiresAt: time.Now().Add(-time.Minute).In(time.UTC).Format(time.RFC3339),
+	})
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusBadRequest,
+		Method:         "PUT",
+		Path:           "/auth/password/reset",
+		Body:           `{"token": "expired.secret", "new_password": "new-secret", "confirmation_password": "new-secret"}`,
+	})
+
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(string(body), Equals, `{"error":"bad_request","error_description":"The token is invalid, has expired or has already been used."}`)
+}
+
+func (s *S) TestResetPasswordWithInvalidConfirmation(c *C) {
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusBadRequest,
+		Method:         "PUT",
+		Path:           "/auth/password/reset",
+		Body:           `{"token": "id.secret", "new_password": "new-secret", "confirmation_password": "other-secret"}`,
+	})
+
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(string(body), Equals, `{"error":"bad_request","error_description":"Your new password and confirmation password do not match or are invalid."}`)
+}
+
+func (s *S) TestSignupSendsEmailVerification(c *C) {
+	sender := mail.NewMemorySender()
+	s.api.Mailer(sender)
+	defer func() {
+		s.store.DeleteUser(account.User{Email: "alice@example.org"})
+	}()
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusCreated,
+		Method:         "POST",
+		Path:           "/auth/signup",
+		Body:           `{"name": "Alice", "email": "alice@example.org", "password": "123456", "email_verified": true}`,
+	})
+
+	c.Assert(code, Equals, http.StatusCreated)
+	c.Assert(string(body), Equals, `{"name":"Alice","email":"alice@example.org"}`)
+	messages := sender.Messages()
+	c.Assert(messages, HasLen, 1)
+	c.Assert(messages[0].Subject, Equals, "Verify your ApiHub email")
+}
+
+func (s *S) TestVerifyEmail(c *C) {
+	sender := mail.NewMemorySender()
+	s.api.Mailer(sender)
+	user.SendEmailVerification()
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "POST",
+		Path:           "/auth/email/verify",
+		Body:           fmt.Sprintf(`{"token": "%s"}`, lastToken(sender)),
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(string(body), Equals, `{"name":"Bob","email":"bob@bar.example.org","email_verified":true}`)
+}
+
+func (s *S) TestVerifyEmailWithInvalidToken(c *C) {
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusBadRequest,
+		Method:         "POST",
+		Path:           "/auth/email/verify",
+		Body:           `{"token": "invalid"}`,
+	})
+
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(string(body), Equals, `{"error":"bad_request","error_description":"The token is invalid, has expired or has already been used."}`)
+}
+
+func (s *S) TestSendEmailVerification(c *C) {
+	sender := mail.NewMemorySender()
+	s.api.Mailer(sender)
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNoContent,
+		Method:         "POST",
+		Path:           "/auth/email/verification",
+		Body:           fmt.Sprintf(`{"email": "%s"}`, user.Email),
+	})
+
+	c.Assert(code, Equals, http.StatusNoContent)
+	c.Assert(sender.Messages(), HasLen, 1)
+}
+
+func (s *S) TestLoginRequiresEmailVerification(c *C) {
+	sender := mail.NewMemorySender()
+	s.api.Mailer(sender)
+	s.api.RequireEmailVerification(true)
+	team.Create(user)
+	defer s.store.DeleteTeamByAlias(team.Alias)
+	s.createInvitation(c, "alice@example.org", account.ROLE_DEVELOPER)
+	defer func() {
+		s.store.DeleteUser(account.User{Email: "alice@example.org"})
+	}()
+
+	httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusCreated,
+		Method:         "POST",
+		Path:           "/auth/signup",
+		Body:           `{"name": "Alice", "email": "alice@example.org", "password": "123456"}`,
+	})
+	t, _ := s.store.FindTeamByAlias(team.Alias)
+	c.Assert(t.Users, DeepEquals, []string{user.Email})
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusForbidden,
+		Method:         "POST",
+		Path:           "/auth/login",
+		Body:           `{"email": "alice@example.org", "password": "123456"}`,
+	})
+	c.Assert(code, Equals, http.StatusForbidden)
+	c.Assert(string(body), Equals, `{"error":"access_denied","error_description":"Please verify your email before logging in."}`)
+
+	httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "POST",
+		Path:           "/auth/email/verify",
+		Body:           fmt.Sprintf(`{"token": "%s"}`, lastToken(sender)),
+	})
+	_, err := s.api.Login("alice@example.org", "123456")
+	c.Assert(err, IsNil)
+	t, _ = s.store.FindTeamByAlias(team.Alias)
+	c.Assert(t.Users, DeepEquals, []string{user.Email, "alice@example.org"})
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+	"github.com/gorilla/mux"
+)
+
+func (api *Api) planSave(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	service, err := findServiceAndCheckUser(mux.Vars(r)["subdomain"], user, account.PERMISSION_WRITE)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	plan := account.Plan{}
+	if err := json.NewDecoder(r.Body).Decode(&plan); err != nil {
+		handleError(rw, errors.ErrBadRequest)
+		return
+	}
+
+	if err := plan.Save(*service); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, plan)
+}
+
+func (api *Api) planList(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	service, err := findServiceAndCheckUser(mux.Vars(r)["subdomain"], user, account.PERMISSION_READ)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	plans, _ := account.FindPlansByService(*service)
+	Ok(rw, CollectionSerializer{Items: plans, Count: len(plans)})
+}
+
+func (api *Api) planDelete(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	service, err := findServiceAndCheckUser(mux.Vars(r)["subdomain"], user, account.PERMISSION_DELETE)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	plan, err := account.FindPlanByNameAndService(mux.Vars(r)["name"], *service)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	if err := plan.Delete(); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, plan)
+}
@@ -0,0 +1,96 @@
+package api_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/requests"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestSavePlan(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "PUT",
+		Path:           fmt.Sprintf("/api/services/%s/plans", service.Subdomain),
+		Body:           `{"name": "gold", "quota_per_day": 1000, "rate_limit": 60, "methods": ["get"], "paths": ["/v1"]}`,
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(string(body), Equals, `{"name":"gold","service":"apihub","quota_per_day":1000,"rate_limit":60,"methods":["GET"],"paths":["/v1"]}`)
+}
+
+func (s *S) TestSavePlanWithInvalidFields(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusBadRequest,
+		Method:         "PUT",
+		Path:           fmt.Sprintf("/api/services/%s/plans", service.Subdomain),
+		Body:           `{"name": "gold", "quota_per_day": -1}`,
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(string(body), Equals, `{"error":"bad_request","error_description":"Plan must have positive quotas, valid HTTP Methods and Paths starting with a slash."}`)
+}
+
+func (s *S) TestSavePlanWithoutSignIn(c *C) {
+	testWithoutSignIn(requests.Args{AcceptableCode: http.StatusUnauthorized, Method: "PUT", Path: "/api/services/apihub/plans", Body: `{}`}, c)
+}
+
+func (s *S) TestListPlans(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	plan := account.Plan{Name: "gold", QuotaPerMonth: 10000}
+	plan.Save(service)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "GET",
+		Path:           fmt.Sprintf("/api/services/%s/plans", service.Subdomain),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(string(body), Equals, `{"items":[{"name":"gold","service":"apihub","quota_per_month":10000}],"item_count":1}`)
+}
+
+func (s *S) TestDeletePlan(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	plan := account.Plan{Name: "gold"}
+	plan.Save(service)
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "DELETE",
+		Path:           fmt.Sprintf("/api/services/%s/plans/gold", service.Subdomain),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	_, err := account.FindPlanByNameAndService("gold", service)
+	c.Assert(err, NotNil)
+}
+
+func (s *S) TestDeletePlanNotFound(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNotFound,
+		Method:         "DELETE",
+		Path:           fmt.Sprintf("/api/services/%s/plans/not-found", service.Subdomain),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusNotFound)
+	c.Assert(string(body), Equals, `{"error":"not_found","error_description":"Plan not found."}`)
+}
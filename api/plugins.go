@@ -16,7 +16,7 @@ func (api *Api) pluginSubsribe(rw http.ResponseWriter, r *http.Request, user *ac
 		return
 	}
 
-	_, err = findTeamAndCheckUser(service.Team, user)
+	_, err = findTeamAndCheckUser(service.Team, user, account.PERMISSION_WRITE)
 	if err != nil {
 		handleError(rw, err)
 		return
@@ -44,7 +44,7 @@ func (api *Api) pluginUnsubsribe(rw http.ResponseWriter, r *http.Request, user *
 		return
 	}
 
-	_, err = findTeamAndCheckUser(service.Team, user)
+	_, err = findTeamAndCheckUser(service.Team, user, account.PERMISSION_WRITE)
 	if err != nil {
 		handleError(rw, err)
 		return
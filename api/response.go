@@ -34,6 +34,13 @@ func handleError(rw http.ResponseWriter, err error) {
 	case errors.UnauthorizedError:
 		erro := errors.ErrorResponse{Type: errors.E_UNAUTHORIZED_REQUEST, Description: err.Error()}
 		Unauthorized(rw, erro)
+	case errors.OAuthError:
+		erro := errors.ErrorResponse{Type: err.(errors.OAuthError).Type, Description: err.Error()}
+		if erro.Type == errors.E_INVALID_CLIENT {
+			Unauthorized(rw, erro)
+			return
+		}
+		BadRequest(rw, erro)
 	default:
 		erro := errors.ErrorResponse{Type: errors.E_BAD_REQUEST, Description: err.Error()}
 		BadRequest(rw, erro)
@@ -19,12 +19,17 @@ func (api *Api) serviceCreate(rw http.ResponseWriter, r *http.Request, user *acc
 		return
 	}
 
-	team, err := findTeamAndCheckUser(service.Team, user)
+	team, err := findTeamAndCheckUser(service.Team, user, account.PERMISSION_WRITE)
 	if err != nil {
 		handleError(rw, err)
 		return
 	}
 
+	if err := api.checkTransformers(service); err != nil {
+		handleError(rw, err)
+		return
+	}
+
 	if err := service.Create(*user, *team); err != nil {
 		handleError(rw, err)
 		return
@@ -41,18 +46,25 @@ func (api *Api) serviceUpdate(rw http.ResponseWriter, r *http.Request, user *acc
 		return
 	}
 
-	_, err = findTeamAndCheckUser(service.Team, user)
+	_, err = findTeamAndCheckUser(service.Team, user, account.PERMISSION_WRITE)
 	if err != nil {
 		handleError(rw, err)
 		return
 	}
 
+	team := service.Team
 	if err := json.NewDecoder(r.Body).Decode(&service); err != nil {
 		handleError(rw, errors.ErrBadRequest)
 		return
 	}
-	// It is not allowed to change the subdomain yet.
+	// It is not allowed to change the subdomain yet, nor the team, which the permissions were checked on.
 	service.Subdomain = mux.Vars(r)["subdomain"]
+	service.Team = team
+
+	if err := api.checkTransformers(*service); err != nil {
+		handleError(rw, err)
+		return
+	}
 
 	err = service.Update()
 	if err != nil {
@@ -87,7 +99,7 @@ func (api *Api) serviceInfo(rw http.ResponseWriter, r *http.Request, user *accou
 		return
 	}
 
-	_, err = findTeamAndCheckUser(service.Team, user)
+	_, err = findTeamAndCheckUser(service.Team, user, account.PERMISSION_READ)
 	if err != nil {
 		handleError(rw, err)
 		return
@@ -96,11 +108,66 @@ func (api *Api) serviceInfo(rw http.ResponseWriter, r *http.Request, user *accou
 	Ok(rw, service)
 }
 
+func (api *Api) serviceUpstreams(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	service, err := account.FindServiceBySubdomain(mux.Vars(r)["subdomain"])
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	_, err = findTeamAndCheckUser(service.Team, user, account.PERMISSION_READ)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	states, err := service.UpstreamStates()
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, CollectionSerializer{Items: states, Count: len(states)})
+}
+
+func (api *Api) serviceCircuitBreaker(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	service, err := account.FindServiceBySubdomain(mux.Vars(r)["subdomain"])
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	_, err = findTeamAndCheckUser(service.Team, user, account.PERMISSION_READ)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	state, err := service.CircuitBreakerState()
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, state)
+}
+
 func (api *Api) serviceList(rw http.ResponseWriter, r *http.Request, user *account.User) {
 	services, _ := user.Services()
 	Ok(rw, CollectionSerializer{Items: services, Count: len(services)})
 }
 
+// checkTransformers rejects services using transformers that were not registered.
+func (api *Api) checkTransformers(service account.Service) error {
+	for _, name := range service.Transformers {
+		if !api.transformers[name] {
+			Logger.Warn("Service `%s` is trying to use an unknown transformer: %s.", service.Subdomain, name)
+			return errors.NewValidationError(errors.ErrServiceTransformerNotFound)
+		}
+	}
+	return nil
+}
+
 type serviceEvent struct {
 	CreatedAt time.Time       `json:"created_at"`
 	Title     string          `json:"name"`
@@ -111,6 +178,10 @@ func (e *serviceEvent) Name() string {
 	return e.Title
 }
 
+func (e *serviceEvent) Team() string {
+	return e.Service.Team
+}
+
 func (e *serviceEvent) Data() []byte {
 	j, err := json.Marshal(e)
 	if err != nil {
@@ -126,3 +197,39 @@ func newServiceEvent(name string, service account.Service) *serviceEvent {
 		Service:   service,
 	}
 }
+
+type upstreamEvent struct {
+	CreatedAt time.Time             `json:"created_at"`
+	Title     string                `json:"name"`
+	Upstream  account.UpstreamState `json:"upstream"`
+	team      string
+}
+
+func (e *upstreamEvent) Name() string {
+	return e.Title
+}
+
+func (e *upstreamEvent) Team() string {
+	return e.team
+}
+
+func (e *upstreamEvent) Data() []byte {
+	j, err := json.Marshal(e)
+	if err != nil {
+		Logger.Error(fmt.Sprintf("Failed to create an upstream event: %+v.", err))
+	}
+	return j
+}
+
+func newUpstreamEvent(state account.UpstreamState, service account.Service) *upstreamEvent {
+	name := "service.upstream.down"
+	if state.Healthy {
+		name = "service.upstream.up"
+	}
+	return &upstreamEvent{
+		CreatedAt: time.Now().UTC(),
+		Title:     name,
+		Upstream:  state,
+		team:      service.Team,
+	}
+}
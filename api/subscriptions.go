@@ -0,0 +1,149 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+	"github.com/gorilla/mux"
+)
+
+func (api *Api) subscriptionCreate(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	app, err := findAppAndCheckUser(mux.Vars(r)["client_id"], user, account.PERMISSION_WRITE)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	subscription := account.Subscription{}
+	if err := json.NewDecoder(r.Body).Decode(&subscription); err != nil {
+		handleError(rw, errors.ErrBadRequest)
+		return
+	}
+
+	if err := subscription.Create(*user, *app); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Created(rw, subscription)
+}
+
+func (api *Api) subscriptionList(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	app, err := findAppAndCheckUser(mux.Vars(r)["client_id"], user, account.PERMISSION_READ)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	subscriptions, _ := account.AppSubscriptions(*app)
+	Ok(rw, CollectionSerializer{Items: subscriptions, Count: len(subscriptions)})
+}
+
+func (api *Api) subscriptionDelete(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	app, err := findAppAndCheckUser(mux.Vars(r)["client_id"], user, account.PERMISSION_WRITE)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	subscription, err := account.FindSubscription(app.ClientId, mux.Vars(r)["subdomain"])
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	if err := subscription.Delete(); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, subscription)
+}
+
+func (api *Api) serviceSubscriptionList(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	service, err := findServiceAndCheckUser(mux.Vars(r)["subdomain"], user, account.PERMISSION_READ)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	subscriptions, _ := account.ServiceSubscriptions(*service)
+	Ok(rw, CollectionSerializer{Items: subscriptions, Count: len(subscriptions)})
+}
+
+func (api *Api) serviceSubscriptionApprove(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	subscription, err := findSubscriptionAndCheckUser(mux.Vars(r)["subdomain"], mux.Vars(r)["client_id"], user, account.PERMISSION_WRITE)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	if err := subscription.Approve(*user); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, subscription)
+}
+
+func (api *Api) serviceSubscriptionRevoke(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	subscription, err := findSubscriptionAndCheckUser(mux.Vars(r)["subdomain"], mux.Vars(r)["client_id"], user, account.PERMISSION_WRITE)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	if err := subscription.Revoke(*user); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, subscription)
+}
+
+func (api *Api) serviceSubscriptionChangePlan(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	subscription, err := findSubscriptionAndCheckUser(mux.Vars(r)["subdomain"], mux.Vars(r)["client_id"], user, account.PERMISSION_WRITE)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	var body struct {
+		Plan string `json:"plan"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		handleError(rw, errors.ErrBadRequest)
+		return
+	}
+
+	if err := subscription.ChangePlan(*user, body.Plan); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, subscription)
+}
+
+func findServiceAndCheckUser(subdomain string, user *account.User, permission account.Permission) (*account.Service, error) {
+	service, err := account.FindServiceBySubdomain(subdomain)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := findTeamAndCheckUser(service.Team, user, permission); err != nil {
+		return nil, err
+	}
+
+	return service, nil
+}
+
+// findSubscriptionAndCheckUser finds the subscription to be reviewed, which is only allowed to the team of the service.
+func findSubscriptionAndCheckUser(subdomain, clientId string, user *account.User, permission account.Permission) (*account.Subscription, error) {
+	service, err := findServiceAndCheckUser(subdomain, user, permission)
+	if err != nil {
+		return nil, err
+	}
+
+	return account.FindSubscription(clientId, service.Subdomain)
+}
@@ -0,0 +1,237 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/requests"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) createSubscribedService() {
+	s.createOAuthApp()
+	service.Create(user, team)
+}
+
+func (s *S) deleteSubscribedService() {
+	s.store.DeleteSubscriptionsByService(service)
+	s.store.DeletePlansByService(service)
+	s.store.DeleteService(service)
+	s.deleteOAuthApp()
+}
+
+func (s *S) TestCreateSubscription(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	plan := account.Plan{Name: "gold"}
+	plan.Save(service)
+	defer plan.Delete()
+
+	headers, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusCreated,
+		Method:         "POST",
+		Path:           fmt.Sprintf("/api/apps/%s/subscriptions", app.ClientId),
+		Body:           fmt.Sprintf(`{"service": "%s", "plan": "gold"}`, service.Subdomain),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusCreated)
+	c.Assert(headers.Get("Content-Type"), Equals, "application/json")
+	subscription := account.Subscription{}
+	json.Unmarshal(body, &subscription)
+	c.Assert(subscription.ClientId, Equals, app.ClientId)
+	c.Assert(subscription.Service, Equals, service.Subdomain)
+	c.Assert(subscription.Plan, Equals, "gold")
+	c.Assert(subscription.State, Equals, account.SUBSCRIPTION_PENDING)
+	c.Assert(subscription.RequestedBy, Equals, user.Email)
+}
+
+func (s *S) TestCreateSubscriptionServiceNotFound(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNotFound,
+		Method:         "POST",
+		Path:           fmt.Sprintf("/api/apps/%s/subscriptions", app.ClientId),
+		Body:           `{"service": "not-found"}`,
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusNotFound)
+	c.Assert(string(body), Equals, `{"error":"not_found","error_description":"Service not found."}`)
+}
+
+func (s *S) TestCreateSubscriptionWithoutSignIn(c *C) {
+	testWithoutSignIn(requests.Args{AcceptableCode: http.StatusUnauthorized, Method: "POST", Path: "/api/apps/ios/subscriptions", Body: `{}`}, c)
+}
+
+func (s *S) TestListAppSubscriptions(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	subscription := account.Subscription{Service: service.Subdomain}
+	subscription.Create(user, app)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "GET",
+		Path:           fmt.Sprintf("/api/apps/%s/subscriptions", app.ClientId),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(string(body), Equals, fmt.Sprintf(`{"items":[{"client_id":"ios","service":"apihub","state":"pending","requested_by":"%s","created_at":"%s"}],"item_count":1}`, user.Email, subscription.CreatedAt))
+}
+
+func (s *S) TestDeleteSubscription(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	subscription := account.Subscription{Service: service.Subdomain}
+	subscription.Create(user, app)
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "DELETE",
+		Path:           fmt.Sprintf("/api/apps/%s/subscriptions/%s", app.ClientId, service.Subdomain),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	_, err := account.FindSubscription(app.ClientId, service.Subdomain)
+	c.Assert(err, NotNil)
+}
+
+func (s *S) TestListServiceSubscriptions(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	subscription := account.Subscription{Service: service.Subdomain}
+	subscription.Create(user, app)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "GET",
+		Path:           fmt.Sprintf("/api/services/%s/subscriptions", service.Subdomain),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(string(body), Equals, fmt.Sprintf(`{"items":[{"client_id":"ios","service":"apihub","state":"pending","requested_by":"%s","created_at":"%s"}],"item_count":1}`, user.Email, subscription.CreatedAt))
+}
+
+func (s *S) TestApproveSubscription(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	subscription := account.Subscription{Service: service.Subdomain}
+	subscription.Create(user, app)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "POST",
+		Path:           fmt.Sprintf("/api/services/%s/subscriptions/%s/approve", service.Subdomain, app.ClientId),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	approved := account.Subscription{}
+	json.Unmarshal(body, &approved)
+	c.Assert(approved.State, Equals, account.SUBSCRIPTION_APPROVED)
+	c.Assert(approved.ReviewedBy, Equals, user.Email)
+}
+
+func (s *S) TestRevokeSubscription(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	subscription := account.Subscription{Service: service.Subdomain}
+	subscription.Create(user, app)
+	subscription.Approve(user)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "POST",
+		Path:           fmt.Sprintf("/api/services/%s/subscriptions/%s/revoke", service.Subdomain, app.ClientId),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	revoked := account.Subscription{}
+	json.Unmarshal(body, &revoked)
+	c.Assert(revoked.State, Equals, account.SUBSCRIPTION_REJECTED)
+}
+
+func (s *S) TestApproveSubscriptionOfAnotherTeam(c *C) {
+	s.createOAuthApp()
+	defer s.deleteOAuthApp()
+	another := account.Service{Endpoint: "http://example.org/api", Subdomain: "another", Team: "another-team"}
+	s.store.UpsertService(another)
+	defer s.store.DeleteService(another)
+	s.store.UpsertSubscription(account.Subscription{ClientId: app.ClientId, Service: another.Subdomain, State: account.SUBSCRIPTION_PENDING})
+	defer s.store.DeleteSubscriptionsByService(another)
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNotFound,
+		Method:         "POST",
+		Path:           fmt.Sprintf("/api/services/%s/subscriptions/%s/approve", another.Subdomain, app.ClientId),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusNotFound)
+	subscription, _ := account.FindSubscription(app.ClientId, another.Subdomain)
+	c.Assert(subscription.Approved(), Equals, false)
+}
+
+func (s *S) TestApproveSubscriptionNotFound(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNotFound,
+		Method:         "POST",
+		Path:           fmt.Sprintf("/api/services/%s/subscriptions/not-found/approve", service.Subdomain),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusNotFound)
+	c.Assert(string(body), Equals, `{"error":"not_found","error_description":"Subscription not found."}`)
+}
+
+func (s *S) TestChangeSubscriptionPlan(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	plan := account.Plan{Name: "gold"}
+	plan.Save(service)
+	subscription := account.Subscription{Service: service.Subdomain}
+	subscription.Create(user, app)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "PUT",
+		Path:           fmt.Sprintf("/api/services/%s/subscriptions/%s/plan", service.Subdomain, app.ClientId),
+		Body:           `{"plan": "gold"}`,
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	changed := account.Subscription{}
+	json.Unmarshal(body, &changed)
+	c.Assert(changed.Plan, Equals, "gold")
+}
+
+func (s *S) TestChangeSubscriptionPlanNotFound(c *C) {
+	s.createSubscribedService()
+	defer s.deleteSubscribedService()
+	subscription := account.Subscription{Service: service.Subdomain}
+	subscription.Create(user, app)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNotFound,
+		Method:         "PUT",
+		Path:           fmt.Sprintf("/api/services/%s/subscriptions/%s/plan", service.Subdomain, app.ClientId),
+		Body:           `{"plan": "not-found"}`,
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusNotFound)
+	c.Assert(string(body), Equals, `{"error":"not_found","error_description":"Plan not found."}`)
+}
@@ -45,6 +45,7 @@ func (s *S) SetUpTest(c *C) {
 	setUpMemoryTest(s)
 
 	s.api = api.NewApi(s.store, s.pubsub)
+	s.api.ClientCertificateSecret("secret")
 	s.server = httptest.NewServer(s.api.Handler())
 	httpClient = requests.NewHTTPClient(s.server.URL)
 
@@ -25,16 +25,19 @@ func (api *Api) teamCreate(rw http.ResponseWriter, r *http.Request, user *accoun
 }
 
 func (api *Api) teamUpdate(rw http.ResponseWriter, r *http.Request, user *account.User) {
-	team, err := findTeamAndCheckUser(mux.Vars(r)["alias"], user)
+	team, err := findTeamAndCheckUser(mux.Vars(r)["alias"], user, account.PERMISSION_MANAGE_MEMBERS)
 	if err != nil {
 		handleError(rw, err)
 		return
 	}
 
+	owner, users, roles := team.Owner, team.Users, team.Roles
 	if err := json.NewDecoder(r.Body).Decode(&team); err != nil {
 		handleError(rw, errors.ErrBadRequest)
 		return
 	}
+	// The members and their roles are changed through their own endpoints.
+	team.Owner, team.Users, team.Roles = owner, users, roles
 
 	if err := team.Update(); err != nil {
 		handleError(rw, err)
@@ -64,7 +67,7 @@ func (api *Api) teamDelete(rw http.ResponseWriter, r *http.Request, user *accoun
 }
 
 func (api *Api) teamInfo(rw http.ResponseWriter, r *http.Request, user *account.User) {
-	team, err := findTeamAndCheckUser(mux.Vars(r)["alias"], user)
+	team, err := findTeamAndCheckUser(mux.Vars(r)["alias"], user, account.PERMISSION_READ)
 	if err != nil {
 		handleError(rw, err)
 		return
@@ -74,7 +77,7 @@ func (api *Api) teamInfo(rw http.ResponseWriter, r *http.Request, user *account.
 }
 
 func (api *Api) teamAddUsers(rw http.ResponseWriter, r *http.Request, user *account.User) {
-	team, err := findTeamAndCheckUser(mux.Vars(r)["alias"], user)
+	team, err := findTeamAndCheckUser(mux.Vars(r)["alias"], user, account.PERMISSION_MANAGE_MEMBERS)
 	if err != nil {
 		handleError(rw, err)
 		return
@@ -96,17 +99,23 @@ func (api *Api) teamAddUsers(rw http.ResponseWriter, r *http.Request, user *acco
 }
 
 func (api *Api) teamRemoveUsers(rw http.ResponseWriter, r *http.Request, user *account.User) {
-	team, err := findTeamAndCheckUser(mux.Vars(r)["alias"], user)
-	if err != nil {
-		handleError(rw, err)
-		return
-	}
-
 	var t *account.Team
 	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
 		handleError(rw, errors.ErrBadRequest)
 		return
 	}
+
+	// Any member is allowed to leave the team.
+	permission := account.PERMISSION_MANAGE_MEMBERS
+	if len(t.Users) == 1 && t.Users[0] == user.Email {
+		permission = account.PERMISSION_READ
+	}
+	team, err := findTeamAndCheckUser(mux.Vars(r)["alias"], user, permission)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
 	err = team.RemoveUsers(t.Users)
 	if err != nil {
 		handleError(rw, err)
@@ -116,14 +125,56 @@ func (api *Api) teamRemoveUsers(rw http.ResponseWriter, r *http.Request, user *a
 	Ok(rw, team)
 }
 
-func findTeamAndCheckUser(alias string, user *account.User) (*account.Team, error) {
+func findTeamAndCheckUser(alias string, user *account.User, permission account.Permission) (*account.Team, error) {
 	team, err := account.FindTeamByAlias(alias)
 	if err != nil {
 		return nil, err
 	}
-	if _, err := team.ContainsUser(user); err != nil {
+	if err := team.Authorize(*user, permission); err != nil {
 		return nil, err
 	}
 
 	return team, nil
 }
+
+func (api *Api) teamChangeRole(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	team, err := findTeamAndCheckUser(mux.Vars(r)["alias"], user, account.PERMISSION_MANAGE_MEMBERS)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	var role account.MemberRole
+	if err := json.NewDecoder(r.Body).Decode(&role); err != nil {
+		handleError(rw, errors.ErrBadRequest)
+		return
+	}
+
+	if err := team.ChangeRole(mux.Vars(r)["email"], role.Role, *user); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, team)
+}
+
+func (api *Api) teamTransferOwnership(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	team, err := findTeamAndCheckUser(mux.Vars(r)["alias"], user, account.PERMISSION_TRANSFER_OWNERSHIP)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	var owner account.MemberRole
+	if err := json.NewDecoder(r.Body).Decode(&owner); err != nil {
+		handleError(rw, errors.ErrBadRequest)
+		return
+	}
+
+	if err := team.TransferOwnership(owner.Email, *user); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, team)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
 	"github.com/apihub/apihub/requests"
 	. "gopkg.in/check.v1"
 )
@@ -427,3 +428,150 @@ func (s *S) TestUpdateTeamNotFound(c *C) {
 	c.Assert(headers.Get("Content-Type"), Equals, "application/json")
 	c.Assert(string(body), Equals, `{"error":"not_found","error_description":"Team not found."}`)
 }
+
+func (s *S) TestChangeRole(c *C) {
+	alice := account.User{Name: "alice", Email: "alice@bar.example.org", Password: "secret"}
+	alice.Create()
+	defer alice.Delete()
+
+	team := account.Team{Name: "ApiHub Team", Alias: "apihub", Users: []string{alice.Email}}
+	team.Create(user)
+	defer func() {
+		s.store.DeleteTeamByAlias(team.Alias)
+	}()
+
+	headers, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "PUT",
+		Path:           fmt.Sprintf("/api/teams/%s/users/%s/role", team.Alias, alice.Email),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+		Body:           `{"role": "viewer"}`,
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(headers.Get("Content-Type"), Equals, "application/json")
+	c.Assert(string(body), Equals, `{"name":"ApiHub Team","alias":"apihub","users":["alice@bar.example.org","bob@bar.example.org"],"owner":"bob@bar.example.org","roles":[{"email":"alice@bar.example.org","role":"viewer"}]}`)
+}
+
+func (s *S) TestChangeRoleWithInvalidRole(c *C) {
+	alice := account.User{Name: "alice", Email: "alice@bar.example.org", Password: "secret"}
+	alice.Create()
+	defer alice.Delete()
+
+	team := account.Team{Name: "ApiHub Team", Alias: "apihub", Users: []string{alice.Email}}
+	team.Create(user)
+	defer func() {
+		s.store.DeleteTeamByAlias(team.Alias)
+	}()
+
+	headers, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusBadRequest,
+		Method:         "PUT",
+		Path:           fmt.Sprintf("/api/teams/%s/users/%s/role", team.Alias, alice.Email),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+		Body:           `{"role": "owner"}`,
+	})
+
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(headers.Get("Content-Type"), Equals, "application/json")
+	c.Assert(string(body), Equals, fmt.Sprintf(`{"error":"bad_request","error_description":"%s"}`, errors.ErrTeamInvalidRole.Error()))
+}
+
+func (s *S) TestChangeRoleWithoutPermission(c *C) {
+	alice := account.User{Name: "alice", Email: "alice@bar.example.org", Password: "secret"}
+	alice.Create()
+	defer alice.Delete()
+
+	team := account.Team{Name: "ApiHub Team", Alias: "apihub", Users: []string{user.Email}}
+	team.Create(alice)
+	defer func() {
+		s.store.DeleteTeamByAlias(team.Alias)
+	}()
+
+	headers, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusForbidden,
+		Method:         "PUT",
+		Path:           fmt.Sprintf("/api/teams/%s/users/%s/role", team.Alias, user.Email),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+		Body:           `{"role": "admin"}`,
+	})
+
+	c.Assert(code, Equals, http.StatusForbidden)
+	c.Assert(headers.Get("Content-Type"), Equals, "application/json")
+	c.Assert(string(body), Equals, fmt.Sprintf(`{"error":"access_denied","error_description":"%s"}`, errors.ErrRoleNotAllowed.Error()))
+}
+
+func (s *S) TestChangeRoleWithoutSignIn(c *C) {
+	testWithoutSignIn(requests.Args{AcceptableCode: http.StatusUnauthorized, Method: "PUT", Path: "/api/teams/apihub/users/alice@bar.example.org/role", Body: `{"role": "admin"}`}, c)
+}
+
+func (s *S) TestRemoveUserLeavingTeam(c *C) {
+	alice := account.User{Name: "alice", Email: "alice@bar.example.org", Password: "secret"}
+	alice.Create()
+	defer alice.Delete()
+
+	team := account.Team{Name: "ApiHub Team", Alias: "apihub", Users: []string{user.Email}}
+	team.Create(alice)
+	defer func() {
+		s.store.DeleteTeamByAlias(team.Alias)
+	}()
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "DELETE",
+		Path:           fmt.Sprintf("/api/teams/%s/users", team.Alias),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+		Body:           fmt.Sprintf(`{"users": ["%s"]}`, user.Email),
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(string(body), Equals, `{"name":"ApiHub Team","alias":"apihub","users":["alice@bar.example.org"],"owner":"alice@bar.example.org"}`)
+}
+
+func (s *S) TestTransferOwnership(c *C) {
+	alice := account.User{Name: "alice", Email: "alice@bar.example.org", Password: "secret"}
+	alice.Create()
+	defer alice.Delete()
+
+	team := account.Team{Name: "ApiHub Team", Alias: "apihub", Users: []string{alice.Email}}
+	team.Create(user)
+	defer func() {
+		s.store.DeleteTeamByAlias(team.Alias)
+	}()
+
+	headers, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "PUT",
+		Path:           fmt.Sprintf("/api/teams/%s/owner", team.Alias),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+		Body:           fmt.Sprintf(`{"email": "%s"}`, alice.Email),
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(headers.Get("Content-Type"), Equals, "application/json")
+	c.Assert(string(body), Equals, `{"name":"ApiHub Team","alias":"apihub","users":["alice@bar.example.org","bob@bar.example.org"],"owner":"alice@bar.example.org","roles":[{"email":"bob@bar.example.org","role":"admin"}]}`)
+}
+
+func (s *S) TestTransferOwnershipNotOwner(c *C) {
+	alice := account.User{Name: "alice", Email: "alice@bar.example.org", Password: "secret"}
+	alice.Create()
+	defer alice.Delete()
+
+	team := account.Team{Name: "ApiHub Team", Alias: "apihub", Users: []string{user.Email}, Roles: []account.MemberRole{{Email: user.Email, Role: account.ROLE_ADMIN}}}
+	team.Create(alice)
+	defer func() {
+		s.store.DeleteTeamByAlias(team.Alias)
+	}()
+
+	headers, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusForbidden,
+		Method:         "PUT",
+		Path:           fmt.Sprintf("/api/teams/%s/owner", team.Alias),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+		Body:           fmt.Sprintf(`{"email": "%s"}`, user.Email),
+	})
+
+	c.Assert(code, Equals, http.StatusForbidden)
+	c.Assert(headers.Get("Content-Type"), Equals, "application/json")
+	c.Assert(string(body), Equals, `{"error":"access_denied","error_description":"Only the owner has permission to perform this operation."}`)
+}
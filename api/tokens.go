@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+	"github.com/gorilla/mux"
+)
+
+// Resources which the personal access tokens may access, by the first segment of the path, and their scopes.
+var scopeResources = map[string]string{
+	"teams":       "teams",
+	"invitations": "teams",
+	"services":    "services",
+	"apps":        "apps",
+	"hooks":       "hooks",
+}
+
+func (api *Api) userTokenCreate(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	token := account.PersonalAccessToken{}
+	if err := json.NewDecoder(r.Body).Decode(&token); err != nil {
+		handleError(rw, errors.ErrBadRequest)
+		return
+	}
+
+	if err := token.Create(*user); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Created(rw, token)
+}
+
+// userTokenList lists the active sessions of the user, followed by the personal access tokens.
+func (api *Api) userTokenList(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	sessions, err := api.auth.UserTokens(user)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+	personalTokens, err := account.FindPersonalAccessTokensByUser(*user)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	tokens := []interface{}{}
+	for _, session := range sessions {
+		// The tokens themselves are only known by the client of each session.
+		session.AccessToken = ""
+		session.RefreshToken = ""
+		tokens = append(tokens, session)
+	}
+	for _, personalToken := range personalTokens {
+		tokens = append(tokens, personalToken)
+	}
+
+	Ok(rw, CollectionSerializer{Items: tokens, Count: len(tokens)})
+}
+
+// userTokenRevoke revokes either a session or a personal access token of the user.
+func (api *Api) userTokenRevoke(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	id := mux.Vars(r)["id"]
+	err := api.auth.RevokeUserTokenById(user, id)
+	if _, ok := err.(errors.NotFoundError); ok {
+		if token, findErr := account.FindPersonalAccessTokenByIdAndUser(id, *user); findErr == nil {
+			err = token.Delete()
+		}
+	}
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	NoContent(rw)
+}
+
+// userTokensRevoke revokes all the sessions of the user. The personal access tokens are kept.
+func (api *Api) userTokensRevoke(rw http.ResponseWriter, r *http.Request, user *account.User) {
+	if err := api.auth.RevokeUserTokens(user); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	NoContent(rw)
+}
+
+// userFromPersonalAccessToken returns the owner of the token, as long as the token has the scope
+// required by the request.
+func (api *Api) userFromPersonalAccessToken(r *http.Request, value string) (*account.User, error) {
+	token, err := account.AuthenticatePersonalAccessToken(value)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Allows(requiredScope(r)) {
+		return nil, errors.NewForbiddenError(errors.ErrPersonalAccessTokenNotAllowed)
+	}
+
+	user, err := api.store.FindUserByEmail(token.User)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// requiredScope returns the scope which allows the request, such as `services:write`, or an empty string
+// when the resource is not available to the personal access tokens.
+func requiredScope(r *http.Request) string {
+	segments := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/api/"), "/", 2)
+	resource, ok := scopeResources[segments[0]]
+	if !ok {
+		return ""
+	}
+
+	if r.Method == "GET" || r.Method == "HEAD" {
+		return resource + ":read"
+	}
+	return resource + ":write"
+}
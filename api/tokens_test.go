@@ -0,0 +1,317 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/requests"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestRefreshToken(c *C) {
+	token, err := s.api.Login(user.Email, "secret")
+	c.Assert(err, IsNil)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "POST",
+		Path:           "/auth/refresh",
+		Body:           fmt.Sprintf(`{"refresh_token": "%s"}`, token.RefreshToken),
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	refreshed := account.Token{}
+	json.Unmarshal(body, &refreshed)
+	c.Assert(refreshed.Id, Equals, token.Id)
+	c.Assert(refreshed.AccessToken, Not(Equals), token.AccessToken)
+	c.Assert(refreshed.RefreshToken, Not(Equals), token.RefreshToken)
+
+	testWithoutSignIn(requests.Args{AcceptableCode: http.StatusUnauthorized, Method: "GET", Path: "/api/users/tokens", Headers: http.Header{"Authorization": {fmt.Sprintf("%s %s", token.Type, token.AccessToken)}}}, c)
+	_, code, _, _ = httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "GET",
+		Path:           "/api/users/tokens",
+		Headers:        http.Header{"Authorization": {fmt.Sprintf("%s %s", refreshed.Type, refreshed.AccessToken)}},
+	})
+	c.Assert(code, Equals, http.StatusOK)
+}
+
+func (s *S) TestRefreshTokenWithInvalidToken(c *C) {
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusUnauthorized,
+		Method:         "POST",
+		Path:           "/auth/refresh",
+		Body:           `{"refresh_token": "invalid-token"}`,
+	})
+
+	c.Assert(code, Equals, http.StatusUnauthorized)
+	c.Assert(string(body), Equals, `{"error":"unauthorized_access","error_description":"The refresh token is invalid or has expired."}`)
+}
+
+func (s *S) TestRefreshTokenWithExpiredSession(c *C) {
+	token, _ := s.api.Login(user.Email, "secret")
+	t, _ := s.store.FindTokenByRefreshToken(token.RefreshToken)
+	t.RefreshExpiresAt = time.Now().Add(-time.Minute).In(time.UTC).Format(time.RFC3339)
+	s.store.UpsertToken(t)
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusUnauthorized,
+		Method:         "POST",
+		Path:           "/auth/refresh",
+		Body:           fmt.Sprintf(`{"refresh_token": "%s"}`, token.RefreshToken),
+	})
+
+	c.Assert(code, Equals, http.StatusUnauthorized)
+	_, err := s.store.FindTokenByRefreshToken(token.RefreshToken)
+	c.Assert(err, NotNil)
+}
+
+func (s *S) TestExpiredTokenIsRefused(c *C) {
+	token, _ := s.api.Login(user.Email, "secret")
+	t, _ := s.store.FindTokenByAccessToken(token.AccessToken)
+	t.ExpiresAt = time.Now().Add(-time.Minute).In(time.UTC).Format(time.RFC3339)
+	s.store.UpsertToken(t)
+
+	testWithoutSignIn(requests.Args{AcceptableCode: http.StatusUnauthorized, Method: "GET", Path: "/api/users/tokens", Headers: http.Header{"Authorization": {fmt.Sprintf("%s %s", token.Type, token.AccessToken)}}}, c)
+}
+
+func (s *S) TestUserTokenList(c *C) {
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "POST",
+		Path:           "/auth/login",
+		Body:           fmt.Sprintf(`{"email": "%s", "password": "secret"}`, user.Email),
+	})
+	c.Assert(code, Equals, http.StatusOK)
+	token := account.Token{}
+	json.Unmarshal(body, &token)
+
+	_, code, body, _ = httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "GET",
+		Path:           "/api/users/tokens",
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	tokens := struct {
+		Items []account.Token `json:"items"`
+		Count int             `json:"item_count"`
+	}{}
+	json.Unmarshal(body, &tokens)
+	c.Assert(tokens.Count, Equals, 2)
+	for _, t := range tokens.Items {
+		c.Assert(t.AccessToken, Equals, "")
+		c.Assert(t.RefreshToken, Equals, "")
+		c.Assert(t.CreatedAt, Not(Equals), "")
+		c.Assert(t.LastUsedAt, Not(Equals), "")
+		if t.Id == token.Id {
+			c.Assert(t.UserAgent, Equals, "Go-http-client/1.1")
+		}
+	}
+}
+
+func (s *S) TestUserTokenListWithoutSignIn(c *C) {
+	testWithoutSignIn(requests.Args{AcceptableCode: http.StatusUnauthorized, Method: "GET", Path: "/api/users/tokens"}, c)
+}
+
+func (s *S) TestUserTokenRevoke(c *C) {
+	token, _ := s.api.Login(user.Email, "secret")
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNoContent,
+		Method:         "DELETE",
+		Path:           fmt.Sprintf("/api/users/tokens/%s", token.Id),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusNoContent)
+	testWithoutSignIn(requests.Args{AcceptableCode: http.StatusUnauthorized, Method: "GET", Path: "/api/users/tokens", Headers: http.Header{"Authorization": {fmt.Sprintf("%s %s", token.Type, token.AccessToken)}}}, c)
+}
+
+func (s *S) TestUserTokenRevokeOfAnotherUser(c *C) {
+	alice := account.User{Name: "alice", Email: "alice@bar.example.org", Password: "secret"}
+	alice.Create()
+	defer alice.Delete()
+	token, _ := s.api.Login(alice.Email, "secret")
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNotFound,
+		Method:         "DELETE",
+		Path:           fmt.Sprintf("/api/users/tokens/%s", token.Id),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusNotFound)
+	c.Assert(string(body), Equals, `{"error":"not_found","error_description":"Token not found."}`)
+}
+
+func (s *S) TestUserTokensRevoke(c *C) {
+	token, _ := s.api.Login(user.Email, "secret")
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNoContent,
+		Method:         "DELETE",
+		Path:           "/api/users/tokens",
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusNoContent)
+	testWithoutSignIn(requests.Args{AcceptableCode: http.StatusUnauthorized, Method: "GET", Path: "/api/users/tokens", Headers: http.Header{"Authorization": {s.authHeader}}}, c)
+	testWithoutSignIn(requests.Args{AcceptableCode: http.StatusUnauthorized, Method: "GET", Path: "/api/users/tokens", Headers: http.Header{"Authorization": {fmt.Sprintf("%s %s", token.Type, token.AccessToken)}}}, c)
+}
+
+func (s *S) createPersonalAccessToken(c *C, scopes ...string) account.PersonalAccessToken {
+	token := account.PersonalAccessToken{Name: "deploy", Scopes: scopes}
+	c.Assert(token.Create(user), IsNil)
+	return token
+}
+
+func (s *S) TestPersonalAccessTokenCreate(c *C) {
+	headers, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusCreated,
+		Method:         "POST",
+		Path:           "/api/users/tokens",
+		Body:           `{"name": "deploy", "scopes": ["services:write", "teams:read"]}`,
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusCreated)
+	c.Assert(headers.Get("Content-Type"), Equals, "application/json")
+	token := account.PersonalAccessToken{}
+	json.Unmarshal(body, &token)
+	c.Assert(token.Name, Equals, "deploy")
+	c.Assert(token.Scopes, DeepEquals, []string{"services:write", "teams:read"})
+	c.Assert(token.Type, Equals, account.PERSONAL_ACCESS_TOKEN_TYPE)
+	c.Assert(strings.HasPrefix(token.Token, token.Id+"."), Equals, true)
+	c.Assert(strings.Contains(string(body), "hash"), Equals, false)
+
+	stored, err := s.store.FindPersonalAccessTokenById(token.Id)
+	c.Assert(err, IsNil)
+	c.Assert(stored.User, Equals, user.Email)
+}
+
+func (s *S) TestPersonalAccessTokenCreateWithInvalidFields(c *C) {
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusBadRequest,
+		Method:         "POST",
+		Path:           "/api/users/tokens",
+		Body:           `{"name": "deploy", "scopes": ["users:write"]}`,
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(string(body), Equals, `{"error":"bad_request","error_description":"Personal access token must have a Name, valid Scopes and an Expiration in the future, in RFC 3339 format."}`)
+}
+
+func (s *S) TestPersonalAccessTokenCreateWithoutSignIn(c *C) {
+	testWithoutSignIn(requests.Args{AcceptableCode: http.StatusUnauthorized, Method: "POST", Path: "/api/users/tokens", Body: `{"name": "deploy", "scopes": ["teams:read"]}`}, c)
+}
+
+func (s *S) TestUserTokenListWithPersonalAccessTokens(c *C) {
+	token := s.createPersonalAccessToken(c, account.SCOPE_TEAMS_READ)
+
+	_, code, body, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "GET",
+		Path:           "/api/users/tokens",
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(string(body), Matches, fmt.Sprintf(`{"items":\[{"id":".*","token_type":"Token"},{"id":"%s","name":"deploy","scopes":\["teams:read"\],"created_at":"%s","token_type":"PersonalToken"}\],"item_count":2}`, token.Id, token.CreatedAt))
+}
+
+func (s *S) TestPersonalAccessTokenAuthorization(c *C) {
+	token := s.createPersonalAccessToken(c, account.SCOPE_TEAMS_READ)
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "GET",
+		Path:           "/api/teams",
+		Headers:        http.Header{"Authorization": {fmt.Sprintf("%s %s", token.Type, token.Token)}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	stored, _ := s.store.FindPersonalAccessTokenById(token.Id)
+	c.Assert(stored.LastUsedAt, Not(Equals), "")
+}
+
+func (s *S) TestPersonalAccessTokenWithoutScope(c *C) {
+	token := s.createPersonalAccessToken(c, account.SCOPE_TEAMS_READ)
+
+	for _, args := range []requests.Args{
+		{Method: "POST", Path: "/api/teams", Body: `{"name": "ApiHub Team", "alias": "apihub"}`},
+		{Method: "GET", Path: "/api/services/apihub"},
+		{Method: "GET", Path: "/api/users/tokens"},
+		{Method: "POST", Path: "/api/users/tokens", Body: `{"name": "deploy", "scopes": ["teams:write"]}`},
+	} {
+		args.AcceptableCode = http.StatusForbidden
+		args.Headers = http.Header{"Authorization": {fmt.Sprintf("%s %s", token.Type, token.Token)}}
+		_, code, body, _ := httpClient.MakeRequest(args)
+
+		c.Assert(code, Equals, http.StatusForbidden)
+		c.Assert(string(body), Equals, `{"error":"access_denied","error_description":"The personal access token does not have the scope required by this operation."}`)
+	}
+}
+
+func (s *S) TestPersonalAccessTokenWriteScopeIncludesRead(c *C) {
+	token := s.createPersonalAccessToken(c, account.SCOPE_SERVICES_WRITE)
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusOK,
+		Method:         "GET",
+		Path:           "/api/services",
+		Headers:        http.Header{"Authorization": {fmt.Sprintf("%s %s", token.Type, token.Token)}},
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+}
+
+func (s *S) TestPersonalAccessTokenWithInvalidSecret(c *C) {
+	token := s.createPersonalAccessToken(c, account.SCOPE_TEAMS_READ)
+
+	testWithoutSignIn(requests.Args{AcceptableCode: http.StatusUnauthorized, Method: "GET", Path: "/api/teams", Headers: http.Header{"Authorization": {fmt.Sprintf("%s %s.wrong", token.Type, token.Id)}}}, c)
+}
+
+func (s *S) TestExpiredPersonalAccessTokenIsRefused(c *C) {
+	token := s.createPersonalAccessToken(c, account.SCOPE_TEAMS_READ)
+	stored, _ := s.store.FindPersonalAccessTokenById(token.Id)
+	stored.ExpiresAt = time.Now().Add(-time.Minute).In(time.UTC).Format(time.RFC3339)
+	s.store.UpsertPersonalAccessToken(stored)
+
+	testWithoutSignIn(requests.Args{AcceptableCode: http.StatusUnauthorized, Method: "GET", Path: "/api/teams", Headers: http.Header{"Authorization": {fmt.Sprintf("%s %s", token.Type, token.Token)}}}, c)
+}
+
+func (s *S) TestPersonalAccessTokenRevoke(c *C) {
+	token := s.createPersonalAccessToken(c, account.SCOPE_TEAMS_READ)
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNoContent,
+		Method:         "DELETE",
+		Path:           fmt.Sprintf("/api/users/tokens/%s", token.Id),
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusNoContent)
+	testWithoutSignIn(requests.Args{AcceptableCode: http.StatusUnauthorized, Method: "GET", Path: "/api/teams", Headers: http.Header{"Authorization": {fmt.Sprintf("%s %s", token.Type, token.Token)}}}, c)
+}
+
+func (s *S) TestUserTokensRevokeKeepsThePersonalAccessTokens(c *C) {
+	token := s.createPersonalAccessToken(c, account.SCOPE_TEAMS_READ)
+
+	_, code, _, _ := httpClient.MakeRequest(requests.Args{
+		AcceptableCode: http.StatusNoContent,
+		Method:         "DELETE",
+		Path:           "/api/users/tokens",
+		Headers:        http.Header{"Authorization": {s.authHeader}},
+	})
+
+	c.Assert(code, Equals, http.StatusNoContent)
+	_, err := s.store.FindPersonalAccessTokenById(token.Id)
+	c.Assert(err, IsNil)
+}
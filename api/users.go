@@ -10,16 +10,44 @@ import (
 )
 
 func (api *Api) userSignup(rw http.ResponseWriter, r *http.Request) {
-	user := account.User{}
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+	body := struct {
+		account.User
+		Invitation string `json:"invitation,omitempty"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		handleError(rw, errors.ErrBadRequest)
 		return
 	}
+	user := body.User
 
+	// The token of the invitation proves that the user owns the email, so the email is verified right away
+	// and the invitations are accepted. Otherwise, they are accepted once the email is verified.
+	var invitation *account.Invitation
+	if body.Invitation != "" {
+		inv, err := account.FindInvitationByToken(body.Invitation)
+		if err != nil {
+			handleError(rw, err)
+			return
+		}
+		if inv.Email != user.Email {
+			handleError(rw, errors.NewForbiddenError(errors.ErrInvitationNotForUser))
+			return
+		}
+		invitation = inv
+	}
+
+	user.EmailVerified = invitation != nil
 	if err := user.Create(); err != nil {
 		handleError(rw, err)
 		return
 	}
+	if user.EmailVerified {
+		if err := user.AcceptInvitations(); err != nil {
+			Logger.Warn("Failed to accept the invitations of %s: %+v.", user.Email, err)
+		}
+	} else if err := user.SendEmailVerification(); err != nil {
+		Logger.Warn("Failed to send the email verification to %s: %+v.", user.Email, err)
+	}
 	// Remove hashed-password from response.
 	user.Password = ""
 
@@ -74,6 +102,88 @@ func (api *Api) userChangePassword(rw http.ResponseWriter, r *http.Request) {
 	NoContent(rw)
 }
 
+// userRecoverPassword sends a token to reset the password.
+// The response is the same whether the user exists or not, so the emails of the users are not disclosed.
+func (api *Api) userRecoverPassword(rw http.ResponseWriter, r *http.Request) {
+	user := account.User{}
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil || user.Email == "" {
+		handleError(rw, errors.ErrBadRequest)
+		return
+	}
+
+	if err := api.auth.RecoverPassword(user.Email); err != nil {
+		Logger.Info("Failed to recover the password of %s: %+v.", user.Email, err)
+	}
+
+	NoContent(rw)
+}
+
+func (api *Api) userResetPassword(rw http.ResponseWriter, r *http.Request) {
+	u := struct {
+		Token                string `json:"token"`
+		NewPassword          string `json:"new_password,omitempty"`
+		ConfirmationPassword string `json:"confirmation_password,omitempty"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+		handleError(rw, errors.ErrBadRequest)
+		return
+	}
+
+	if u.NewPassword != u.ConfirmationPassword || u.NewPassword == "" {
+		handleError(rw, errors.ErrConfirmationPassword)
+		return
+	}
+
+	if _, err := api.auth.ResetPassword(u.Token, u.NewPassword); err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	NoContent(rw)
+}
+
+// userSendEmailVerification sends a new token to verify the email, in case the previous one has expired.
+// The response is the same whether the user exists or not, so the emails of the users are not disclosed.
+func (api *Api) userSendEmailVerification(rw http.ResponseWriter, r *http.Request) {
+	u := account.User{}
+	if err := json.NewDecoder(r.Body).Decode(&u); err != nil || u.Email == "" {
+		handleError(rw, errors.ErrBadRequest)
+		return
+	}
+
+	if user, err := api.store.FindUserByEmail(u.Email); err == nil && !user.EmailVerified {
+		if err := user.SendEmailVerification(); err != nil {
+			Logger.Warn("Failed to send the email verification to %s: %+v.", user.Email, err)
+		}
+	}
+
+	NoContent(rw)
+}
+
+func (api *Api) userVerifyEmail(rw http.ResponseWriter, r *http.Request) {
+	body := struct {
+		Token string `json:"token"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		handleError(rw, errors.ErrBadRequest)
+		return
+	}
+
+	user, err := account.VerifyEmail(body.Token)
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+	if err := user.AcceptInvitations(); err != nil {
+		Logger.Warn("Failed to accept the invitations of %s: %+v.", user.Email, err)
+	}
+	// Remove hashed-password from response.
+	user.Password = ""
+
+	Ok(rw, user)
+}
+
 func (api *Api) userLogin(rw http.ResponseWriter, r *http.Request) {
 	user := account.User{}
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
@@ -81,7 +191,25 @@ func (api *Api) userLogin(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := api.Login(user.Email, user.Password)
+	token, err := api.login(user.Email, user.Password, r.UserAgent())
+	if err != nil {
+		handleError(rw, err)
+		return
+	}
+
+	Ok(rw, token)
+}
+
+func (api *Api) userRefreshToken(rw http.ResponseWriter, r *http.Request) {
+	body := struct {
+		RefreshToken string `json:"refresh_token"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		handleError(rw, errors.ErrBadRequest)
+		return
+	}
+
+	token, err := api.auth.RefreshUserToken(body.RefreshToken, r.UserAgent())
 	if err != nil {
 		handleError(rw, err)
 		return
@@ -101,9 +229,16 @@ func (api *Api) userLogout(rw http.ResponseWriter, r *http.Request) {
 
 // Split Authenticate and CreateUserToken because we can override only the authentication method and still use the token method.
 func (api *Api) Login(email, password string) (*account.Token, error) {
+	return api.login(email, password, "")
+}
+
+func (api *Api) login(email, password, userAgent string) (*account.Token, error) {
 	user, ok := api.auth.Authenticate(email, password)
 	if ok {
-		token, err := api.auth.CreateUserToken(user)
+		if api.requireEmailVerification && !user.EmailVerified {
+			return nil, errors.NewForbiddenError(errors.ErrUserEmailNotVerified)
+		}
+		token, err := api.auth.CreateUserToken(user, userAgent)
 		if err != nil {
 			Logger.Warn(err.Error())
 			return nil, err
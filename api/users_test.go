@@ -84,7 +84,7 @@ func (s *S) TestLoginUser(c *C) {
 
 	c.Assert(code, Equals, http.StatusOK)
 	c.Assert(headers.Get("Content-Type"), Equals, "application/json")
-	c.Assert(string(body), Matches, fmt.Sprintf(`{"access_token":".*","created_at":".*","expires":%d,"token_type":"%s"}`, auth.EXPIRES_IN_SECONDS, auth.TOKEN_TYPE))
+	c.Assert(string(body), Matches, fmt.Sprintf(`{"id":".*","access_token":".*","refresh_token":".*","user_agent":"Go-http-client/1.1","created_at":".*","last_used_at":".*","expires":%d,"expires_at":".*","refresh_expires_at":".*","token_type":"%s"}`, auth.EXPIRES_IN_SECONDS, auth.TOKEN_TYPE))
 }
 
 func (s *S) TestLoginUserWithInvalidUser(c *C) {
@@ -16,7 +16,7 @@ func (api *Api) hookSave(rw http.ResponseWriter, r *http.Request, user *account.
 		return
 	}
 
-	team, err := findTeamAndCheckUser(hook.Team, user)
+	team, err := findTeamAndCheckUser(hook.Team, user, account.PERMISSION_WRITE)
 	if err != nil {
 		handleError(rw, err)
 		return
@@ -37,7 +37,7 @@ func (api *Api) hookDelete(rw http.ResponseWriter, r *http.Request, user *accoun
 		return
 	}
 
-	_, err = findTeamAndCheckUser(hook.Team, user)
+	_, err = findTeamAndCheckUser(hook.Team, user, account.PERMISSION_DELETE)
 	if err != nil {
 		handleError(rw, err)
 		return
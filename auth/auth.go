@@ -1,7 +1,7 @@
 package auth
 
 import (
-	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,8 +13,10 @@ import (
 )
 
 const (
-	EXPIRES_IN_SECONDS = 24 * 3600
-	TOKEN_TYPE         = "Token"
+	EXPIRES_IN_SECONDS         = 24 * 3600
+	REFRESH_EXPIRES_IN_SECONDS = 30 * 24 * 3600
+	LAST_USED_INTERVAL         = time.Minute
+	TOKEN_TYPE                 = "Token"
 )
 
 type auth struct {
@@ -41,37 +43,99 @@ func (a *auth) Authenticate(email, password string) (*account.User, bool) {
 	return &user, true
 }
 
-func (a *auth) CreateUserToken(user *account.User) (*account.Token, error) {
-	api := account.Token{
-		CreatedAt:   time.Now().In(time.UTC).Format("2006-01-02T15:04:05Z07:00"),
-		Expires:     EXPIRES_IN_SECONDS,
-		Type:        TOKEN_TYPE,
-		AccessToken: util.GenerateRandomStr(32),
-		User:        user,
+// RecoverPassword sends a token to the email of the user, which allows to reset the password.
+func (a *auth) RecoverPassword(email string) error {
+	user, err := a.store.FindUserByEmail(email)
+	if err != nil {
+		Logger.Info("Failed trying to find the user '%s' to recover the password. Original Error: '%s'.", email, err.Error())
+		return err
+	}
+
+	return user.RecoverPassword()
+}
+
+// ResetPassword changes the password of the user who the token was sent to, and revokes all the sessions
+// and personal access tokens of the user, so whoever knew the previous password is logged out.
+func (a *auth) ResetPassword(token, password string) (*account.User, error) {
+	user, err := account.ResetPassword(token, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.store.DeleteTokensByUser(user.Email); err != nil {
+		Logger.Warn("Failed to revoke the sessions of %s: %+v.", user.Email, err)
+	}
+	if err := a.store.DeletePersonalAccessTokensByUser(user.Email); err != nil {
+		Logger.Warn("Failed to revoke the personal access tokens of %s: %+v.", user.Email, err)
+	}
+
+	return user, nil
+}
+
+// CreateUserToken starts a new session for the user, keeping the other sessions of the user.
+func (a *auth) CreateUserToken(user *account.User, userAgent string) (*account.Token, error) {
+	now := time.Now().In(time.UTC)
+	token := account.Token{
+		Id:         util.GenerateRandomStr(16),
+		User:       user.Email,
+		UserAgent:  userAgent,
+		CreatedAt:  now.Format(time.RFC3339),
+		LastUsedAt: now.Format(time.RFC3339),
+	}
+	issueToken(&token, now)
+
+	if err := a.store.UpsertToken(token); err != nil {
+		return nil, err
 	}
 
-	err := a.store.CreateToken(api)
+	return &token, nil
+}
+
+// RefreshUserToken exchanges the refresh token for new access and refresh tokens of the same session.
+// The previous ones are no longer accepted.
+func (a *auth) RefreshUserToken(refreshToken, userAgent string) (*account.Token, error) {
+	token, err := a.store.FindTokenByRefreshToken(refreshToken)
 	if err != nil {
+		return nil, errors.NewUnauthorizedError(errors.ErrRefreshTokenInvalid)
+	}
+	if token.RefreshExpired() {
+		a.store.DeleteToken(token)
+		return nil, errors.NewUnauthorizedError(errors.ErrRefreshTokenInvalid)
+	}
+	if _, err := a.store.FindUserByEmail(token.User); err != nil {
+		a.store.DeleteToken(token)
+		return nil, errors.NewUnauthorizedError(errors.ErrRefreshTokenInvalid)
+	}
+
+	now := time.Now().In(time.UTC)
+	issueToken(&token, now)
+	token.LastUsedAt = now.Format(time.RFC3339)
+	if userAgent != "" {
+		token.UserAgent = userAgent
+	}
+
+	if err := a.store.UpsertToken(token); err != nil {
 		return nil, err
 	}
 
-	return &api, err
+	return &token, nil
 }
 
+// UserFromToken returns the user of the session, as long as the access token has not expired.
 func (a *auth) UserFromToken(token string) (*account.User, error) {
 	h := strings.Split(token, " ")
 	if len(h) == 2 {
-		apiToken := account.Token{Type: h[0], AccessToken: h[1]}
-
-		if apiToken.Type == TOKEN_TYPE {
-			var user account.User
-
-			if err := a.store.DecodeToken(apiToken.AccessToken, &user); err != nil {
-				return nil, err
+		if h[0] == TOKEN_TYPE {
+			userToken, err := a.store.FindTokenByAccessToken(h[1])
+			if err != nil || userToken.Expired() {
+				return nil, errors.ErrTokenNotFound
 			}
-			if user.Email == "" {
+
+			user, err := a.store.FindUserByEmail(userToken.User)
+			if err != nil {
 				return nil, errors.ErrTokenNotFound
 			}
+			a.touchToken(userToken)
 
 			return &user, nil
 		}
@@ -80,17 +144,94 @@ func (a *auth) UserFromToken(token string) (*account.User, error) {
 	return nil, errors.ErrInvalidTokenFormat
 }
 
+// UserTokens returns the active sessions of the user, the oldest first.
+// The sessions which can no longer be refreshed are removed.
+func (a *auth) UserTokens(user *account.User) ([]account.Token, error) {
+	tokens, err := a.store.FindTokensByUser(user.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	active := []account.Token{}
+	for _, token := range tokens {
+		if token.RefreshExpired() {
+			a.store.DeleteToken(token)
+			continue
+		}
+		active = append(active, token)
+	}
+	sort.Sort(byCreatedAt(active))
+
+	return active, nil
+}
+
 func (a *auth) RevokeUserToken(token string) error {
-	user, err := a.UserFromToken(token)
-
-	if err == nil && user.Email != "" {
-		key := fmt.Sprintf("%s: %s", TOKEN_TYPE, user.Email)
-		err = a.store.DeleteToken(key)
-		if err == nil {
-			h := strings.Split(token, " ")
-			err = a.store.DeleteToken(h[1])
+	h := strings.Split(token, " ")
+	if len(h) != 2 || h[0] != TOKEN_TYPE {
+		return errors.ErrInvalidTokenFormat
+	}
+
+	userToken, err := a.store.FindTokenByAccessToken(h[1])
+	if err != nil {
+		return err
+	}
+
+	return a.store.DeleteToken(userToken)
+}
+
+// RevokeUserTokenById ends one of the sessions of the user.
+func (a *auth) RevokeUserTokenById(user *account.User, id string) error {
+	tokens, err := a.store.FindTokensByUser(user.Email)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		if token.Id == id {
+			return a.store.DeleteToken(token)
 		}
 	}
 
-	return err
+	return errors.NewNotFoundError(errors.ErrTokenNotFound)
+}
+
+// RevokeUserTokens ends all the sessions of the user.
+func (a *auth) RevokeUserTokens(user *account.User) error {
+	return a.store.DeleteTokensByUser(user.Email)
+}
+
+// touchToken records when the session was last used, at most once every LAST_USED_INTERVAL,
+// to avoid writing to the storage on every request. Only the time is written, so a session revoked
+// or refreshed meanwhile is not restored.
+func (a *auth) touchToken(token account.Token) {
+	now := time.Now().In(time.UTC)
+	lastUsedAt, err := time.Parse(time.RFC3339, token.LastUsedAt)
+	if err == nil && now.Sub(lastUsedAt) < LAST_USED_INTERVAL {
+		return
+	}
+
+	token.LastUsedAt = now.Format(time.RFC3339)
+	if err := a.store.UpdateTokenLastUsedAt(token); err != nil {
+		Logger.Warn("Failed to update the session of %s: %+v.", token.User, err)
+	}
+}
+
+func issueToken(token *account.Token, now time.Time) {
+	token.AccessToken = util.GenerateRandomStr(32)
+	token.RefreshToken = util.GenerateRandomStr(32)
+	token.Type = TOKEN_TYPE
+	token.Expires = EXPIRES_IN_SECONDS
+	token.ExpiresAt = now.Add(EXPIRES_IN_SECONDS * time.Second).Format(time.RFC3339)
+	token.RefreshExpiresAt = now.Add(REFRESH_EXPIRES_IN_SECONDS * time.Second).Format(time.RFC3339)
+}
+
+type byCreatedAt []account.Token
+
+func (t byCreatedAt) Len() int      { return len(t) }
+func (t byCreatedAt) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+func (t byCreatedAt) Less(i, j int) bool {
+	if t[i].CreatedAt == t[j].CreatedAt {
+		return t[i].Id < t[j].Id
+	}
+	return t[i].CreatedAt < t[j].CreatedAt
 }
@@ -7,8 +7,13 @@ import (
 type Authenticatable interface {
 	Authenticate(email, password string) (*account.User, bool)
 	// ChangePassword(email, password string) (*account.User, bool)
-	// RecoverPassword(email string) (bool, error)
-	CreateUserToken(user *account.User) (*account.Token, error)
+	RecoverPassword(email string) error
+	ResetPassword(token, password string) (*account.User, error)
+	CreateUserToken(user *account.User, userAgent string) (*account.Token, error)
+	RefreshUserToken(refreshToken, userAgent string) (*account.Token, error)
 	UserFromToken(token string) (*account.User, error)
+	UserTokens(user *account.User) ([]account.Token, error)
 	RevokeUserToken(token string) error
+	RevokeUserTokenById(user *account.User, id string) error
+	RevokeUserTokens(user *account.User) error
 }
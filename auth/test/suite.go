@@ -2,11 +2,13 @@ package test
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/apihub/apihub/account"
 	"github.com/apihub/apihub/auth"
 	"github.com/apihub/apihub/errors"
+	"github.com/apihub/apihub/mail"
 	. "gopkg.in/check.v1"
 )
 
@@ -46,9 +48,60 @@ func (s *AuthenticatableSuite) TestCreateUserToken(c *C) {
 	user.Create()
 	defer user.Delete()
 
-	token, err := s.Auth.CreateUserToken(user)
+	token, err := s.Auth.CreateUserToken(user, "")
 	c.Check(err, IsNil)
 	c.Assert(token.AccessToken, Not(Equals), "")
+	c.Assert(token.RefreshToken, Not(Equals), "")
+	c.Assert(token.Expires, Equals, auth.EXPIRES_IN_SECONDS)
+	c.Assert(token.ExpiresAt, Not(Equals), "")
+}
+
+func (s *AuthenticatableSuite) TestCreateUserTokenKeepsTheOtherSessions(c *C) {
+	user := &account.User{Name: "Alice", Email: "alice@bar.example.org", Password: "123"}
+	user.Create()
+	defer user.Delete()
+
+	first, _ := s.Auth.CreateUserToken(user, "curl/7.43.0")
+	second, _ := s.Auth.CreateUserToken(user, "Mozilla/5.0")
+	_, err := s.Auth.UserFromToken(fmt.Sprintf("%s %s", first.Type, first.AccessToken))
+	c.Check(err, IsNil)
+	_, err = s.Auth.UserFromToken(fmt.Sprintf("%s %s", second.Type, second.AccessToken))
+	c.Check(err, IsNil)
+
+	tokens, err := s.Auth.UserTokens(user)
+	c.Assert(err, IsNil)
+	c.Assert(tokens, HasLen, 2)
+}
+
+func (s *AuthenticatableSuite) TestRefreshUserToken(c *C) {
+	user := &account.User{Name: "Alice", Email: "alice@bar.example.org", Password: "123"}
+	user.Create()
+	defer user.Delete()
+
+	token, _ := s.Auth.CreateUserToken(user, "")
+	refreshed, err := s.Auth.RefreshUserToken(token.RefreshToken, "curl/7.43.0")
+	c.Assert(err, IsNil)
+	c.Assert(refreshed.Id, Equals, token.Id)
+	c.Assert(refreshed.AccessToken, Not(Equals), token.AccessToken)
+	c.Assert(refreshed.RefreshToken, Not(Equals), token.RefreshToken)
+	c.Assert(refreshed.UserAgent, Equals, "curl/7.43.0")
+
+	_, err = s.Auth.UserFromToken(fmt.Sprintf("%s %s", token.Type, token.AccessToken))
+	c.Assert(err, Equals, errors.ErrTokenNotFound)
+	foundUser, err := s.Auth.UserFromToken(fmt.Sprintf("%s %s", refreshed.Type, refreshed.AccessToken))
+	c.Check(err, IsNil)
+	c.Assert(foundUser.Email, Equals, user.Email)
+
+	_, err = s.Auth.RefreshUserToken(token.RefreshToken, "")
+	_, ok := err.(errors.UnauthorizedError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *AuthenticatableSuite) TestRefreshUserTokenWithNotFound(c *C) {
+	token, err := s.Auth.RefreshUserToken("invalid-token", "")
+	_, ok := err.(errors.UnauthorizedError)
+	c.Assert(ok, Equals, true)
+	c.Check(token, IsNil)
 }
 
 func (s *AuthenticatableSuite) TestUserFromToken(c *C) {
@@ -56,7 +109,7 @@ func (s *AuthenticatableSuite) TestUserFromToken(c *C) {
 	user.Create()
 	defer user.Delete()
 
-	token, _ := s.Auth.CreateUserToken(user)
+	token, _ := s.Auth.CreateUserToken(user, "")
 	foundUser, err := s.Auth.UserFromToken(fmt.Sprintf("%s %s", token.Type, token.AccessToken))
 	c.Check(err, IsNil)
 	c.Assert(foundUser, DeepEquals, user)
@@ -79,7 +132,7 @@ func (s *AuthenticatableSuite) TestRevokeUserToken(c *C) {
 	user.Create()
 	defer user.Delete()
 
-	token, _ := s.Auth.CreateUserToken(user)
+	token, _ := s.Auth.CreateUserToken(user, "")
 	tstr := fmt.Sprintf("%s %s", token.Type, token.AccessToken)
 	foundUser, err := s.Auth.UserFromToken(tstr)
 	c.Check(err, IsNil)
@@ -90,3 +143,113 @@ func (s *AuthenticatableSuite) TestRevokeUserToken(c *C) {
 	c.Assert(err, Equals, errors.ErrTokenNotFound)
 	c.Check(foundUser, IsNil)
 }
+
+func (s *AuthenticatableSuite) TestRevokeUserTokenById(c *C) {
+	user := &account.User{Name: "Alice", Email: "alice@bar.example.org", Password: "123"}
+	user.Create()
+	defer user.Delete()
+
+	first, _ := s.Auth.CreateUserToken(user, "")
+	second, _ := s.Auth.CreateUserToken(user, "")
+	err := s.Auth.RevokeUserTokenById(user, first.Id)
+	c.Assert(err, IsNil)
+
+	_, err = s.Auth.UserFromToken(fmt.Sprintf("%s %s", first.Type, first.AccessToken))
+	c.Assert(err, Equals, errors.ErrTokenNotFound)
+	_, err = s.Auth.UserFromToken(fmt.Sprintf("%s %s", second.Type, second.AccessToken))
+	c.Check(err, IsNil)
+}
+
+func (s *AuthenticatableSuite) TestRevokeUserTokenByIdWithNotFound(c *C) {
+	user := &account.User{Name: "Alice", Email: "alice@bar.example.org", Password: "123"}
+	user.Create()
+	defer user.Delete()
+
+	err := s.Auth.RevokeUserTokenById(user, "not-found")
+	_, ok := err.(errors.NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *AuthenticatableSuite) TestRevokeUserTokens(c *C) {
+	user := &account.User{Name: "Alice", Email: "alice@bar.example.org", Password: "123"}
+	user.Create()
+	defer user.Delete()
+
+	s.Auth.CreateUserToken(user, "")
+	s.Auth.CreateUserToken(user, "")
+	err := s.Auth.RevokeUserTokens(user)
+	c.Assert(err, IsNil)
+
+	tokens, err := s.Auth.UserTokens(user)
+	c.Assert(err, IsNil)
+	c.Assert(tokens, HasLen, 0)
+}
+
+func (s *AuthenticatableSuite) TestRecoverPasswordWithNotFound(c *C) {
+	err := s.Auth.RecoverPassword("invalid-email")
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *AuthenticatableSuite) TestResetPassword(c *C) {
+	sender := mail.NewMemorySender()
+	account.Mailer(sender)
+	user := &account.User{Name: "Alice", Email: "alice@bar.example.org", Password: "123"}
+	user.Create()
+	defer user.Delete()
+
+	err := s.Auth.RecoverPassword(user.Email)
+	c.Assert(err, IsNil)
+	messages := sender.Messages()
+	c.Assert(messages, HasLen, 1)
+	lines := strings.Split(strings.TrimSpace(messages[0].Body), "\n")
+
+	found, err := s.Auth.ResetPassword(lines[len(lines)-1], "456")
+	c.Assert(err, IsNil)
+	c.Assert(found.Email, Equals, user.Email)
+	_, ok := s.Auth.Authenticate(user.Email, "123")
+	c.Assert(ok, Equals, false)
+	_, ok = s.Auth.Authenticate(user.Email, "456")
+	c.Assert(ok, Equals, true)
+}
+
+func (s *AuthenticatableSuite) TestResetPasswordRevokesTheSessions(c *C) {
+	sender := mail.NewMemorySender()
+	account.Mailer(sender)
+	user := &account.User{Name: "Alice", Email: "alice@bar.example.org", Password: "123"}
+	user.Create()
+	defer user.Delete()
+	token, _ := s.Auth.CreateUserToken(user, "")
+
+	s.Auth.RecoverPassword(user.Email)
+	lines := strings.Split(strings.TrimSpace(sender.Messages()[0].Body), "\n")
+	_, err := s.Auth.ResetPassword(lines[len(lines)-1], "456")
+	c.Assert(err, IsNil)
+
+	_, err = s.Auth.UserFromToken(fmt.Sprintf("%s %s", token.Type, token.AccessToken))
+	c.Assert(err, Equals, errors.ErrTokenNotFound)
+}
+
+func (s *AuthenticatableSuite) TestResetPasswordRevokesThePersonalAccessTokens(c *C) {
+	sender := mail.NewMemorySender()
+	account.Mailer(sender)
+	user := &account.User{Name: "Alice", Email: "alice@bar.example.org", Password: "123"}
+	user.Create()
+	defer user.Delete()
+	token := account.PersonalAccessToken{Name: "deploy", Scopes: []string{account.SCOPE_TEAMS_READ}}
+	token.Create(*user)
+
+	s.Auth.RecoverPassword(user.Email)
+	lines := strings.Split(strings.TrimSpace(sender.Messages()[0].Body), "\n")
+	_, err := s.Auth.ResetPassword(lines[len(lines)-1], "456")
+	c.Assert(err, IsNil)
+
+	_, err = account.AuthenticatePersonalAccessToken(token.Token)
+	c.Assert(err, NotNil)
+}
+
+func (s *AuthenticatableSuite) TestResetPasswordWithInvalidToken(c *C) {
+	found, err := s.Auth.ResetPassword("invalid.token", "456")
+	_, ok := err.(errors.ValidationError)
+	c.Assert(ok, Equals, true)
+	c.Check(found, IsNil)
+}
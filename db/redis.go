@@ -114,3 +114,70 @@ func AddHCache(key string, expires int, data map[string]interface{}) {
 	}
 	conn.Do("EXPIRE", key, expires)
 }
+
+// IncrCounter increments the counter stored at key, setting it to expire after the given number of seconds.
+// It returns the value of the counter after the increment.
+func IncrCounter(key string, expires int) (int64, error) {
+	conn := getRedis().Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("INCR", key)
+	conn.Send("EXPIRE", key, expires)
+	values, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return 0, err
+	}
+	return redis.Int64(values[0], nil)
+}
+
+// AddToSortedSet adds the members to the sorted set stored at key, each one with the score at the same position.
+func AddToSortedSet(key string, scores []int64, members []string) error {
+	conn := getRedis().Get()
+	defer conn.Close()
+
+	args := redis.Args{key}
+	for i, member := range members {
+		args = args.Add(scores[i], member)
+	}
+	_, err := conn.Do("ZADD", args...)
+	return err
+}
+
+// RangeSortedSetByScore returns the members of the sorted set stored at key with scores between min and max, inclusive.
+func RangeSortedSetByScore(key string, min, max int64) ([]string, error) {
+	conn := getRedis().Get()
+	defer conn.Close()
+
+	return redis.Strings(conn.Do("ZRANGEBYSCORE", key, min, max))
+}
+
+// RemoveSortedSetByScore removes the members of the sorted set stored at key with scores between min and max, inclusive.
+func RemoveSortedSetByScore(key string, min, max int64) error {
+	conn := getRedis().Get()
+	defer conn.Close()
+
+	_, err := conn.Do("ZREMRANGEBYSCORE", key, min, max)
+	return err
+}
+
+// SetCache stores the value at key, setting it to expire after the given number of seconds.
+func SetCache(key string, value []byte, expires int) error {
+	conn := getRedis().Get()
+	defer conn.Close()
+
+	_, err := conn.Do("SETEX", key, expires, value)
+	return err
+}
+
+// GetCache returns the value stored at key, or nil when the key does not exist.
+func GetCache(key string) ([]byte, error) {
+	conn := getRedis().Get()
+	defer conn.Close()
+
+	value, err := redis.Bytes(conn.Do("GET", key))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	return value, err
+}
@@ -11,6 +11,18 @@ const (
 	E_UNAUTHORIZED_REQUEST  string = "unauthorized_access"
 	E_INTERNAL_SERVER_ERROR string = "internal_server_error"
 	E_GATEWAY_TIMEOUT       string = "gateway_timeout"
+	E_BAD_GATEWAY           string = "bad_gateway"
+	E_TOO_MANY_REQUESTS     string = "too_many_requests"
+	E_QUOTA_EXCEEDED        string = "quota_exceeded"
+
+	// Errors defined by the OAuth 2.0 specification (RFC 6749).
+	E_INVALID_REQUEST           string = "invalid_request"
+	E_INVALID_CLIENT            string = "invalid_client"
+	E_INVALID_GRANT             string = "invalid_grant"
+	E_INVALID_SCOPE             string = "invalid_scope"
+	E_UNAUTHORIZED_CLIENT       string = "unauthorized_client"
+	E_UNSUPPORTED_GRANT_TYPE    string = "unsupported_grant_type"
+	E_UNSUPPORTED_RESPONSE_TYPE string = "unsupported_response_type"
 )
 
 var (
@@ -27,6 +39,7 @@ var (
 	ErrServiceNotFound        = errors.New("Service not found.")
 	ErrTeamNotFound           = errors.New("Team not found.")
 	ErrTokenNotFound          = errors.New("Token not found.")
+	ErrRefreshTokenInvalid    = errors.New("The refresh token is invalid or has expired.")
 	ErrUserNotInTeam          = errors.New("You do not belong to this team!")
 	ErrNotFound               = errors.New("The resource requested does not exist.")
 	ErrConfirmationPassword   = errors.New("Your new password and confirmation password do not match or are invalid.")
@@ -34,22 +47,106 @@ var (
 	ErrUserDuplicateEntry        = errors.New("Someone already has that email. Could you try another?")
 	ErrUserNotFound              = errors.New("User not found.")
 	ErrUserMissingRequiredFields = errors.New("Name/Email/Password cannot be empty.")
+	ErrUserEmailNotVerified      = errors.New("Please verify your email before logging in.")
+	ErrEmailTokenInvalid         = errors.New("The token is invalid, has expired or has already been used.")
 
 	ErrServiceMissingRequiredFields = errors.New("Endpoint/Subdomain/Team cannot be empty.")
+	ErrServiceInvalidUpstream       = errors.New("Upstreams must have a valid Target and a positive Weight.")
+	ErrServiceInvalidLoadBalancer   = errors.New("Load Balancer strategy must be round-robin, least-connections or consistent-hash.")
+	ErrServiceInvalidHealthCheck    = errors.New("Health Check must have a Path starting with a slash and positive values.")
+	ErrServiceInvalidTimeouts       = errors.New("Timeouts must have positive values, in milliseconds.")
+	ErrServiceInvalidRetry          = errors.New("Retry must have positive values, valid status codes and HTTP methods.")
+	ErrServiceInvalidCircuitBreaker = errors.New("Circuit Breaker must have an Error Threshold between 1 and 100 and positive values.")
+	ErrServiceInvalidUpstreamTLS    = errors.New("Upstream TLS must reference a client certificate of the service.")
 	ErrServiceDuplicateEntry        = errors.New("There is another service with this subdomain.")
+	ErrServiceInvalidRoute          = errors.New("Routes must have a Host and/or a Path Prefix starting with a slash.")
+	ErrServiceRouteHostNotVerified  = errors.New("The Host of the route must be under a domain verified by the team.")
+	ErrServiceRouteDuplicateEntry   = errors.New("There is another service with a route to this Host and Path Prefix.")
+	ErrServiceTransformerNotFound   = errors.New("Transformer not found. Please check the transformers available on ApiHub.")
+	ErrCircuitBreakerStateNotFound  = errors.New("Circuit breaker state not found.")
 
 	ErrTeamMissingRequiredFields = errors.New("Name cannot be empty.")
 	ErrTeamDuplicateEntry        = errors.New("Someone already has that team alias. Could you try another?")
+	ErrTeamInvalidRole           = errors.New("Role must be admin, developer or viewer.")
+	ErrTeamMemberNotFound        = errors.New("The user is not a member of this team.")
+	ErrTeamOwnerRole             = errors.New("The role of the owner cannot be changed. Please transfer the ownership instead.")
+	ErrRoleNotAllowed            = errors.New("Your role in this team does not allow this operation.")
+
+	ErrInvitationNotFound              = errors.New("Invitation not found.")
+	ErrInvitationMissingRequiredFields = errors.New("Email cannot be empty.")
+	ErrInvitationAlreadyMember         = errors.New("The user is already a member of this team.")
+	ErrInvitationExpired               = errors.New("The invitation has expired. Please ask for a new one.")
+	ErrInvitationNotForUser            = errors.New("The invitation was sent to another email.")
+
+	ErrDomainNotFound       = errors.New("Domain not found.")
+	ErrDomainInvalid        = errors.New("Name must be a valid domain, such as example.org.")
+	ErrDomainDuplicateEntry = errors.New("The team has already added this domain.")
+	ErrDomainTaken          = errors.New("The domain has been verified by another team.")
+	ErrDomainNotVerified    = errors.New("The TXT record with the token was not found. Please check the DNS settings of the domain.")
 
 	ErrAppNotFound              = errors.New("App not found.")
 	ErrAppMissingRequiredFields = errors.New("Name cannot be empty.")
 	ErrAppDuplicateEntry        = errors.New("There is another app with this client id.")
+	ErrAppInvalidScopes         = errors.New("Scopes cannot be empty or contain spaces.")
+
+	ErrApiKeyNotFound      = errors.New("API key not found.")
+	ErrApiKeyInvalidFields = errors.New("API key must have a Name, valid HTTP Methods and an Expiration in RFC 3339 format.")
+	ErrApiKeyRevoked       = errors.New("API key has been revoked.")
+	ErrApiKeyUnauthorized  = errors.New("The API key is invalid, revoked or has expired.")
+	ErrApiKeyNotAllowed    = errors.New("The API key is not allowed to access this service with this method.")
+
+	ErrPersonalAccessTokenNotFound      = errors.New("Personal access token not found.")
+	ErrPersonalAccessTokenInvalidFields = errors.New("Personal access token must have a Name, valid Scopes and an Expiration in the future, in RFC 3339 format.")
+	ErrPersonalAccessTokenNotAllowed    = errors.New("The personal access token does not have the scope required by this operation.")
+
+	ErrSubscriptionNotFound              = errors.New("Subscription not found.")
+	ErrSubscriptionMissingRequiredFields = errors.New("Service cannot be empty.")
+	ErrSubscriptionDuplicateEntry        = errors.New("This app has already requested access to this service.")
+	ErrSubscriptionNotApproved           = errors.New("The app is not subscribed to this service.")
+
+	ErrPlanNotFound              = errors.New("Plan not found.")
+	ErrPlanMissingRequiredFields = errors.New("Name and Service cannot be empty.")
+	ErrPlanInvalidFields         = errors.New("Plan must have positive quotas, valid HTTP Methods and Paths starting with a slash.")
+	ErrPlanNotAllowed            = errors.New("The plan of the app does not allow this request.")
+
+	ErrCertificateNotFound              = errors.New("Certificate not found.")
+	ErrCertificateMissingRequiredFields = errors.New("Host, Certificate and Key cannot be empty.")
+	ErrCertificateInvalidHost           = errors.New("Host must be the subdomain of the service or one of the hosts of its routes.")
+	ErrCertificateInvalid               = errors.New("Certificate and Key must be a PEM encoded pair, valid for the host.")
+	ErrCertificateDuplicateEntry        = errors.New("There is a certificate for this host in another service.")
+
+	ErrClientCertificateNotFound              = errors.New("Client certificate not found.")
+	ErrClientCertificateMissingRequiredFields = errors.New("Name and either a Certificate with its Key or a CA bundle cannot be empty.")
+	ErrClientCertificateInvalid               = errors.New("Certificate and Key must be a PEM encoded pair, and CA a PEM encoded bundle.")
+	ErrClientCertificateInUse                 = errors.New("The client certificate is used by the service. Please remove it from the upstream TLS settings first.")
+	ErrClientCertificateSecretMissing         = errors.New("The keys of the client certificates cannot be saved until the secret to encrypt them is configured.")
+	ErrClientCertificateKeyNotDecrypted       = errors.New("The key of the client certificate cannot be decrypted with the secret.")
 
 	ErrPluginNotFound              = errors.New("Plugin Config not found.")
 	ErrPluginMissingRequiredFields = errors.New("Name and Service cannot be empty.")
 
+	ErrRateLimitExceeded = errors.New("API rate limit exceeded. Try again later.")
+	ErrQuotaExceeded     = errors.New("The quota of the plan has been exhausted.")
+
+	ErrAnalyticsNotEnabled    = errors.New("Analytics is not enabled.")
+	ErrAnalyticsInvalidPeriod = errors.New("From and To must be in RFC 3339 format, with From before To and at most 31 days apart, and Interval must be a duration such as 1h, up to 1000 intervals.")
+
 	ErrHookNotFound              = errors.New("Hook not found.")
 	ErrHookMissingRequiredFields = errors.New("Name, Team and Events cannot be empty.")
+
+	ErrGrantNotFound                = errors.New("Authorization code not found.")
+	ErrOAuthMissingParameters       = errors.New("The request is missing a required parameter.")
+	ErrOAuthInvalidClient           = errors.New("Client authentication failed.")
+	ErrOAuthInvalidRedirectUri      = errors.New("The redirect uri is missing or is not registered for this client.")
+	ErrOAuthInvalidGrant            = errors.New("The authorization code or refresh token is invalid, expired or was issued to another client.")
+	ErrOAuthInvalidCodeVerifier     = errors.New("The code verifier does not match the code challenge.")
+	ErrOAuthInvalidScope            = errors.New("The scope requested is not allowed to this client.")
+	ErrOAuthInvalidChallengeMethod  = errors.New("Code challenge method must be plain or S256.")
+	ErrOAuthUnsupportedGrantType    = errors.New("Grant type must be authorization_code, client_credentials or refresh_token.")
+	ErrOAuthUnsupportedResponseType = errors.New("Response type must be code.")
+	ErrOAuthMissingToken            = errors.New("The request must carry an access token or the client credentials.")
+	ErrOAuthInvalidToken            = errors.New("The access token is invalid or has expired.")
+	ErrOAuthInsufficientScope       = errors.New("The access token does not have the scopes required by this service.")
 )
 
 type ErrorResponse struct {
@@ -125,6 +222,20 @@ func (err ForbiddenError) Error() string {
 	return err.description.Error()
 }
 
+// OAuthError is an error of the OAuth 2.0 endpoints, whose type is one of the codes defined by RFC 6749.
+type OAuthError struct {
+	Type        string
+	description error
+}
+
+func NewOAuthError(errType string, err error) OAuthError {
+	return OAuthError{Type: errType, description: err}
+}
+
+func (err OAuthError) Error() string {
+	return err.description.Error()
+}
+
 type InvalidHostError struct {
 	description error
 }
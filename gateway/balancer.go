@@ -0,0 +1,263 @@
+package gateway
+
+import (
+	"hash/crc32"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+)
+
+const (
+	DEFAULT_MAX_FAILS       = 3
+	DEFAULT_COOLDOWN        = 10
+	VIRTUAL_NODES_BY_WEIGHT = 100
+	ERR_NO_UPSTREAM         = "There is no upstream server available to respond the request at this moment."
+)
+
+// upstream is a target of a service, along with its load and health.
+type upstream struct {
+	// active is the number of in-flight requests. It is kept as the first field
+	// to guarantee the 64-bit alignment required by atomic operations.
+	active int64
+	target *url.URL
+	weight int
+
+	mtx          sync.Mutex
+	current      int
+	fails        int
+	ejectedUntil time.Time
+	// down is set by the active health checker, regardless of the passive ejection.
+	down bool
+}
+
+func (u *upstream) available(now time.Time) bool {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	return !u.down && !now.Before(u.ejectedUntil)
+}
+
+func (u *upstream) state(service string) account.UpstreamState {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	state := account.UpstreamState{
+		Service:   service,
+		Target:    u.target.String(),
+		Healthy:   !u.down && u.ejectedUntil.IsZero(),
+		Failures:  u.fails,
+		UpdatedAt: time.Now().In(time.UTC).Format(time.RFC3339),
+	}
+	if !u.ejectedUntil.IsZero() {
+		state.EjectedUntil = u.ejectedUntil.In(time.UTC).Format(time.RFC3339)
+	}
+	return state
+}
+
+type ringPoint struct {
+	hash     uint32
+	upstream *upstream
+}
+
+// upstreamPool picks an upstream for each request, according to the strategy of the service,
+// and ejects the upstreams which fail consecutive requests for a cooldown period.
+type upstreamPool struct {
+	service    string
+	strategy   string
+	hashHeader string
+	maxFails   int
+	cooldown   time.Duration
+	upstreams  []*upstream
+	ring       []ringPoint
+	mtx        sync.Mutex
+
+	// onStateChange is called whenever an upstream is ejected, marked down or recovered.
+	onStateChange func(account.UpstreamState)
+}
+
+func newUpstreamPool(service *account.Service) (*upstreamPool, error) {
+	lb := account.LoadBalancer{}
+	if service.LoadBalancer != nil {
+		lb = *service.LoadBalancer
+	}
+	if lb.Strategy == "" {
+		lb.Strategy = account.ROUND_ROBIN
+	}
+	if lb.MaxFails <= 0 {
+		lb.MaxFails = DEFAULT_MAX_FAILS
+	}
+	if lb.Cooldown <= 0 {
+		lb.Cooldown = DEFAULT_COOLDOWN
+	}
+
+	p := &upstreamPool{
+		service:    service.Subdomain,
+		strategy:   lb.Strategy,
+		hashHeader: lb.HashHeader,
+		maxFails:   lb.MaxFails,
+		cooldown:   time.Duration(lb.Cooldown) * time.Second,
+	}
+	for _, t := range service.Targets() {
+		target, err := url.Parse(t.Target)
+		if err != nil {
+			return nil, err
+		}
+		p.upstreams = append(p.upstreams, &upstream{target: target, weight: t.Weight})
+	}
+	if p.strategy == account.CONSISTENT_HASH {
+		p.buildRing()
+	}
+	return p, nil
+}
+
+// next returns the upstream which must respond the request.
+func (p *upstreamPool) next(r *http.Request) (*upstream, error) {
+	now := time.Now()
+	var u *upstream
+	switch p.strategy {
+	case account.LEAST_CONNECTIONS:
+		u = p.leastConnections(now)
+	case account.CONSISTENT_HASH:
+		u = p.consistentHash(r, now)
+	default:
+		u = p.roundRobin(now)
+	}
+
+	if u == nil {
+		return nil, errors.NewErrorResponse(errors.E_SERVICE_UNAVAILABLE, ERR_NO_UPSTREAM)
+	}
+	return u, nil
+}
+
+// roundRobin implements the smooth weighted round-robin, which spreads the requests
+// evenly even when the weights are different.
+func (p *upstreamPool) roundRobin(now time.Time) *upstream {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	var (
+		best  *upstream
+		total int
+	)
+	for _, u := range p.upstreams {
+		if !u.available(now) {
+			continue
+		}
+		u.current += u.weight
+		total += u.weight
+		if best == nil || u.current > best.current {
+			best = u
+		}
+	}
+	if best != nil {
+		best.current -= total
+	}
+	return best
+}
+
+func (p *upstreamPool) leastConnections(now time.Time) *upstream {
+	var best *upstream
+	for _, u := range p.upstreams {
+		if !u.available(now) {
+			continue
+		}
+		// Compare active/weight ratios without dividing.
+		if best == nil || atomic.LoadInt64(&u.active)*int64(best.weight) < atomic.LoadInt64(&best.active)*int64(u.weight) {
+			best = u
+		}
+	}
+	return best
+}
+
+func (p *upstreamPool) consistentHash(r *http.Request, now time.Time) *upstream {
+	if len(p.ring) == 0 {
+		return nil
+	}
+
+	key := r.Header.Get(p.hashHeader)
+	if p.hashHeader == "" || key == "" {
+		key, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= hash })
+
+	// Walk the ring clockwise until an available upstream is found.
+	for n := 0; n < len(p.ring); n++ {
+		point := p.ring[(i+n)%len(p.ring)]
+		if point.upstream.available(now) {
+			return point.upstream
+		}
+	}
+	return nil
+}
+
+func (p *upstreamPool) buildRing() {
+	for _, u := range p.upstreams {
+		for i := 0; i < u.weight*VIRTUAL_NODES_BY_WEIGHT; i++ {
+			hash := crc32.ChecksumIEEE([]byte(u.target.String() + "#" + strconv.Itoa(i)))
+			p.ring = append(p.ring, ringPoint{hash: hash, upstream: u})
+		}
+	}
+	sort.Sort(byHash(p.ring))
+}
+
+// acquire must be called before sending a request to the upstream.
+func (p *upstreamPool) acquire(u *upstream) {
+	atomic.AddInt64(&u.active, 1)
+}
+
+// release must be called after the upstream has responded, informing whether the request has failed.
+// Consecutive failures eject the upstream for the cooldown period.
+func (p *upstreamPool) release(u *upstream, failed bool) {
+	atomic.AddInt64(&u.active, -1)
+
+	u.mtx.Lock()
+	changed := false
+	if failed {
+		u.fails++
+		if u.fails >= p.maxFails {
+			changed = u.ejectedUntil.IsZero()
+			u.ejectedUntil = time.Now().Add(p.cooldown)
+		}
+	} else {
+		changed = !u.ejectedUntil.IsZero()
+		u.fails = 0
+		u.ejectedUntil = time.Time{}
+	}
+	u.mtx.Unlock()
+
+	if changed {
+		p.notify(u)
+	}
+}
+
+// setDown marks the upstream as down or up, as reported by the active health checker.
+func (p *upstreamPool) setDown(u *upstream, down bool) {
+	u.mtx.Lock()
+	changed := u.down != down
+	u.down = down
+	u.mtx.Unlock()
+
+	if changed {
+		p.notify(u)
+	}
+}
+
+func (p *upstreamPool) notify(u *upstream) {
+	if p.onStateChange != nil {
+		p.onStateChange(u.state(p.service))
+	}
+}
+
+type byHash []ringPoint
+
+func (r byHash) Len() int           { return len(r) }
+func (r byHash) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r byHash) Less(i, j int) bool { return r[i].hash < r[j].hash }
@@ -0,0 +1,92 @@
+package gateway
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	. "gopkg.in/check.v1"
+)
+
+func newTestPool(c *C, lb *account.LoadBalancer, upstreams ...account.Upstream) *upstreamPool {
+	pool, err := newUpstreamPool(&account.Service{Subdomain: "test", Upstreams: upstreams, LoadBalancer: lb})
+	c.Assert(err, IsNil)
+	return pool
+}
+
+func (s *S) TestUpstreamPoolWithEndpoint(c *C) {
+	pool, err := newUpstreamPool(&account.Service{Subdomain: "test", Endpoint: "http://example.org/api"})
+	c.Assert(err, IsNil)
+	c.Assert(len(pool.upstreams), Equals, 1)
+	c.Assert(pool.upstreams[0].target.String(), Equals, "http://example.org/api")
+}
+
+func (s *S) TestUpstreamPoolRoundRobin(c *C) {
+	pool := newTestPool(c, nil, account.Upstream{Target: "http://a.example.org", Weight: 2}, account.Upstream{Target: "http://b.example.org"})
+	r, _ := http.NewRequest("GET", "http://test.apihub.dev", nil)
+
+	picked := map[string]int{}
+	for i := 0; i < 6; i++ {
+		u, err := pool.next(r)
+		c.Assert(err, IsNil)
+		picked[u.target.Host]++
+	}
+	c.Assert(picked["a.example.org"], Equals, 4)
+	c.Assert(picked["b.example.org"], Equals, 2)
+}
+
+func (s *S) TestUpstreamPoolLeastConnections(c *C) {
+	pool := newTestPool(c, &account.LoadBalancer{Strategy: account.LEAST_CONNECTIONS}, account.Upstream{Target: "http://a.example.org"}, account.Upstream{Target: "http://b.example.org"})
+	r, _ := http.NewRequest("GET", "http://test.apihub.dev", nil)
+
+	u, _ := pool.next(r)
+	pool.acquire(u)
+	another, _ := pool.next(r)
+	c.Assert(another, Not(Equals), u)
+
+	pool.release(u, false)
+	pool.acquire(another)
+	u, _ = pool.next(r)
+	c.Assert(u, Not(Equals), another)
+}
+
+func (s *S) TestUpstreamPoolConsistentHash(c *C) {
+	pool := newTestPool(c, &account.LoadBalancer{Strategy: account.CONSISTENT_HASH, HashHeader: "X-User"}, account.Upstream{Target: "http://a.example.org"}, account.Upstream{Target: "http://b.example.org"}, account.Upstream{Target: "http://c.example.org"})
+	r, _ := http.NewRequest("GET", "http://test.apihub.dev", nil)
+	r.Header.Set("X-User", "alice")
+
+	u, err := pool.next(r)
+	c.Assert(err, IsNil)
+	for i := 0; i < 10; i++ {
+		another, _ := pool.next(r)
+		c.Assert(another, Equals, u)
+	}
+}
+
+func (s *S) TestUpstreamPoolEjectsFailingUpstream(c *C) {
+	var states []account.UpstreamState
+	pool := newTestPool(c, &account.LoadBalancer{MaxFails: 2, Cooldown: 1}, account.Upstream{Target: "http://a.example.org"})
+	pool.onStateChange = func(state account.UpstreamState) { states = append(states, state) }
+	r, _ := http.NewRequest("GET", "http://test.apihub.dev", nil)
+
+	for i := 0; i < 2; i++ {
+		u, err := pool.next(r)
+		c.Assert(err, IsNil)
+		pool.acquire(u)
+		pool.release(u, true)
+	}
+	_, err := pool.next(r)
+	c.Assert(err, NotNil)
+	c.Assert(len(states), Equals, 1)
+	c.Assert(states[0].Healthy, Equals, false)
+	c.Assert(states[0].Failures, Equals, 2)
+
+	// After the cooldown, the upstream is allowed to respond again.
+	pool.upstreams[0].ejectedUntil = time.Now().Add(-time.Second)
+	u, err := pool.next(r)
+	c.Assert(err, IsNil)
+	pool.acquire(u)
+	pool.release(u, false)
+	c.Assert(len(states), Equals, 2)
+	c.Assert(states[1].Healthy, Equals, true)
+}
@@ -0,0 +1,160 @@
+package gateway
+
+import (
+	"sync"
+	"time"
+
+	"github.com/apihub/apihub/account"
+)
+
+const (
+	DEFAULT_ERROR_THRESHOLD    = 50
+	DEFAULT_MIN_REQUESTS       = 20
+	DEFAULT_BREAKER_WINDOW     = 10
+	DEFAULT_BREAKER_COOLDOWN   = 30
+	DEFAULT_HALF_OPEN_REQUESTS = 1
+	ERR_CIRCUIT_OPEN           = "The service is not available at this moment, because too many requests have failed. Try again later."
+)
+
+// circuitBreaker stops sending requests to a service whose requests are failing,
+// letting a few requests through after the cooldown to probe whether it has recovered.
+type circuitBreaker struct {
+	service          string
+	errorThreshold   int
+	minRequests      int
+	window           time.Duration
+	cooldown         time.Duration
+	halfOpenRequests int
+
+	mtx         sync.Mutex
+	state       string
+	windowStart time.Time
+	requests    int
+	failures    int
+	openUntil   time.Time
+	// probes is the number of requests let through while half-open, and successes how many of them succeeded.
+	probes    int
+	successes int
+
+	// onStateChange is called whenever the circuit opens, half-opens or closes.
+	onStateChange func(account.CircuitBreakerState)
+}
+
+func newCircuitBreaker(service *account.Service) *circuitBreaker {
+	cb := *service.CircuitBreaker
+	if cb.ErrorThreshold <= 0 {
+		cb.ErrorThreshold = DEFAULT_ERROR_THRESHOLD
+	}
+	if cb.MinRequests <= 0 {
+		cb.MinRequests = DEFAULT_MIN_REQUESTS
+	}
+	if cb.Window <= 0 {
+		cb.Window = DEFAULT_BREAKER_WINDOW
+	}
+	if cb.Cooldown <= 0 {
+		cb.Cooldown = DEFAULT_BREAKER_COOLDOWN
+	}
+	if cb.HalfOpenRequests <= 0 {
+		cb.HalfOpenRequests = DEFAULT_HALF_OPEN_REQUESTS
+	}
+
+	return &circuitBreaker{
+		service:          service.Subdomain,
+		errorThreshold:   cb.ErrorThreshold,
+		minRequests:      cb.MinRequests,
+		window:           time.Duration(cb.Window) * time.Second,
+		cooldown:         time.Duration(cb.Cooldown) * time.Second,
+		halfOpenRequests: cb.HalfOpenRequests,
+		state:            account.CIRCUIT_CLOSED,
+	}
+}
+
+// allow reports whether the request may be sent to the service.
+func (b *circuitBreaker) allow() bool {
+	b.mtx.Lock()
+	changed := false
+	if b.state == account.CIRCUIT_OPEN && !time.Now().Before(b.openUntil) {
+		b.state, b.probes, b.successes = account.CIRCUIT_HALF_OPEN, 0, 0
+		changed = true
+	}
+
+	allowed := true
+	switch b.state {
+	case account.CIRCUIT_OPEN:
+		allowed = false
+	case account.CIRCUIT_HALF_OPEN:
+		allowed = b.probes < b.halfOpenRequests
+		if allowed {
+			b.probes++
+		}
+	}
+	b.mtx.Unlock()
+
+	if changed {
+		b.notify()
+	}
+	return allowed
+}
+
+// record keeps the result of a request let through by allow.
+func (b *circuitBreaker) record(failed bool) {
+	now := time.Now()
+	b.mtx.Lock()
+	changed := false
+	switch b.state {
+	case account.CIRCUIT_CLOSED:
+		if now.Sub(b.windowStart) >= b.window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if b.requests >= b.minRequests && b.failures*100 >= b.errorThreshold*b.requests {
+			b.open(now)
+			changed = true
+		}
+	case account.CIRCUIT_HALF_OPEN:
+		if failed {
+			b.open(now)
+			changed = true
+		} else if b.successes++; b.successes >= b.halfOpenRequests {
+			b.state, b.windowStart, b.requests, b.failures = account.CIRCUIT_CLOSED, now, 0, 0
+			changed = true
+		}
+	}
+	b.mtx.Unlock()
+
+	if changed {
+		b.notify()
+	}
+}
+
+// open must be called with the lock held.
+func (b *circuitBreaker) open(now time.Time) {
+	b.state = account.CIRCUIT_OPEN
+	b.openUntil = now.Add(b.cooldown)
+}
+
+func (b *circuitBreaker) currentState() account.CircuitBreakerState {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	state := account.CircuitBreakerState{
+		Service:   b.service,
+		State:     b.state,
+		Requests:  b.requests,
+		Failures:  b.failures,
+		UpdatedAt: time.Now().In(time.UTC).Format(time.RFC3339),
+	}
+	if b.state == account.CIRCUIT_OPEN {
+		state.OpenUntil = b.openUntil.In(time.UTC).Format(time.RFC3339)
+	}
+	return state
+}
+
+func (b *circuitBreaker) notify() {
+	if b.onStateChange != nil {
+		b.onStateChange(b.currentState())
+	}
+}
@@ -0,0 +1,123 @@
+package gateway
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/account/mem"
+	. "gopkg.in/check.v1"
+)
+
+func newTestBreaker(cb account.CircuitBreaker) (*circuitBreaker, *[]string) {
+	breaker := newCircuitBreaker(&account.Service{Subdomain: "test", CircuitBreaker: &cb})
+	states := &[]string{}
+	breaker.onStateChange = func(state account.CircuitBreakerState) {
+		*states = append(*states, state.State)
+	}
+	return breaker, states
+}
+
+func (s *S) TestCircuitBreakerOpensAfterErrorThreshold(c *C) {
+	breaker, states := newTestBreaker(account.CircuitBreaker{ErrorThreshold: 50, MinRequests: 4, Cooldown: 60})
+
+	for _, failed := range []bool{false, true, false} {
+		c.Assert(breaker.allow(), Equals, true)
+		breaker.record(failed)
+	}
+	c.Assert(breaker.currentState().State, Equals, account.CIRCUIT_CLOSED)
+
+	c.Assert(breaker.allow(), Equals, true)
+	breaker.record(true)
+	c.Assert(breaker.allow(), Equals, false)
+	c.Assert(*states, DeepEquals, []string{account.CIRCUIT_OPEN})
+
+	state := breaker.currentState()
+	c.Assert(state.Requests, Equals, 4)
+	c.Assert(state.Failures, Equals, 2)
+	c.Assert(state.OpenUntil, Not(Equals), "")
+}
+
+func (s *S) TestCircuitBreakerStartsANewWindow(c *C) {
+	breaker, _ := newTestBreaker(account.CircuitBreaker{ErrorThreshold: 50, MinRequests: 2})
+	breaker.record(true)
+	breaker.windowStart = time.Now().Add(-time.Minute)
+	breaker.record(false)
+
+	state := breaker.currentState()
+	c.Assert(state.State, Equals, account.CIRCUIT_CLOSED)
+	c.Assert(state.Requests, Equals, 1)
+	c.Assert(state.Failures, Equals, 0)
+}
+
+func (s *S) TestCircuitBreakerHalfOpens(c *C) {
+	breaker, states := newTestBreaker(account.CircuitBreaker{MinRequests: 1, HalfOpenRequests: 2})
+	breaker.record(true)
+	breaker.openUntil = time.Now()
+
+	// Only the probes are let through.
+	c.Assert(breaker.allow(), Equals, true)
+	c.Assert(breaker.allow(), Equals, true)
+	c.Assert(breaker.allow(), Equals, false)
+	c.Assert(breaker.currentState().State, Equals, account.CIRCUIT_HALF_OPEN)
+
+	breaker.record(false)
+	c.Assert(breaker.currentState().State, Equals, account.CIRCUIT_HALF_OPEN)
+	breaker.record(false)
+	c.Assert(breaker.currentState().State, Equals, account.CIRCUIT_CLOSED)
+	c.Assert(*states, DeepEquals, []string{account.CIRCUIT_OPEN, account.CIRCUIT_HALF_OPEN, account.CIRCUIT_CLOSED})
+}
+
+func (s *S) TestCircuitBreakerOpensAgainWhenProbeFails(c *C) {
+	breaker, states := newTestBreaker(account.CircuitBreaker{MinRequests: 1})
+	breaker.record(true)
+	breaker.openUntil = time.Now()
+
+	c.Assert(breaker.allow(), Equals, true)
+	breaker.record(true)
+	c.Assert(breaker.allow(), Equals, false)
+	c.Assert(*states, DeepEquals, []string{account.CIRCUIT_OPEN, account.CIRCUIT_HALF_OPEN, account.CIRCUIT_OPEN})
+}
+
+func (s *S) TestGatewayShortCircuitsOpenService(c *C) {
+	hits := 0
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer target.Close()
+
+	store := mem.New()
+	service := &account.Service{Subdomain: "test", Endpoint: target.URL, CircuitBreaker: &account.CircuitBreaker{MinRequests: 2, Cooldown: 60}}
+	gateway := New(s.Settings, nil)
+	gateway.Storage(store)
+	gateway.AddService(service)
+
+	codes := []int{}
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		w.Body = new(bytes.Buffer)
+		r, _ := http.NewRequest("GET", "http://test.apihub.dev", nil)
+		gateway.ServeHTTP(w, r)
+		codes = append(codes, w.Code)
+		if i == 2 {
+			c.Assert(w.Body.String(), Equals, `{"error":"service_unavailable","error_description":"`+ERR_CIRCUIT_OPEN+`"}`)
+		}
+	}
+	c.Assert(codes, DeepEquals, []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusServiceUnavailable})
+	c.Assert(hits, Equals, 2)
+
+	// The circuit breaker is kept when the service is reloaded.
+	gateway.AddService(service)
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://test.apihub.dev", nil)
+	gateway.ServeHTTP(w, r)
+	c.Assert(w.Code, Equals, http.StatusServiceUnavailable)
+
+	time.Sleep(50 * time.Millisecond)
+	state, err := store.FindCircuitBreakerStateByService(*service)
+	c.Assert(err, IsNil)
+	c.Assert(state.State, Equals, account.CIRCUIT_OPEN)
+}
@@ -5,17 +5,19 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net"
 	"net/http"
-	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/apihub/apihub/analytics"
 	"github.com/apihub/apihub/api"
 	"github.com/apihub/apihub/errors"
 	"github.com/apihub/apihub/gateway/middleware"
+	. "github.com/apihub/apihub/log"
 	"github.com/codegangsta/negroni"
 )
 
@@ -23,20 +25,68 @@ const (
 	DEFAULT_TIMEOUT = 10
 	ERR_TIMEOUT     = "The server, while acting as a gateway or proxy, did not receive a timely response from the upstream server."
 	ERR_NOT_FOUND   = "The requested resource could not be found but may be available again in the future."
+
+	ERR_BODY_TOO_LARGE     = "The response of the upstream server is too large to be transformed."
+	DEFAULT_FLUSH_INTERVAL = 100
 )
 
 type Dispatcher struct {
 	handler   ServiceHandler
 	proxy     *ReverseProxy
-	Transport *http.Transport
+	upstreams *upstreamPool
+	timeouts  timeouts
 }
 
-func (rp *Dispatcher) Director(r *http.Request) {
-	target, err := url.Parse(rp.handler.service.Endpoint)
-	if err != nil {
-		log.Fatal(err)
+// ServeHTTP tunnels the upgrade requests, such as WebSocket, and proxies all the others.
+// It runs after the middlewares of the service, so they apply to both.
+func (rp *Dispatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if isUpgrade(r) {
+		rp.serveUpgrade(w, r)
+		return
+	}
+	rp.proxy.ServeHTTP(w, r)
+}
+
+// serveRecorded serves the request through the middlewares of the service and the proxy,
+// emitting a record of it to the analytics sink, including the responses written by the middlewares,
+// such as the cached ones. Upgrade requests are not recorded.
+func serveRecorded(h ServiceHandler, next http.Handler, w http.ResponseWriter, r *http.Request) {
+	if isUpgrade(r) {
+		next.ServeHTTP(w, r)
+		return
 	}
 
+	record := analytics.Record{
+		Service:   h.service.Subdomain,
+		Method:    r.Method,
+		Path:      analytics.PathTemplate(r.URL.Path),
+		Timestamp: time.Now().Unix(),
+	}
+	body := &countingReader{ReadCloser: r.Body}
+	if r.Body != nil {
+		r.Body = body
+	}
+	rw := &recordingWriter{ResponseWriter: w, start: time.Now()}
+
+	next.ServeHTTP(rw, r)
+
+	// Only the client verified by the Auth middleware is trusted, not the headers sent by the client.
+	record.ClientId = middleware.ClientId(r)
+	record.RequestId = r.Header.Get("X-Request-Id")
+	record.Status = rw.code()
+	record.BytesIn = body.bytes
+	record.BytesOut = rw.bytes
+	record.Latency = int64(rw.latency / time.Millisecond)
+	h.sink.Write(record)
+}
+
+// Director leaves the request as it is: the upstream is picked by RoundTrip,
+// so nothing is kept for the requests which never reach it.
+func (rp *Dispatcher) Director(r *http.Request) {}
+
+// rewrite points the request to the given upstream.
+func (rp *Dispatcher) rewrite(r *http.Request, u *upstream) {
+	target := u.target
 	targetQuery := target.RawQuery
 	r.URL.Scheme = target.Scheme
 	r.URL.Host = target.Host
@@ -50,51 +100,117 @@ func (rp *Dispatcher) Director(r *http.Request) {
 	}
 }
 
+// RoundTrip sends the request to the upstream picked by the Director, retrying it on the next upstreams
+// according to the retry policy of the service. Requests are not sent while the circuit is open.
 func (rp *Dispatcher) RoundTrip(r *http.Request) (*http.Response, error) {
-	var (
-		err      error
-		response *http.Response
-	)
+	u, err := rp.upstreams.next(r)
+	if err != nil {
+		return Response(r, serviceUnavailable(ERR_NO_UPSTREAM)), nil
+	}
+
+	breaker := rp.handler.breaker
+	if breaker != nil && !breaker.allow() {
+		upstreamErrors.Inc(rp.handler.service.Subdomain, "circuit_open")
+		return Response(r, serviceUnavailable(ERR_CIRCUIT_OPEN)), nil
+	}
+
+	// The URL is kept before it is rewritten, so the request may be retried on another upstream.
+	original := *r.URL
+	rp.rewrite(r, u)
 
 	via := headerVia(r.Header.Get("Via"), r.ProtoMajor, r.ProtoMinor)
 	if via != "" {
 		r.Header.Set("Via", via)
 	}
 
-	response, err = rp.Transport.RoundTrip(r)
+	deadline := startDeadline(r, rp.timeouts.total)
+	retry := rp.handler.retry
+	attempts := retry.attempts(r)
+	var rewind func()
+	if attempts > 1 {
+		var ok bool
+		if rewind, ok = replayableBody(r); !ok {
+			attempts = 1
+		}
+	}
+
+	var (
+		response *http.Response
+		failed   bool
+	)
+	for attempt := 1; ; attempt++ {
+		if rewind != nil {
+			rewind()
+		}
+		response, failed = rp.send(r, u, deadline)
+		if attempt >= attempts || deadline.exceeded() || !retry.retryable(response, failed) {
+			break
+		}
+
+		response.Body.Close()
+		retriesTotal.Inc(rp.handler.service.Subdomain)
+		time.Sleep(retry.wait(attempt))
+		if next, err := rp.upstreams.next(r); err == nil {
+			u = next
+			*r.URL = original
+			rp.rewrite(r, u)
+		}
+	}
+
+	if breaker != nil {
+		breaker.record(failed || response.StatusCode >= http.StatusInternalServerError)
+	}
+	if deadline != nil {
+		// The total timeout also covers the response body, so it only stops once the body is closed.
+		response.Body = &releaseOnClose{ReadCloser: response.Body, release: deadline.stop}
+	}
+	return response, nil
+}
+
+// send sends the request to the given upstream, informing whether it has failed to respond.
+func (rp *Dispatcher) send(r *http.Request, u *upstream, deadline *deadline) (*http.Response, bool) {
+	rp.upstreams.acquire(u)
+	response, err := transports.get(u.target, rp.timeouts, rp.handler.upstreamTLS).RoundTrip(r)
 
 	if err != nil {
+		rp.upstreams.release(u, true)
 		msg := internalServerError(err.Error())
+		errorType := "error"
 
-		if e, ok := err.(*net.OpError); ok {
-			if e.Timeout() {
-				msg = gatewayTimeout(ERR_TIMEOUT)
-			}
-		}
-		response = Response(r, msg)
-	} else {
-		via = headerVia(response.Header.Get("Via"), r.ProtoMajor, r.ProtoMinor)
-		if via != "" {
-			response.Header.Set("Via", via)
+		if e, ok := err.(net.Error); (ok && e.Timeout()) || deadline.exceeded() {
+			msg = gatewayTimeout(ERR_TIMEOUT)
+			errorType = "timeout"
 		}
+		upstreamErrors.Inc(rp.handler.service.Subdomain, errorType)
+		return Response(r, msg), true
 	}
 
-	return response, nil
+	// The upstream is still busy until the response body is consumed.
+	response.Body = &releaseOnClose{ReadCloser: response.Body, release: func() { rp.upstreams.release(u, false) }}
+	via := headerVia(response.Header.Get("Via"), r.ProtoMajor, r.ProtoMinor)
+	if via != "" {
+		response.Header.Set("Via", via)
+	}
+	return response, false
 }
 
 func NewDispatcher(h ServiceHandler) http.Handler {
-	rp := &Dispatcher{handler: h}
-	t := h.service.Timeout
-	if t <= 0 {
-		t = DEFAULT_TIMEOUT
+	upstreams := h.upstreams
+	if upstreams == nil {
+		var err error
+		if upstreams, err = newUpstreamPool(h.service); err != nil {
+			Logger.Warn("Failed to parse the upstreams of service `%s`: %+v.", h.service.Subdomain, err)
+			return nil
+		}
+		upstreams.onStateChange = h.onUpstreamStateChange
 	}
-	timeout := time.Duration(t)
 
-	rp.Transport = &http.Transport{
-		Dial:                timeoutDialer(timeout*time.Second, timeout*time.Second),
-		Proxy:               http.ProxyFromEnvironment,
-		TLSHandshakeTimeout: timeout * time.Second,
+	rp := &Dispatcher{
+		handler:   h,
+		upstreams: upstreams,
+		timeouts:  newTimeouts(h.service),
 	}
+
 	//Load middlewares before adding the reverse proxy to the stack.
 	n := negroni.New()
 	n.Use(middleware.NewRequestIdMiddleware())
@@ -103,11 +219,17 @@ func NewDispatcher(h ServiceHandler) http.Handler {
 	}
 
 	rp.proxy = &ReverseProxy{
-		Director:     rp.Director,
-		Transport:    rp,
-		Transformers: h.transformers,
+		Director:            rp.Director,
+		Transport:           rp,
+		FlushInterval:       DEFAULT_FLUSH_INTERVAL * time.Millisecond,
+		Transformers:        h.transformers,
+		StreamTransformers:  h.streamTransformers,
+		MaxBufferedBodySize: h.maxBufferedBodySize,
+	}
+	n.UseHandler(rp)
+	if h.sink != nil {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { serveRecorded(h, n, w, r) })
 	}
-	n.UseHandler(rp.proxy)
 	return n
 }
 
@@ -129,6 +251,82 @@ func Response(r *http.Request, httpResponse *api.HTTPResponse) *http.Response {
 	return w
 }
 
+// writeResponse writes an error response, when there is no http.Response to be proxied.
+func writeResponse(rw http.ResponseWriter, httpResponse *api.HTTPResponse) {
+	out := httpResponse.ToJson()
+	rw.Header().Set("Content-Type", httpResponse.ContentType)
+	rw.Header().Set("Content-Length", strconv.Itoa(len(out)))
+	rw.WriteHeader(httpResponse.StatusCode)
+	rw.Write(out)
+}
+
+// releaseOnClose calls release once, when the body is closed.
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releaseOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// countingReader counts the bytes read from the request body.
+type countingReader struct {
+	io.ReadCloser
+	bytes int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.bytes += int64(n)
+	return n, err
+}
+
+// recordingWriter keeps the status and the number of bytes of the response, along with its latency:
+// the time from the start of the request until its response starts to be written.
+type recordingWriter struct {
+	http.ResponseWriter
+	status  int
+	bytes   int64
+	start   time.Time
+	latency time.Duration
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.latency = time.Since(w.start)
+	}
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.latency = time.Since(w.start)
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// code returns the status of the response, which is 200 when nothing has been written.
+func (w *recordingWriter) code() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *recordingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func headerVia(original string, protoMajor, protoMinor int) string {
 	hostname, err := os.Hostname()
 
@@ -141,18 +339,6 @@ func headerVia(original string, protoMajor, protoMinor int) string {
 	return ""
 }
 
-func timeoutDialer(cTimeout time.Duration, rwTimeout time.Duration) func(net, addr string) (c net.Conn, err error) {
-	return func(netw, addr string) (net.Conn, error) {
-		conn, err := net.DialTimeout(netw, addr, cTimeout)
-		if err != nil {
-			return nil, err
-		}
-
-		conn.SetDeadline(time.Now().Add(rwTimeout))
-		return conn, nil
-	}
-}
-
 func joinSlash(target, path string) string {
 	target = strings.TrimSuffix(target, "/")
 	path = strings.TrimPrefix(path, "/")
@@ -165,6 +351,16 @@ func gatewayTimeout(errorDescription string) *api.HTTPResponse {
 	return &api.HTTPResponse{StatusCode: http.StatusGatewayTimeout, Body: erro}
 }
 
+func serviceUnavailable(errorDescription string) *api.HTTPResponse {
+	erro := errors.ErrorResponse{Type: errors.E_SERVICE_UNAVAILABLE, Description: errorDescription}
+	return &api.HTTPResponse{StatusCode: http.StatusServiceUnavailable, Body: erro}
+}
+
+func badGateway(errorDescription string) *api.HTTPResponse {
+	erro := errors.ErrorResponse{Type: errors.E_BAD_GATEWAY, Description: errorDescription}
+	return &api.HTTPResponse{StatusCode: http.StatusBadGateway, Body: erro}
+}
+
 func internalServerError(errorDescription string) *api.HTTPResponse {
 	erro := errors.ErrorResponse{Type: errors.E_INTERNAL_SERVER_ERROR, Description: errorDescription}
 	return &api.HTTPResponse{StatusCode: http.StatusInternalServerError, Body: erro}
@@ -3,11 +3,17 @@ package gateway
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/account/mem"
+	"github.com/apihub/apihub/analytics"
+	"github.com/apihub/apihub/gateway/middleware"
 	. "gopkg.in/check.v1"
 )
 
@@ -34,3 +40,129 @@ func (s *S) TestServer(c *C) {
 	c.Assert(w.Body.String(), Equals, "OK")
 	c.Assert(w.Header().Get("Via"), Equals, via)
 }
+
+func (s *S) TestServerRecordsAnalytics(c *C) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("Created"))
+	}))
+	defer target.Close()
+
+	store := mem.New()
+	account.Storage(store)
+	store.UpsertOAuthToken(account.OAuthToken{AccessToken: "secret-token", ClientId: "ios", ExpiresAt: time.Now().Add(time.Hour).Format(time.RFC3339)})
+	store.UpsertSubscription(account.Subscription{ClientId: "ios", Service: "test", State: account.SUBSCRIPTION_APPROVED})
+	auth := middleware.NewAuthMiddleware()
+	auth.(middleware.ServiceMiddleware).SetService("test")
+
+	sink := analytics.NewRingBuffer(10)
+	service := ServiceHandler{service: &account.Service{Endpoint: "http://" + target.Listener.Addr().String(), Subdomain: "test"}, middlewares: []middleware.Middleware{auth}, sink: sink}
+	dispatcher := NewDispatcher(service)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "http://test.apihub.dev/users/42/orders", strings.NewReader(`{"item":1}`))
+	r.Header.Set("Authorization", "Bearer secret-token")
+	dispatcher.ServeHTTP(w, r)
+	c.Assert(w.Code, Equals, http.StatusCreated)
+
+	records, _ := sink.Records("test", time.Now().Add(-time.Minute), time.Now().Add(time.Minute))
+	c.Assert(records, HasLen, 1)
+	record := records[0]
+	c.Assert(record.Service, Equals, "test")
+	c.Assert(record.ClientId, Equals, "ios")
+	c.Assert(record.Method, Equals, "POST")
+	c.Assert(record.Path, Equals, "/users/:id/orders")
+	c.Assert(record.Status, Equals, http.StatusCreated)
+	c.Assert(record.BytesIn, Equals, int64(10))
+	c.Assert(record.BytesOut, Equals, int64(7))
+	c.Assert(record.RequestId, Not(Equals), "")
+	c.Assert(record.Latency >= 0, Equals, true)
+}
+
+func (s *S) TestServerRecordsAnalyticsWithoutTrustingTheClientIdHeader(c *C) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	sink := analytics.NewRingBuffer(10)
+	service := ServiceHandler{service: &account.Service{Endpoint: "http://" + target.Listener.Addr().String(), Subdomain: "test"}, sink: sink}
+	dispatcher := NewDispatcher(service)
+
+	r, _ := http.NewRequest("GET", "http://test.apihub.dev/", nil)
+	r.Header.Set(middleware.CLIENT_ID_HEADER, "ios")
+	dispatcher.ServeHTTP(httptest.NewRecorder(), r)
+
+	records, _ := sink.Records("test", time.Now().Add(-time.Minute), time.Now().Add(time.Minute))
+	c.Assert(records, HasLen, 1)
+	c.Assert(records[0].ClientId, Equals, "")
+}
+
+// answerMiddleware writes the response itself, as the cache does, so the request never reaches the upstream.
+type answerMiddleware struct{}
+
+func (answerMiddleware) Configure(cfg string) {}
+
+func (answerMiddleware) ProcessRequest(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	rw.Write([]byte("cached"))
+}
+
+func (s *S) TestServerRecordsAnalyticsOfResponsesWrittenByMiddlewares(c *C) {
+	sink := analytics.NewRingBuffer(10)
+	service := ServiceHandler{service: &account.Service{Endpoint: "http://127.0.0.1:1", Subdomain: "test"}, middlewares: []middleware.Middleware{answerMiddleware{}}, sink: sink}
+	dispatcher := NewDispatcher(service)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://test.apihub.dev/users", nil)
+	dispatcher.ServeHTTP(w, r)
+	c.Assert(w.Body.String(), Equals, "cached")
+
+	records, _ := sink.Records("test", time.Now().Add(-time.Minute), time.Now().Add(time.Minute))
+	c.Assert(records, HasLen, 1)
+	c.Assert(records[0].Path, Equals, "/users")
+	c.Assert(records[0].Status, Equals, http.StatusOK)
+	c.Assert(records[0].BytesOut, Equals, int64(6))
+	c.Assert(records[0].RequestId, Not(Equals), "")
+}
+
+func (s *S) TestServerRecordsLatencyOfEachRequest(c *C) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	sink := analytics.NewRingBuffer(10)
+	service := ServiceHandler{service: &account.Service{Endpoint: "http://" + target.Listener.Addr().String(), Subdomain: "test"}, sink: sink}
+	dispatcher := NewDispatcher(service)
+
+	// The request ids may repeat, which must not mix up the latencies of the requests.
+	for _, path := range []string{"/slow", "/fast"} {
+		r, _ := http.NewRequest("GET", "http://test.apihub.dev"+path, nil)
+		dispatcher.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	records, _ := sink.Records("test", time.Now().Add(-time.Minute), time.Now().Add(time.Minute))
+	c.Assert(records, HasLen, 2)
+	latencies := map[string]int64{}
+	for _, record := range records {
+		latencies[record.Path] = record.Latency
+	}
+	c.Assert(latencies["/slow"] >= 50, Equals, true)
+	c.Assert(latencies["/fast"] < 50, Equals, true)
+}
+
+func (s *S) TestServerRecordsAnalyticsWithoutUpstream(c *C) {
+	sink := analytics.NewRingBuffer(10)
+	service := ServiceHandler{service: &account.Service{Upstreams: []account.Upstream{{Target: "http://127.0.0.1:1", Weight: 1}}, Subdomain: "test"}, sink: sink}
+	dispatcher := NewDispatcher(service)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://test.apihub.dev/", nil)
+	dispatcher.ServeHTTP(w, r)
+
+	records, _ := sink.Records("test", time.Now().Add(-time.Minute), time.Now().Add(time.Minute))
+	c.Assert(records, HasLen, 1)
+	c.Assert(records[0].Status, Equals, w.Code)
+}
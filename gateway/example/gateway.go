@@ -24,5 +24,7 @@ func main() {
 	gw := gateway.New(settings, pubsub)
 	gw.LoadServices(services)
 	gw.RefreshServices()
+	gw.RefreshPlugins()
+	gw.RefreshCertificates()
 	gw.Run()
 }
@@ -7,30 +7,64 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
+	"sort"
 	"sync"
 
 	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/acme"
+	"github.com/apihub/apihub/analytics"
 	"github.com/apihub/apihub/gateway/middleware"
 	"github.com/apihub/apihub/gateway/transformer"
 	. "github.com/apihub/apihub/log"
+	"github.com/apihub/apihub/metrics"
+	"github.com/gorilla/context"
 )
 
 const (
-	DEFAULT_PORT = ":8001"
+	DEFAULT_PORT                   = ":8001"
+	DEFAULT_MAX_BUFFERED_BODY_SIZE = 10 << 20
 )
 
+// Settings of the gateway.
+// `MaxBufferedBodySize` is the maximum size, in bytes, of a response body kept in memory
+// to be transformed. A negative value means there is no limit.
+// `MetricsPort` is the port where the metrics are served on /metrics, apart from the services.
+// The metrics are not served when it is empty.
+// `TLSPort` is the port where the services are served over HTTPS, picking the certificate by the
+// server name. `TLSMinVersion` (1.0, 1.1 or 1.2, default 1.2) and `TLSCipherSuites` restrict the handshake.
+// With `RedirectHTTPS`, the requests received on `Port` are redirected to `TLSPort`.
+// `ClientCertificateSecret` decrypts the keys of the client certificates presented to the upstreams,
+// and must be the same secret the Api encrypts them with.
 type Settings struct {
-	Host string
-	Port string
+	Host                    string
+	Port                    string
+	MaxBufferedBodySize     int64
+	MetricsPort             string
+	TLSPort                 string
+	TLSMinVersion           string
+	TLSCipherSuites         []string
+	RedirectHTTPS           bool
+	ACME                    *ACMESettings
+	ClientCertificateSecret string
 }
 
 type Gateway struct {
 	pubsub       account.PubSub
+	store        account.Storable
 	Settings     *Settings
 	services     map[string]ServiceHandler
+	routes       routingTable
 	transformers transformer.Transformers
 	middlewares  middleware.Middlewares
 	mtx          sync.RWMutex
+
+	streamTransformers transformer.StreamTransformers
+	analytics          analytics.Sink
+
+	certificates *certificateCache
+	acme         *acme.Client
+	solver       *acme.HTTPSolver
 }
 
 func New(config *Settings, pubsub account.PubSub) *Gateway {
@@ -39,12 +73,50 @@ func New(config *Settings, pubsub account.PubSub) *Gateway {
 		Settings:     config,
 		services:     map[string]ServiceHandler{},
 		middlewares:  map[string]func() middleware.Middleware{},
-		transformers: map[string]transformer.Transformer{},
+		transformers: transformer.Default(),
+
+		streamTransformers: map[string]transformer.StreamTransformer{},
+		certificates:       newCertificateCache(),
 	}
+	g.middlewares.Add("cors", middleware.NewCorsMiddleware)
+	g.middlewares.Add("rate_limit", middleware.NewRateLimitMiddleware)
+	g.middlewares.Add("auth", middleware.NewAuthMiddleware)
+	g.middlewares.Add("cache", middleware.NewCacheMiddleware)
 
 	return g
 }
 
+// Allow to set the storage engine used to load the plugins of each service.
+// To be compatible, it is needed to implement the Storable interface.
+func (g *Gateway) Storage(store account.Storable) {
+	g.store = store
+	account.Storage(store)
+}
+
+// Analytics sets the sink which receives a record of every request proxied by the gateway.
+// It must be set before loading the services.
+func (g *Gateway) Analytics(sink analytics.Sink) {
+	g.analytics = sink
+}
+
+// Middleware returns the registry of middlewares available to the services.
+// Custom middlewares must be added before loading the services.
+func (g *Gateway) Middleware() middleware.Middlewares {
+	return g.middlewares
+}
+
+// Transformer returns the registry of transformers available to the services.
+// Custom transformers must be added before loading the services.
+func (g *Gateway) Transformer() transformer.Transformers {
+	return g.transformers
+}
+
+// StreamTransformer returns the registry of stream transformers available to the services.
+// Custom stream transformers must be added before loading the services.
+func (g *Gateway) StreamTransformer() transformer.StreamTransformers {
+	return g.streamTransformers
+}
+
 func (g *Gateway) Run() {
 	Logger.Info("Starting ApiHub Gateway...")
 	g.setDefaults()
@@ -53,23 +125,62 @@ func (g *Gateway) Run() {
 		Logger.Error("Failed to run ApiHub: %+v.", err)
 		panic(err)
 	}
+	if g.Settings.MetricsPort != "" {
+		go g.serveMetrics()
+	}
+	if g.Settings.TLSPort != "" {
+		if err := g.loadACME(); err != nil {
+			Logger.Error("Failed to configure ACME: %+v.", err)
+			panic(err)
+		}
+		config, err := g.tlsConfig()
+		if err != nil {
+			Logger.Error("Failed to configure TLS: %+v.", err)
+			panic(err)
+		}
+		go g.serveTLS(config)
+	}
 	Logger.Info("ApiHub is now ready to accept connections on port %s.", g.Settings.Port)
-	Logger.Error(http.Serve(l, g).Error())
+	server := &http.Server{Handler: g.httpHandler(), ConnState: trackConnection}
+	Logger.Error(server.Serve(l).Error())
+}
+
+func (g *Gateway) serveMetrics() {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	Logger.Info("ApiHub metrics are now available on port %s.", g.Settings.MetricsPort)
+	Logger.Error(http.ListenAndServe(g.Settings.MetricsPort, mux).Error())
 }
 
 // handler is responsible to check if the gateway has a service to respond the request.
+// The routes declared by the services are checked first, falling back to the subdomain.
 func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	g.mtx.RLock()
-	defer g.mtx.RUnlock()
-	subdomain := extractSubdomainFromRequest(r)
-	if serviceH, ok := g.services[subdomain]; ok {
-		serviceH.handler.ServeHTTP(w, r)
+	// The identity verified by the middlewares is kept in the request context until the request is served.
+	defer context.Clear(r)
+	if serviceH, ok := g.serviceHandlerOf(r); ok {
+		serveMeasured(serviceH, w, r)
 		return
 	}
 
 	notFound(w)
 }
 
+// serviceHandlerOf finds the service which responds the request. The lock is only held while looking it up,
+// so the requests being served, such as WebSocket tunnels, do not keep the services from being reloaded.
+func (g *Gateway) serviceHandlerOf(r *http.Request) (ServiceHandler, bool) {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+	if rt, ok := g.routes.match(r); ok {
+		if serviceH, ok := g.services[rt.subdomain]; ok {
+			rt.stripPrefix(r)
+			return serviceH, true
+		}
+	}
+
+	serviceH, ok := g.services[extractSubdomainFromRequest(r)]
+	return serviceH, ok
+}
+
 // LoadServices wraps and loads the services provided.
 func (g *Gateway) LoadServices(services []*account.Service) {
 	if services != nil {
@@ -89,6 +200,7 @@ func (g *Gateway) RefreshServices() {
 	go func() {
 		for msg := range receiverC {
 			if msg != nil {
+				pubsubUpdates.Inc("/services")
 				m, ok := msg.(string)
 				if !ok {
 					Logger.Warn("Failed to convert message to string: %+v.", msg)
@@ -112,31 +224,259 @@ func (g *Gateway) RefreshServices() {
 	}()
 }
 
+// RefreshPlugins rebuilds the middleware chain of a service whenever one of its plugins
+// is added or removed.
+func (g *Gateway) RefreshPlugins() {
+	receiverC := make(chan interface{})
+	done := make(chan bool)
+
+	g.pubsub.Subscribe("/plugins", receiverC, done)
+
+	go func() {
+		for msg := range receiverC {
+			if msg != nil {
+				pubsubUpdates.Inc("/plugins")
+				m, ok := msg.(string)
+				if !ok {
+					Logger.Warn("Failed to convert message to string: %+v.", msg)
+					continue
+				}
+
+				mf := bytes.NewBufferString(m)
+				var plugin account.Plugin
+				if err := json.NewDecoder(mf).Decode(&plugin); err != nil {
+					Logger.Warn("Failed to decode plugin data: %+v.", msg)
+					continue
+				}
+
+				g.mtx.RLock()
+				serviceH, ok := g.services[plugin.Service]
+				g.mtx.RUnlock()
+				if !ok {
+					Logger.Warn("Failed to refresh plugins for a not-found service: %s.", plugin.Service)
+					continue
+				}
+				g.AddService(serviceH.service)
+			}
+		}
+	}()
+}
+
 // Add a new service that will be used for proxying requests.
 func (g *Gateway) AddService(service *account.Service) {
-	h := ServiceHandler{service: service}
+	h := ServiceHandler{service: service, onUpstreamStateChange: g.upstreamStateChanged, maxBufferedBodySize: g.maxBufferedBodySize(), sink: g.analytics}
+	g.loadPlugins(&h)
+	g.loadTransformers(&h)
+	g.loadUpstreamTLS(&h)
+	g.loadUpstreams(&h)
+	g.loadTransports(&h)
+	g.loadRetryAndCircuitBreaker(&h)
 	if h.handler = newProxyHandler(h); h.handler != nil {
 		g.mtx.Lock()
+		if old, ok := g.services[h.service.Subdomain]; ok {
+			old.stopHealthCheck()
+			// The transports still used by the service are held by the new handler, so they are kept.
+			old.releaseTransports()
+		}
 		g.services[h.service.Subdomain] = h
+		g.routes = g.routes.add(h.service)
+		servicesLoaded.Set(float64(len(g.services)))
 		g.mtx.Unlock()
+		h.startHealthCheck()
 		Logger.Info("Service added on ApiHub: %+v.", service)
 		return
 	}
+	h.releaseTransports()
 	Logger.Warn("Failed to add a new service: %+v.", service)
 }
 
 // Remove an existing service from the Gateway.
 func (g *Gateway) RemoveService(service *account.Service) {
 	g.mtx.Lock()
+	if old, ok := g.services[service.Subdomain]; ok {
+		old.stopHealthCheck()
+		old.releaseTransports()
+	}
 	delete(g.services, service.Subdomain)
+	g.routes = g.routes.remove(service.Subdomain)
+	servicesLoaded.Set(float64(len(g.services)))
 	g.mtx.Unlock()
 	Logger.Info("Service removed on ApiHub: %+v.", service)
 }
 
+// loadPlugins instantiates the middlewares subscribed by the service, in the order of pluginOrder.
+// Plugins that do not match any registered middleware are ignored.
+func (g *Gateway) loadPlugins(h *ServiceHandler) {
+	if g.store == nil {
+		return
+	}
+
+	plugins, err := g.store.FindPluginsByService(*h.service)
+	if err != nil {
+		Logger.Warn("Failed to load plugins for service `%s`: %+v.", h.service.Subdomain, err)
+		return
+	}
+
+	sort.Sort(byPluginOrder(plugins))
+	for _, plugin := range plugins {
+		m := g.middlewares.Get(plugin.Name)
+		if m == nil {
+			Logger.Warn("Middleware `%s` not found for service `%s`.", plugin.Name, h.service.Subdomain)
+			continue
+		}
+		h.addMiddleware(m(), plugin)
+	}
+}
+
+// loadTransformers resolves the transformer names declared by the service.
+// Names that do not match any registered transformer are ignored.
+func (g *Gateway) loadTransformers(h *ServiceHandler) {
+	for _, name := range h.service.Transformers {
+		if t := g.transformers.Get(name); t != nil {
+			h.addTransformer(name, t)
+			continue
+		}
+		if t := g.streamTransformers.Get(name); t != nil {
+			h.addStreamTransformer(name, t)
+			continue
+		}
+		Logger.Warn("Transformer `%s` not found for service `%s`.", name, h.service.Subdomain)
+	}
+}
+
+// loadUpstreams builds the pool of upstreams of the service, along with its health checker.
+// Invalid upstreams are reported by the Dispatcher.
+func (g *Gateway) loadUpstreams(h *ServiceHandler) {
+	pool, err := newUpstreamPool(h.service)
+	if err != nil {
+		return
+	}
+	pool.onStateChange = h.onUpstreamStateChange
+	h.upstreams = pool
+
+	if h.service.HealthCheck != nil {
+		h.checker = newHealthChecker(pool, *h.service.HealthCheck)
+		if h.upstreamTLS != nil {
+			dialer := &net.Dialer{Timeout: h.checker.client.Timeout}
+			h.checker.client.Transport = &http.Transport{DialTLS: h.upstreamTLS.dialer(dialer.Dial, h.checker.client.Timeout)}
+		}
+	}
+}
+
+// loadTransports holds the transports to the upstreams of the service, shared with the other services
+// sending requests to the same hosts, until the service is removed or replaced.
+func (g *Gateway) loadTransports(h *ServiceHandler) {
+	if h.upstreams == nil {
+		return
+	}
+	targets := []*url.URL{}
+	for _, u := range h.upstreams.upstreams {
+		targets = append(targets, u.target)
+	}
+	h.transports = transports.acquire(targets, newTimeouts(h.service), h.upstreamTLS)
+}
+
+// loadUpstreamTLS builds the TLS configuration used to reach the upstreams of the service,
+// loading its client certificate from the storage.
+func (g *Gateway) loadUpstreamTLS(h *ServiceHandler) {
+	settings := h.service.UpstreamTLS
+	if settings == nil {
+		return
+	}
+
+	var cert *account.ClientCertificate
+	if settings.ClientCertificate != "" {
+		if g.store == nil {
+			Logger.Warn("Failed to load client certificate `%s` for service `%s`: no storage.", settings.ClientCertificate, h.service.Subdomain)
+		} else if found, err := g.store.FindClientCertificateByNameAndService(settings.ClientCertificate, *h.service); err != nil {
+			Logger.Warn("Failed to load client certificate `%s` for service `%s`: %+v.", settings.ClientCertificate, h.service.Subdomain, err)
+		} else if found.Key, err = found.DecryptKey(g.Settings.ClientCertificateSecret); err != nil {
+			Logger.Warn("Failed to load client certificate `%s` for service `%s`: %+v.", settings.ClientCertificate, h.service.Subdomain, err)
+		} else {
+			cert = &found
+		}
+	}
+
+	tlsc, err := newUpstreamTLS(*settings, cert)
+	if err != nil {
+		Logger.Warn("Failed to load upstream TLS for service `%s`: %+v.", h.service.Subdomain, err)
+		return
+	}
+	h.upstreamTLS = tlsc
+}
+
+// loadRetryAndCircuitBreaker builds the retry policy and the circuit breaker of the service.
+// The circuit breaker is kept when the service is reloaded with the same configuration.
+func (g *Gateway) loadRetryAndCircuitBreaker(h *ServiceHandler) {
+	if h.service.Retry != nil {
+		h.retry = newRetryPolicy(h.service)
+	}
+	if h.service.CircuitBreaker == nil {
+		return
+	}
+
+	g.mtx.RLock()
+	old, ok := g.services[h.service.Subdomain]
+	g.mtx.RUnlock()
+	if ok && old.breaker != nil && *old.service.CircuitBreaker == *h.service.CircuitBreaker {
+		h.breaker = old.breaker
+		return
+	}
+	h.breaker = newCircuitBreaker(h.service)
+	h.breaker.onStateChange = g.circuitBreakerStateChanged
+}
+
+// circuitBreakerStateChanged keeps the state of the circuit breaker in the storage,
+// so it is visible through the services api.
+func (g *Gateway) circuitBreakerStateChanged(state account.CircuitBreakerState) {
+	if state.State == account.CIRCUIT_OPEN {
+		Logger.Warn("The circuit of service `%s` is open until %s.", state.Service, state.OpenUntil)
+	} else {
+		Logger.Info("The circuit of service `%s` is %s.", state.Service, state.State)
+	}
+
+	if g.store != nil {
+		go g.store.UpsertCircuitBreakerState(state)
+	}
+}
+
+// upstreamStateChanged keeps the health of the upstreams in the storage,
+// so it is visible through the services api, and publishes it to let the api notify the teams.
+func (g *Gateway) upstreamStateChanged(state account.UpstreamState) {
+	switch {
+	case state.Healthy:
+		Logger.Info("Upstream `%s` of service `%s` has recovered.", state.Target, state.Service)
+	case state.EjectedUntil != "":
+		Logger.Warn("Upstream `%s` of service `%s` has been ejected until %s.", state.Target, state.Service, state.EjectedUntil)
+	default:
+		Logger.Warn("Upstream `%s` of service `%s` is down.", state.Target, state.Service)
+	}
+
+	if g.store != nil {
+		go g.store.UpsertUpstreamState(state)
+	}
+	if g.pubsub != nil {
+		go g.publishUpstreamState(state)
+	}
+}
+
+func (g *Gateway) publishUpstreamState(state account.UpstreamState) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		Logger.Warn("Failed to encode upstream state: %+v.", err)
+		return
+	}
+
+	name := fmt.Sprintf("/upstreams/%s", state.Service)
+	if err := g.pubsub.Publish(name, data); err != nil {
+		Logger.Warn("Failed to publish upstream state of service `%s`: %+v.", state.Service, err)
+	}
+}
+
 // newProxyHandler returns an instance of Dispatch, which implements http.Handler.
 // It is an instance of reverse proxy that will be available to be used by ApiHub Gateway.
 func newProxyHandler(e ServiceHandler) http.Handler {
-	if h := e.service.Endpoint; h != "" {
+	if len(e.service.Targets()) > 0 {
 		return NewDispatcher(e)
 	}
 	return nil
@@ -148,6 +488,14 @@ func notFound(w http.ResponseWriter) {
 	fmt.Fprintln(w, fmt.Sprintf(`{"error":"not_found","error_description":"%s"}`, ERR_NOT_FOUND))
 }
 
+// maxBufferedBodySize is resolved when each service is added, since services may be loaded before running the gateway.
+func (g *Gateway) maxBufferedBodySize() int64 {
+	if g.Settings.MaxBufferedBodySize == 0 {
+		return DEFAULT_MAX_BUFFERED_BODY_SIZE
+	}
+	return g.Settings.MaxBufferedBodySize
+}
+
 func (g *Gateway) setDefaults() {
 	if g.Settings.Port == "" {
 		g.Settings.Port = DEFAULT_PORT
@@ -2,11 +2,14 @@ package gateway
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"time"
 
 	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/account/mem"
+	"github.com/apihub/apihub/gateway/middleware"
 	. "gopkg.in/check.v1"
 )
 
@@ -139,3 +142,239 @@ func (s *S) TestRemoveService(c *C) {
 	c.Assert(w.Body.String(), Equals, "{\"error\":\"not_found\",\"error_description\":\"The requested resource could not be found but may be available again in the future.\"}\n")
 	c.Assert(w.Code, Equals, http.StatusNotFound)
 }
+
+type headerMiddleware struct {
+	Value string `json:"value"`
+}
+
+func (h *headerMiddleware) Configure(cfg string) {
+	json.Unmarshal([]byte(cfg), h)
+}
+
+func (h *headerMiddleware) ProcessRequest(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	r.Header.Set("X-Plugin", h.Value)
+	next(rw, r)
+}
+
+func (s *S) TestAddServiceLoadsPlugins(c *C) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Plugin")))
+	}))
+	defer target.Close()
+
+	store := mem.New()
+	service := &account.Service{Endpoint: "http://" + target.Listener.Addr().String(), Subdomain: "test"}
+	store.UpsertPlugin(account.Plugin{Name: "header", Service: service.Subdomain, Config: map[string]interface{}{"value": "plugin"}})
+
+	gateway := New(s.Settings, nil)
+	gateway.Storage(store)
+	gateway.Middleware().Add("header", func() middleware.Middleware { return &headerMiddleware{} })
+	gateway.AddService(service)
+
+	w := httptest.NewRecorder()
+	w.Body = new(bytes.Buffer)
+	r, _ := http.NewRequest("GET", "http://test.apihub.dev", nil)
+	gateway.ServeHTTP(w, r)
+
+	c.Assert(w.Code, Equals, http.StatusOK)
+	c.Assert(w.Body.String(), Equals, "plugin")
+}
+
+func (s *S) TestAddServiceRunsAuthMiddlewareFirst(c *C) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Plugin")))
+	}))
+	defer target.Close()
+
+	store := mem.New()
+	service := &account.Service{Endpoint: "http://" + target.Listener.Addr().String(), Subdomain: "test"}
+	store.UpsertPlugin(account.Plugin{Name: "client", Service: service.Subdomain})
+	store.UpsertPlugin(account.Plugin{Name: "auth", Service: service.Subdomain})
+	store.UpsertOAuthToken(account.OAuthToken{AccessToken: "secret-token", ClientId: "ios", ExpiresAt: time.Now().Add(time.Hour).Format(time.RFC3339)})
+	store.UpsertSubscription(account.Subscription{ClientId: "ios", Service: service.Subdomain, State: account.SUBSCRIPTION_APPROVED})
+
+	gateway := New(s.Settings, nil)
+	gateway.Storage(store)
+	// Sees the client identified by the auth middleware.
+	gateway.Middleware().Add("client", func() middleware.Middleware {
+		return &headerFuncMiddleware{func(r *http.Request) string { return r.Header.Get(middleware.CLIENT_ID_HEADER) }}
+	})
+	gateway.AddService(service)
+
+	w := httptest.NewRecorder()
+	w.Body = new(bytes.Buffer)
+	r, _ := http.NewRequest("GET", "http://test.apihub.dev", nil)
+	r.Header.Set("Authorization", "Bearer secret-token")
+	gateway.ServeHTTP(w, r)
+
+	c.Assert(w.Code, Equals, http.StatusOK)
+	c.Assert(w.Body.String(), Equals, "ios")
+}
+
+func (s *S) TestAddServiceRunsMiddlewaresInAFixedOrder(c *C) {
+	store := mem.New()
+	service := &account.Service{Endpoint: "http://example.org", Subdomain: "test"}
+	for _, name := range []string{"header", "cache", "rate_limit", "cors", "auth", "client"} {
+		store.UpsertPlugin(account.Plugin{Name: name, Service: service.Subdomain})
+	}
+
+	gateway := New(s.Settings, nil)
+	gateway.Storage(store)
+	gateway.Middleware().Add("header", func() middleware.Middleware { return &headerMiddleware{} })
+	gateway.Middleware().Add("client", func() middleware.Middleware { return &headerFuncMiddleware{} })
+
+	// The order does not depend on the storage, so it is the same whenever the service is loaded again.
+	for i := 0; i < 10; i++ {
+		gateway.AddService(service)
+		m := gateway.services[service.Subdomain].middlewares
+		c.Assert(m, HasLen, 6)
+		c.Assert(m[0], FitsTypeOf, &middleware.Auth{})
+		c.Assert(m[1], FitsTypeOf, &middleware.Cors{})
+		c.Assert(m[2], FitsTypeOf, &middleware.RateLimit{})
+		c.Assert(m[3], FitsTypeOf, &middleware.Cache{})
+		c.Assert(m[4], FitsTypeOf, &headerFuncMiddleware{})
+		c.Assert(m[5], FitsTypeOf, &headerMiddleware{})
+	}
+}
+
+type headerFuncMiddleware struct {
+	value func(r *http.Request) string
+}
+
+func (h *headerFuncMiddleware) Configure(cfg string) {}
+
+func (h *headerFuncMiddleware) ProcessRequest(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	r.Header.Set("X-Plugin", h.value(r))
+	next(rw, r)
+}
+
+func (s *S) TestAddServiceLoadsTransformers(c *C) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	defer target.Close()
+
+	service := &account.Service{Endpoint: "http://" + target.Listener.Addr().String(), Subdomain: "test", Transformers: []string{"Foo", "not-found"}}
+	gateway := New(s.Settings, nil)
+	gateway.Transformer().Add("Foo", func(r *http.Request, w *http.Response, body *bytes.Buffer) {
+		body.Reset()
+		body.WriteString("Foo")
+	})
+	gateway.AddService(service)
+
+	w := httptest.NewRecorder()
+	w.Body = new(bytes.Buffer)
+	r, _ := http.NewRequest("GET", "http://test.apihub.dev", nil)
+	gateway.ServeHTTP(w, r)
+
+	c.Assert(w.Code, Equals, http.StatusOK)
+	c.Assert(w.Body.String(), Equals, "Foo")
+}
+
+func (s *S) TestGatewayPathPrefixRoute(c *C) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer target.Close()
+
+	service := &account.Service{
+		Endpoint:  "http://" + target.Listener.Addr().String() + "/v1",
+		Subdomain: "billing",
+		Routes:    []account.Route{{Host: "api.example.org", PathPrefix: "/billing", StripPrefix: true}},
+	}
+	gateway := New(s.Settings, nil)
+	gateway.AddService(service)
+
+	w := httptest.NewRecorder()
+	w.Body = new(bytes.Buffer)
+	r, _ := http.NewRequest("GET", "http://api.example.org/billing/invoices", nil)
+	gateway.ServeHTTP(w, r)
+
+	c.Assert(w.Code, Equals, http.StatusOK)
+	c.Assert(w.Body.String(), Equals, "/v1/invoices")
+}
+
+func (s *S) TestGatewayCustomDomainRoute(c *C) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	defer target.Close()
+
+	service := &account.Service{
+		Endpoint:  "http://" + target.Listener.Addr().String(),
+		Subdomain: "custom",
+		Routes:    []account.Route{{Host: "www.custom.org"}},
+	}
+	gateway := New(s.Settings, nil)
+	gateway.AddService(service)
+
+	w := httptest.NewRecorder()
+	w.Body = new(bytes.Buffer)
+	r, _ := http.NewRequest("GET", "http://www.custom.org/", nil)
+	gateway.ServeHTTP(w, r)
+	c.Assert(w.Code, Equals, http.StatusOK)
+	c.Assert(w.Body.String(), Equals, "OK")
+
+	gateway.RemoveService(service)
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "http://www.custom.org/", nil)
+	gateway.ServeHTTP(w, r)
+	c.Assert(w.Code, Equals, http.StatusNotFound)
+}
+
+func (s *S) TestGatewayMultipleUpstreams(c *C) {
+	first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first"))
+	}))
+	defer first.Close()
+	second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("second"))
+	}))
+	defer second.Close()
+
+	service := &account.Service{
+		Subdomain: "test",
+		Upstreams: []account.Upstream{{Target: first.URL}, {Target: second.URL}},
+	}
+	gateway := New(s.Settings, nil)
+	gateway.AddService(service)
+
+	bodies := map[string]int{}
+	for i := 0; i < 4; i++ {
+		w := httptest.NewRecorder()
+		w.Body = new(bytes.Buffer)
+		r, _ := http.NewRequest("GET", "http://test.apihub.dev", nil)
+		gateway.ServeHTTP(w, r)
+		c.Assert(w.Code, Equals, http.StatusOK)
+		bodies[w.Body.String()]++
+	}
+	c.Assert(bodies["first"], Equals, 2)
+	c.Assert(bodies["second"], Equals, 2)
+}
+
+func (s *S) TestGatewayEjectsFailingUpstream(c *C) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	defer target.Close()
+
+	store := mem.New()
+	service := &account.Service{
+		Subdomain:    "test",
+		Upstreams:    []account.Upstream{{Target: "http://invalidurl"}, {Target: target.URL}},
+		LoadBalancer: &account.LoadBalancer{MaxFails: 1, Cooldown: 60},
+	}
+	gateway := New(s.Settings, nil)
+	gateway.Storage(store)
+	gateway.AddService(service)
+
+	codes := []int{}
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		w.Body = new(bytes.Buffer)
+		r, _ := http.NewRequest("GET", "http://test.apihub.dev", nil)
+		gateway.ServeHTTP(w, r)
+		codes = append(codes, w.Code)
+	}
+	c.Assert(codes, DeepEquals, []int{http.StatusInternalServerError, http.StatusOK, http.StatusOK})
+}
@@ -0,0 +1,149 @@
+package gateway
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	. "github.com/apihub/apihub/log"
+)
+
+const (
+	DEFAULT_HEALTH_CHECK_INTERVAL = 10
+	DEFAULT_HEALTH_CHECK_TIMEOUT  = 2
+	DEFAULT_HEALTHY_THRESHOLD     = 2
+	DEFAULT_UNHEALTHY_THRESHOLD   = 3
+	HEALTH_CHECK_USER_AGENT       = "ApiHub-HealthCheck"
+)
+
+// probe keeps the consecutive results of the health checks of an upstream.
+type probe struct {
+	successes int
+	failures  int
+}
+
+// healthChecker actively probes the upstreams of a service, marking them down or up
+// in the pool according to the thresholds of the service.
+type healthChecker struct {
+	pool               *upstreamPool
+	path               string
+	interval           time.Duration
+	expectedStatus     int
+	healthyThreshold   int
+	unhealthyThreshold int
+	client             *http.Client
+	// probes is filled when the checker is created, so it is only read concurrently.
+	probes map[*upstream]*probe
+	done   chan bool
+	once   sync.Once
+}
+
+func newHealthChecker(pool *upstreamPool, hc account.HealthCheck) *healthChecker {
+	if hc.Interval <= 0 {
+		hc.Interval = DEFAULT_HEALTH_CHECK_INTERVAL
+	}
+	if hc.Timeout <= 0 {
+		hc.Timeout = DEFAULT_HEALTH_CHECK_TIMEOUT
+	}
+	if hc.ExpectedStatus == 0 {
+		hc.ExpectedStatus = http.StatusOK
+	}
+	if hc.HealthyThreshold <= 0 {
+		hc.HealthyThreshold = DEFAULT_HEALTHY_THRESHOLD
+	}
+	if hc.UnhealthyThreshold <= 0 {
+		hc.UnhealthyThreshold = DEFAULT_UNHEALTHY_THRESHOLD
+	}
+
+	checker := &healthChecker{
+		pool:               pool,
+		path:               hc.Path,
+		interval:           time.Duration(hc.Interval) * time.Second,
+		expectedStatus:     hc.ExpectedStatus,
+		healthyThreshold:   hc.HealthyThreshold,
+		unhealthyThreshold: hc.UnhealthyThreshold,
+		client:             &http.Client{Timeout: time.Duration(hc.Timeout) * time.Second},
+		probes:             make(map[*upstream]*probe),
+		done:               make(chan bool),
+	}
+	for _, u := range pool.upstreams {
+		checker.probes[u] = &probe{}
+	}
+	return checker
+}
+
+// start probes the upstreams in background, until the checker is stopped.
+func (hc *healthChecker) start() {
+	go func() {
+		ticker := time.NewTicker(hc.interval)
+		defer ticker.Stop()
+
+		hc.check()
+		for {
+			select {
+			case <-ticker.C:
+				hc.check()
+			case <-hc.done:
+				return
+			}
+		}
+	}()
+}
+
+func (hc *healthChecker) stop() {
+	hc.once.Do(func() { close(hc.done) })
+}
+
+// check probes all the upstreams at once and waits for the results.
+func (hc *healthChecker) check() {
+	var wg sync.WaitGroup
+	for _, u := range hc.pool.upstreams {
+		wg.Add(1)
+		go func(u *upstream) {
+			defer wg.Done()
+			hc.report(u, hc.probe(u))
+		}(u)
+	}
+	wg.Wait()
+}
+
+// probe sends a request to the health check path on the host of the upstream,
+// returning whether it has responded with the expected status.
+func (hc *healthChecker) probe(u *upstream) bool {
+	req, err := http.NewRequest("GET", u.target.Scheme+"://"+u.target.Host+hc.path, nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("User-Agent", HEALTH_CHECK_USER_AGENT)
+
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		Logger.Debug("Health check failed for upstream `%s` of service `%s`: %+v.", u.target, hc.pool.service, err)
+		return false
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode == hc.expectedStatus
+}
+
+// report marks the upstream down or up once the thresholds are reached.
+func (hc *healthChecker) report(u *upstream, healthy bool) {
+	p := hc.probes[u]
+	if healthy {
+		p.failures = 0
+		p.successes++
+		if p.successes >= hc.healthyThreshold {
+			hc.pool.setDown(u, false)
+		}
+		return
+	}
+
+	p.successes = 0
+	p.failures++
+	if p.failures >= hc.unhealthyThreshold {
+		hc.pool.setDown(u, true)
+	}
+}
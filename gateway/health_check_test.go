@@ -0,0 +1,89 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+
+	"github.com/apihub/apihub/account"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestHealthCheckerMarksUpstreamDown(c *C) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(r.URL.Path, Equals, "/health")
+		c.Assert(r.Header.Get("User-Agent"), Equals, HEALTH_CHECK_USER_AGENT)
+	}))
+	defer healthy.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	pool := newTestPool(c, nil, account.Upstream{Target: healthy.URL}, account.Upstream{Target: failing.URL})
+	states := []account.UpstreamState{}
+	pool.onStateChange = func(state account.UpstreamState) { states = append(states, state) }
+	checker := newHealthChecker(pool, account.HealthCheck{Path: "/health", UnhealthyThreshold: 2})
+
+	checker.check()
+	c.Assert(len(states), Equals, 0)
+	checker.check()
+	c.Assert(len(states), Equals, 1)
+	c.Assert(states[0].Target, Equals, failing.URL)
+	c.Assert(states[0].Healthy, Equals, false)
+
+	r, _ := http.NewRequest("GET", "http://test.apihub.dev", nil)
+	for i := 0; i < 4; i++ {
+		u, err := pool.next(r)
+		c.Assert(err, IsNil)
+		c.Assert(u.target.String(), Equals, healthy.URL)
+	}
+}
+
+func (s *S) TestHealthCheckerMarksUpstreamUp(c *C) {
+	var status int32 = http.StatusInternalServerError
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer target.Close()
+
+	pool := newTestPool(c, nil, account.Upstream{Target: target.URL})
+	checker := newHealthChecker(pool, account.HealthCheck{Path: "/health", ExpectedStatus: http.StatusNoContent, HealthyThreshold: 2, UnhealthyThreshold: 1})
+	r, _ := http.NewRequest("GET", "http://test.apihub.dev", nil)
+
+	checker.check()
+	_, err := pool.next(r)
+	c.Assert(err, NotNil)
+
+	atomic.StoreInt32(&status, http.StatusNoContent)
+	checker.check()
+	_, err = pool.next(r)
+	c.Assert(err, NotNil)
+	checker.check()
+	u, err := pool.next(r)
+	c.Assert(err, IsNil)
+	c.Assert(u.state("test").Healthy, Equals, true)
+}
+
+func (s *S) TestAddServiceStartsHealthCheck(c *C) {
+	checked := make(chan bool, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			select {
+			case checked <- true:
+			default:
+			}
+		}
+	}))
+	defer target.Close()
+
+	service := &account.Service{Endpoint: target.URL, Subdomain: "test", HealthCheck: &account.HealthCheck{Path: "/health"}}
+	gateway := New(s.Settings, nil)
+	gateway.AddService(service)
+	<-checked
+
+	checker := gateway.services["test"].checker
+	gateway.RemoveService(service)
+	_, open := <-checker.done
+	c.Assert(open, Equals, false)
+}
@@ -0,0 +1,51 @@
+package gateway
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apihub/apihub/metrics"
+)
+
+var (
+	requestsTotal     = metrics.NewCounter("apihub_gateway_requests_total", "Number of requests handled by the gateway.", "service", "code")
+	requestDuration   = metrics.NewHistogram("apihub_gateway_request_duration_seconds", "Time taken to handle the requests, in seconds.", nil, "service", "code")
+	upstreamErrors    = metrics.NewCounter("apihub_gateway_upstream_errors_total", "Number of requests which failed to reach the upstream, by type (error, timeout or circuit_open).", "service", "type")
+	retriesTotal      = metrics.NewCounter("apihub_gateway_retries_total", "Number of requests sent again to the upstreams.", "service")
+	activeConnections = metrics.NewGauge("apihub_gateway_active_connections", "Number of open client connections.")
+	servicesLoaded    = metrics.NewGauge("apihub_gateway_services", "Number of services loaded on the gateway.")
+	pubsubUpdates     = metrics.NewCounter("apihub_gateway_pubsub_updates_total", "Number of updates received through the pubsub, by channel.", "channel")
+)
+
+func init() {
+	metrics.MustRegister(requestsTotal, requestDuration, upstreamErrors, retriesTotal, activeConnections, servicesLoaded, pubsubUpdates)
+}
+
+// serveMeasured serves the request of the service, counting it by status code.
+// Upgraded connections, such as WebSocket, are not counted, since they last as long as the client wants.
+func serveMeasured(serviceH ServiceHandler, w http.ResponseWriter, r *http.Request) {
+	if isUpgrade(r) {
+		serviceH.handler.ServeHTTP(w, r)
+		return
+	}
+
+	start := time.Now()
+	rw := &recordingWriter{ResponseWriter: w}
+	serviceH.handler.ServeHTTP(rw, r)
+
+	code := strconv.Itoa(rw.code())
+	requestsTotal.Inc(serviceH.service.Subdomain, code)
+	requestDuration.Observe(time.Since(start).Seconds(), serviceH.service.Subdomain, code)
+}
+
+// trackConnection keeps the number of open client connections.
+func trackConnection(conn net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		activeConnections.Inc()
+	case http.StateHijacked, http.StateClosed:
+		activeConnections.Dec()
+	}
+}
@@ -0,0 +1,76 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/metrics"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestGatewayCountsRequests(c *C) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer target.Close()
+
+	gateway := New(s.Settings, nil)
+	gateway.AddService(&account.Service{Endpoint: "http://" + target.Listener.Addr().String(), Subdomain: "counted"})
+	requests := requestsTotal.Value("counted", "201")
+	observations := requestDuration.Count("counted", "201")
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "http://counted.apihub.dev", nil)
+	gateway.ServeHTTP(w, r)
+
+	c.Assert(w.Code, Equals, http.StatusCreated)
+	c.Assert(requestsTotal.Value("counted", "201"), Equals, requests+1)
+	c.Assert(requestDuration.Count("counted", "201"), Equals, observations+1)
+}
+
+func (s *S) TestGatewayCountsUpstreamErrors(c *C) {
+	gateway := New(s.Settings, nil)
+	gateway.AddService(&account.Service{Endpoint: "http://invalidurl", Subdomain: "failing"})
+	errors := upstreamErrors.Value("failing", "error")
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://failing.apihub.dev", nil)
+	gateway.ServeHTTP(w, r)
+
+	c.Assert(w.Code, Equals, http.StatusInternalServerError)
+	c.Assert(upstreamErrors.Value("failing", "error"), Equals, errors+1)
+	c.Assert(requestsTotal.Value("failing", "500"), Not(Equals), float64(0))
+}
+
+func (s *S) TestGatewayCountsServices(c *C) {
+	gateway := New(s.Settings, nil)
+	service := &account.Service{Endpoint: "http://example.org", Subdomain: "test"}
+	gateway.AddService(service)
+	c.Assert(servicesLoaded.Value(), Equals, float64(1))
+
+	gateway.RemoveService(service)
+	c.Assert(servicesLoaded.Value(), Equals, float64(0))
+}
+
+func (s *S) TestTrackConnection(c *C) {
+	connections := activeConnections.Value()
+	trackConnection(nil, http.StateNew)
+	trackConnection(nil, http.StateActive)
+	c.Assert(activeConnections.Value(), Equals, connections+1)
+
+	trackConnection(nil, http.StateClosed)
+	c.Assert(activeConnections.Value(), Equals, connections)
+}
+
+func (s *S) TestGatewayMetrics(c *C) {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/metrics", nil)
+	metrics.Handler().ServeHTTP(w, r)
+
+	for _, name := range []string{"apihub_gateway_requests_total", "apihub_gateway_request_duration_seconds", "apihub_gateway_upstream_errors_total",
+		"apihub_gateway_active_connections", "apihub_gateway_services", "apihub_gateway_pubsub_updates_total"} {
+		c.Assert(strings.Contains(w.Body.String(), "# TYPE "+name+" "), Equals, true)
+	}
+}
@@ -0,0 +1,234 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/db"
+	"github.com/apihub/apihub/errors"
+	"github.com/gorilla/context"
+)
+
+const (
+	CLIENT_ID_HEADER = "X-ApiHub-Client-Id"
+	USER_HEADER      = "X-ApiHub-User"
+	SCOPES_HEADER    = "X-ApiHub-Scopes"
+	PLAN_HEADER      = "X-ApiHub-Plan"
+	API_KEY_HEADER   = "X-Api-Key"
+	AUTH_REALM       = "ApiHub"
+
+	// AUTH_CACHE_TTL is how long each gateway instance keeps the tokens, subscriptions and plans it has looked up,
+	// so they are not read from the database on every request.
+	AUTH_CACHE_TTL = 10 * time.Second
+)
+
+// identityHeaders are set by the gateway only, so they are removed from the incoming requests.
+var identityHeaders = []string{CLIENT_ID_HEADER, USER_HEADER, SCOPES_HEADER, PLAN_HEADER}
+
+type contextKey int
+
+// identityKey keeps the identity verified by the Auth middleware in the request context,
+// which is cleared by the gateway once the request is served.
+const identityKey contextKey = 0
+
+// Auth validates the access tokens issued to the Apps before proxying the request,
+// forwarding the identity of the client to the upstream.
+// When `AllowClientSecret` is set, Apps may also authenticate with their client id and secret,
+// either through HTTP Basic authentication or the client_id and client_secret parameters.
+// When `AllowApiKeys` is set, Apps may also send one of their API keys in the X-Api-Key header.
+// Only Apps with an approved subscription to the service are let through, within the limits of their plan.
+type Auth struct {
+	Scopes            []string `json:"scopes"`
+	AllowClientSecret bool     `json:"allow_client_secret"`
+	AllowApiKeys      bool     `json:"allow_api_keys"`
+	service           string
+	counter           Counter
+	// cacheTTL is how long the tokens, subscriptions and plans looked up are kept, if at all.
+	cacheTTL time.Duration
+}
+
+type identity struct {
+	clientId string
+	// credential is what the client has authenticated with: the access token, the id of the API key or the client id.
+	credential string
+	user       string
+	scopes     []string
+	// all is set when the client authenticates with its secret or an API key, which are not restricted to any scope.
+	all bool
+	// apiKey is set when the client authenticates with an API key, which may be restricted to some services and methods.
+	apiKey *account.ApiKey
+}
+
+func NewAuthMiddleware() Middleware {
+	return &Auth{counter: redisCounter{}, cacheTTL: AUTH_CACHE_TTL}
+}
+
+func (a *Auth) Configure(cfg string) {
+	json.Unmarshal([]byte(cfg), a)
+}
+
+func (a *Auth) SetService(subdomain string) {
+	a.service = subdomain
+}
+
+func (a *Auth) ProcessRequest(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	for _, h := range identityHeaders {
+		r.Header.Del(h)
+	}
+
+	id, err := a.authenticate(r)
+	if err != nil {
+		rw.Header().Set("WWW-Authenticate", `Bearer realm="`+AUTH_REALM+`"`)
+		writeError(rw, http.StatusUnauthorized, errors.E_UNAUTHORIZED_REQUEST, err)
+		return
+	}
+	subscription, ok := a.subscription(id.clientId)
+	if !ok {
+		writeError(rw, http.StatusForbidden, errors.E_FORBIDDEN_REQUEST, errors.ErrSubscriptionNotApproved)
+		return
+	}
+	if id.apiKey != nil && !id.apiKey.Allows(a.service, r.Method) {
+		writeError(rw, http.StatusForbidden, errors.E_FORBIDDEN_REQUEST, errors.ErrApiKeyNotAllowed)
+		return
+	}
+	if !id.allowed(a.Scopes) {
+		writeError(rw, http.StatusForbidden, errors.E_FORBIDDEN_REQUEST, errors.ErrOAuthInsufficientScope)
+		return
+	}
+	if subscription.Plan != "" && !a.enforcePlan(rw, r, subscription) {
+		return
+	}
+
+	// The credentials are not sent to the upstream.
+	r.Header.Del("Authorization")
+	r.Header.Del(API_KEY_HEADER)
+	if query := r.URL.Query(); a.AllowClientSecret && query.Get("client_secret") != "" {
+		query.Del("client_id")
+		query.Del("client_secret")
+		r.URL.RawQuery = query.Encode()
+	}
+	r.Header.Set(CLIENT_ID_HEADER, id.clientId)
+	if id.user != "" {
+		r.Header.Set(USER_HEADER, id.user)
+	}
+	if len(id.scopes) > 0 {
+		r.Header.Set(SCOPES_HEADER, strings.Join(id.scopes, " "))
+	}
+	if subscription.Plan != "" {
+		r.Header.Set(PLAN_HEADER, subscription.Plan)
+	}
+	context.Set(r, identityKey, id)
+	next(rw, r)
+}
+
+// authenticated returns the identity verified by the Auth middleware, if any.
+func authenticated(r *http.Request) (*identity, bool) {
+	id, ok := context.GetOk(r, identityKey)
+	if !ok {
+		return nil, false
+	}
+	return id.(*identity), true
+}
+
+// ClientId returns the id of the client verified by the Auth middleware,
+// or an empty string when the request has not been authenticated.
+func ClientId(r *http.Request) string {
+	if id, ok := authenticated(r); ok {
+		return id.clientId
+	}
+	return ""
+}
+
+func (a *Auth) authenticate(r *http.Request) (*identity, error) {
+	if authorization := r.Header.Get("Authorization"); strings.HasPrefix(authorization, account.OAUTH_TOKEN_TYPE+" ") {
+		accessToken := strings.TrimPrefix(authorization, account.OAUTH_TOKEN_TYPE+" ")
+		value, err := a.cached("gateway:token:"+accessToken, func() (interface{}, error) {
+			return account.FindOAuthTokenByAccessToken(accessToken)
+		})
+		if err != nil {
+			return nil, errors.ErrOAuthInvalidToken
+		}
+		token := value.(*account.OAuthToken)
+		if token.Expired() {
+			return nil, errors.ErrOAuthInvalidToken
+		}
+		return &identity{clientId: token.ClientId, credential: token.AccessToken, user: token.User, scopes: strings.Fields(token.Scope)}, nil
+	}
+
+	if key := r.Header.Get(API_KEY_HEADER); key != "" && a.AllowApiKeys {
+		apiKey, err := account.AuthenticateApiKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return &identity{clientId: apiKey.ClientId, credential: apiKey.Id, all: true, apiKey: apiKey}, nil
+	}
+
+	if a.AllowClientSecret {
+		clientId, secret, ok := r.BasicAuth()
+		if !ok {
+			clientId, secret = r.URL.Query().Get("client_id"), r.URL.Query().Get("client_secret")
+		}
+		if clientId != "" {
+			app, err := account.FindAppByClientId(clientId)
+			if err != nil || !app.Authenticate(secret) {
+				return nil, errors.ErrOAuthInvalidClient
+			}
+			return &identity{clientId: app.ClientId, credential: app.ClientId, all: true}, nil
+		}
+	}
+
+	return nil, errors.ErrOAuthMissingToken
+}
+
+// subscription returns the subscription of the client to the service, as long as it is approved.
+func (a *Auth) subscription(clientId string) (*account.Subscription, bool) {
+	value, err := a.cached("gateway:subscription:"+a.service+":"+clientId, func() (interface{}, error) {
+		return account.FindSubscription(clientId, a.service)
+	})
+	if err != nil {
+		return nil, false
+	}
+	subscription := value.(*account.Subscription)
+	if !subscription.Approved() {
+		return nil, false
+	}
+	return subscription, true
+}
+
+// cached returns the value kept at key in the memory of the gateway instance,
+// looking it up when it is missing or has expired. Lookups which fail are not cached.
+func (a *Auth) cached(key string, lookup func() (interface{}, error)) (interface{}, error) {
+	if a.cacheTTL <= 0 {
+		return lookup()
+	}
+	if item := db.Cache.Get(key); item != nil && !item.Expired() && item.Value() != nil {
+		return item.Value(), nil
+	}
+	value, err := lookup()
+	if err != nil {
+		return nil, err
+	}
+	db.Cache.Set(key, value, a.cacheTTL)
+	return value, nil
+}
+
+// allowed reports whether the identity has all the scopes required.
+func (id *identity) allowed(required []string) bool {
+	if id.all {
+		return true
+	}
+
+	scopes := map[string]bool{}
+	for _, s := range id.scopes {
+		scopes[s] = true
+	}
+	for _, s := range required {
+		if !scopes[s] {
+			return false
+		}
+	}
+	return true
+}
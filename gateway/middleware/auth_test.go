@@ -0,0 +1,330 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/account/mem"
+	apiErrors "github.com/apihub/apihub/errors"
+	. "gopkg.in/check.v1"
+)
+
+func setUpAuth(cfg string) (*Auth, *mem.Mem) {
+	store := mem.New()
+	account.Storage(store)
+	store.UpsertApp(account.App{ClientId: "ios", ClientSecret: "secret", Name: "Ios App"})
+	store.UpsertSubscription(account.Subscription{ClientId: "ios", Service: "apihub", State: account.SUBSCRIPTION_APPROVED})
+
+	a := &Auth{counter: &memoryCounter{hits: map[string]int64{}}}
+	a.Configure(cfg)
+	a.SetService("apihub")
+	return a, store
+}
+
+func sendAuthenticated(a *Auth, req *http.Request) (*httptest.ResponseRecorder, *http.Request) {
+	var forwarded *http.Request
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r
+		w.WriteHeader(http.StatusOK)
+	})
+	res := httptest.NewRecorder()
+	a.ProcessRequest(res, req, next)
+	return res, forwarded
+}
+
+func (s *S) TestAuthWithAccessToken(c *C) {
+	a, _ := setUpAuth(`{}`)
+	token := account.OAuthToken{ClientId: "ios", User: "alice@example.org", Scope: "read write"}
+	token.Create(true)
+
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	res, forwarded := sendAuthenticated(a, req)
+	c.Assert(res.Code, Equals, http.StatusOK)
+	c.Assert(forwarded.Header.Get(CLIENT_ID_HEADER), Equals, "ios")
+	c.Assert(forwarded.Header.Get(USER_HEADER), Equals, "alice@example.org")
+	c.Assert(forwarded.Header.Get(SCOPES_HEADER), Equals, "read write")
+	c.Assert(forwarded.Header.Get("Authorization"), Equals, "")
+}
+
+func (s *S) TestAuthWithInvalidAccessToken(c *C) {
+	a, _ := setUpAuth(`{}`)
+
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+	req.Header.Set("Authorization", "Bearer invalid")
+	res, forwarded := sendAuthenticated(a, req)
+	c.Assert(forwarded, IsNil)
+	c.Assert(res.Code, Equals, http.StatusUnauthorized)
+	c.Assert(res.Header().Get("WWW-Authenticate"), Equals, `Bearer realm="ApiHub"`)
+	c.Assert(res.Header().Get("Content-Type"), Equals, "application/json")
+
+	var body apiErrors.ErrorResponse
+	json.Unmarshal(res.Body.Bytes(), &body)
+	c.Assert(body, DeepEquals, apiErrors.ErrorResponse{Type: apiErrors.E_UNAUTHORIZED_REQUEST, Description: apiErrors.ErrOAuthInvalidToken.Error()})
+}
+
+func (s *S) TestAuthWithExpiredAccessToken(c *C) {
+	a, store := setUpAuth(`{}`)
+	store.UpsertOAuthToken(account.OAuthToken{AccessToken: "expired", ClientId: "ios", ExpiresAt: "2015-05-16T13:40:44Z"})
+
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+	req.Header.Set("Authorization", "Bearer expired")
+	res, _ := sendAuthenticated(a, req)
+	c.Assert(res.Code, Equals, http.StatusUnauthorized)
+}
+
+func (s *S) TestAuthWithoutCredentials(c *C) {
+	a, _ := setUpAuth(`{}`)
+
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+	res, _ := sendAuthenticated(a, req)
+	c.Assert(res.Code, Equals, http.StatusUnauthorized)
+	c.Assert(res.Body.String(), Equals, `{"error":"unauthorized_access","error_description":"The request must carry an access token or the client credentials."}`)
+}
+
+func (s *S) TestAuthRemovesIdentityHeaders(c *C) {
+	a, _ := setUpAuth(`{}`)
+	token := account.OAuthToken{ClientId: "ios"}
+	token.Create(false)
+
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	req.Header.Set(USER_HEADER, "admin@example.org")
+	req.Header.Set(SCOPES_HEADER, "admin")
+	_, forwarded := sendAuthenticated(a, req)
+	c.Assert(forwarded.Header.Get(CLIENT_ID_HEADER), Equals, "ios")
+	c.Assert(forwarded.Header.Get(USER_HEADER), Equals, "")
+	c.Assert(forwarded.Header.Get(SCOPES_HEADER), Equals, "")
+}
+
+func (s *S) TestAuthWithRequiredScopes(c *C) {
+	a, _ := setUpAuth(`{"scopes": ["write"]}`)
+	token := account.OAuthToken{ClientId: "ios", Scope: "read"}
+	token.Create(false)
+
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	res, _ := sendAuthenticated(a, req)
+	c.Assert(res.Code, Equals, http.StatusForbidden)
+	c.Assert(res.Body.String(), Equals, `{"error":"access_denied","error_description":"The access token does not have the scopes required by this service."}`)
+}
+
+func (s *S) TestAuthWithClientSecret(c *C) {
+	a, _ := setUpAuth(`{"allow_client_secret": true, "scopes": ["write"]}`)
+
+	req, _ := http.NewRequest("GET", "http://apihub.example.org/?client_id=ios&client_secret=secret&page=2", nil)
+	res, forwarded := sendAuthenticated(a, req)
+	c.Assert(res.Code, Equals, http.StatusOK)
+	c.Assert(forwarded.Header.Get(CLIENT_ID_HEADER), Equals, "ios")
+	c.Assert(forwarded.URL.RawQuery, Equals, "page=2")
+
+	req, _ = http.NewRequest("GET", "http://apihub.example.org", nil)
+	req.SetBasicAuth("ios", "secret")
+	res, forwarded = sendAuthenticated(a, req)
+	c.Assert(res.Code, Equals, http.StatusOK)
+	c.Assert(forwarded.Header.Get(CLIENT_ID_HEADER), Equals, "ios")
+	c.Assert(forwarded.Header.Get("Authorization"), Equals, "")
+}
+
+func (s *S) TestAuthWithInvalidClientSecret(c *C) {
+	a, _ := setUpAuth(`{"allow_client_secret": true}`)
+
+	req, _ := http.NewRequest("GET", "http://apihub.example.org/?client_id=ios&client_secret=wrong", nil)
+	res, _ := sendAuthenticated(a, req)
+	c.Assert(res.Code, Equals, http.StatusUnauthorized)
+	c.Assert(res.Body.String(), Equals, `{"error":"unauthorized_access","error_description":"Client authentication failed."}`)
+}
+
+func (s *S) TestAuthWithClientSecretNotAllowed(c *C) {
+	a, _ := setUpAuth(`{}`)
+
+	req, _ := http.NewRequest("GET", "http://apihub.example.org/?client_id=ios&client_secret=secret", nil)
+	res, _ := sendAuthenticated(a, req)
+	c.Assert(res.Code, Equals, http.StatusUnauthorized)
+}
+
+func (s *S) TestAuthWithApiKey(c *C) {
+	a, _ := setUpAuth(`{"allow_api_keys": true, "scopes": ["read"]}`)
+	apiKey := account.ApiKey{Name: "production", Services: []string{"apihub"}, Methods: []string{"GET"}}
+	apiKey.Create(account.App{ClientId: "ios"})
+
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+	req.Header.Set(API_KEY_HEADER, apiKey.Key)
+	res, forwarded := sendAuthenticated(a, req)
+	c.Assert(res.Code, Equals, http.StatusOK)
+	c.Assert(forwarded.Header.Get(CLIENT_ID_HEADER), Equals, "ios")
+	c.Assert(forwarded.Header.Get(API_KEY_HEADER), Equals, "")
+}
+
+func (s *S) TestAuthWithApiKeyNotAllowedForTheRequest(c *C) {
+	a, _ := setUpAuth(`{"allow_api_keys": true}`)
+	apiKey := account.ApiKey{Name: "production", Services: []string{"another"}}
+	apiKey.Create(account.App{ClientId: "ios"})
+
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+	req.Header.Set(API_KEY_HEADER, apiKey.Key)
+	res, forwarded := sendAuthenticated(a, req)
+	c.Assert(forwarded, IsNil)
+	c.Assert(res.Code, Equals, http.StatusForbidden)
+
+	var body apiErrors.ErrorResponse
+	json.Unmarshal(res.Body.Bytes(), &body)
+	c.Assert(body, DeepEquals, apiErrors.ErrorResponse{Type: apiErrors.E_FORBIDDEN_REQUEST, Description: apiErrors.ErrApiKeyNotAllowed.Error()})
+}
+
+func (s *S) TestAuthWithRevokedApiKey(c *C) {
+	a, _ := setUpAuth(`{"allow_api_keys": true}`)
+	apiKey := account.ApiKey{Name: "production"}
+	apiKey.Create(account.App{ClientId: "ios"})
+	key := apiKey.Key
+	apiKey.Revoke()
+
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+	req.Header.Set(API_KEY_HEADER, key)
+	res, _ := sendAuthenticated(a, req)
+	c.Assert(res.Code, Equals, http.StatusUnauthorized)
+}
+
+func (s *S) TestAuthWithApiKeyNotAllowed(c *C) {
+	a, _ := setUpAuth(`{}`)
+	apiKey := account.ApiKey{Name: "production"}
+	apiKey.Create(account.App{ClientId: "ios"})
+
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+	req.Header.Set(API_KEY_HEADER, apiKey.Key)
+	res, _ := sendAuthenticated(a, req)
+	c.Assert(res.Code, Equals, http.StatusUnauthorized)
+}
+
+func (s *S) TestAuthWithoutApprovedSubscription(c *C) {
+	a, store := setUpAuth(`{}`)
+	store.UpsertSubscription(account.Subscription{ClientId: "ios", Service: "apihub", State: account.SUBSCRIPTION_PENDING})
+	token := account.OAuthToken{ClientId: "ios"}
+	token.Create(false)
+
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	res, forwarded := sendAuthenticated(a, req)
+	c.Assert(forwarded, IsNil)
+	c.Assert(res.Code, Equals, http.StatusForbidden)
+
+	var body apiErrors.ErrorResponse
+	json.Unmarshal(res.Body.Bytes(), &body)
+	c.Assert(body, DeepEquals, apiErrors.ErrorResponse{Type: apiErrors.E_FORBIDDEN_REQUEST, Description: apiErrors.ErrSubscriptionNotApproved.Error()})
+}
+
+func (s *S) TestAuthWithSubscriptionToAnotherService(c *C) {
+	a, _ := setUpAuth(`{}`)
+	a.SetService("another")
+	token := account.OAuthToken{ClientId: "ios"}
+	token.Create(false)
+
+	req, _ := http.NewRequest("GET", "http://another.example.org", nil)
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	res, _ := sendAuthenticated(a, req)
+	c.Assert(res.Code, Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAuthWithPlan(c *C) {
+	a, store := setUpAuth(`{"allow_client_secret": true}`)
+	store.UpsertPlan(account.Plan{Name: "gold", Service: "apihub", QuotaPerDay: 2, QuotaPerMonth: 10, Methods: []string{"GET"}, Paths: []string{"/v1"}})
+	store.UpsertSubscription(account.Subscription{ClientId: "ios", Service: "apihub", Plan: "gold", State: account.SUBSCRIPTION_APPROVED})
+
+	for i := 1; i >= 0; i-- {
+		req, _ := http.NewRequest("GET", "http://apihub.example.org/v1/users?client_id=ios&client_secret=secret", nil)
+		res, forwarded := sendAuthenticated(a, req)
+		c.Assert(res.Code, Equals, http.StatusOK)
+		c.Assert(forwarded.Header.Get(PLAN_HEADER), Equals, "gold")
+		c.Assert(res.Header().Get("X-Quota-Limit"), Equals, "2")
+		c.Assert(res.Header().Get("X-Quota-Remaining"), Equals, strconv.Itoa(i))
+	}
+
+	req, _ := http.NewRequest("GET", "http://apihub.example.org/v1/users?client_id=ios&client_secret=secret", nil)
+	res, forwarded := sendAuthenticated(a, req)
+	c.Assert(forwarded, IsNil)
+	c.Assert(res.Code, Equals, STATUS_TOO_MANY_REQUESTS)
+	c.Assert(res.Header().Get("Retry-After"), Not(Equals), "")
+
+	var body apiErrors.ErrorResponse
+	json.Unmarshal(res.Body.Bytes(), &body)
+	c.Assert(body, DeepEquals, apiErrors.ErrorResponse{Type: apiErrors.E_QUOTA_EXCEEDED, Description: apiErrors.ErrQuotaExceeded.Error()})
+}
+
+func (s *S) TestAuthWithPlanRateLimit(c *C) {
+	a, store := setUpAuth(`{"allow_client_secret": true}`)
+	store.UpsertPlan(account.Plan{Name: "free", Service: "apihub", RateLimit: 1})
+	store.UpsertSubscription(account.Subscription{ClientId: "ios", Service: "apihub", Plan: "free", State: account.SUBSCRIPTION_APPROVED})
+
+	req, _ := http.NewRequest("GET", "http://apihub.example.org/?client_id=ios&client_secret=secret", nil)
+	res, _ := sendAuthenticated(a, req)
+	c.Assert(res.Code, Equals, http.StatusOK)
+
+	req, _ = http.NewRequest("GET", "http://apihub.example.org/?client_id=ios&client_secret=secret", nil)
+	res, _ = sendAuthenticated(a, req)
+	c.Assert(res.Code, Equals, STATUS_TOO_MANY_REQUESTS)
+
+	var body apiErrors.ErrorResponse
+	json.Unmarshal(res.Body.Bytes(), &body)
+	c.Assert(body.Type, Equals, apiErrors.E_TOO_MANY_REQUESTS)
+}
+
+func (s *S) TestAuthWithPlanRateLimitDoesNotChargeTheQuota(c *C) {
+	a, store := setUpAuth(`{"allow_client_secret": true}`)
+	counter := &memoryCounter{hits: map[string]int64{}}
+	a.counter = counter
+	store.UpsertPlan(account.Plan{Name: "free", Service: "apihub", RateLimit: 1, QuotaPerDay: 5})
+	store.UpsertSubscription(account.Subscription{ClientId: "ios", Service: "apihub", Plan: "free", State: account.SUBSCRIPTION_APPROVED})
+
+	var allowed int64
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", "http://apihub.example.org/?client_id=ios&client_secret=secret", nil)
+		if res, _ := sendAuthenticated(a, req); res.Code == http.StatusOK {
+			allowed++
+		}
+	}
+
+	var daily int64
+	for key, hits := range counter.hits {
+		if strings.Contains(key, ":day:") {
+			daily += hits
+		}
+	}
+	c.Assert(allowed < 3, Equals, true)
+	c.Assert(daily, Equals, allowed)
+}
+
+func (s *S) TestAuthWithPlanNotAllowingTheRequest(c *C) {
+	a, store := setUpAuth(`{"allow_client_secret": true}`)
+	store.UpsertPlan(account.Plan{Name: "gold", Service: "apihub", Methods: []string{"GET"}, Paths: []string{"/v1"}})
+	store.UpsertSubscription(account.Subscription{ClientId: "ios", Service: "apihub", Plan: "gold", State: account.SUBSCRIPTION_APPROVED})
+
+	for _, r := range []struct{ method, url string }{
+		{"POST", "http://apihub.example.org/v1/users?client_id=ios&client_secret=secret"},
+		{"GET", "http://apihub.example.org/v2/users?client_id=ios&client_secret=secret"},
+	} {
+		req, _ := http.NewRequest(r.method, r.url, nil)
+		res, forwarded := sendAuthenticated(a, req)
+		c.Assert(forwarded, IsNil)
+		c.Assert(res.Code, Equals, http.StatusForbidden)
+
+		var body apiErrors.ErrorResponse
+		json.Unmarshal(res.Body.Bytes(), &body)
+		c.Assert(body.Description, Equals, apiErrors.ErrPlanNotAllowed.Error())
+	}
+}
+
+func (s *S) TestAuthWithPlanWhenCounterFails(c *C) {
+	a, store := setUpAuth(`{"allow_client_secret": true}`)
+	a.counter = &memoryCounter{err: errors.New("connection refused")}
+	store.UpsertPlan(account.Plan{Name: "gold", Service: "apihub", QuotaPerDay: 1})
+	store.UpsertSubscription(account.Subscription{ClientId: "ios", Service: "apihub", Plan: "gold", State: account.SUBSCRIPTION_APPROVED})
+
+	req, _ := http.NewRequest("GET", "http://apihub.example.org/?client_id=ios&client_secret=secret", nil)
+	res, _ := sendAuthenticated(a, req)
+	c.Assert(res.Code, Equals, http.StatusOK)
+}
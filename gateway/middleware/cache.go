@@ -0,0 +1,487 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apihub/apihub/db"
+	. "github.com/apihub/apihub/log"
+)
+
+const (
+	CACHE_HEADER             = "X-Cache"
+	CACHE_HIT                = "HIT"
+	CACHE_MISS               = "MISS"
+	CACHE_STORE_MEMORY       = "memory"
+	CACHE_STORE_REDIS        = "redis"
+	DEFAULT_CACHE_TTL        = 60
+	DEFAULT_CACHE_OBJECT_MAX = 1 << 20
+
+	// CACHE_STALE_PERIOD is how long the responses with validators are kept after they expire,
+	// so they may be revalidated with the upstream.
+	CACHE_STALE_PERIOD = time.Hour
+)
+
+var (
+	defaultCacheMethods     = []string{"GET", "HEAD"}
+	defaultCacheStatusCodes = []int{200, 203, 300, 301, 404, 410}
+)
+
+// CacheStore keeps the responses cached by the Cache middleware.
+type CacheStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, expires time.Duration)
+}
+
+// memoryCacheStore keeps the responses in the memory of each gateway instance.
+type memoryCacheStore struct{}
+
+func (memoryCacheStore) Get(key string) ([]byte, bool) {
+	item := db.Cache.Get(key)
+	if item == nil || item.Expired() {
+		return nil, false
+	}
+	value, ok := item.Value().([]byte)
+	return value, ok
+}
+
+func (memoryCacheStore) Set(key string, value []byte, expires time.Duration) {
+	db.Cache.Set(key, value, expires)
+}
+
+// redisCacheStore shares the responses among all the gateway instances.
+type redisCacheStore struct{}
+
+func (redisCacheStore) Get(key string) ([]byte, bool) {
+	value, err := db.GetCache(key)
+	if err != nil {
+		Logger.Warn("Failed to get the cached response `%s`: %+v.", key, err)
+	}
+	return value, value != nil
+}
+
+func (redisCacheStore) Set(key string, value []byte, expires time.Duration) {
+	seconds := int(expires / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	if err := db.SetCache(key, value, seconds); err != nil {
+		Logger.Warn("Failed to cache the response `%s`: %+v.", key, err)
+	}
+}
+
+// Cache keeps the responses of the service, so the same requests are not sent to the upstream again.
+// The `Cache-Control` and `Expires` headers of the upstream take precedence over the `TTL`, in seconds.
+// Responses are cached by the method, the path, the `VaryByQuery` parameters (or the whole query string
+// when none is given) and the `VaryByHeaders`. Responses which vary by other headers are not cached.
+// Requests with credentials, in the `Authorization` or `X-Api-Key` headers, are cached apart for each client,
+// and only when the upstream allows it with `public`, `s-maxage` or `must-revalidate` (RFC 7234, section 3.2).
+// Requests to switch protocols, such as WebSocket, are not cached.
+// Expired responses with an `ETag` or `Last-Modified` header are revalidated with the upstream.
+type Cache struct {
+	TTL           int      `json:"ttl"`
+	Methods       []string `json:"methods"`
+	StatusCodes   []int    `json:"status_codes"`
+	VaryByHeaders []string `json:"vary_by_headers"`
+	VaryByQuery   []string `json:"vary_by_query"`
+	MaxObjectSize int      `json:"max_object_size"`
+	Store         string   `json:"store"`
+	service       string
+	store         CacheStore
+}
+
+// cachedResponse is the response kept on the store.
+type cachedResponse struct {
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	StoredAt int64       `json:"stored_at"`
+	Expires  int64       `json:"expires"`
+}
+
+func NewCacheMiddleware() Middleware {
+	return &Cache{}
+}
+
+func (c *Cache) Configure(cfg string) {
+	json.Unmarshal([]byte(cfg), c)
+}
+
+// SetService keeps the responses of each service apart.
+func (c *Cache) SetService(subdomain string) {
+	c.service = subdomain
+}
+
+func (c *Cache) ProcessRequest(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	requestDirectives := cacheControl(r.Header)
+	if !c.cacheable(r) || requestDirectives.has("no-store") || upgrading(r) {
+		next(rw, r)
+		return
+	}
+	// The Auth middleware runs first and removes the credentials, keeping the identity it has verified.
+	_, verified := authenticated(r)
+	authorized := verified || hasCredentials(r)
+
+	key := c.key(r)
+	cached, found := c.get(key)
+	now := time.Now()
+	if found && !requestDirectives.has("no-cache") && cached.fresh(now) {
+		cached.write(rw, r, now)
+		return
+	}
+
+	// The validators of the client are restored when the response is not revalidated.
+	clientETag, clientModified := r.Header.Get("If-None-Match"), r.Header.Get("If-Modified-Since")
+	revalidating := found && cached.validatable()
+	if revalidating {
+		r.Header.Del("If-None-Match")
+		r.Header.Del("If-Modified-Since")
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		if modified := cached.Header.Get("Last-Modified"); modified != "" {
+			r.Header.Set("If-Modified-Since", modified)
+		}
+	}
+
+	// The headers set by the other middlewares, such as the rate limit, are not cached.
+	previous := map[string]string{}
+	for name, values := range rw.Header() {
+		previous[name] = strings.Join(values, ",")
+	}
+	rw.Header().Set(CACHE_HEADER, CACHE_MISS)
+	w := &cacheWriter{ResponseWriter: rw, revalidating: revalidating, maxSize: c.maxObjectSize()}
+	next(w, r)
+
+	if w.notModified {
+		setHeader(r.Header, "If-None-Match", clientETag)
+		setHeader(r.Header, "If-Modified-Since", clientModified)
+		cached.refresh(w.Header(), now, c.ttl())
+		c.set(key, cached)
+		cached.write(rw, r, now)
+		return
+	}
+	if w.overflow || !c.storable(w.code(), w.Header(), authorized) {
+		return
+	}
+
+	response := &cachedResponse{Status: w.code(), Header: http.Header{}, Body: w.body.Bytes()}
+	for name, values := range w.Header() {
+		if value, ok := previous[name]; name != CACHE_HEADER && (!ok || value != strings.Join(values, ",")) {
+			response.Header[name] = values
+		}
+	}
+	response.refresh(nil, now, c.ttl())
+	c.set(key, response)
+}
+
+func setHeader(header http.Header, name, value string) {
+	if value == "" {
+		header.Del(name)
+		return
+	}
+	header.Set(name, value)
+}
+
+// cacheable reports whether the request may be answered from the cache.
+func (c *Cache) cacheable(r *http.Request) bool {
+	methods := c.Methods
+	if len(methods) == 0 {
+		methods = defaultCacheMethods
+	}
+	for _, method := range methods {
+		if strings.EqualFold(method, r.Method) {
+			return true
+		}
+	}
+	return false
+}
+
+// storable reports whether the response of the upstream may be cached.
+func (c *Cache) storable(status int, header http.Header, authorized bool) bool {
+	statusCodes := c.StatusCodes
+	if len(statusCodes) == 0 {
+		statusCodes = defaultCacheStatusCodes
+	}
+	allowed := false
+	for _, code := range statusCodes {
+		allowed = allowed || code == status
+	}
+	if !allowed || header.Get("Set-Cookie") != "" {
+		return false
+	}
+
+	directives := cacheControl(header)
+	if directives.has("no-store") || directives.has("private") {
+		return false
+	}
+	if authorized && !directives.has("public") && !directives.has("s-maxage") && !directives.has("must-revalidate") {
+		return false
+	}
+	for _, vary := range strings.Split(header.Get("Vary"), ",") {
+		if vary = strings.TrimSpace(vary); vary != "" && !c.variesBy(vary) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Cache) variesBy(header string) bool {
+	for _, h := range c.VaryByHeaders {
+		if strings.EqualFold(h, header) {
+			return true
+		}
+	}
+	return false
+}
+
+// key identifies the response of the request, without keeping the values of the headers in plain text.
+func (c *Cache) key(r *http.Request) string {
+	query := r.URL.Query()
+	if len(c.VaryByQuery) > 0 {
+		selected := map[string][]string{}
+		for _, name := range c.VaryByQuery {
+			if values, ok := query[name]; ok {
+				selected[name] = values
+			}
+		}
+		query = selected
+	}
+
+	headers := []string{}
+	for _, name := range c.VaryByHeaders {
+		headers = append(headers, http.CanonicalHeaderKey(name)+"="+strings.Join(r.Header[http.CanonicalHeaderKey(name)], ","))
+	}
+	sort.Strings(headers)
+
+	sum := sha1.Sum([]byte(r.Method + " " + r.URL.Path + "?" + query.Encode() + "\n" + strings.Join(headers, "\n") + "\n" + cacheClient(r)))
+	return fmt.Sprintf("cache:%s:%s", c.service, hex.EncodeToString(sum[:]))
+}
+
+// cacheClient identifies who the response is cached for: the client, user and credential verified by
+// the Auth middleware or, when there is none, the credentials themselves. Anonymous requests share the responses.
+func cacheClient(r *http.Request) string {
+	if id, ok := authenticated(r); ok {
+		return "client=" + id.clientId + ",user=" + id.user + ",credential=" + id.credential
+	}
+	if hasCredentials(r) {
+		return "credentials=" + r.Header.Get("Authorization") + "," + r.Header.Get(API_KEY_HEADER)
+	}
+	return ""
+}
+
+func hasCredentials(r *http.Request) bool {
+	return r.Header.Get("Authorization") != "" || r.Header.Get(API_KEY_HEADER) != ""
+}
+
+// upgrading reports whether the client asks to switch protocols, whose connection is hijacked by the gateway.
+func upgrading(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, value := range r.Header["Connection"] {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *Cache) get(key string) (*cachedResponse, bool) {
+	value, ok := c.cacheStore().Get(key)
+	if !ok {
+		return nil, false
+	}
+	cached := &cachedResponse{}
+	if err := json.Unmarshal(value, cached); err != nil {
+		return nil, false
+	}
+	return cached, true
+}
+
+func (c *Cache) set(key string, cached *cachedResponse) {
+	expires := time.Unix(cached.Expires, 0).Sub(time.Now())
+	if cached.validatable() {
+		expires += CACHE_STALE_PERIOD
+	}
+	if expires <= 0 {
+		return
+	}
+	value, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+	c.cacheStore().Set(key, value, expires)
+}
+
+func (c *Cache) cacheStore() CacheStore {
+	if c.store != nil {
+		return c.store
+	}
+	if c.Store == CACHE_STORE_REDIS {
+		return redisCacheStore{}
+	}
+	return memoryCacheStore{}
+}
+
+func (c *Cache) ttl() time.Duration {
+	if c.TTL > 0 {
+		return time.Duration(c.TTL) * time.Second
+	}
+	return DEFAULT_CACHE_TTL * time.Second
+}
+
+func (c *Cache) maxObjectSize() int {
+	if c.MaxObjectSize > 0 {
+		return c.MaxObjectSize
+	}
+	return DEFAULT_CACHE_OBJECT_MAX
+}
+
+func (cr *cachedResponse) fresh(now time.Time) bool {
+	return now.Unix() < cr.Expires
+}
+
+func (cr *cachedResponse) validatable() bool {
+	return cr.Header.Get("ETag") != "" || cr.Header.Get("Last-Modified") != ""
+}
+
+// refresh updates the headers sent by the upstream when revalidating the response,
+// computing when it expires.
+func (cr *cachedResponse) refresh(header http.Header, now time.Time, ttl time.Duration) {
+	for _, name := range []string{"Cache-Control", "Expires", "ETag", "Last-Modified", "Date"} {
+		if value := header.Get(name); value != "" {
+			cr.Header.Set(name, value)
+		}
+	}
+
+	cr.StoredAt = now.Unix()
+	cr.Expires = now.Add(freshness(cr.Header, now, ttl)).Unix()
+}
+
+// write answers the request with the cached response, or with `304 Not Modified`
+// when the client already has it.
+func (cr *cachedResponse) write(rw http.ResponseWriter, r *http.Request, now time.Time) {
+	for name, values := range cr.Header {
+		rw.Header()[name] = values
+	}
+	rw.Header().Set(CACHE_HEADER, CACHE_HIT)
+	rw.Header().Set("Age", strconv.FormatInt(now.Unix()-cr.StoredAt, 10))
+
+	if etag := cr.Header.Get("ETag"); etag != "" && r.Header.Get("If-None-Match") == etag {
+		rw.Header().Del("Content-Length")
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+	rw.WriteHeader(cr.Status)
+	if r.Method != "HEAD" {
+		rw.Write(cr.Body)
+	}
+}
+
+// freshness returns how long the response is fresh, according to the headers of the upstream.
+func freshness(header http.Header, now time.Time, ttl time.Duration) time.Duration {
+	directives := cacheControl(header)
+	if directives.has("no-cache") {
+		return 0
+	}
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if value, ok := directives[directive]; ok {
+			if seconds, err := strconv.Atoi(value); err == nil {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	if expires := header.Get("Expires"); expires != "" {
+		if t, err := http.ParseTime(expires); err == nil {
+			return t.Sub(now)
+		}
+		return 0
+	}
+	return ttl
+}
+
+type cacheDirectives map[string]string
+
+func cacheControl(header http.Header) cacheDirectives {
+	directives := cacheDirectives{}
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		parts := strings.SplitN(strings.TrimSpace(directive), "=", 2)
+		if parts[0] == "" {
+			continue
+		}
+		value := ""
+		if len(parts) == 2 {
+			value = strings.Trim(parts[1], `"`)
+		}
+		directives[strings.ToLower(parts[0])] = value
+	}
+	return directives
+}
+
+func (d cacheDirectives) has(directive string) bool {
+	_, ok := d[directive]
+	return ok
+}
+
+// cacheWriter keeps a copy of the response while it is written to the client.
+// When revalidating, a `304 Not Modified` of the upstream is not written, so the cached response is sent instead.
+type cacheWriter struct {
+	http.ResponseWriter
+	status       int
+	body         bytes.Buffer
+	maxSize      int
+	overflow     bool
+	revalidating bool
+	notModified  bool
+}
+
+func (w *cacheWriter) WriteHeader(status int) {
+	w.status = status
+	if w.revalidating && status == http.StatusNotModified {
+		w.notModified = true
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.notModified {
+		return len(p), nil
+	}
+	if !w.overflow {
+		if w.body.Len()+len(p) > w.maxSize {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(p)
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *cacheWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *cacheWriter) code() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
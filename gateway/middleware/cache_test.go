@@ -0,0 +1,329 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gorilla/context"
+	. "gopkg.in/check.v1"
+)
+
+type memoryCacheStoreForTest struct {
+	values map[string][]byte
+}
+
+func (m *memoryCacheStoreForTest) Get(key string) ([]byte, bool) {
+	value, ok := m.values[key]
+	return value, ok
+}
+
+func (m *memoryCacheStoreForTest) Set(key string, value []byte, expires time.Duration) {
+	m.values[key] = value
+}
+
+// upstream counts the requests which reach it, answering with the given headers.
+type upstream struct {
+	hits        int
+	header      http.Header
+	status      int
+	body        string
+	ifNoneMatch string
+}
+
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.hits++
+	u.ifNoneMatch = r.Header.Get("If-None-Match")
+	for name, values := range u.header {
+		w.Header()[name] = values
+	}
+	if u.status != 0 {
+		w.WriteHeader(u.status)
+	}
+	w.Write([]byte(u.body))
+}
+
+func newCache(cfg string) *Cache {
+	cache := &Cache{store: &memoryCacheStoreForTest{values: map[string][]byte{}}}
+	cache.Configure(cfg)
+	cache.SetService("test")
+	return cache
+}
+
+func sendCached(cache *Cache, u *upstream, req *http.Request) *httptest.ResponseRecorder {
+	res := httptest.NewRecorder()
+	cache.ProcessRequest(res, req, u.ServeHTTP)
+	return res
+}
+
+func (s *S) TestCacheMissAndHit(c *C) {
+	cache := newCache(`{"ttl": 60}`)
+	u := &upstream{body: "OK", header: http.Header{"Content-Type": {"text/plain"}}}
+
+	req, _ := http.NewRequest("GET", "http://test.apihub.dev/users?page=1", nil)
+	res := sendCached(cache, u, req)
+	c.Assert(res.Code, Equals, http.StatusOK)
+	c.Assert(res.Header().Get(CACHE_HEADER), Equals, CACHE_MISS)
+	c.Assert(res.Body.String(), Equals, "OK")
+
+	res = sendCached(cache, u, req)
+	c.Assert(res.Code, Equals, http.StatusOK)
+	c.Assert(res.Header().Get(CACHE_HEADER), Equals, CACHE_HIT)
+	c.Assert(res.Header().Get("Content-Type"), Equals, "text/plain")
+	c.Assert(res.Header().Get("Age"), Equals, "0")
+	c.Assert(res.Body.String(), Equals, "OK")
+	c.Assert(u.hits, Equals, 1)
+
+	// Another query string is another response.
+	req, _ = http.NewRequest("GET", "http://test.apihub.dev/users?page=2", nil)
+	res = sendCached(cache, u, req)
+	c.Assert(res.Header().Get(CACHE_HEADER), Equals, CACHE_MISS)
+	c.Assert(u.hits, Equals, 2)
+}
+
+func (s *S) TestCacheIgnoresOtherMethods(c *C) {
+	cache := newCache(`{}`)
+	u := &upstream{status: http.StatusCreated}
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("POST", "http://test.apihub.dev/users", nil)
+		res := sendCached(cache, u, req)
+		c.Assert(res.Code, Equals, http.StatusCreated)
+		c.Assert(res.Header().Get(CACHE_HEADER), Equals, "")
+	}
+	c.Assert(u.hits, Equals, 2)
+}
+
+func (s *S) TestCacheDoesNotStoreUncacheableResponses(c *C) {
+	for _, u := range []*upstream{
+		&upstream{status: http.StatusInternalServerError},
+		&upstream{header: http.Header{"Cache-Control": {"no-store"}}},
+		&upstream{header: http.Header{"Cache-Control": {"private, max-age=60"}}},
+		&upstream{header: http.Header{"Set-Cookie": {"session=1"}}},
+		&upstream{header: http.Header{"Vary": {"Accept-Language"}}},
+		&upstream{header: http.Header{"Cache-Control": {"max-age=0"}}},
+	} {
+		cache := newCache(`{}`)
+		req, _ := http.NewRequest("GET", "http://test.apihub.dev/", nil)
+		sendCached(cache, u, req)
+		res := sendCached(cache, u, req)
+		c.Assert(res.Header().Get(CACHE_HEADER), Equals, CACHE_MISS)
+		c.Assert(u.hits, Equals, 2)
+	}
+}
+
+func (s *S) TestCacheDoesNotStoreLargeResponses(c *C) {
+	cache := newCache(`{"max_object_size": 2}`)
+	u := &upstream{body: "Large"}
+
+	req, _ := http.NewRequest("GET", "http://test.apihub.dev/", nil)
+	res := sendCached(cache, u, req)
+	c.Assert(res.Body.String(), Equals, "Large")
+	sendCached(cache, u, req)
+	c.Assert(u.hits, Equals, 2)
+}
+
+func (s *S) TestCacheVaryByHeadersAndQuery(c *C) {
+	cache := newCache(`{"vary_by_headers": ["Accept-Language"], "vary_by_query": ["page"]}`)
+	u := &upstream{header: http.Header{"Vary": {"Accept-Language"}}}
+
+	req, _ := http.NewRequest("GET", "http://test.apihub.dev/users?page=1&_=123", nil)
+	req.Header.Set("Accept-Language", "en")
+	sendCached(cache, u, req)
+
+	// Parameters other than page are ignored.
+	req, _ = http.NewRequest("GET", "http://test.apihub.dev/users?page=1&_=456", nil)
+	req.Header.Set("Accept-Language", "en")
+	res := sendCached(cache, u, req)
+	c.Assert(res.Header().Get(CACHE_HEADER), Equals, CACHE_HIT)
+
+	req, _ = http.NewRequest("GET", "http://test.apihub.dev/users?page=1", nil)
+	req.Header.Set("Accept-Language", "pt-BR")
+	res = sendCached(cache, u, req)
+	c.Assert(res.Header().Get(CACHE_HEADER), Equals, CACHE_MISS)
+	c.Assert(u.hits, Equals, 2)
+}
+
+func (s *S) TestCacheHonorsRequestCacheControl(c *C) {
+	cache := newCache(`{}`)
+	u := &upstream{}
+
+	req, _ := http.NewRequest("GET", "http://test.apihub.dev/", nil)
+	sendCached(cache, u, req)
+
+	req.Header.Set("Cache-Control", "no-cache")
+	res := sendCached(cache, u, req)
+	c.Assert(res.Header().Get(CACHE_HEADER), Equals, CACHE_MISS)
+	c.Assert(u.hits, Equals, 2)
+
+	req.Header.Set("Cache-Control", "no-store")
+	res = sendCached(cache, u, req)
+	c.Assert(res.Header().Get(CACHE_HEADER), Equals, "")
+	c.Assert(u.hits, Equals, 3)
+}
+
+func (s *S) TestCacheRevalidatesExpiredResponses(c *C) {
+	cache := newCache(`{}`)
+	u := &upstream{body: "OK", header: http.Header{"Etag": {`"v1"`}, "Cache-Control": {"no-cache"}}}
+
+	req, _ := http.NewRequest("GET", "http://test.apihub.dev/", nil)
+	sendCached(cache, u, req)
+
+	// The upstream answers the revalidation with Not Modified, and the cached response is sent.
+	u.status, u.body = http.StatusNotModified, ""
+	req, _ = http.NewRequest("GET", "http://test.apihub.dev/", nil)
+	res := sendCached(cache, u, req)
+	c.Assert(u.ifNoneMatch, Equals, `"v1"`)
+	c.Assert(res.Code, Equals, http.StatusOK)
+	c.Assert(res.Header().Get(CACHE_HEADER), Equals, CACHE_HIT)
+	c.Assert(res.Body.String(), Equals, "OK")
+
+	// A new version of the response replaces the cached one.
+	u.status, u.body, u.header = http.StatusOK, "New", http.Header{"Etag": {`"v2"`}, "Cache-Control": {"no-cache"}}
+	req, _ = http.NewRequest("GET", "http://test.apihub.dev/", nil)
+	res = sendCached(cache, u, req)
+	c.Assert(res.Header().Get(CACHE_HEADER), Equals, CACHE_MISS)
+	c.Assert(res.Body.String(), Equals, "New")
+
+	cached, _ := cache.get(cache.key(req))
+	c.Assert(cached.Header.Get("Etag"), Equals, `"v2"`)
+	c.Assert(u.hits, Equals, 3)
+}
+
+func (s *S) TestCacheAnswersConditionalRequests(c *C) {
+	cache := newCache(`{}`)
+	u := &upstream{body: "OK", header: http.Header{"Etag": {`"v1"`}, "Cache-Control": {"max-age=60"}}}
+
+	req, _ := http.NewRequest("GET", "http://test.apihub.dev/", nil)
+	sendCached(cache, u, req)
+
+	req.Header.Set("If-None-Match", `"v1"`)
+	res := sendCached(cache, u, req)
+	c.Assert(res.Code, Equals, http.StatusNotModified)
+	c.Assert(res.Body.String(), Equals, "")
+	c.Assert(u.hits, Equals, 1)
+}
+
+func (s *S) TestCacheDoesNotStoreHeadersOfOtherMiddlewares(c *C) {
+	cache := newCache(`{}`)
+	u := &upstream{}
+
+	req, _ := http.NewRequest("GET", "http://test.apihub.dev/", nil)
+	res := httptest.NewRecorder()
+	res.Header().Set("X-RateLimit-Remaining", "9")
+	cache.ProcessRequest(res, req, u.ServeHTTP)
+
+	res = httptest.NewRecorder()
+	res.Header().Set("X-RateLimit-Remaining", "8")
+	cache.ProcessRequest(res, req, u.ServeHTTP)
+	c.Assert(res.Header().Get(CACHE_HEADER), Equals, CACHE_HIT)
+	c.Assert(res.Header().Get("X-RateLimit-Remaining"), Equals, "8")
+}
+
+func (s *S) TestCacheDoesNotStoreAuthorizedResponsesUnlessAllowed(c *C) {
+	for _, header := range []string{"Authorization", API_KEY_HEADER} {
+		cache := newCache(`{}`)
+		u := &upstream{header: http.Header{"Cache-Control": {"max-age=60"}}}
+
+		req, _ := http.NewRequest("GET", "http://test.apihub.dev/", nil)
+		req.Header.Set(header, "secret")
+		sendCached(cache, u, req)
+		res := sendCached(cache, u, req)
+		c.Assert(res.Header().Get(CACHE_HEADER), Equals, CACHE_MISS)
+		c.Assert(u.hits, Equals, 2)
+	}
+}
+
+func (s *S) TestCacheKeepsAuthorizedResponsesApart(c *C) {
+	cache := newCache(`{}`)
+	u := &upstream{body: "OK", header: http.Header{"Cache-Control": {"public, max-age=60"}}}
+
+	req, _ := http.NewRequest("GET", "http://test.apihub.dev/", nil)
+	req.Header.Set("Authorization", "Bearer first")
+	sendCached(cache, u, req)
+	res := sendCached(cache, u, req)
+	c.Assert(res.Header().Get(CACHE_HEADER), Equals, CACHE_HIT)
+
+	// Neither another client nor an anonymous request get the response.
+	req, _ = http.NewRequest("GET", "http://test.apihub.dev/", nil)
+	req.Header.Set("Authorization", "Bearer second")
+	res = sendCached(cache, u, req)
+	c.Assert(res.Header().Get(CACHE_HEADER), Equals, CACHE_MISS)
+	req, _ = http.NewRequest("GET", "http://test.apihub.dev/", nil)
+	res = sendCached(cache, u, req)
+	c.Assert(res.Header().Get(CACHE_HEADER), Equals, CACHE_MISS)
+	c.Assert(u.hits, Equals, 3)
+}
+
+func (s *S) TestCacheKeyedByAuthenticatedClient(c *C) {
+	cache := newCache(`{}`)
+	req, _ := http.NewRequest("GET", "http://test.apihub.dev/", nil)
+	defer context.Clear(req)
+	context.Set(req, identityKey, &identity{clientId: "first"})
+	first := cache.key(req)
+
+	context.Set(req, identityKey, &identity{clientId: "second"})
+	c.Assert(cache.key(req), Not(Equals), first)
+}
+
+func (s *S) TestCacheKeepsTheUsersOfAClientApart(c *C) {
+	cache := newCache(`{}`)
+	u := &upstream{body: "OK", header: http.Header{"Cache-Control": {"public, max-age=60"}}}
+
+	send := func(id *identity) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "http://test.apihub.dev/", nil)
+		defer context.Clear(req)
+		context.Set(req, identityKey, id)
+		return sendCached(cache, u, req)
+	}
+	send(&identity{clientId: "ios", user: "alice@example.org", credential: "first"})
+	res := send(&identity{clientId: "ios", user: "bob@example.org", credential: "second"})
+	c.Assert(res.Header().Get(CACHE_HEADER), Equals, CACHE_MISS)
+	c.Assert(u.hits, Equals, 2)
+}
+
+func (s *S) TestCacheDoesNotStoreAuthenticatedResponsesUnlessAllowed(c *C) {
+	cache := newCache(`{}`)
+	u := &upstream{header: http.Header{"Cache-Control": {"max-age=60"}}}
+
+	for i := 0; i < 2; i++ {
+		// The credentials have already been removed by the Auth middleware.
+		req, _ := http.NewRequest("GET", "http://test.apihub.dev/", nil)
+		context.Set(req, identityKey, &identity{clientId: "ios", credential: "token"})
+		res := sendCached(cache, u, req)
+		context.Clear(req)
+		c.Assert(res.Header().Get(CACHE_HEADER), Equals, CACHE_MISS)
+	}
+	c.Assert(u.hits, Equals, 2)
+}
+
+func (s *S) TestCacheIgnoresUpgradeRequests(c *C) {
+	cache := newCache(`{}`)
+	u := &upstream{}
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "http://test.apihub.dev/chat", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		res := httptest.NewRecorder()
+		cache.ProcessRequest(res, req, func(w http.ResponseWriter, r *http.Request) {
+			// The writer of the gateway is not wrapped, so the connection may still be hijacked.
+			c.Assert(w, Equals, http.ResponseWriter(res))
+			u.ServeHTTP(w, r)
+		})
+		c.Assert(res.Header().Get(CACHE_HEADER), Equals, "")
+	}
+	c.Assert(u.hits, Equals, 2)
+}
+
+func (s *S) TestFreshness(c *C) {
+	now := time.Now()
+	ttl := time.Minute
+	c.Assert(freshness(http.Header{}, now, ttl), Equals, ttl)
+	c.Assert(freshness(http.Header{"Cache-Control": {"public, max-age=10"}}, now, ttl), Equals, 10*time.Second)
+	c.Assert(freshness(http.Header{"Cache-Control": {"max-age=10, s-maxage=20"}}, now, ttl), Equals, 20*time.Second)
+	c.Assert(freshness(http.Header{"Cache-Control": {"no-cache"}}, now, ttl), Equals, time.Duration(0))
+	c.Assert(freshness(http.Header{"Expires": {"0"}}, now, ttl), Equals, time.Duration(0))
+	expires := now.Add(time.Hour).UTC().Truncate(time.Second)
+	c.Assert(freshness(http.Header{"Expires": {expires.Format(http.TimeFormat)}}, expires.Add(-30*time.Second), ttl), Equals, 30*time.Second)
+}
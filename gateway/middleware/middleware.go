@@ -1,7 +1,10 @@
 package middleware
 
 import (
+	"encoding/json"
 	"net/http"
+
+	"github.com/apihub/apihub/errors"
 )
 
 // Middleware which modify the request.
@@ -10,6 +13,11 @@ type Middleware interface {
 	ProcessRequest(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc)
 }
 
+// ServiceMiddleware is implemented by middlewares which need to know the service they are added to.
+type ServiceMiddleware interface {
+	SetService(subdomain string)
+}
+
 // An array of Middleware with key to be used by the gateway and service.
 type Middlewares map[string]func() Middleware
 
@@ -19,3 +27,11 @@ func (f Middlewares) Add(key string, value func() Middleware) {
 func (f Middlewares) Get(key string) func() Middleware {
 	return f[key]
 }
+
+// writeError writes the error in the same format used by the api.
+func writeError(rw http.ResponseWriter, status int, errType string, err error) {
+	body, _ := json.Marshal(errors.NewErrorResponse(errType, err.Error()))
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	rw.Write(body)
+}
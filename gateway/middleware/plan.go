@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/errors"
+	. "github.com/apihub/apihub/log"
+)
+
+// planWindow is a fixed window in which the requests of an App are counted against one of the limits of its plan.
+type planWindow struct {
+	name  string
+	start time.Time
+	end   time.Time
+	limit int64
+	quota bool
+}
+
+// enforcePlan applies the plan of the subscription to the request: the methods and paths it allows,
+// its rate limit and its daily and monthly quotas, which are counted on Redis.
+// The windows after the one which rejects the request are not charged for it.
+// It reports whether the request is allowed to go on.
+func (a *Auth) enforcePlan(rw http.ResponseWriter, r *http.Request, subscription *account.Subscription) bool {
+	value, err := a.cached("gateway:plan:"+a.service+":"+subscription.Plan, func() (interface{}, error) {
+		return account.FindPlanByNameAndService(subscription.Plan, account.Service{Subdomain: a.service})
+	})
+	plan, _ := value.(*account.Plan)
+	if err != nil || !plan.Allows(r.Method, r.URL.Path) {
+		writeError(rw, http.StatusForbidden, errors.E_FORBIDDEN_REQUEST, errors.ErrPlanNotAllowed)
+		return false
+	}
+
+	now := time.Now().In(time.UTC)
+	var quotaLimit, quotaRemaining int64 = 0, -1
+	var quotaReset time.Time
+	for _, w := range planWindows(*plan, now) {
+		key := fmt.Sprintf("plan:%s:%s:%s:%d", a.service, subscription.ClientId, w.name, w.start.Unix())
+		hits, err := a.counter.Incr(key, int(w.end.Sub(now).Seconds())+1)
+		if err != nil {
+			// The requests are not blocked when the counters are not available.
+			Logger.Warn("Failed to count the requests of `%s` for service `%s`: %+v.", subscription.ClientId, a.service, err)
+			return true
+		}
+
+		left := w.limit - hits
+		if w.quota && (quotaRemaining < 0 || left < quotaRemaining) {
+			quotaLimit, quotaRemaining, quotaReset = w.limit, left, w.end
+		}
+		if left < 0 {
+			rw.Header().Set("Retry-After", strconv.FormatInt(int64(w.end.Sub(now).Seconds())+1, 10))
+			if w.quota {
+				writeError(rw, STATUS_TOO_MANY_REQUESTS, errors.E_QUOTA_EXCEEDED, errors.ErrQuotaExceeded)
+			} else {
+				writeError(rw, STATUS_TOO_MANY_REQUESTS, errors.E_TOO_MANY_REQUESTS, errors.ErrRateLimitExceeded)
+			}
+			return false
+		}
+	}
+
+	if quotaRemaining >= 0 {
+		rw.Header().Set("X-Quota-Limit", strconv.FormatInt(quotaLimit, 10))
+		rw.Header().Set("X-Quota-Remaining", strconv.FormatInt(quotaRemaining, 10))
+		rw.Header().Set("X-Quota-Reset", strconv.FormatInt(quotaReset.Unix(), 10))
+	}
+	return true
+}
+
+func planWindows(plan account.Plan, now time.Time) []planWindow {
+	windows := []planWindow{}
+	if plan.RateLimit > 0 {
+		start := now.Truncate(time.Minute)
+		windows = append(windows, planWindow{name: "minute", start: start, end: start.Add(time.Minute), limit: int64(plan.RateLimit)})
+	}
+	if plan.QuotaPerDay > 0 {
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		windows = append(windows, planWindow{name: "day", start: start, end: start.AddDate(0, 0, 1), limit: int64(plan.QuotaPerDay), quota: true})
+	}
+	if plan.QuotaPerMonth > 0 {
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		windows = append(windows, planWindow{name: "month", start: start, end: start.AddDate(0, 1, 0), limit: int64(plan.QuotaPerMonth), quota: true})
+	}
+	return windows
+}
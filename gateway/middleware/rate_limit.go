@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apihub/apihub/db"
+	"github.com/apihub/apihub/errors"
+	. "github.com/apihub/apihub/log"
+)
+
+const (
+	RATE_LIMIT_BY_CLIENT_ID  = "client_id"
+	RATE_LIMIT_BY_TOKEN      = "token"
+	RATE_LIMIT_BY_IP         = "ip"
+	STATUS_TOO_MANY_REQUESTS = 429
+)
+
+// Counter keeps the number of hits of a key, which expires after the given number of seconds.
+type Counter interface {
+	Incr(key string, expires int) (int64, error)
+}
+
+// redisCounter shares the counters among all the gateway instances.
+type redisCounter struct{}
+
+func (redisCounter) Incr(key string, expires int) (int64, error) {
+	return db.IncrCounter(key, expires)
+}
+
+// RateLimit limits the number of requests each client is allowed to send to a service.
+// Every limit is counted in a fixed window, and the burst is added to the shortest one.
+type RateLimit struct {
+	PerSecond int    `json:"second"`
+	PerMinute int    `json:"minute"`
+	PerDay    int    `json:"day"`
+	Burst     int    `json:"burst"`
+	KeyBy     string `json:"key_by"`
+	service   string
+	counter   Counter
+}
+
+type rateLimitWindow struct {
+	name   string
+	period int64
+	limit  int64
+}
+
+func NewRateLimitMiddleware() Middleware {
+	return &RateLimit{counter: redisCounter{}}
+}
+
+func (rl *RateLimit) Configure(cfg string) {
+	json.Unmarshal([]byte(cfg), rl)
+}
+
+// SetService keeps the counters of each service apart.
+func (rl *RateLimit) SetService(subdomain string) {
+	rl.service = subdomain
+}
+
+func (rl *RateLimit) ProcessRequest(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	windows := rl.windows()
+	if len(windows) == 0 {
+		next(rw, r)
+		return
+	}
+
+	now := time.Now().Unix()
+	client := rl.client(r)
+	var limit, remaining, reset int64 = 0, -1, 0
+	for _, w := range windows {
+		start := now - now%w.period
+		key := fmt.Sprintf("ratelimit:%s:%s:%s:%d", rl.service, client, w.name, start)
+		hits, err := rl.counter.Incr(key, int(w.period))
+		if err != nil {
+			// The requests are not blocked when the counters are not available.
+			Logger.Warn("Failed to count the requests of `%s` for service `%s`: %+v.", client, rl.service, err)
+			next(rw, r)
+			return
+		}
+		left := w.limit - hits
+		if left < 0 {
+			// The longer windows are not charged for the requests that are rejected.
+			limit, remaining, reset = w.limit, left, start+w.period
+			break
+		}
+		if remaining < 0 || left < remaining {
+			limit, remaining, reset = w.limit, left, start+w.period
+		}
+	}
+
+	rw.Header().Set("X-RateLimit-Limit", strconv.FormatInt(limit, 10))
+	rw.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+	if remaining < 0 {
+		rw.Header().Set("X-RateLimit-Remaining", "0")
+		rw.Header().Set("Retry-After", strconv.FormatInt(reset-now, 10))
+		writeError(rw, STATUS_TOO_MANY_REQUESTS, errors.E_TOO_MANY_REQUESTS, errors.ErrRateLimitExceeded)
+		return
+	}
+	rw.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+	next(rw, r)
+}
+
+func (rl *RateLimit) windows() []rateLimitWindow {
+	windows := []rateLimitWindow{}
+	if rl.PerSecond > 0 {
+		windows = append(windows, rateLimitWindow{name: "second", period: 1, limit: int64(rl.PerSecond)})
+	}
+	if rl.PerMinute > 0 {
+		windows = append(windows, rateLimitWindow{name: "minute", period: 60, limit: int64(rl.PerMinute)})
+	}
+	if rl.PerDay > 0 {
+		windows = append(windows, rateLimitWindow{name: "day", period: 86400, limit: int64(rl.PerDay)})
+	}
+	if len(windows) > 0 && rl.Burst > 0 {
+		windows[0].limit += int64(rl.Burst)
+	}
+	return windows
+}
+
+// client identifies who is sending the request by the identity verified by the Auth middleware,
+// falling back to the client IP when the request has not been authenticated.
+func (rl *RateLimit) client(r *http.Request) string {
+	if id, ok := authenticated(r); ok {
+		switch rl.KeyBy {
+		case RATE_LIMIT_BY_CLIENT_ID:
+			return "client_id:" + id.clientId
+		case RATE_LIMIT_BY_TOKEN:
+			// Tokens are not kept in plain text on Redis.
+			sum := sha1.Sum([]byte(id.credential))
+			return "token:" + hex.EncodeToString(sum[:])
+		}
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return "ip:" + ip
+}
@@ -0,0 +1,188 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	apiErrors "github.com/apihub/apihub/errors"
+	"github.com/gorilla/context"
+	. "gopkg.in/check.v1"
+)
+
+type memoryCounter struct {
+	hits map[string]int64
+	err  error
+}
+
+func (m *memoryCounter) Incr(key string, expires int) (int64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	m.hits[key]++
+	return m.hits[key], nil
+}
+
+func newRateLimit(cfg string, counter Counter) *RateLimit {
+	rl := &RateLimit{counter: counter}
+	rl.Configure(cfg)
+	rl.SetService("test")
+	return rl
+}
+
+func sendRateLimited(rl *RateLimit, req *http.Request) *httptest.ResponseRecorder {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	res := httptest.NewRecorder()
+	rl.ProcessRequest(res, req, next)
+	return res
+}
+
+func (s *S) TestRateLimitAllowsRequestsWithinLimit(c *C) {
+	rl := newRateLimit(`{"minute": 2}`, &memoryCounter{hits: map[string]int64{}})
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	res := sendRateLimited(rl, req)
+	c.Assert(res.Code, Equals, http.StatusOK)
+	c.Assert(res.Header().Get("X-RateLimit-Limit"), Equals, "2")
+	c.Assert(res.Header().Get("X-RateLimit-Remaining"), Equals, "1")
+	c.Assert(res.Header().Get("X-RateLimit-Reset"), Not(Equals), "")
+}
+
+func (s *S) TestRateLimitExceeded(c *C) {
+	rl := newRateLimit(`{"minute": 1}`, &memoryCounter{hits: map[string]int64{}})
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	sendRateLimited(rl, req)
+	res := sendRateLimited(rl, req)
+	c.Assert(res.Code, Equals, STATUS_TOO_MANY_REQUESTS)
+	c.Assert(res.Header().Get("X-RateLimit-Remaining"), Equals, "0")
+	c.Assert(res.Header().Get("Retry-After"), Not(Equals), "")
+
+	var body apiErrors.ErrorResponse
+	json.Unmarshal(res.Body.Bytes(), &body)
+	c.Assert(body.Type, Equals, apiErrors.E_TOO_MANY_REQUESTS)
+	c.Assert(body.Description, Equals, apiErrors.ErrRateLimitExceeded.Error())
+}
+
+func (s *S) TestRateLimitWithBurst(c *C) {
+	rl := newRateLimit(`{"second": 1, "burst": 1}`, &memoryCounter{hits: map[string]int64{}})
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	c.Assert(sendRateLimited(rl, req).Code, Equals, http.StatusOK)
+	c.Assert(sendRateLimited(rl, req).Code, Equals, http.StatusOK)
+}
+
+func (s *S) TestRateLimitDoesNotChargeRejectedRequests(c *C) {
+	counter := &memoryCounter{hits: map[string]int64{}}
+	rl := newRateLimit(`{"second": 1, "day": 5}`, counter)
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	var allowed int64
+	for i := 0; i < 3; i++ {
+		if sendRateLimited(rl, req).Code == http.StatusOK {
+			allowed++
+		}
+	}
+
+	var daily int64
+	for key, hits := range counter.hits {
+		if strings.Contains(key, ":day:") {
+			daily += hits
+		}
+	}
+	c.Assert(allowed < 3, Equals, true)
+	c.Assert(daily, Equals, allowed)
+}
+
+func (s *S) TestRateLimitKeyedByClientId(c *C) {
+	rl := newRateLimit(`{"day": 1, "key_by": "client_id"}`, &memoryCounter{hits: map[string]int64{}})
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	defer context.Clear(req)
+	context.Set(req, identityKey, &identity{clientId: "first"})
+	c.Assert(sendRateLimited(rl, req).Code, Equals, http.StatusOK)
+
+	context.Set(req, identityKey, &identity{clientId: "second"})
+	c.Assert(sendRateLimited(rl, req).Code, Equals, http.StatusOK)
+	c.Assert(sendRateLimited(rl, req).Code, Equals, STATUS_TOO_MANY_REQUESTS)
+}
+
+func (s *S) TestRateLimitIgnoresClientIdNotAuthenticated(c *C) {
+	rl := newRateLimit(`{"day": 1, "key_by": "client_id"}`, &memoryCounter{hits: map[string]int64{}})
+	req, _ := http.NewRequest("GET", "http://apihub.example.org?client_id=second", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set(CLIENT_ID_HEADER, "first")
+	c.Assert(sendRateLimited(rl, req).Code, Equals, http.StatusOK)
+
+	req.Header.Set(CLIENT_ID_HEADER, "second")
+	c.Assert(sendRateLimited(rl, req).Code, Equals, STATUS_TOO_MANY_REQUESTS)
+}
+
+func (s *S) TestRateLimitKeyedByToken(c *C) {
+	counter := &memoryCounter{hits: map[string]int64{}}
+	rl := newRateLimit(`{"day": 1, "key_by": "token"}`, counter)
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	defer context.Clear(req)
+	context.Set(req, identityKey, &identity{clientId: "ios", credential: "secret"})
+	sendRateLimited(rl, req)
+
+	for key := range counter.hits {
+		c.Assert(key, Matches, "ratelimit:test:token:[0-9a-f]{40}:day:[0-9]+")
+	}
+
+	// Random tokens which have not been verified are counted by the IP.
+	context.Clear(req)
+	req.Header.Set("Authorization", "Bearer random")
+	c.Assert(sendRateLimited(rl, req).Code, Equals, http.StatusOK)
+	req.Header.Set("Authorization", "Bearer another")
+	c.Assert(sendRateLimited(rl, req).Code, Equals, STATUS_TOO_MANY_REQUESTS)
+}
+
+func (s *S) TestRateLimitAfterAuth(c *C) {
+	a, _ := setUpAuth(`{"allow_client_secret": true}`)
+	rl := newRateLimit(`{"day": 1, "key_by": "client_id"}`, &memoryCounter{hits: map[string]int64{}})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	send := func(remoteAddr string) int {
+		req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+		req.RemoteAddr = remoteAddr
+		req.SetBasicAuth("ios", "secret")
+		defer context.Clear(req)
+		res := httptest.NewRecorder()
+		a.ProcessRequest(res, req, func(w http.ResponseWriter, r *http.Request) {
+			rl.ProcessRequest(w, r, next)
+		})
+		return res.Code
+	}
+	c.Assert(send("10.0.0.1:1234"), Equals, http.StatusOK)
+	c.Assert(send("10.0.0.2:1234"), Equals, STATUS_TOO_MANY_REQUESTS)
+}
+
+func (s *S) TestRateLimitAllowsRequestsWhenCounterFails(c *C) {
+	rl := newRateLimit(`{"second": 1}`, &memoryCounter{err: errors.New("connection refused")})
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+
+	res := sendRateLimited(rl, req)
+	c.Assert(res.Code, Equals, http.StatusOK)
+	c.Assert(res.Header().Get("X-RateLimit-Limit"), Equals, "")
+}
+
+func (s *S) TestRateLimitWithoutLimits(c *C) {
+	rl := newRateLimit(`{}`, &memoryCounter{hits: map[string]int64{}})
+	req, _ := http.NewRequest("GET", "http://apihub.example.org", nil)
+
+	res := sendRateLimited(rl, req)
+	c.Assert(res.Code, Equals, http.StatusOK)
+	c.Assert(res.Header().Get("X-RateLimit-Limit"), Equals, "")
+}
@@ -0,0 +1,120 @@
+package gateway
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/apihub/apihub/account"
+)
+
+const (
+	DEFAULT_RETRY_ATTEMPTS    = 3
+	DEFAULT_RETRY_BACKOFF     = 100
+	DEFAULT_RETRY_MAX_BACKOFF = 2000
+	// MAX_REPLAYABLE_BODY_SIZE is the maximum size, in bytes, of a request body kept in memory to be retried.
+	// Requests with larger bodies are not retried.
+	MAX_REPLAYABLE_BODY_SIZE = 1 << 20
+)
+
+var (
+	defaultRetryStatusCodes = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	idempotentMethods       = []string{"GET", "HEAD", "OPTIONS", "PUT", "DELETE", "TRACE"}
+)
+
+// retryPolicy decides whether a request which failed must be sent again, and when.
+type retryPolicy struct {
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	statusCodes map[int]bool
+	methods     map[string]bool
+}
+
+func newRetryPolicy(service *account.Service) *retryPolicy {
+	retry := *service.Retry
+	if retry.MaxAttempts <= 0 {
+		retry.MaxAttempts = DEFAULT_RETRY_ATTEMPTS
+	}
+	if retry.Backoff <= 0 {
+		retry.Backoff = DEFAULT_RETRY_BACKOFF
+	}
+	if retry.MaxBackoff <= 0 {
+		retry.MaxBackoff = DEFAULT_RETRY_MAX_BACKOFF
+	}
+	if len(retry.StatusCodes) == 0 {
+		retry.StatusCodes = defaultRetryStatusCodes
+	}
+	if len(retry.Methods) == 0 {
+		retry.Methods = idempotentMethods
+	}
+
+	p := &retryPolicy{
+		maxAttempts: retry.MaxAttempts,
+		backoff:     time.Duration(retry.Backoff) * time.Millisecond,
+		maxBackoff:  time.Duration(retry.MaxBackoff) * time.Millisecond,
+		statusCodes: map[int]bool{},
+		methods:     map[string]bool{},
+	}
+	for _, code := range retry.StatusCodes {
+		p.statusCodes[code] = true
+	}
+	for _, method := range retry.Methods {
+		p.methods[strings.ToUpper(method)] = true
+	}
+	return p
+}
+
+// attempts returns how many times the request may be sent.
+func (p *retryPolicy) attempts(r *http.Request) int {
+	if p == nil || !p.methods[r.Method] {
+		return 1
+	}
+	return p.maxAttempts
+}
+
+// retryable reports whether the result of an attempt must be retried.
+func (p *retryPolicy) retryable(response *http.Response, failed bool) bool {
+	return failed || p.statusCodes[response.StatusCode]
+}
+
+// wait returns the time to wait before the given retry, doubling the backoff on each one.
+func (p *retryPolicy) wait(retry int) time.Duration {
+	wait := p.backoff
+	for i := 1; i < retry && wait < p.maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > p.maxBackoff {
+		return p.maxBackoff
+	}
+	return wait
+}
+
+// replayableBody keeps the body of the request in memory, so it may be sent again.
+// It returns a function which rewinds the body, or false when the body is too large to be kept.
+func replayableBody(r *http.Request) (func(), bool) {
+	if r.Body == nil {
+		return func() {}, true
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, MAX_REPLAYABLE_BODY_SIZE+1))
+	if err != nil || len(body) > MAX_REPLAYABLE_BODY_SIZE {
+		// The body is sent as is, without retries.
+		r.Body = &prefixedBody{Reader: io.MultiReader(bytes.NewReader(body), r.Body), Closer: r.Body}
+		return nil, false
+	}
+	r.Body.Close()
+	if len(body) == 0 {
+		return func() { r.Body = nil }, true
+	}
+	return func() { r.Body = ioutil.NopCloser(bytes.NewReader(body)) }, true
+}
+
+// prefixedBody is a body whose beginning has already been read.
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,117 @@
+package gateway
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestRetryPolicyDefaults(c *C) {
+	retry := newRetryPolicy(&account.Service{Retry: &account.Retry{}})
+	get, _ := http.NewRequest("GET", "http://test.apihub.dev", nil)
+	post, _ := http.NewRequest("POST", "http://test.apihub.dev", nil)
+
+	c.Assert(retry.attempts(get), Equals, DEFAULT_RETRY_ATTEMPTS)
+	c.Assert(retry.attempts(post), Equals, 1)
+	c.Assert(retry.retryable(&http.Response{StatusCode: http.StatusBadGateway}, false), Equals, true)
+	c.Assert(retry.retryable(&http.Response{StatusCode: http.StatusInternalServerError}, false), Equals, false)
+	c.Assert(retry.retryable(&http.Response{StatusCode: http.StatusInternalServerError}, true), Equals, true)
+
+	var none *retryPolicy
+	c.Assert(none.attempts(get), Equals, 1)
+}
+
+func (s *S) TestRetryPolicyBackoff(c *C) {
+	retry := newRetryPolicy(&account.Service{Retry: &account.Retry{Backoff: 100, MaxBackoff: 300}})
+	c.Assert(retry.wait(1), Equals, 100*time.Millisecond)
+	c.Assert(retry.wait(2), Equals, 200*time.Millisecond)
+	c.Assert(retry.wait(3), Equals, 300*time.Millisecond)
+	c.Assert(retry.wait(10), Equals, 300*time.Millisecond)
+}
+
+func (s *S) TestReplayableBody(c *C) {
+	r, _ := http.NewRequest("POST", "http://test.apihub.dev", strings.NewReader("body"))
+	rewind, ok := replayableBody(r)
+	c.Assert(ok, Equals, true)
+
+	for i := 0; i < 2; i++ {
+		rewind()
+		body, _ := ioutil.ReadAll(r.Body)
+		c.Assert(string(body), Equals, "body")
+	}
+}
+
+func (s *S) TestReplayableBodyTooLarge(c *C) {
+	large := strings.Repeat("a", MAX_REPLAYABLE_BODY_SIZE+10)
+	r, _ := http.NewRequest("POST", "http://test.apihub.dev", strings.NewReader(large))
+	_, ok := replayableBody(r)
+	c.Assert(ok, Equals, false)
+
+	body, _ := ioutil.ReadAll(r.Body)
+	c.Assert(string(body), Equals, large)
+}
+
+func (s *S) TestGatewayRetriesOnAnotherUpstream(c *C) {
+	bodies := []string{}
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.Write([]byte(r.URL.RequestURI()))
+	}))
+	defer target.Close()
+
+	service := &account.Service{
+		Subdomain: "test",
+		Upstreams: []account.Upstream{{Target: failing.URL + "/v1"}, {Target: target.URL + "/v1"}},
+		Retry:     &account.Retry{Backoff: 1, Methods: []string{"PUT"}},
+	}
+	gateway := New(s.Settings, nil)
+	gateway.AddService(service)
+
+	w := httptest.NewRecorder()
+	w.Body = new(bytes.Buffer)
+	r, _ := http.NewRequest("PUT", "http://test.apihub.dev/users?page=1", strings.NewReader("alice"))
+	gateway.ServeHTTP(w, r)
+
+	c.Assert(w.Code, Equals, http.StatusOK)
+	c.Assert(w.Body.String(), Equals, "/v1/users?page=1")
+	c.Assert(bodies, DeepEquals, []string{"alice", "alice"})
+	c.Assert(retriesTotal.Value("test"), Not(Equals), float64(0))
+}
+
+func (s *S) TestGatewayDoesNotRetryNonIdempotentMethods(c *C) {
+	hits := 0
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer target.Close()
+
+	service := &account.Service{Subdomain: "test", Endpoint: target.URL, Retry: &account.Retry{Backoff: 1}}
+	gateway := New(s.Settings, nil)
+	gateway.AddService(service)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "http://test.apihub.dev", strings.NewReader("alice"))
+	gateway.ServeHTTP(w, r)
+	c.Assert(w.Code, Equals, http.StatusServiceUnavailable)
+	c.Assert(hits, Equals, 1)
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "http://test.apihub.dev", nil)
+	gateway.ServeHTTP(w, r)
+	c.Assert(w.Code, Equals, http.StatusServiceUnavailable)
+	c.Assert(hits, Equals, 1+DEFAULT_RETRY_ATTEMPTS)
+}
@@ -10,6 +10,7 @@ package gateway
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"log"
 	"net"
@@ -56,8 +57,19 @@ type ReverseProxy struct {
 	// Transformers are supposed to modify the response before
 	// sending it back.
 	Transformers []transformer.Transformer
+
+	// StreamTransformers modify the response body while it is
+	// copied to the client. They are applied before the Transformers.
+	StreamTransformers []transformer.StreamTransformer
+
+	// MaxBufferedBodySize limits the size of the response body kept
+	// in memory, which is only needed when Transformers are used.
+	// If zero or negative, there is no limit.
+	MaxBufferedBodySize int64
 }
 
+var errBodyTooLarge = errors.New("http: response body too large to be buffered")
+
 func singleJoiningSlash(a, b string) string {
 	aslash := strings.HasSuffix(a, "/")
 	bslash := strings.HasPrefix(b, "/")
@@ -160,10 +172,33 @@ func (p *ReverseProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	}
 	defer res.Body.Close()
 
-	body := new(bytes.Buffer)
-	body.ReadFrom(res.Body)
-	for _, transformer := range p.Transformers {
-		transformer(req, res, body)
+	var body io.Reader = res.Body
+	for _, transformer := range p.StreamTransformers {
+		body = transformer(req, res, body)
+	}
+	if len(p.StreamTransformers) > 0 {
+		// The length of the body is only known after it is transformed.
+		res.ContentLength = -1
+		res.Header.Del("Content-Length")
+	}
+
+	// The body is only buffered when there are transformers which require it.
+	if len(p.Transformers) > 0 {
+		buffer, err := p.bufferBody(res, body)
+		if err != nil {
+			p.logf("http: proxy error: %v", err)
+			description := err.Error()
+			if err == errBodyTooLarge {
+				description = ERR_BODY_TOO_LARGE
+			}
+			writeResponse(rw, badGateway(description))
+			return
+		}
+		for _, transformer := range p.Transformers {
+			transformer(req, res, buffer)
+		}
+		res.ContentLength = int64(buffer.Len())
+		body = buffer
 	}
 
 	for _, h := range hopHeaders {
@@ -171,21 +206,56 @@ func (p *ReverseProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	copyHeader(rw.Header(), res.Header)
-	rw.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	if res.ContentLength >= 0 {
+		rw.Header().Set("Content-Length", strconv.FormatInt(res.ContentLength, 10))
+	}
 	rw.WriteHeader(res.StatusCode)
-	p.copyResponse(rw, body)
+	p.copyResponse(rw, body, p.flushInterval(res))
+}
+
+// bufferBody reads the whole body in memory, up to MaxBufferedBodySize.
+func (p *ReverseProxy) bufferBody(res *http.Response, body io.Reader) (*bytes.Buffer, error) {
+	buffer := new(bytes.Buffer)
+	if p.MaxBufferedBodySize <= 0 {
+		_, err := buffer.ReadFrom(body)
+		return buffer, err
+	}
+
+	if res.ContentLength > p.MaxBufferedBodySize {
+		return nil, errBodyTooLarge
+	}
+	// Read one extra byte to find out whether the body exceeds the limit.
+	n, err := buffer.ReadFrom(io.LimitReader(body, p.MaxBufferedBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if n > p.MaxBufferedBodySize {
+		return nil, errBodyTooLarge
+	}
+	return buffer, nil
+}
+
+// flushInterval returns the interval to flush the response. Server-sent events
+// are flushed after each write, which is indicated by a negative interval.
+func (p *ReverseProxy) flushInterval(res *http.Response) time.Duration {
+	if strings.HasPrefix(res.Header.Get("Content-Type"), "text/event-stream") {
+		return -1
+	}
+	return p.FlushInterval
 }
 
-func (p *ReverseProxy) copyResponse(dst io.Writer, src io.Reader) {
-	if p.FlushInterval != 0 {
+func (p *ReverseProxy) copyResponse(dst io.Writer, src io.Reader, latency time.Duration) {
+	if latency != 0 {
 		if wf, ok := dst.(writeFlusher); ok {
 			mlw := &maxLatencyWriter{
 				dst:     wf,
-				latency: p.FlushInterval,
+				latency: latency,
 				done:    make(chan bool),
 			}
-			go mlw.flushLoop()
-			defer mlw.stop()
+			if latency > 0 {
+				go mlw.flushLoop()
+				defer mlw.stop()
+			}
 			dst = mlw
 		}
 	}
@@ -217,7 +287,11 @@ type maxLatencyWriter struct {
 func (m *maxLatencyWriter) Write(p []byte) (int, error) {
 	m.lk.Lock()
 	defer m.lk.Unlock()
-	return m.dst.Write(p)
+	n, err := m.dst.Write(p)
+	if m.latency < 0 {
+		m.dst.Flush()
+	}
+	return n, err
 }
 
 func (m *maxLatencyWriter) flushLoop() {
@@ -9,6 +9,9 @@
 package gateway
 
 import (
+	"bufio"
+	"bytes"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -16,6 +19,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/apihub/apihub/gateway/transformer"
 )
 
 const fakeHopHeader = "X-Fake-Hop-Header-For-Test"
@@ -213,3 +218,107 @@ func TestReverseProxyFlushInterval(t *testing.T) {
 		t.Error("maxLatencyWriter flushLoop() never exited")
 	}
 }
+
+func TestReverseProxyEventStream(t *testing.T) {
+	proceed := make(chan bool)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: first\n\n"))
+		w.(http.Flusher).Flush()
+		// The second event is only sent after the client has received the first one.
+		<-proceed
+		w.Write([]byte("data: second\n\n"))
+	}))
+	defer backend.Close()
+
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frontend := httptest.NewServer(NewSingleHostReverseProxy(backendURL))
+	defer frontend.Close()
+
+	res, err := http.Get(frontend.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer res.Body.Close()
+
+	reader := bufio.NewReader(res.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil || line != "data: first\n" {
+		t.Fatalf("got first event %q (%v); expected %q", line, err, "data: first\n")
+	}
+	close(proceed)
+	rest, _ := ioutil.ReadAll(reader)
+	if g, e := string(rest), "\ndata: second\n\n"; g != e {
+		t.Errorf("got %q; expected %q", g, e)
+	}
+}
+
+func TestReverseProxyStreamTransformer(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("streamed body"))
+	}))
+	defer backend.Close()
+
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyHandler := NewSingleHostReverseProxy(backendURL)
+	proxyHandler.StreamTransformers = []transformer.StreamTransformer{
+		func(r *http.Request, w *http.Response, body io.Reader) io.Reader {
+			w.Header.Set("X-Stream", "true")
+			return io.MultiReader(body, strings.NewReader("!"))
+		},
+	}
+	frontend := httptest.NewServer(proxyHandler)
+	defer frontend.Close()
+
+	res, err := http.Get(frontend.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer res.Body.Close()
+	if g, e := res.Header.Get("X-Stream"), "true"; g != e {
+		t.Errorf("got X-Stream %q; expected %q", g, e)
+	}
+	if bodyBytes, _ := ioutil.ReadAll(res.Body); string(bodyBytes) != "streamed body!" {
+		t.Errorf("got body %q; expected %q", bodyBytes, "streamed body!")
+	}
+}
+
+func TestReverseProxyMaxBufferedBodySize(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a body larger than the limit"))
+	}))
+	defer backend.Close()
+
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyHandler := NewSingleHostReverseProxy(backendURL)
+	proxyHandler.MaxBufferedBodySize = 4
+	proxyHandler.Transformers = []transformer.Transformer{
+		func(r *http.Request, w *http.Response, body *bytes.Buffer) {
+			t.Error("transformer called with a body larger than the limit")
+		},
+	}
+	frontend := httptest.NewServer(proxyHandler)
+	defer frontend.Close()
+
+	res, err := http.Get(frontend.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer res.Body.Close()
+	if g, e := res.StatusCode, http.StatusBadGateway; g != e {
+		t.Errorf("got res.StatusCode %d; expected %d", g, e)
+	}
+	expected := `{"error":"bad_gateway","error_description":"` + ERR_BODY_TOO_LARGE + `"}`
+	if bodyBytes, _ := ioutil.ReadAll(res.Body); string(bodyBytes) != expected {
+		t.Errorf("got body %q; expected %q", bodyBytes, expected)
+	}
+}
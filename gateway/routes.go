@@ -0,0 +1,114 @@
+package gateway
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/apihub/apihub/account"
+)
+
+// route is an entry of the routing table, pointing to the subdomain of the service
+// which is responsible to respond the matching requests.
+type route struct {
+	account.Route
+	subdomain string
+}
+
+// routingTable keeps the routes declared by the services.
+// It is not safe for concurrent use; the Gateway protects it with its own lock.
+type routingTable []route
+
+// add replaces the routes of the given service.
+func (rt routingTable) add(service *account.Service) routingTable {
+	rt = rt.remove(service.Subdomain)
+	for _, r := range service.Routes {
+		rt = append(rt, route{Route: r, subdomain: service.Subdomain})
+	}
+	return rt
+}
+
+// remove deletes all the routes of the given subdomain.
+func (rt routingTable) remove(subdomain string) routingTable {
+	routes := rt[:0]
+	for _, r := range rt {
+		if r.subdomain != subdomain {
+			routes = append(routes, r)
+		}
+	}
+	return routes
+}
+
+// match returns the route which best fits the request.
+//
+// Exact hosts take precedence over wildcard hosts, which take precedence over routes
+// without host. Among routes with the same kind of host, the longest path prefix wins.
+// Routes without host only match the requests sent to the subdomain of their own service.
+func (rt routingTable) match(r *http.Request) (*route, bool) {
+	host := requestHost(r)
+	subdomain := extractSubdomainFromRequest(r)
+
+	var (
+		best      *route
+		bestScore int
+	)
+	for i := range rt {
+		score := matchHost(rt[i].Host, host)
+		if score < 0 || (score == 0 && rt[i].subdomain != subdomain) || !matchPathPrefix(rt[i].PathPrefix, r.URL.Path) {
+			continue
+		}
+
+		if best == nil || score > bestScore ||
+			(score == bestScore && len(rt[i].PathPrefix) > len(best.PathPrefix)) {
+			best, bestScore = &rt[i], score
+		}
+	}
+
+	return best, best != nil
+}
+
+// stripPrefix removes the path prefix of the route from the request, if required.
+func (rt *route) stripPrefix(r *http.Request) {
+	if !rt.StripPrefix || rt.PathPrefix == "" {
+		return
+	}
+
+	prefix := strings.TrimSuffix(rt.PathPrefix, "/")
+	r.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	r.URL.RawPath = ""
+	r.Header.Set("X-Forwarded-Prefix", prefix)
+}
+
+// matchHost returns a score for the host match: 2 for exact host, 1 for wildcard host,
+// 0 when the route does not declare a host and -1 when it does not match at all.
+func matchHost(pattern, host string) int {
+	pattern = strings.ToLower(pattern)
+	switch {
+	case pattern == "":
+		return 0
+	case pattern == host:
+		return 2
+	case strings.HasPrefix(pattern, "*."):
+		suffix := pattern[1:]
+		if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+			return 1
+		}
+	}
+	return -1
+}
+
+func matchPathPrefix(prefix, path string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" || path == prefix {
+		return true
+	}
+	return strings.HasPrefix(path, prefix+"/")
+}
+
+// Extract the host from request, without port.
+func requestHost(r *http.Request) string {
+	host := strings.ToLower(strings.TrimSpace(r.Host))
+	if i := strings.Index(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	return host
+}
@@ -0,0 +1,93 @@
+package gateway
+
+import (
+	"net/http"
+
+	"github.com/apihub/apihub/account"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestRoutingTableMatchExactHost(c *C) {
+	var rt routingTable
+	rt = rt.add(&account.Service{Subdomain: "wildcard", Routes: []account.Route{{Host: "*.example.org"}}})
+	rt = rt.add(&account.Service{Subdomain: "exact", Routes: []account.Route{{Host: "api.example.org"}}})
+
+	r, _ := http.NewRequest("GET", "http://api.example.org:8001/users", nil)
+	route, ok := rt.match(r)
+	c.Assert(ok, Equals, true)
+	c.Assert(route.subdomain, Equals, "exact")
+}
+
+func (s *S) TestRoutingTableMatchWildcardHost(c *C) {
+	var rt routingTable
+	rt = rt.add(&account.Service{Subdomain: "wildcard", Routes: []account.Route{{Host: "*.example.org"}}})
+
+	r, _ := http.NewRequest("GET", "http://www.example.org/users", nil)
+	route, ok := rt.match(r)
+	c.Assert(ok, Equals, true)
+	c.Assert(route.subdomain, Equals, "wildcard")
+
+	r, _ = http.NewRequest("GET", "http://example.org/users", nil)
+	_, ok = rt.match(r)
+	c.Assert(ok, Equals, false)
+}
+
+func (s *S) TestRoutingTableMatchLongestPathPrefix(c *C) {
+	var rt routingTable
+	rt = rt.add(&account.Service{Subdomain: "billing", Routes: []account.Route{{Host: "api.example.org", PathPrefix: "/billing"}}})
+	rt = rt.add(&account.Service{Subdomain: "invoices", Routes: []account.Route{{Host: "api.example.org", PathPrefix: "/billing/invoices"}}})
+
+	r, _ := http.NewRequest("GET", "http://api.example.org/billing/invoices/1", nil)
+	route, ok := rt.match(r)
+	c.Assert(ok, Equals, true)
+	c.Assert(route.subdomain, Equals, "invoices")
+
+	r, _ = http.NewRequest("GET", "http://api.example.org/billing", nil)
+	route, ok = rt.match(r)
+	c.Assert(ok, Equals, true)
+	c.Assert(route.subdomain, Equals, "billing")
+
+	r, _ = http.NewRequest("GET", "http://api.example.org/billings", nil)
+	_, ok = rt.match(r)
+	c.Assert(ok, Equals, false)
+}
+
+func (s *S) TestRoutingTableMatchRouteWithoutHost(c *C) {
+	var rt routingTable
+	rt = rt.add(&account.Service{Subdomain: "billing", Routes: []account.Route{{PathPrefix: "/v2", StripPrefix: true}}})
+
+	r, _ := http.NewRequest("GET", "http://billing.apihub.io/v2/invoices", nil)
+	route, ok := rt.match(r)
+	c.Assert(ok, Equals, true)
+	c.Assert(route.subdomain, Equals, "billing")
+
+	r, _ = http.NewRequest("GET", "http://users.apihub.io/v2/invoices", nil)
+	_, ok = rt.match(r)
+	c.Assert(ok, Equals, false)
+
+	r, _ = http.NewRequest("GET", "http://example.org/v2/invoices", nil)
+	_, ok = rt.match(r)
+	c.Assert(ok, Equals, false)
+}
+
+func (s *S) TestRoutingTableRemove(c *C) {
+	var rt routingTable
+	rt = rt.add(&account.Service{Subdomain: "billing", Routes: []account.Route{{Host: "api.example.org", PathPrefix: "/billing"}}})
+	rt = rt.remove("billing")
+
+	r, _ := http.NewRequest("GET", "http://api.example.org/billing", nil)
+	_, ok := rt.match(r)
+	c.Assert(ok, Equals, false)
+}
+
+func (s *S) TestRouteStripPrefix(c *C) {
+	rt := &route{Route: account.Route{PathPrefix: "/billing/", StripPrefix: true}}
+	r, _ := http.NewRequest("GET", "http://api.example.org/billing/invoices", nil)
+	rt.stripPrefix(r)
+	c.Assert(r.URL.Path, Equals, "/invoices")
+	c.Assert(r.Header.Get("X-Forwarded-Prefix"), Equals, "/billing")
+
+	r, _ = http.NewRequest("GET", "http://api.example.org/billing", nil)
+	rt.stripPrefix(r)
+	c.Assert(r.URL.Path, Equals, "/")
+}
@@ -1,11 +1,14 @@
 package gateway
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/analytics"
 	"github.com/apihub/apihub/gateway/middleware"
 	"github.com/apihub/apihub/gateway/transformer"
+	. "github.com/apihub/apihub/log"
 )
 
 // ServiceHandler registers the handler, transformers and middlewares for the given
@@ -15,20 +18,88 @@ type ServiceHandler struct {
 	service      *account.Service
 	transformers []transformer.Transformer
 	middlewares  []middleware.Middleware
+	upstreams    *upstreamPool
+	checker      *healthChecker
+	retry        *retryPolicy
+	breaker      *circuitBreaker
+	upstreamTLS  *upstreamTLS
+
+	// transports are the keys of the transports held for the upstreams, released when the service is removed or replaced.
+	transports []transportKey
+
+	streamTransformers  []transformer.StreamTransformer
+	maxBufferedBodySize int64
+
+	// sink receives a record of every request to the service, when analytics is enabled.
+	sink analytics.Sink
+
+	// onUpstreamStateChange is called whenever an upstream of the service is ejected, marked down or recovered.
+	onUpstreamStateChange func(account.UpstreamState)
 }
 
-// func (s *ServiceHandler) addMiddleware(m middleware.Middleware, mc *account.Plugin) {
-// 	marshal, err := json.Marshal(mc.Config)
-// 	if err != nil {
-// 		log.Printf("Failed to register middleware `%s`. Error: %s", mc.Name, err)
-// 		return
-// 	}
-// 	m.Configure(string(marshal))
-// 	s.middlewares = append(s.middlewares, m)
-// 	log.Printf("Middleware `%s` added successfully for service `%s`.", mc.Name, s.service.Subdomain)
-// }
-
-// func (s *ServiceHandler) addTransformer(name string, t transformer.Transformer) {
-// 	s.transformers = append(s.transformers, t)
-// 	log.Printf("Transformer `%s` added successfully for service `%s`.", name, s.service.Subdomain)
-// }
+// pluginOrder is the order the built-in middlewares run in, whatever the order the plugins are stored in.
+// The client is identified first, and the rate limit counts the requests before the cache answers them.
+// The other middlewares run after them, sorted by name.
+var pluginOrder = []string{"auth", "cors", "rate_limit", "cache"}
+
+type byPluginOrder []account.Plugin
+
+func (p byPluginOrder) Len() int      { return len(p) }
+func (p byPluginOrder) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byPluginOrder) Less(i, j int) bool {
+	pi, pj := pluginPriority(p[i].Name), pluginPriority(p[j].Name)
+	if pi == pj {
+		return p[i].Name < p[j].Name
+	}
+	return pi < pj
+}
+
+func pluginPriority(name string) int {
+	for i, n := range pluginOrder {
+		if n == name {
+			return i
+		}
+	}
+	return len(pluginOrder)
+}
+
+func (s *ServiceHandler) addMiddleware(m middleware.Middleware, mc account.Plugin) {
+	marshal, err := json.Marshal(mc.Config)
+	if err != nil {
+		Logger.Warn("Failed to register middleware `%s`. Error: %s.", mc.Name, err)
+		return
+	}
+	m.Configure(string(marshal))
+	if sm, ok := m.(middleware.ServiceMiddleware); ok {
+		sm.SetService(s.service.Subdomain)
+	}
+	s.middlewares = append(s.middlewares, m)
+	Logger.Info("Middleware `%s` added successfully for service `%s`.", mc.Name, s.service.Subdomain)
+}
+
+func (s *ServiceHandler) addTransformer(name string, t transformer.Transformer) {
+	s.transformers = append(s.transformers, t)
+	Logger.Info("Transformer `%s` added successfully for service `%s`.", name, s.service.Subdomain)
+}
+
+func (s *ServiceHandler) addStreamTransformer(name string, t transformer.StreamTransformer) {
+	s.streamTransformers = append(s.streamTransformers, t)
+	Logger.Info("Stream transformer `%s` added successfully for service `%s`.", name, s.service.Subdomain)
+}
+
+func (s *ServiceHandler) startHealthCheck() {
+	if s.checker != nil {
+		s.checker.start()
+		Logger.Info("Health check started for service `%s`.", s.service.Subdomain)
+	}
+}
+
+func (s *ServiceHandler) stopHealthCheck() {
+	if s.checker != nil {
+		s.checker.stop()
+	}
+}
+
+func (s *ServiceHandler) releaseTransports() {
+	transports.release(s.transports)
+}
@@ -0,0 +1,440 @@
+package gateway
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/acme"
+	. "github.com/apihub/apihub/log"
+)
+
+const (
+	DEFAULT_TLS_MIN_VERSION   = "1.2"
+	DEFAULT_ACME_RENEW_BEFORE = 30
+
+	// CERTIFICATE_MISS_TTL is how long a host without certificate is not looked up in the storage again.
+	CERTIFICATE_MISS_TTL = time.Minute
+	// ACME_RETRY_DELAY is how long the gateway waits before ordering the certificate of a host again,
+	// once the order has failed.
+	ACME_RETRY_DELAY = 10 * time.Minute
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+}
+
+// cipherSuites are the names accepted by Settings.TLSCipherSuites. RC4 suites are left out on purpose.
+var cipherSuites = map[string]uint16{
+	"TLS_RSA_WITH_3DES_EDE_CBC_SHA":           tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_CBC_SHA":            tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_RSA_WITH_AES_256_CBC_SHA":            tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA":     tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":   tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256": tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":   tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384": tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+}
+
+// ACMESettings enables the gateway to obtain the certificates of the hosts without one from an ACME
+// server, such as Let's Encrypt, answering the http-01 challenges on `Port`.
+//
+// `CACertificate` is the path of a PEM file with the root certificates trusted to reach the ACME server,
+// such as the one of a local pebble server. `AccountKey` is the path of the PEM encoded key of the account,
+// which is created when the file does not exist. Managed certificates are renewed `RenewBefore` days
+// before they expire (default 30).
+type ACMESettings struct {
+	DirectoryURL  string
+	Email         string
+	CACertificate string
+	AccountKey    string
+	RenewBefore   int
+}
+
+// certificateCache keeps the certificates by host, loaded on demand from the storage.
+// The hosts without certificate are kept as well, so the storage is not looked up on every handshake.
+// Managed certificates, obtained through ACME, are ordered and renewed by the gateway.
+type certificateCache struct {
+	mtx       sync.RWMutex
+	byHost    map[string]*tls.Certificate
+	managed   map[string]bool
+	missing   map[string]time.Time
+	obtaining map[string]bool
+	retryAt   map[string]time.Time
+}
+
+func newCertificateCache() *certificateCache {
+	return &certificateCache{
+		byHost:    make(map[string]*tls.Certificate),
+		managed:   make(map[string]bool),
+		missing:   make(map[string]time.Time),
+		obtaining: make(map[string]bool),
+		retryAt:   make(map[string]time.Time),
+	}
+}
+
+func (cc *certificateCache) get(host string) (*tls.Certificate, bool) {
+	cc.mtx.RLock()
+	defer cc.mtx.RUnlock()
+	return cc.byHost[host], cc.managed[host]
+}
+
+func (cc *certificateCache) set(host string, cert *tls.Certificate, managed bool) {
+	cc.mtx.Lock()
+	cc.byHost[host] = cert
+	cc.managed[host] = managed
+	delete(cc.missing, host)
+	cc.mtx.Unlock()
+}
+
+func (cc *certificateCache) remove(host string) {
+	cc.mtx.Lock()
+	delete(cc.byHost, host)
+	delete(cc.managed, host)
+	delete(cc.missing, host)
+	cc.mtx.Unlock()
+}
+
+// miss keeps the host as one without certificate in the storage, until CERTIFICATE_MISS_TTL has passed.
+func (cc *certificateCache) miss(host string) {
+	cc.mtx.Lock()
+	cc.missing[host] = time.Now().Add(CERTIFICATE_MISS_TTL)
+	cc.mtx.Unlock()
+}
+
+func (cc *certificateCache) missed(host string) bool {
+	cc.mtx.RLock()
+	defer cc.mtx.RUnlock()
+	return time.Now().Before(cc.missing[host])
+}
+
+// startObtaining reports whether the order of the certificate of the host must start, which happens once at a time
+// and not before ACME_RETRY_DELAY has passed since the last failure.
+func (cc *certificateCache) startObtaining(host string) bool {
+	cc.mtx.Lock()
+	defer cc.mtx.Unlock()
+	if cc.obtaining[host] || time.Now().Before(cc.retryAt[host]) {
+		return false
+	}
+	cc.obtaining[host] = true
+	return true
+}
+
+func (cc *certificateCache) finishObtaining(host string, err error) {
+	cc.mtx.Lock()
+	delete(cc.obtaining, host)
+	if err != nil {
+		cc.retryAt[host] = time.Now().Add(ACME_RETRY_DELAY)
+	} else {
+		delete(cc.retryAt, host)
+	}
+	cc.mtx.Unlock()
+}
+
+// tlsConfig returns the configuration of the HTTPS listener, which picks the certificate by the server name (SNI).
+func (g *Gateway) tlsConfig() (*tls.Config, error) {
+	version := g.Settings.TLSMinVersion
+	if version == "" {
+		version = DEFAULT_TLS_MIN_VERSION
+	}
+	minVersion, ok := tlsVersions[version]
+	if !ok {
+		return nil, fmt.Errorf("Invalid TLS version: %s.", version)
+	}
+
+	config := &tls.Config{GetCertificate: g.getCertificate, MinVersion: minVersion}
+	for _, name := range g.Settings.TLSCipherSuites {
+		id, ok := cipherSuites[name]
+		if !ok {
+			return nil, fmt.Errorf("Invalid TLS cipher suite: %s.", name)
+		}
+		config.CipherSuites = append(config.CipherSuites, id)
+		config.PreferServerCipherSuites = true
+	}
+	return config, nil
+}
+
+func (g *Gateway) serveTLS(config *tls.Config) {
+	l, err := tls.Listen("tcp", g.Settings.TLSPort, config)
+	if err != nil {
+		Logger.Error("Failed to run ApiHub over TLS: %+v.", err)
+		panic(err)
+	}
+	Logger.Info("ApiHub is now ready to accept TLS connections on port %s.", g.Settings.TLSPort)
+	server := &http.Server{Handler: g, ConnState: trackConnection}
+	Logger.Error(server.Serve(l).Error())
+}
+
+// httpHandler serves the requests received on Port: the ACME challenges come first,
+// then the other requests are either redirected to HTTPS or dispatched to the services.
+func (g *Gateway) httpHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g.solver != nil && acme.IsChallenge(r) {
+			g.solver.ServeHTTP(w, r)
+			return
+		}
+		if g.Settings.RedirectHTTPS && g.Settings.TLSPort != "" {
+			g.redirectHTTPS(w, r)
+			return
+		}
+		g.ServeHTTP(w, r)
+	})
+}
+
+func (g *Gateway) redirectHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := requestHost(r)
+	if _, port, err := net.SplitHostPort(g.Settings.TLSPort); err == nil && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+	u := *r.URL
+	u.Scheme = "https"
+	u.Host = host
+	http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+}
+
+// getCertificate returns the certificate of the host, falling back to the wildcard certificate of its domain.
+// When ACME is enabled, the certificate of a host served by a service is ordered in background,
+// so the handshake fails until it is obtained.
+func (g *Gateway) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	host := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if host == "" {
+		return nil, fmt.Errorf("The client has not informed the server name.")
+	}
+
+	names := []string{host}
+	if i := strings.Index(host, "."); i > 0 {
+		names = append(names, "*"+host[i:])
+	}
+	for _, name := range names {
+		if cert := g.certificate(name); cert != nil {
+			return cert, nil
+		}
+	}
+
+	if g.acme != nil {
+		if subdomain, ok := g.serviceOfHost(host); ok {
+			g.obtainCertificateInBackground(host, subdomain)
+		}
+	}
+	return nil, fmt.Errorf("There is no certificate for %s.", host)
+}
+
+// certificate loads the certificate of the host, renewing it in background when it is managed and about to expire.
+func (g *Gateway) certificate(host string) *tls.Certificate {
+	cert, managed := g.certificates.get(host)
+	if cert == nil {
+		if g.store == nil || g.certificates.missed(host) {
+			return nil
+		}
+		c, err := g.store.FindCertificateByHost(host)
+		if err != nil {
+			g.certificates.miss(host)
+			return nil
+		}
+		if cert, err = parseCertificate(c); err != nil {
+			Logger.Warn("Failed to load the certificate of `%s`: %+v.", host, err)
+			return nil
+		}
+		managed = c.Managed
+		g.certificates.set(host, cert, managed)
+	}
+
+	if managed && g.acme != nil && time.Now().Add(g.renewBefore()).After(cert.Leaf.NotAfter) {
+		if subdomain, ok := g.serviceOfHost(host); ok {
+			g.obtainCertificateInBackground(host, subdomain)
+		}
+	}
+	return cert
+}
+
+// obtainCertificateInBackground orders the certificate of the host apart from the handshake,
+// unless it is already being ordered or the last order has failed recently.
+func (g *Gateway) obtainCertificateInBackground(host, subdomain string) {
+	if !g.certificates.startObtaining(host) {
+		return
+	}
+	go func() {
+		_, err := g.obtainCertificate(host, subdomain)
+		g.certificates.finishObtaining(host, err)
+	}()
+}
+
+// obtainCertificate orders the certificate of the host through ACME, keeping it as a managed certificate.
+func (g *Gateway) obtainCertificate(host, subdomain string) (*tls.Certificate, error) {
+	Logger.Info("Obtaining a certificate for `%s` through ACME.", host)
+	chain, key, err := g.acme.Obtain(g.solver, host)
+	if err != nil {
+		Logger.Warn("Failed to obtain a certificate for `%s`: %+v.", host, err)
+		return nil, err
+	}
+
+	c := account.Certificate{Host: host, Service: subdomain, Certificate: string(chain), Key: string(key), Managed: true}
+	cert, err := parseCertificate(c)
+	if err != nil {
+		return nil, err
+	}
+	c.NotAfter = cert.Leaf.NotAfter.In(time.UTC).Format(time.RFC3339)
+	if g.store != nil {
+		if err := g.store.UpsertCertificate(c); err != nil {
+			Logger.Warn("Failed to keep the certificate of `%s`: %+v.", host, err)
+		}
+	}
+	g.certificates.set(host, cert, true)
+	Logger.Info("Certificate obtained for `%s`, valid until %s.", host, c.NotAfter)
+	return cert, nil
+}
+
+// serviceOfHost returns the subdomain of the service which a certificate may be obtained for: the host is either
+// the exact host of one of its routes, or its subdomain under the host of the gateway. Wildcard routes and any
+// other host sent by the clients are left out, so they cannot make the gateway order certificates at will.
+func (g *Gateway) serviceOfHost(host string) (string, bool) {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+
+	subdomain := ""
+	for _, rt := range g.routes {
+		if strings.ToLower(rt.Host) == host {
+			subdomain = rt.subdomain
+			break
+		}
+	}
+	if base := strings.ToLower(g.Settings.Host); subdomain == "" && base != "" && strings.HasSuffix(host, "."+base) {
+		if name := strings.TrimSuffix(host, "."+base); !strings.Contains(name, ".") {
+			subdomain = name
+		}
+	}
+	if subdomain == "" {
+		return "", false
+	}
+	_, ok := g.services[subdomain]
+	return subdomain, ok
+}
+
+func (g *Gateway) renewBefore() time.Duration {
+	days := g.Settings.ACME.RenewBefore
+	if days <= 0 {
+		days = DEFAULT_ACME_RENEW_BEFORE
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func parseCertificate(c account.Certificate) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair([]byte(c.Certificate), []byte(c.Key))
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// loadACME creates the ACME client from the settings, along with the solver of the challenges.
+func (g *Gateway) loadACME() error {
+	settings := g.Settings.ACME
+	if settings == nil {
+		return nil
+	}
+
+	key, err := loadAccountKey(settings.AccountKey)
+	if err != nil {
+		return err
+	}
+	client, err := acme.NewClient(settings.DirectoryURL, settings.Email, key)
+	if err != nil {
+		return err
+	}
+	if settings.CACertificate != "" {
+		roots, err := ioutil.ReadFile(settings.CACertificate)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(roots) {
+			return fmt.Errorf("Invalid CA certificate: %s.", settings.CACertificate)
+		}
+		client.HTTPClient = &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	}
+
+	g.acme = client
+	g.solver = acme.NewHTTPSolver()
+	return nil
+}
+
+// loadAccountKey reads the key of the ACME account, creating it when the file does not exist.
+// Without a path, a new account is created whenever the gateway starts.
+func loadAccountKey(path string) (*ecdsa.PrivateKey, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("Invalid ACME account key: %s.", path)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return key, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600)
+}
+
+// RefreshCertificates drops the certificates whenever they are uploaded or deleted through the api,
+// so they are loaded again from the storage.
+func (g *Gateway) RefreshCertificates() {
+	receiverC := make(chan interface{})
+	done := make(chan bool)
+
+	g.pubsub.Subscribe("/certificates", receiverC, done)
+
+	go func() {
+		for msg := range receiverC {
+			if msg != nil {
+				pubsubUpdates.Inc("/certificates")
+				m, ok := msg.(string)
+				if !ok {
+					Logger.Warn("Failed to convert message to string: %+v.", msg)
+					continue
+				}
+
+				var cert account.Certificate
+				if err := json.Unmarshal([]byte(m), &cert); err != nil {
+					Logger.Warn("Failed to decode certificate data: %+v.", msg)
+					continue
+				}
+				g.certificates.remove(cert.Host)
+			}
+		}
+	}()
+}
@@ -0,0 +1,304 @@
+package gateway
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/account/mem"
+	"github.com/apihub/apihub/acme/acmetest"
+	. "gopkg.in/check.v1"
+)
+
+// selfSignedCertificate returns a PEM encoded certificate and key, valid for the given hosts until notAfter.
+func selfSignedCertificate(c *C, notAfter time.Time, hosts ...string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		DNSNames:     hosts,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	c.Assert(err, IsNil)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, IsNil)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+}
+
+func newTLSGateway(c *C, settings *Settings, certs ...account.Certificate) (*Gateway, *mem.Mem) {
+	store := mem.New()
+	for _, cert := range certs {
+		store.UpsertCertificate(cert)
+	}
+	gateway := New(settings, nil)
+	gateway.Storage(store)
+	return gateway, store
+}
+
+func (s *S) TestTLSConfig(c *C) {
+	gateway := New(s.Settings, nil)
+	config, err := gateway.tlsConfig()
+	c.Assert(err, IsNil)
+	c.Assert(config.MinVersion, Equals, uint16(tls.VersionTLS12))
+	c.Assert(config.CipherSuites, IsNil)
+
+	s.Settings.TLSMinVersion = "1.1"
+	s.Settings.TLSCipherSuites = []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}
+	config, err = gateway.tlsConfig()
+	c.Assert(err, IsNil)
+	c.Assert(config.MinVersion, Equals, uint16(tls.VersionTLS11))
+	c.Assert(config.CipherSuites, DeepEquals, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256})
+}
+
+func (s *S) TestTLSConfigWithInvalidSettings(c *C) {
+	gateway := New(s.Settings, nil)
+	s.Settings.TLSMinVersion = "3.0"
+	_, err := gateway.tlsConfig()
+	c.Assert(err, NotNil)
+
+	s.Settings.TLSMinVersion = ""
+	s.Settings.TLSCipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"}
+	_, err = gateway.tlsConfig()
+	c.Assert(err, NotNil)
+}
+
+func (s *S) TestGetCertificateByServerName(c *C) {
+	certPEM, keyPEM := selfSignedCertificate(c, time.Now().Add(time.Hour), "api.example.org")
+	wildcardPEM, wildcardKeyPEM := selfSignedCertificate(c, time.Now().Add(time.Hour), "*.example.com")
+	gateway, store := newTLSGateway(c, s.Settings,
+		account.Certificate{Host: "api.example.org", Service: "test", Certificate: certPEM, Key: keyPEM},
+		account.Certificate{Host: "*.example.com", Service: "test", Certificate: wildcardPEM, Key: wildcardKeyPEM},
+	)
+
+	cert, err := gateway.getCertificate(&tls.ClientHelloInfo{ServerName: "API.example.org"})
+	c.Assert(err, IsNil)
+	c.Assert(cert.Leaf.DNSNames, DeepEquals, []string{"api.example.org"})
+
+	cert, err = gateway.getCertificate(&tls.ClientHelloInfo{ServerName: "www.example.com"})
+	c.Assert(err, IsNil)
+	c.Assert(cert.Leaf.DNSNames, DeepEquals, []string{"*.example.com"})
+
+	_, err = gateway.getCertificate(&tls.ClientHelloInfo{ServerName: "www.example.org"})
+	c.Assert(err, NotNil)
+	_, err = gateway.getCertificate(&tls.ClientHelloInfo{})
+	c.Assert(err, NotNil)
+
+	// The certificates are cached until they are refreshed.
+	store.DeleteCertificate(account.Certificate{Host: "api.example.org"})
+	_, err = gateway.getCertificate(&tls.ClientHelloInfo{ServerName: "api.example.org"})
+	c.Assert(err, IsNil)
+	gateway.certificates.remove("api.example.org")
+	_, err = gateway.getCertificate(&tls.ClientHelloInfo{ServerName: "api.example.org"})
+	c.Assert(err, NotNil)
+}
+
+func (s *S) TestGatewayServesHTTPS(c *C) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	defer target.Close()
+
+	certPEM, keyPEM := selfSignedCertificate(c, time.Now().Add(time.Hour), "api.example.org")
+	gateway, _ := newTLSGateway(c, s.Settings, account.Certificate{Host: "api.example.org", Service: "test", Certificate: certPEM, Key: keyPEM})
+	gateway.AddService(&account.Service{Endpoint: target.URL, Subdomain: "test", Routes: []account.Route{{Host: "api.example.org"}}})
+
+	config, err := gateway.tlsConfig()
+	c.Assert(err, IsNil)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	c.Assert(err, IsNil)
+	defer l.Close()
+	go http.Serve(l, gateway)
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM([]byte(certPEM))
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "api.example.org"}}}
+	r, _ := http.NewRequest("GET", "https://"+l.Addr().String(), nil)
+	r.Host = "api.example.org"
+	res, err := client.Do(r)
+	c.Assert(err, IsNil)
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+	c.Assert(res.StatusCode, Equals, http.StatusOK)
+	c.Assert(string(body), Equals, "OK")
+}
+
+func (s *S) TestRedirectHTTPS(c *C) {
+	s.Settings.TLSPort = ":8443"
+	s.Settings.RedirectHTTPS = true
+	gateway := New(s.Settings, nil)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://api.example.org:4567/users?page=2", nil)
+	gateway.httpHandler().ServeHTTP(w, r)
+	c.Assert(w.Code, Equals, http.StatusMovedPermanently)
+	c.Assert(w.Header().Get("Location"), Equals, "https://api.example.org:8443/users?page=2")
+
+	s.Settings.TLSPort = ":443"
+	w = httptest.NewRecorder()
+	gateway.httpHandler().ServeHTTP(w, r)
+	c.Assert(w.Header().Get("Location"), Equals, "https://api.example.org/users?page=2")
+}
+
+func (s *S) TestWithoutRedirectHTTPS(c *C) {
+	s.Settings.TLSPort = ":8443"
+	gateway := New(s.Settings, nil)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://api.example.org/users", nil)
+	gateway.httpHandler().ServeHTTP(w, r)
+	c.Assert(w.Code, Equals, http.StatusNotFound)
+}
+
+func (s *S) TestGatewayObtainsCertificatesThroughACME(c *C) {
+	server, err := acmetest.NewServer()
+	c.Assert(err, IsNil)
+	defer server.Close()
+
+	s.Settings.TLSPort = ":8443"
+	s.Settings.RedirectHTTPS = true
+	s.Settings.ACME = &ACMESettings{DirectoryURL: server.DirectoryURL(), Email: "alice@example.org"}
+	gateway, store := newTLSGateway(c, s.Settings)
+	c.Assert(gateway.loadACME(), IsNil)
+	gateway.acme.PollInterval = time.Millisecond
+	gateway.AddService(&account.Service{Endpoint: "http://example.org", Subdomain: "test", Routes: []account.Route{{Host: "api.example.org"}}})
+
+	// The challenges are answered on the plain port, even when the requests are redirected to HTTPS.
+	challenges := httptest.NewServer(gateway.httpHandler())
+	defer challenges.Close()
+	server.ChallengeURL = challenges.URL
+
+	// The certificate is obtained apart from the handshake, which fails meanwhile.
+	_, err = gateway.getCertificate(&tls.ClientHelloInfo{ServerName: "api.example.org"})
+	c.Assert(err, NotNil)
+	cert := waitCertificate(gateway, "api.example.org")
+	c.Assert(cert, NotNil)
+	c.Assert(cert.Leaf.DNSNames, DeepEquals, []string{"api.example.org"})
+	c.Assert(cert.Leaf.CheckSignatureFrom(server.CA), IsNil)
+	cert, err = gateway.getCertificate(&tls.ClientHelloInfo{ServerName: "api.example.org"})
+	c.Assert(err, IsNil)
+
+	stored, err := store.FindCertificateByHost("api.example.org")
+	c.Assert(err, IsNil)
+	c.Assert(stored.Managed, Equals, true)
+	c.Assert(stored.Service, Equals, "test")
+
+	// Hosts which are not served by any service do not get certificates.
+	_, err = gateway.getCertificate(&tls.ClientHelloInfo{ServerName: "www.example.org"})
+	c.Assert(err, NotNil)
+	c.Assert(gateway.certificates.obtaining["www.example.org"], Equals, false)
+}
+
+// waitCertificate waits for the certificate of the host to be obtained in background.
+func waitCertificate(gateway *Gateway, host string) *tls.Certificate {
+	for i := 0; i < 100; i++ {
+		if cert, _ := gateway.certificates.get(host); cert != nil {
+			return cert
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+func (s *S) TestServiceOfHost(c *C) {
+	s.Settings.Host = "apihub.dev"
+	gateway := New(s.Settings, nil)
+	gateway.AddService(&account.Service{Endpoint: "http://example.org", Subdomain: "test", Routes: []account.Route{{Host: "api.example.org"}, {Host: "*.example.com"}}})
+
+	for host, expected := range map[string]bool{
+		"api.example.org":      true,
+		"test.apihub.dev":      true,
+		"www.example.com":      false,
+		"test.example.net":     false,
+		"other.apihub.dev":     false,
+		"www.test.apihub.dev":  false,
+		"test.apihub.dev.evil": false,
+	} {
+		_, ok := gateway.serviceOfHost(host)
+		c.Check(ok, Equals, expected, Commentf("%s", host))
+	}
+}
+
+func (s *S) TestGetCertificateCachesMissingHosts(c *C) {
+	gateway, store := newTLSGateway(c, s.Settings)
+	_, err := gateway.getCertificate(&tls.ClientHelloInfo{ServerName: "api.example.org"})
+	c.Assert(err, NotNil)
+
+	// The storage is not looked up again until the certificates are refreshed.
+	certPEM, keyPEM := selfSignedCertificate(c, time.Now().Add(time.Hour), "api.example.org")
+	store.UpsertCertificate(account.Certificate{Host: "api.example.org", Service: "test", Certificate: certPEM, Key: keyPEM})
+	_, err = gateway.getCertificate(&tls.ClientHelloInfo{ServerName: "api.example.org"})
+	c.Assert(err, NotNil)
+	gateway.certificates.remove("api.example.org")
+	_, err = gateway.getCertificate(&tls.ClientHelloInfo{ServerName: "api.example.org"})
+	c.Assert(err, IsNil)
+}
+
+func (s *S) TestGatewayWaitsBeforeOrderingFailedCertificatesAgain(c *C) {
+	cc := newCertificateCache()
+	c.Assert(cc.startObtaining("api.example.org"), Equals, true)
+	c.Assert(cc.startObtaining("api.example.org"), Equals, false)
+	cc.finishObtaining("api.example.org", errors.New("rate limited"))
+	c.Assert(cc.startObtaining("api.example.org"), Equals, false)
+
+	cc.retryAt["api.example.org"] = time.Now()
+	c.Assert(cc.startObtaining("api.example.org"), Equals, true)
+}
+
+func (s *S) TestGatewayRenewsManagedCertificates(c *C) {
+	server, err := acmetest.NewServer()
+	c.Assert(err, IsNil)
+	defer server.Close()
+
+	s.Settings.ACME = &ACMESettings{DirectoryURL: server.DirectoryURL()}
+	certPEM, keyPEM := selfSignedCertificate(c, time.Now().Add(24*time.Hour), "api.example.org")
+	gateway, store := newTLSGateway(c, s.Settings, account.Certificate{Host: "api.example.org", Service: "test", Certificate: certPEM, Key: keyPEM, Managed: true})
+	c.Assert(gateway.loadACME(), IsNil)
+	gateway.acme.PollInterval = time.Millisecond
+	gateway.AddService(&account.Service{Endpoint: "http://example.org", Subdomain: "test", Routes: []account.Route{{Host: "api.example.org"}}})
+	challenges := httptest.NewServer(gateway.httpHandler())
+	defer challenges.Close()
+	server.ChallengeURL = challenges.URL
+
+	// The certificate about to expire is served while the new one is obtained.
+	cert, err := gateway.getCertificate(&tls.ClientHelloInfo{ServerName: "api.example.org"})
+	c.Assert(err, IsNil)
+	c.Assert(cert.Leaf.Issuer.CommonName, Equals, "api.example.org")
+
+	for i := 0; i < 100; i++ {
+		if cert, _ = gateway.certificates.get("api.example.org"); cert.Leaf.CheckSignatureFrom(server.CA) == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Assert(cert.Leaf.CheckSignatureFrom(server.CA), IsNil)
+	stored, err := store.FindCertificateByHost("api.example.org")
+	c.Assert(err, IsNil)
+	c.Assert(stored.Certificate, Not(Equals), certPEM)
+}
+
+func (s *S) TestLoadAccountKey(c *C) {
+	dir, err := ioutil.TempDir("", "apihub")
+	c.Assert(err, IsNil)
+	path := dir + "/account.pem"
+
+	key, err := loadAccountKey(path)
+	c.Assert(err, IsNil)
+	again, err := loadAccountKey(path)
+	c.Assert(err, IsNil)
+	c.Assert(again.D.Cmp(key.D), Equals, 0)
+}
@@ -2,6 +2,7 @@ package transformer
 
 import (
 	"bytes"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -24,6 +25,29 @@ func (f Transformers) Get(key string) Transformer {
 	return f[key]
 }
 
+// Function which wraps the response body, modifying it while it is streamed to the client.
+// Unlike Transformer, it must not read the whole body in advance.
+type StreamTransformer func(*http.Request, *http.Response, io.Reader) io.Reader
+
+// An array of StreamTransformer with key to be used by the gateway and service.
+type StreamTransformers map[string]StreamTransformer
+
+func (f StreamTransformers) Add(key string, value StreamTransformer) {
+	f[key] = value
+}
+
+func (f StreamTransformers) Get(key string) StreamTransformer {
+	return f[key]
+}
+
+// Default returns the transformers provided by ApiHub out of the box.
+func Default() Transformers {
+	return Transformers{
+		"ConvertXmlToJson": ConvertXmlToJson,
+		"ConvertJsonToXml": ConvertJsonToXml,
+	}
+}
+
 // Given a xml as response body, convert it to json.
 func ConvertXmlToJson(r *http.Request, w *http.Response, body *bytes.Buffer) {
 	b, err := ioutil.ReadAll(body)
@@ -18,6 +18,12 @@ func (s *S) TestAddTransformer(c *C) {
 	c.Check(s.transformers.Get("AddHeader"), NotNil)
 }
 
+func (s *S) TestDefaultTransformers(c *C) {
+	transformers := Default()
+	c.Check(transformers.Get("ConvertXmlToJson"), NotNil)
+	c.Check(transformers.Get("ConvertJsonToXml"), NotNil)
+}
+
 func (s *S) TestConvertXmlToJson(c *C) {
 	req := &http.Request{Header: make(http.Header)}
 	resp := &http.Response{Header: make(http.Header)}
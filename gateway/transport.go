@@ -0,0 +1,183 @@
+package gateway
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apihub/apihub/account"
+)
+
+// DEFAULT_MAX_IDLE_CONNS_PER_HOST is the number of connections kept alive to each upstream host.
+const DEFAULT_MAX_IDLE_CONNS_PER_HOST = 32
+
+// timeouts are the timeouts of a service, resolved from its settings.
+// A zero total means that the request is not limited as a whole.
+type timeouts struct {
+	connect        time.Duration
+	responseHeader time.Duration
+	total          time.Duration
+	idle           time.Duration
+}
+
+func newTimeouts(service *account.Service) timeouts {
+	t := service.Timeout
+	if t <= 0 {
+		t = DEFAULT_TIMEOUT
+	}
+	d := time.Duration(t) * time.Second
+	ts := timeouts{connect: d, responseHeader: d, idle: d}
+	if service.Timeouts == nil {
+		return ts
+	}
+
+	ms := func(v int, def time.Duration) time.Duration {
+		if v <= 0 {
+			return def
+		}
+		return time.Duration(v) * time.Millisecond
+	}
+	ts.connect = ms(service.Timeouts.Connect, ts.connect)
+	ts.responseHeader = ms(service.Timeouts.ResponseHeader, ts.responseHeader)
+	ts.idle = ms(service.Timeouts.Idle, ts.idle)
+	ts.total = ms(service.Timeouts.Total, 0)
+	return ts
+}
+
+// transportKey identifies the transports which may be shared.
+// The total timeout is enforced per request, so it is not part of the key.
+type transportKey struct {
+	scheme         string
+	host           string
+	connect        time.Duration
+	responseHeader time.Duration
+	idle           time.Duration
+	tls            string
+}
+
+// transportPool shares the transports, and so their idle connections, among the services
+// sending requests to the same upstream host with the same timeouts and TLS configuration.
+// Each transport is kept while a service holds it, and closed once the last one releases it.
+type transportPool struct {
+	mtx        sync.Mutex
+	transports map[transportKey]*http.Transport
+	users      map[transportKey]int
+}
+
+var transports = &transportPool{transports: make(map[transportKey]*http.Transport), users: make(map[transportKey]int)}
+
+func newTransportKey(target *url.URL, t timeouts, tlsc *upstreamTLS) transportKey {
+	key := transportKey{scheme: target.Scheme, host: target.Host, connect: t.connect, responseHeader: t.responseHeader, idle: t.idle}
+	if tlsc != nil {
+		key.tls = tlsc.id
+	}
+	return key
+}
+
+// get returns the transport held for the target. The requests of the services which do not hold it,
+// such as the ones still being served after their service is removed, get a transport of their own.
+func (p *transportPool) get(target *url.URL, t timeouts, tlsc *upstreamTLS) *http.Transport {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if transport, ok := p.transports[newTransportKey(target, t, tlsc)]; ok {
+		return transport
+	}
+	return newTransport(t, tlsc)
+}
+
+// acquire holds the transports to the targets, until they are released.
+func (p *transportPool) acquire(targets []*url.URL, t timeouts, tlsc *upstreamTLS) []transportKey {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	keys := []transportKey{}
+	held := map[transportKey]bool{}
+	for _, target := range targets {
+		key := newTransportKey(target, t, tlsc)
+		if held[key] {
+			continue
+		}
+		held[key] = true
+		if _, ok := p.transports[key]; !ok {
+			p.transports[key] = newTransport(t, tlsc)
+		}
+		p.users[key]++
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// release gives up the transports acquired, closing the idle connections of the ones no longer held.
+func (p *transportPool) release(keys []transportKey) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	for _, key := range keys {
+		if p.users[key]--; p.users[key] > 0 {
+			continue
+		}
+		if transport, ok := p.transports[key]; ok {
+			transport.CloseIdleConnections()
+		}
+		delete(p.transports, key)
+		delete(p.users, key)
+	}
+}
+
+func newTransport(t timeouts, tlsc *upstreamTLS) *http.Transport {
+	transport := &http.Transport{
+		Dial:                  idleTimeoutDialer(t.connect, t.idle),
+		Proxy:                 http.ProxyFromEnvironment,
+		TLSHandshakeTimeout:   t.connect,
+		ResponseHeaderTimeout: t.responseHeader,
+		MaxIdleConnsPerHost:   DEFAULT_MAX_IDLE_CONNS_PER_HOST,
+	}
+	if tlsc != nil {
+		transport.DialTLS = tlsc.dialer(idleTimeoutDialer(t.connect, t.idle), t.connect)
+	}
+	return transport
+}
+
+// idleTimeoutDialer opens connections which are closed only when no data flows for the idle timeout,
+// so connections kept alive and slow streaming responses are not broken while active.
+func idleTimeoutDialer(connect, idle time.Duration) func(network, addr string) (net.Conn, error) {
+	return func(network, addr string) (net.Conn, error) {
+		conn, err := net.DialTimeout(network, addr, connect)
+		if err != nil {
+			return nil, err
+		}
+		return &idleTimeoutConn{Conn: conn, timeout: idle}, nil
+	}
+}
+
+// deadline cancels the request when the total timeout of the service expires.
+type deadline struct {
+	timer   *time.Timer
+	expired int32
+}
+
+// startDeadline starts counting the total timeout of the request, if the service has one.
+func startDeadline(r *http.Request, total time.Duration) *deadline {
+	if total <= 0 {
+		return nil
+	}
+	cancel := make(chan struct{})
+	r.Cancel = cancel
+	d := &deadline{}
+	d.timer = time.AfterFunc(total, func() {
+		atomic.StoreInt32(&d.expired, 1)
+		close(cancel)
+	})
+	return d
+}
+
+func (d *deadline) exceeded() bool {
+	return d != nil && atomic.LoadInt32(&d.expired) == 1
+}
+
+func (d *deadline) stop() {
+	if d != nil {
+		d.timer.Stop()
+	}
+}
@@ -0,0 +1,144 @@
+package gateway
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestNewTimeoutsDefaultsToServiceTimeout(c *C) {
+	t := newTimeouts(&account.Service{Timeout: 2})
+	c.Assert(t, Equals, timeouts{connect: 2 * time.Second, responseHeader: 2 * time.Second, idle: 2 * time.Second})
+
+	t = newTimeouts(&account.Service{})
+	c.Assert(t.connect, Equals, DEFAULT_TIMEOUT*time.Second)
+}
+
+func (s *S) TestNewTimeoutsInMilliseconds(c *C) {
+	t := newTimeouts(&account.Service{Timeout: 2, Timeouts: &account.Timeouts{Connect: 150, Total: 5000, Idle: 60000}})
+	c.Assert(t, Equals, timeouts{connect: 150 * time.Millisecond, responseHeader: 2 * time.Second, total: 5 * time.Second, idle: time.Minute})
+}
+
+func (s *S) TestTransportsAreSharedByHost(c *C) {
+	one, _ := url.Parse("http://10.0.0.1:8080/v1")
+	other, _ := url.Parse("http://10.0.0.1:8080/v2")
+	another, _ := url.Parse("http://10.0.0.2:8080")
+	t := timeouts{connect: time.Second, responseHeader: time.Second, idle: time.Second}
+	keys := transports.acquire([]*url.URL{one, other, another}, t, nil)
+	defer transports.release(keys)
+	c.Assert(keys, HasLen, 2)
+
+	c.Assert(transports.get(one, t, nil), Equals, transports.get(other, t, nil))
+	c.Assert(transports.get(one, t, nil), Not(Equals), transports.get(another, t, nil))
+
+	// The total timeout does not change the transport.
+	withTotal := t
+	withTotal.total = time.Minute
+	c.Assert(transports.get(one, t, nil), Equals, transports.get(one, withTotal, nil))
+	withTotal.idle = time.Minute
+	c.Assert(transports.get(one, t, nil), Not(Equals), transports.get(one, withTotal, nil))
+}
+
+func (s *S) TestTransportsAreClosedOnceReleased(c *C) {
+	target, _ := url.Parse("http://10.0.0.3:8080")
+	t := timeouts{connect: time.Second, responseHeader: time.Second, idle: time.Second}
+	first := transports.acquire([]*url.URL{target}, t, nil)
+	second := transports.acquire([]*url.URL{target}, t, nil)
+	transport := transports.get(target, t, nil)
+
+	transports.release(first)
+	c.Assert(transports.get(target, t, nil), Equals, transport)
+	transports.release(second)
+	c.Assert(transports.get(target, t, nil), Not(Equals), transport)
+}
+
+func (s *S) TestGatewayReleasesTheTransportsOfRemovedServices(c *C) {
+	service := &account.Service{Endpoint: "http://10.0.0.4:8080", Subdomain: "test"}
+	target, _ := url.Parse(service.Endpoint)
+	t := newTimeouts(service)
+
+	gateway := New(s.Settings, nil)
+	gateway.AddService(service)
+	transport := transports.get(target, t, nil)
+
+	// The transports are kept while the service is reloaded.
+	gateway.AddService(service)
+	c.Assert(transports.get(target, t, nil), Equals, transport)
+
+	gateway.RemoveService(service)
+	c.Assert(transports.get(target, t, nil), Not(Equals), transport)
+}
+
+func (s *S) TestGatewayResponseHeaderTimeout(c *C) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer target.Close()
+
+	gateway := New(s.Settings, nil)
+	gateway.AddService(&account.Service{Endpoint: target.URL, Subdomain: "test", Timeouts: &account.Timeouts{ResponseHeader: 50}})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://test.apihub.dev", nil)
+	gateway.ServeHTTP(w, r)
+	c.Assert(w.Code, Equals, http.StatusGatewayTimeout)
+}
+
+func (s *S) TestGatewayTotalTimeout(c *C) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer target.Close()
+
+	gateway := New(s.Settings, nil)
+	gateway.AddService(&account.Service{Endpoint: target.URL, Subdomain: "test", Timeouts: &account.Timeouts{Total: 50}})
+
+	w := httptest.NewRecorder()
+	w.Body = new(bytes.Buffer)
+	r, _ := http.NewRequest("GET", "http://test.apihub.dev", nil)
+	start := time.Now()
+	gateway.ServeHTTP(w, r)
+	c.Assert(w.Code, Equals, http.StatusGatewayTimeout)
+	c.Assert(time.Since(start) < 200*time.Millisecond, Equals, true)
+	c.Assert(w.Body.String(), Equals, `{"error":"gateway_timeout","error_description":"`+ERR_TIMEOUT+`"}`)
+}
+
+func (s *S) TestGatewayKeepsActiveConnectionsBeyondIdleTimeout(c *C) {
+	var conns int32
+	target := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The response streams for longer than the idle timeout, without staying idle.
+		for i := 0; i < 5; i++ {
+			fmt.Fprintf(w, "%d", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(40 * time.Millisecond)
+		}
+	}))
+	target.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	target.Start()
+	defer target.Close()
+
+	gateway := New(s.Settings, nil)
+	gateway.AddService(&account.Service{Endpoint: target.URL, Subdomain: "idle", Timeouts: &account.Timeouts{Idle: 100}})
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		w.Body = new(bytes.Buffer)
+		r, _ := http.NewRequest("GET", "http://idle.apihub.dev", nil)
+		gateway.ServeHTTP(w, r)
+		c.Assert(w.Code, Equals, http.StatusOK)
+		c.Assert(w.Body.String(), Equals, "01234")
+	}
+	c.Assert(atomic.LoadInt32(&conns), Equals, int32(1))
+}
@@ -0,0 +1,209 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	. "github.com/apihub/apihub/log"
+)
+
+const ERR_UPGRADE_NOT_SUPPORTED = "The connection cannot be upgraded by the gateway."
+
+// Hop-by-hop headers which are removed from upgrade requests.
+// Connection and Upgrade are kept, since the upstream needs them to switch protocols.
+var upgradeHopHeaders = []string{
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailers",
+	"Transfer-Encoding",
+}
+
+// isUpgrade reports whether the client asks to switch protocols, such as WebSocket.
+func isUpgrade(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, value := range r.Header["Connection"] {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveUpgrade sends the upgrade request to an upstream and, once it switches protocols,
+// hijacks the client connection and tunnels the data in both directions.
+func (rp *Dispatcher) serveUpgrade(w http.ResponseWriter, r *http.Request) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		writeResponse(w, internalServerError(ERR_UPGRADE_NOT_SUPPORTED))
+		return
+	}
+
+	u, err := rp.upstreams.next(r)
+	if err != nil {
+		writeResponse(w, serviceUnavailable(ERR_NO_UPSTREAM))
+		return
+	}
+
+	outreq := new(http.Request)
+	*outreq = *r
+	outreq.URL = new(url.URL)
+	*outreq.URL = *r.URL
+	outreq.Header = make(http.Header)
+	copyHeader(outreq.Header, r.Header)
+	for _, h := range upgradeHopHeaders {
+		outreq.Header.Del(h)
+	}
+	if clientIP, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if prior, ok := outreq.Header["X-Forwarded-For"]; ok {
+			clientIP = strings.Join(prior, ", ") + ", " + clientIP
+		}
+		outreq.Header.Set("X-Forwarded-For", clientIP)
+	}
+	if via := headerVia(r.Header.Get("Via"), r.ProtoMajor, r.ProtoMinor); via != "" {
+		outreq.Header.Set("Via", via)
+	}
+	rp.rewrite(outreq, u)
+
+	rp.upstreams.acquire(u)
+	backend, br, res, err := rp.handshake(outreq, u)
+	if err != nil {
+		rp.upstreams.release(u, true)
+		msg := internalServerError(err.Error())
+		if e, ok := err.(net.Error); ok && e.Timeout() {
+			msg = gatewayTimeout(ERR_TIMEOUT)
+		}
+		writeResponse(w, msg)
+		return
+	}
+	defer rp.upstreams.release(u, false)
+	defer backend.Close()
+
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		// The upstream has refused to switch protocols, so its response is forwarded as usual.
+		defer res.Body.Close()
+		copyHeader(w.Header(), res.Header)
+		w.WriteHeader(res.StatusCode)
+		io.Copy(w, res.Body)
+		return
+	}
+
+	client, clientBuf, err := hj.Hijack()
+	if err != nil {
+		Logger.Warn("Failed to hijack the connection for service `%s`: %+v.", rp.handler.service.Subdomain, err)
+		writeResponse(w, internalServerError(ERR_UPGRADE_NOT_SUPPORTED))
+		return
+	}
+	defer client.Close()
+
+	fmt.Fprintf(clientBuf, "HTTP/1.1 %s\r\n", res.Status)
+	res.Header.Write(clientBuf)
+	clientBuf.WriteString("\r\n")
+	if err := clientBuf.Flush(); err != nil {
+		return
+	}
+
+	clientConn := &idleTimeoutConn{Conn: client, timeout: rp.timeouts.idle}
+	backendConn := &idleTimeoutConn{Conn: backend, timeout: rp.timeouts.idle}
+	tunnel(clientConn, bufferedReader(clientConn, clientBuf.Reader), backendConn, bufferedReader(backendConn, br))
+}
+
+// handshake sends the upgrade request to the upstream and reads its response.
+func (rp *Dispatcher) handshake(outreq *http.Request, u *upstream) (net.Conn, *bufio.Reader, *http.Response, error) {
+	backend, err := dialUpstream(u.target, rp.timeouts.connect, rp.handler.upstreamTLS)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	backend.SetDeadline(time.Now().Add(rp.timeouts.responseHeader))
+	if err = outreq.Write(backend); err != nil {
+		backend.Close()
+		return nil, nil, nil, err
+	}
+	br := bufio.NewReader(backend)
+	res, err := http.ReadResponse(br, outreq)
+	if err != nil {
+		backend.Close()
+		return nil, nil, nil, err
+	}
+	backend.SetDeadline(time.Time{})
+	return backend, br, res, nil
+}
+
+func dialUpstream(target *url.URL, timeout time.Duration, tlsc *upstreamTLS) (net.Conn, error) {
+	host := target.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		if target.Scheme == "https" {
+			host = net.JoinHostPort(host, "443")
+		} else {
+			host = net.JoinHostPort(host, "80")
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	if target.Scheme == "https" && tlsc != nil {
+		return tlsc.dialer(dialer.Dial, timeout)("tcp", host)
+	}
+	if target.Scheme == "https" {
+		serverName, _, _ := net.SplitHostPort(host)
+		return tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: serverName})
+	}
+	return dialer.Dial("tcp", host)
+}
+
+// tunnel copies the data in both directions, until one of the sides closes the connection
+// or both stay idle for longer than the timeout.
+func tunnel(client net.Conn, clientReader io.Reader, backend net.Conn, backendReader io.Reader) {
+	done := make(chan bool, 2)
+	pipe := func(dst net.Conn, src io.Reader) {
+		io.Copy(dst, src)
+		done <- true
+	}
+	go pipe(backend, clientReader)
+	go pipe(client, backendReader)
+
+	<-done
+	client.Close()
+	backend.Close()
+	<-done
+}
+
+// bufferedReader returns a reader which does not lose the data already buffered from the connection.
+func bufferedReader(conn net.Conn, br *bufio.Reader) io.Reader {
+	if n := br.Buffered(); n > 0 {
+		data, _ := br.Peek(n)
+		return io.MultiReader(bytes.NewReader(data), conn)
+	}
+	return conn
+}
+
+// idleTimeoutConn extends the deadline of the connection on every read or write.
+// Since data flowing in any direction reads from one connection and writes to the other,
+// a tunnel is only closed when there is no traffic at all.
+type idleTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleTimeoutConn) Read(b []byte) (int, error) {
+	c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Read(b)
+}
+
+func (c *idleTimeoutConn) Write(b []byte) (int, error) {
+	c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Write(b)
+}
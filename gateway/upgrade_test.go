@@ -0,0 +1,163 @@
+package gateway
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/account/mem"
+	"github.com/apihub/apihub/gateway/middleware"
+	. "gopkg.in/check.v1"
+)
+
+// echoUpgradeHandler switches to a line-based echo protocol.
+func echoUpgradeHandler(c *C) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "echo" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		c.Assert(err, IsNil)
+		defer conn.Close()
+
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\nX-Plugin: " + r.Header.Get("X-Plugin") + "\r\n\r\n")
+		buf.Flush()
+		for {
+			line, err := buf.ReadString('\n')
+			if err != nil {
+				return
+			}
+			buf.WriteString(line)
+			buf.Flush()
+		}
+	}
+}
+
+func dialUpgrade(c *C, addr, protocol string) (net.Conn, *bufio.Reader, *http.Response) {
+	conn, err := net.Dial("tcp", addr)
+	c.Assert(err, IsNil)
+	conn.Write([]byte("GET /chat HTTP/1.1\r\nHost: test.apihub.dev\r\nConnection: Upgrade\r\nUpgrade: " + protocol + "\r\n\r\n"))
+
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, nil)
+	c.Assert(err, IsNil)
+	return conn, br, res
+}
+
+func (s *S) TestGatewayUpgradeTunnel(c *C) {
+	target := httptest.NewServer(echoUpgradeHandler(c))
+	defer target.Close()
+
+	store := mem.New()
+	service := &account.Service{Endpoint: target.URL, Subdomain: "test"}
+	store.UpsertPlugin(account.Plugin{Name: "header", Service: service.Subdomain, Config: map[string]interface{}{"value": "plugin"}})
+
+	gateway := New(s.Settings, nil)
+	gateway.Storage(store)
+	gateway.Middleware().Add("header", func() middleware.Middleware { return &headerMiddleware{} })
+	gateway.AddService(service)
+	frontend := httptest.NewServer(gateway)
+	defer frontend.Close()
+
+	conn, br, res := dialUpgrade(c, frontend.Listener.Addr().String(), "echo")
+	defer conn.Close()
+	c.Assert(res.StatusCode, Equals, http.StatusSwitchingProtocols)
+	c.Assert(res.Header.Get("Upgrade"), Equals, "echo")
+	// The middlewares of the service run before the upgrade.
+	c.Assert(res.Header.Get("X-Plugin"), Equals, "plugin")
+
+	for _, msg := range []string{"hello\n", "world\n"} {
+		conn.Write([]byte(msg))
+		line, err := br.ReadString('\n')
+		c.Assert(err, IsNil)
+		c.Assert(line, Equals, msg)
+	}
+}
+
+func (s *S) TestGatewayUpgradeWithCache(c *C) {
+	target := httptest.NewServer(echoUpgradeHandler(c))
+	defer target.Close()
+
+	store := mem.New()
+	service := &account.Service{Endpoint: target.URL, Subdomain: "test"}
+	store.UpsertPlugin(account.Plugin{Name: "cache", Service: service.Subdomain, Config: map[string]interface{}{"ttl": 60}})
+
+	gateway := New(s.Settings, nil)
+	gateway.Storage(store)
+	gateway.AddService(service)
+	frontend := httptest.NewServer(gateway)
+	defer frontend.Close()
+
+	conn, br, res := dialUpgrade(c, frontend.Listener.Addr().String(), "echo")
+	defer conn.Close()
+	c.Assert(res.StatusCode, Equals, http.StatusSwitchingProtocols)
+
+	conn.Write([]byte("hello\n"))
+	line, err := br.ReadString('\n')
+	c.Assert(err, IsNil)
+	c.Assert(line, Equals, "hello\n")
+}
+
+func (s *S) TestGatewayReloadsServicesWhileTunneling(c *C) {
+	target := httptest.NewServer(echoUpgradeHandler(c))
+	defer target.Close()
+
+	gateway := New(s.Settings, nil)
+	service := &account.Service{Endpoint: target.URL, Subdomain: "test"}
+	gateway.AddService(service)
+	frontend := httptest.NewServer(gateway)
+	defer frontend.Close()
+
+	conn, _, res := dialUpgrade(c, frontend.Listener.Addr().String(), "echo")
+	defer conn.Close()
+	c.Assert(res.StatusCode, Equals, http.StatusSwitchingProtocols)
+
+	// The open tunnel does not hold the gateway while the service is reloaded.
+	done := make(chan bool)
+	go func() {
+		gateway.AddService(service)
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		c.Fatal("The service was not reloaded while the tunnel was open.")
+	}
+}
+
+func (s *S) TestGatewayUpgradeIdleTimeout(c *C) {
+	target := httptest.NewServer(echoUpgradeHandler(c))
+	defer target.Close()
+
+	gateway := New(s.Settings, nil)
+	gateway.AddService(&account.Service{Endpoint: target.URL, Subdomain: "test", Timeout: 1})
+	frontend := httptest.NewServer(gateway)
+	defer frontend.Close()
+
+	conn, br, res := dialUpgrade(c, frontend.Listener.Addr().String(), "echo")
+	defer conn.Close()
+	c.Assert(res.StatusCode, Equals, http.StatusSwitchingProtocols)
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err := br.ReadByte()
+	c.Assert(err, Equals, io.EOF)
+}
+
+func (s *S) TestGatewayUpgradeRefused(c *C) {
+	target := httptest.NewServer(echoUpgradeHandler(c))
+	defer target.Close()
+
+	gateway := New(s.Settings, nil)
+	gateway.AddService(&account.Service{Endpoint: target.URL, Subdomain: "test"})
+	frontend := httptest.NewServer(gateway)
+	defer frontend.Close()
+
+	conn, _, res := dialUpgrade(c, frontend.Listener.Addr().String(), "unknown")
+	defer conn.Close()
+	c.Assert(res.StatusCode, Equals, http.StatusBadRequest)
+}
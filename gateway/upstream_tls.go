@@ -0,0 +1,117 @@
+package gateway
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/apihub/apihub/account"
+)
+
+// upstreamTLS is the TLS configuration used to reach the upstreams of a service over HTTPS,
+// presenting its client certificate and trusting its CA bundle.
+type upstreamTLS struct {
+	certificates   []tls.Certificate
+	rootCAs        *x509.CertPool
+	serverName     string
+	skipServerName bool
+
+	// id identifies the configuration, so the transports are shared only among the services which have the same one.
+	id string
+}
+
+func newUpstreamTLS(settings account.UpstreamTLS, cert *account.ClientCertificate) (*upstreamTLS, error) {
+	t := &upstreamTLS{serverName: settings.ServerName, skipServerName: settings.SkipServerNameVerification}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%t\n", settings.ServerName, settings.SkipServerNameVerification)
+
+	if cert != nil {
+		if cert.Certificate != "" {
+			pair, err := tls.X509KeyPair([]byte(cert.Certificate), []byte(cert.Key))
+			if err != nil {
+				return nil, err
+			}
+			t.certificates = []tls.Certificate{pair}
+		}
+		if cert.CA != "" {
+			t.rootCAs = x509.NewCertPool()
+			if !t.rootCAs.AppendCertsFromPEM([]byte(cert.CA)) {
+				return nil, errors.New("the CA bundle has no valid certificate")
+			}
+		}
+		fmt.Fprintf(h, "%s\n%s\n%s\n", cert.Certificate, cert.Key, cert.CA)
+	}
+
+	t.id = fmt.Sprintf("%x", h.Sum(nil))
+	return t, nil
+}
+
+// config returns the configuration of the connections to the host.
+// When the server name is not verified, the handshake does not verify the certificates at all,
+// so the chain is verified by verifyChain.
+func (t *upstreamTLS) config(host string) *tls.Config {
+	serverName := t.serverName
+	if serverName == "" {
+		serverName = host
+	}
+	return &tls.Config{
+		Certificates:       t.certificates,
+		RootCAs:            t.rootCAs,
+		ServerName:         serverName,
+		InsecureSkipVerify: t.skipServerName,
+	}
+}
+
+// verifyChain verifies the certificates presented by the upstream against the trusted authorities,
+// ignoring the names they are valid for.
+func (t *upstreamTLS) verifyChain(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("the upstream has not presented a certificate")
+	}
+	opts := x509.VerifyOptions{Roots: t.rootCAs, Intermediates: x509.NewCertPool()}
+	for _, cert := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// dialer opens the connections with dial and runs the TLS handshake, which must complete within the timeout.
+func (t *upstreamTLS) dialer(dial func(network, addr string) (net.Conn, error), timeout time.Duration) func(network, addr string) (net.Conn, error) {
+	return func(network, addr string) (net.Conn, error) {
+		conn, err := dial(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+
+		tlsConn := tls.Client(conn, t.config(host))
+		timer := time.AfterFunc(timeout, func() { conn.Close() })
+		err = tlsConn.Handshake()
+		if !timer.Stop() {
+			err = handshakeTimeoutError{}
+		}
+		if err == nil && t.skipServerName {
+			err = t.verifyChain(tlsConn.ConnectionState())
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
+
+// handshakeTimeoutError is reported as a timeout, so the request is answered with Gateway Timeout.
+type handshakeTimeoutError struct{}
+
+func (handshakeTimeoutError) Error() string   { return "TLS handshake timeout" }
+func (handshakeTimeoutError) Timeout() bool   { return true }
+func (handshakeTimeoutError) Temporary() bool { return true }
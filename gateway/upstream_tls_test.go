@@ -0,0 +1,189 @@
+package gateway
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/apihub/apihub/account"
+	"github.com/apihub/apihub/account/mem"
+	. "gopkg.in/check.v1"
+)
+
+// testCA signs the certificates of the upstreams and of the gateway in the mutual TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  string
+}
+
+func newTestCA(c *C) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ApiHub Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	c.Assert(err, IsNil)
+	cert, err := x509.ParseCertificate(der)
+	c.Assert(err, IsNil)
+	return &testCA{cert: cert, key: key, pem: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))}
+}
+
+// issue returns a PEM encoded certificate and key signed by the CA, valid for the host.
+func (ca *testCA) issue(c *C, host string, usage x509.ExtKeyUsage) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	c.Assert(err, IsNil)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, IsNil)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+}
+
+// newMutualTLSServer starts an upstream, valid for internal.example.org, which requires client certificates signed by the CA.
+func newMutualTLSServer(c *C, ca *testCA) *httptest.Server {
+	certPEM, keyPEM := ca.issue(c, "internal.example.org", x509.ExtKeyUsageServerAuth)
+	pair, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	c.Assert(err, IsNil)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{pair}, ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	server.StartTLS()
+	return server
+}
+
+func (s *S) serveMutualTLS(c *C, server *httptest.Server, upstreamTLS *account.UpstreamTLS, cert account.ClientCertificate) *httptest.ResponseRecorder {
+	store := mem.New()
+	cert.Service = "test"
+	store.UpsertClientCertificate(cert)
+	service := &account.Service{Subdomain: "test", Endpoint: server.URL, Timeout: 2, UpstreamTLS: upstreamTLS}
+	gateway := New(s.Settings, nil)
+	gateway.Storage(store)
+	gateway.AddService(service)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://test.apihub.dev", nil)
+	gateway.ServeHTTP(w, r)
+	return w
+}
+
+func (s *S) TestGatewayPresentsClientCertificate(c *C) {
+	ca := newTestCA(c)
+	server := newMutualTLSServer(c, ca)
+	defer server.Close()
+	certPEM, keyPEM := ca.issue(c, "gateway.example.org", x509.ExtKeyUsageClientAuth)
+
+	w := s.serveMutualTLS(c, server,
+		&account.UpstreamTLS{ClientCertificate: "internal", ServerName: "internal.example.org"},
+		account.ClientCertificate{Name: "internal", Certificate: certPEM, Key: keyPEM, CA: ca.pem})
+	c.Assert(w.Code, Equals, http.StatusOK)
+	c.Assert(w.Body.String(), Equals, "gateway.example.org")
+}
+
+func (s *S) TestGatewayDecryptsClientCertificateKey(c *C) {
+	ca := newTestCA(c)
+	server := newMutualTLSServer(c, ca)
+	defer server.Close()
+	certPEM, keyPEM := ca.issue(c, "gateway.example.org", x509.ExtKeyUsageClientAuth)
+
+	store := mem.New()
+	account.Storage(store)
+	account.ClientCertificateSecret("secret")
+	defer account.ClientCertificateSecret("")
+	cert := account.ClientCertificate{Name: "internal", Certificate: certPEM, Key: keyPEM, CA: ca.pem}
+	c.Assert(cert.Save(account.Service{Subdomain: "test"}), IsNil)
+	upstreamTLS := &account.UpstreamTLS{ClientCertificate: "internal", ServerName: "internal.example.org"}
+
+	for secret, code := range map[string]int{"secret": http.StatusOK, "other": http.StatusInternalServerError} {
+		s.Settings.ClientCertificateSecret = secret
+		gateway := New(s.Settings, nil)
+		gateway.Storage(store)
+		gateway.AddService(&account.Service{Subdomain: "test", Endpoint: server.URL, Timeout: 2, UpstreamTLS: upstreamTLS})
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "http://test.apihub.dev", nil)
+		gateway.ServeHTTP(w, r)
+		c.Assert(w.Code, Equals, code)
+	}
+}
+
+func (s *S) TestGatewayWithoutClientCertificate(c *C) {
+	ca := newTestCA(c)
+	server := newMutualTLSServer(c, ca)
+	defer server.Close()
+
+	w := s.serveMutualTLS(c, server,
+		&account.UpstreamTLS{ClientCertificate: "internal", ServerName: "internal.example.org"},
+		account.ClientCertificate{Name: "internal", CA: ca.pem})
+	c.Assert(w.Code, Equals, http.StatusInternalServerError)
+}
+
+func (s *S) TestGatewayVerifiesUpstreamServerName(c *C) {
+	ca := newTestCA(c)
+	server := newMutualTLSServer(c, ca)
+	defer server.Close()
+	certPEM, keyPEM := ca.issue(c, "gateway.example.org", x509.ExtKeyUsageClientAuth)
+	cert := account.ClientCertificate{Name: "internal", Certificate: certPEM, Key: keyPEM, CA: ca.pem}
+
+	// The upstream is reached by its IP address, which is not in its certificate.
+	w := s.serveMutualTLS(c, server, &account.UpstreamTLS{ClientCertificate: "internal"}, cert)
+	c.Assert(w.Code, Equals, http.StatusInternalServerError)
+
+	w = s.serveMutualTLS(c, server, &account.UpstreamTLS{ClientCertificate: "internal", SkipServerNameVerification: true}, cert)
+	c.Assert(w.Code, Equals, http.StatusOK)
+}
+
+func (s *S) TestGatewaySkipServerNameVerificationStillVerifiesChain(c *C) {
+	ca := newTestCA(c)
+	server := newMutualTLSServer(c, ca)
+	defer server.Close()
+	certPEM, keyPEM := ca.issue(c, "gateway.example.org", x509.ExtKeyUsageClientAuth)
+
+	w := s.serveMutualTLS(c, server,
+		&account.UpstreamTLS{ClientCertificate: "internal", SkipServerNameVerification: true},
+		account.ClientCertificate{Name: "internal", Certificate: certPEM, Key: keyPEM, CA: newTestCA(c).pem})
+	c.Assert(w.Code, Equals, http.StatusInternalServerError)
+}
+
+func (s *S) TestTransportsAreSharedByUpstreamTLS(c *C) {
+	target, _ := url.Parse("https://10.0.0.1:8443")
+	t := timeouts{connect: time.Second, responseHeader: time.Second, idle: time.Second}
+	one, _ := newUpstreamTLS(account.UpstreamTLS{ServerName: "internal.example.org"}, nil)
+	other, _ := newUpstreamTLS(account.UpstreamTLS{ServerName: "internal.example.org"}, nil)
+	another, _ := newUpstreamTLS(account.UpstreamTLS{ServerName: "other.example.org"}, nil)
+	keys := transports.acquire([]*url.URL{target}, t, one)
+	defer transports.release(keys)
+
+	c.Assert(transports.get(target, t, one), Equals, transports.get(target, t, other))
+	c.Assert(transports.get(target, t, one), Not(Equals), transports.get(target, t, another))
+	c.Assert(transports.get(target, t, one), Not(Equals), transports.get(target, t, nil))
+}
@@ -0,0 +1,101 @@
+package mail
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+// fakeSMTPServer accepts a single message, sending what was received through the channel.
+func fakeSMTPServer(c *C) (string, chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	received := make(chan string, 1)
+
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ESMTP")
+
+		var transcript []string
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			transcript = append(transcript, line)
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 localhost")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				data, _ := tp.ReadDotLines()
+				transcript = append(transcript, data...)
+				tp.PrintfLine("250 ok")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				received <- strings.Join(transcript, "\n")
+				return
+			default:
+				tp.PrintfLine("250 ok")
+			}
+		}
+	}()
+	return l.Addr().String(), received
+}
+
+func (s *S) TestSMTPSender(c *C) {
+	addr, received := fakeSMTPServer(c)
+	sender := NewSMTPSender(SMTPConfig{Addr: addr, From: "apihub@example.org"})
+
+	err := sender.Send(Message{To: []string{"alice@example.org"}, Subject: "Hello", Body: "Hello Alice!\nBye."})
+	c.Assert(err, IsNil)
+
+	transcript := <-received
+	c.Assert(transcript, Matches, `(?s).*MAIL FROM:<apihub@example.org>.*`)
+	c.Assert(transcript, Matches, `(?s).*RCPT TO:<alice@example.org>.*`)
+	c.Assert(transcript, Matches, `(?s).*To: alice@example.org\nSubject: Hello\n.*`)
+	c.Assert(transcript, Matches, `(?s).*\n\nHello Alice!\nBye\..*`)
+}
+
+func (s *S) TestSMTPSenderWithUnreachableServer(c *C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	addr := l.Addr().String()
+	l.Close()
+
+	sender := NewSMTPSender(SMTPConfig{Addr: addr, From: "apihub@example.org"})
+	err = sender.Send(Message{To: []string{"alice@example.org"}, Subject: "Hello", Body: "Hello Alice!"})
+	c.Assert(err, NotNil)
+}
+
+func (s *S) TestMessageBytes(c *C) {
+	m := Message{To: []string{"alice@example.org", "bob@example.org"}, Subject: "Olá", Body: "line 1\nline 2"}
+	r := textproto.NewReader(bufio.NewReader(strings.NewReader(string(m.bytes("apihub@example.org")))))
+	header, err := r.ReadMIMEHeader()
+	c.Assert(err, IsNil)
+	c.Assert(header.Get("From"), Equals, "apihub@example.org")
+	c.Assert(header.Get("To"), Equals, "alice@example.org, bob@example.org")
+	c.Assert(header.Get("Subject"), Equals, "=?utf-8?q?Ol=C3=A1?=")
+	c.Assert(header.Get("Content-Type"), Equals, "text/plain; charset=utf-8")
+	body, _ := r.ReadLine()
+	c.Assert(body, Equals, "line 1")
+}
+
+func (s *S) TestMemorySender(c *C) {
+	sender := NewMemorySender()
+	m := Message{To: []string{"alice@example.org"}, Subject: "Hello", Body: "Hello Alice!"}
+	c.Assert(sender.Send(m), IsNil)
+	c.Assert(sender.Messages(), DeepEquals, []Message{m})
+
+	sender.Reset()
+	c.Assert(sender.Messages(), HasLen, 0)
+}
@@ -0,0 +1,34 @@
+package mail
+
+import "sync"
+
+// MemorySender keeps the messages instead of delivering them, for test purposes.
+type MemorySender struct {
+	messages []Message
+	mtx      sync.Mutex
+}
+
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+func (s *MemorySender) Send(m Message) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.messages = append(s.messages, m)
+	return nil
+}
+
+// Messages returns the messages sent so far.
+func (s *MemorySender) Messages() []Message {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]Message{}, s.messages...)
+}
+
+// Reset discards the messages sent so far.
+func (s *MemorySender) Reset() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.messages = nil
+}
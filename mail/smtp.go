@@ -0,0 +1,37 @@
+package mail
+
+import (
+	"net"
+	"net/smtp"
+)
+
+// SMTPConfig describes the server used to deliver the messages, such as `smtp.example.org:587`.
+// The sender authenticates with PLAIN when `Username` is informed, which requires TLS unless the server is local.
+type SMTPConfig struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPSender delivers the messages through an SMTP server, upgrading the connection with STARTTLS when supported.
+type SMTPSender struct {
+	config SMTPConfig
+	auth   smtp.Auth
+}
+
+func NewSMTPSender(config SMTPConfig) *SMTPSender {
+	s := &SMTPSender{config: config}
+	if config.Username != "" {
+		host, _, err := net.SplitHostPort(config.Addr)
+		if err != nil {
+			host = config.Addr
+		}
+		s.auth = smtp.PlainAuth("", config.Username, config.Password, host)
+	}
+	return s
+}
+
+func (s *SMTPSender) Send(m Message) error {
+	return smtp.SendMail(s.config.Addr, s.auth, s.config.From, m.To, m.bytes(s.config.From))
+}
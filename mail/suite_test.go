@@ -0,0 +1,18 @@
+package mail
+
+import (
+	"testing"
+
+	. "github.com/apihub/apihub/log"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type S struct{}
+
+func (s *S) SetUpSuite(c *C) {
+	Logger.Disable()
+}
+
+var _ = Suite(&S{})
@@ -0,0 +1,301 @@
+// Package metrics keeps counters, gauges and histograms in memory, exposing them
+// in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const CONTENT_TYPE = "text/plain; version=0.0.4"
+
+// DEFAULT_BUCKETS are the upper bounds, in seconds, of the histograms of latencies.
+var DEFAULT_BUCKETS = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Collector is a metric which is able to write its samples in the Prometheus text format.
+type Collector interface {
+	Name() string
+	Write(w io.Writer)
+}
+
+// series keeps the samples of a metric by the values of its labels.
+type series struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	mtx    sync.Mutex
+	values map[string][]string
+}
+
+func newSeries(name, help, kind string, labels []string) series {
+	return series{name: name, help: help, kind: kind, labels: labels, values: map[string][]string{}}
+}
+
+func (s *series) Name() string {
+	return s.name
+}
+
+// key returns the key of the sample with the given label values, which must be called with the lock held.
+func (s *series) key(values []string) string {
+	if len(values) != len(s.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", s.name, len(s.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	if _, ok := s.values[key]; !ok {
+		s.values[key] = append([]string{}, values...)
+	}
+	return key
+}
+
+// keys returns the keys of the samples, sorted to keep the output stable.
+func (s *series) keys() []string {
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *series) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", s.name, s.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", s.name, s.kind)
+}
+
+// labelPairs formats the labels of a sample, such as `{service="apihub",code="200"}`.
+func labelPairs(names, values []string, extra ...string) string {
+	pairs := []string{}
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escape(values[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], escape(extra[i+1])))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escape(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+	return strings.Replace(value, "\n", `\n`, -1)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Counter is a value which only goes up, such as the number of requests.
+type Counter struct {
+	series
+	counts map[string]float64
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{series: newSeries(name, help, "counter", labels), counts: map[string]float64{}}
+}
+
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *Counter) Add(v float64, values ...string) {
+	c.mtx.Lock()
+	c.counts[c.key(values)] += v
+	c.mtx.Unlock()
+}
+
+func (c *Counter) Value(values ...string) float64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.counts[strings.Join(values, "\xff")]
+}
+
+func (c *Counter) Write(w io.Writer) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.writeHeader(w)
+	for _, key := range c.keys() {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labelPairs(c.labels, c.values[key]), formatFloat(c.counts[key]))
+	}
+}
+
+// Gauge is a value which goes up and down, such as the number of open connections.
+type Gauge struct {
+	series
+	gauges map[string]float64
+}
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{series: newSeries(name, help, "gauge", labels), gauges: map[string]float64{}}
+}
+
+func (g *Gauge) Set(v float64, values ...string) {
+	g.mtx.Lock()
+	g.gauges[g.key(values)] = v
+	g.mtx.Unlock()
+}
+
+func (g *Gauge) Add(v float64, values ...string) {
+	g.mtx.Lock()
+	g.gauges[g.key(values)] += v
+	g.mtx.Unlock()
+}
+
+func (g *Gauge) Inc(values ...string) {
+	g.Add(1, values...)
+}
+
+func (g *Gauge) Dec(values ...string) {
+	g.Add(-1, values...)
+}
+
+func (g *Gauge) Value(values ...string) float64 {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return g.gauges[strings.Join(values, "\xff")]
+}
+
+func (g *Gauge) Write(w io.Writer) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	g.writeHeader(w)
+	for _, key := range g.keys() {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, labelPairs(g.labels, g.values[key]), formatFloat(g.gauges[key]))
+	}
+}
+
+// Histogram counts the observations, such as latencies, in cumulative buckets.
+type Histogram struct {
+	series
+	buckets []float64
+	counts  map[string][]uint64
+	sums    map[string]float64
+}
+
+// NewHistogram returns a histogram with the given upper bounds, or DEFAULT_BUCKETS when none is given.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DEFAULT_BUCKETS
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &Histogram{series: newSeries(name, help, "histogram", labels), buckets: buckets, counts: map[string][]uint64{}, sums: map[string]float64{}}
+}
+
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	key := h.key(values)
+	counts, ok := h.counts[key]
+	if !ok {
+		// The last bucket is +Inf.
+		counts = make([]uint64, len(h.buckets)+1)
+		h.counts[key] = counts
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			counts[i]++
+		}
+	}
+	counts[len(h.buckets)]++
+	h.sums[key] += v
+}
+
+// Count returns the number of observations with the given label values.
+func (h *Histogram) Count(values ...string) uint64 {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if counts, ok := h.counts[strings.Join(values, "\xff")]; ok {
+		return counts[len(h.buckets)]
+	}
+	return 0
+}
+
+func (h *Histogram) Write(w io.Writer) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.writeHeader(w)
+	for _, key := range h.keys() {
+		values, counts := h.values[key], h.counts[key]
+		for i, bound := range append(h.buckets, math.Inf(1)) {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelPairs(h.labels, values, "le", formatFloat(bound)), counts[i])
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labelPairs(h.labels, values), formatFloat(h.sums[key]))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labelPairs(h.labels, values), counts[len(h.buckets)])
+	}
+}
+
+// Registry keeps the metrics exposed by the Handler.
+type Registry struct {
+	mtx        sync.RWMutex
+	collectors map[string]Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: map[string]Collector{}}
+}
+
+func (r *Registry) Register(c Collector) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if _, ok := r.collectors[c.Name()]; ok {
+		return fmt.Errorf("metrics: %s is already registered", c.Name())
+	}
+	r.collectors[c.Name()] = c
+	return nil
+}
+
+// MustRegister registers the metrics, panicking when any of them is already registered.
+func (r *Registry) MustRegister(cs ...Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Write writes all the metrics, sorted by name.
+func (r *Registry) Write(w io.Writer) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r.collectors[name].Write(w)
+	}
+}
+
+// Handler serves the metrics in the Prometheus text format, usually on /metrics.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", CONTENT_TYPE)
+		r.Write(rw)
+	})
+}
+
+// DefaultRegistry keeps the metrics of the gateway and the api.
+var DefaultRegistry = NewRegistry()
+
+func MustRegister(cs ...Collector) {
+	DefaultRegistry.MustRegister(cs...)
+}
+
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
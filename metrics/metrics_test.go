@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestCounter(c *C) {
+	counter := NewCounter("requests_total", "Number of requests.", "service", "code")
+	counter.Inc("apihub", "200")
+	counter.Add(2, "apihub", "200")
+	counter.Inc("another", "500")
+
+	c.Assert(counter.Value("apihub", "200"), Equals, float64(3))
+	c.Assert(counter.Value("apihub", "404"), Equals, float64(0))
+
+	buf := new(bytes.Buffer)
+	counter.Write(buf)
+	c.Assert(buf.String(), Equals, `# HELP requests_total Number of requests.
+# TYPE requests_total counter
+requests_total{service="another",code="500"} 1
+requests_total{service="apihub",code="200"} 3
+`)
+}
+
+func (s *S) TestCounterWithWrongNumberOfLabels(c *C) {
+	counter := NewCounter("requests_total", "Number of requests.", "service")
+	c.Assert(func() { counter.Inc() }, PanicMatches, "metrics: requests_total expects 1 label values, got 0")
+}
+
+func (s *S) TestGauge(c *C) {
+	gauge := NewGauge("connections", "Number of open connections.")
+	gauge.Inc()
+	gauge.Inc()
+	gauge.Dec()
+	c.Assert(gauge.Value(), Equals, float64(1))
+
+	gauge.Set(5)
+	buf := new(bytes.Buffer)
+	gauge.Write(buf)
+	c.Assert(buf.String(), Equals, `# HELP connections Number of open connections.
+# TYPE connections gauge
+connections 5
+`)
+}
+
+func (s *S) TestHistogram(c *C) {
+	histogram := NewHistogram("duration_seconds", "Duration of the requests.", []float64{1, 0.1}, "service")
+	histogram.Observe(0.05, "apihub")
+	histogram.Observe(0.5, "apihub")
+	histogram.Observe(3, "apihub")
+	c.Assert(histogram.Count("apihub"), Equals, uint64(3))
+
+	buf := new(bytes.Buffer)
+	histogram.Write(buf)
+	c.Assert(buf.String(), Equals, `# HELP duration_seconds Duration of the requests.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{service="apihub",le="0.1"} 1
+duration_seconds_bucket{service="apihub",le="1"} 2
+duration_seconds_bucket{service="apihub",le="+Inf"} 3
+duration_seconds_sum{service="apihub"} 3.55
+duration_seconds_count{service="apihub"} 3
+`)
+}
+
+func (s *S) TestLabelValuesAreEscaped(c *C) {
+	counter := NewCounter("requests_total", "Number of requests.", "path")
+	counter.Inc("/\"quoted\"\\\n")
+
+	buf := new(bytes.Buffer)
+	counter.Write(buf)
+	c.Assert(buf.String(), Matches, `(?s).*requests_total\{path="/\\"quoted\\"\\\\\\n"\} 1.*`)
+}
+
+func (s *S) TestRegistry(c *C) {
+	registry := NewRegistry()
+	registry.MustRegister(NewGauge("b_gauge", "B."), NewCounter("a_total", "A."))
+	c.Assert(registry.Register(NewCounter("a_total", "A.")), ErrorMatches, "metrics: a_total is already registered")
+
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	registry.Handler().ServeHTTP(rw, req)
+	c.Assert(rw.Code, Equals, http.StatusOK)
+	c.Assert(rw.Header().Get("Content-Type"), Equals, CONTENT_TYPE)
+	c.Assert(rw.Body.String(), Equals, "# HELP a_total A.\n# TYPE a_total counter\n# HELP b_gauge B.\n# TYPE b_gauge gauge\n")
+}